@@ -0,0 +1,73 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache stores values by key, in process
+// or in Redis, to share them between instances.
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Store stores values by key. Values are never
+// modified once set, so they are not copied.
+type Store interface {
+	// Get returns the value of key, if any.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set sets the value of key.
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// Memory is a Store holding a bounded number of
+// values in process, evicting the least recently
+// used values first.
+type Memory struct {
+	values *lru.Cache
+}
+
+// NewMemory creates a Memory
+// holding up to size values.
+func NewMemory(size int) (*Memory, error) {
+	values, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create cache", err)
+	}
+
+	return &Memory{values: values}, nil
+}
+
+// Get returns the value of key, if any.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := m.values.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return value.([]byte), true, nil
+}
+
+// Set sets the value of key.
+func (m *Memory) Set(ctx context.Context, key string, value []byte) error {
+	m.values.Add(key, value)
+	return nil
+}
+
+// Len returns the number of values held.
+func (m *Memory) Len() int {
+	return m.values.Len()
+}
@@ -0,0 +1,315 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// redisTimeout is the longest a command sent to
+	// Redis may take, so that a slow Redis does not
+	// slow down the requests it should speed up.
+	redisTimeout = 1 * time.Second
+
+	// redisMaxIdle is the number of idle
+	// connections to Redis kept open.
+	redisMaxIdle = 8
+
+	// redisRetryDelay is how long Redis is not
+	// used after a connection to it failed.
+	redisRetryDelay = 5 * time.Second
+
+	// redisTTL is how long values are kept in Redis,
+	// so that values no longer requested expire even
+	// if Redis is not configured to evict them.
+	redisTTL = 24 * time.Hour
+)
+
+var (
+	// ErrInvalidRedisURL is returned by ParseRedisURL
+	// when a URL is not a valid Redis URL.
+	ErrInvalidRedisURL = errors.New("invalid redis URL")
+
+	// ErrRedisUnavailable is returned instead of
+	// sending commands to Redis for redisRetryDelay
+	// after a connection to it failed, so that an
+	// unreachable Redis does not slow down requests.
+	ErrRedisUnavailable = errors.New("redis unavailable")
+
+	// errRedisReply is returned when a reply
+	// of Redis cannot be parsed.
+	errRedisReply = errors.New("unexpected redis reply")
+)
+
+// RedisOptions are the options of a Redis connection.
+type RedisOptions struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+}
+
+// ParseRedisURL returns the options of rawURL, of the form
+// `redis://[[username]:password@]host:port[/db]` (`rediss://`
+// for TLS).
+func ParseRedisURL(rawURL string) (*RedisOptions, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRedisURL, err.Error())
+	}
+
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("%w: scheme must be redis or rediss", ErrInvalidRedisURL)
+	}
+
+	if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("%w: missing host", ErrInvalidRedisURL)
+	}
+
+	options := &RedisOptions{
+		Addr: u.Host,
+		TLS:  u.Scheme == "rediss",
+	}
+	if len(u.Port()) == 0 {
+		options.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	if u.User != nil {
+		options.Username = u.User.Username()
+		options.Password, _ = u.User.Password()
+	}
+
+	if db := strings.TrimPrefix(u.Path, "/"); len(db) > 0 {
+		options.DB, err = strconv.Atoi(db)
+		if err != nil || options.DB < 0 {
+			return nil, fmt.Errorf("%w: invalid database %s", ErrInvalidRedisURL, db)
+		}
+	}
+
+	return options, nil
+}
+
+// redisError is an error replied by Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection to Redis.
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Redis is a Store holding values in Redis (with
+// redisTTL), so that they are shared by instances
+// and survive restarts.
+type Redis struct {
+	options *RedisOptions
+	idle    chan *redisConn
+
+	// retryAt is when (in Unix nanoseconds) Redis is
+	// used again after a connection to it failed.
+	retryAt int64
+}
+
+// NewRedis creates a Redis store with options. Connections
+// are dialed when needed.
+func NewRedis(options *RedisOptions) *Redis {
+	return &Redis{
+		options: options,
+		idle:    make(chan *redisConn, redisMaxIdle),
+	}
+}
+
+// dial opens a connection to Redis, and
+// authenticates and selects its database.
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.options.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to dial redis", err)
+	}
+
+	if r.options.TLS {
+		host, _, _ := net.SplitHostPort(r.options.Addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	}
+
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	setup := [][]string{}
+	switch {
+	case len(r.options.Username) > 0:
+		setup = append(setup, []string{"AUTH", r.options.Username, r.options.Password})
+	case len(r.options.Password) > 0:
+		setup = append(setup, []string{"AUTH", r.options.Password})
+	}
+	if r.options.DB > 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.options.DB)})
+	}
+
+	for _, args := range setup {
+		if _, err := c.do(ctx, args...); err != nil {
+			conn.Close() // nolint:errcheck
+			return nil, fmt.Errorf("%w: unable to %s", err, strings.ToLower(args[0]))
+		}
+	}
+
+	return c, nil
+}
+
+// do sends the command args and returns its reply,
+// which is nil if it is a null bulk string.
+func (c *redisConn) do(ctx context.Context, args ...string) ([]byte, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(c.conn, command.String()); err != nil {
+		return nil, err
+	}
+
+	return c.reply()
+}
+
+// reply reads a simple string, error, integer
+// or bulk string reply.
+func (c *redisConn) reply() ([]byte, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errRedisReply
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errRedisReply
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		value := make([]byte, length+2) // nolint:gomnd
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+
+		return value[:length], nil
+	default:
+		return nil, fmt.Errorf("%w: %q", errRedisReply, line)
+	}
+}
+
+// do sends the command args on an idle (or new)
+// connection and returns its reply.
+func (r *Redis) do(ctx context.Context, args ...string) ([]byte, error) {
+	if time.Now().UnixNano() < atomic.LoadInt64(&r.retryAt) {
+		return nil, ErrRedisUnavailable
+	}
+
+	var c *redisConn
+	select {
+	case c = <-r.idle:
+	default:
+		var err error
+		c, err = r.dial(ctx)
+		if err != nil {
+			atomic.StoreInt64(&r.retryAt, time.Now().Add(redisRetryDelay).UnixNano())
+			return nil, err
+		}
+	}
+
+	reply, err := c.do(ctx, args...)
+
+	// Errors replied by Redis leave the connection usable,
+	// but other errors leave it in an unknown state.
+	var replied redisError
+	if err != nil && !errors.As(err, &replied) {
+		c.conn.Close() // nolint:errcheck
+		atomic.StoreInt64(&r.retryAt, time.Now().Add(redisRetryDelay).UnixNano())
+		return nil, err
+	}
+
+	select {
+	case r.idle <- c:
+	default:
+		c.conn.Close() // nolint:errcheck
+	}
+
+	return reply, err
+}
+
+// Get returns the value of key, if any.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, value != nil, nil
+}
+
+// Set sets the value of key, for redisTTL.
+func (r *Redis) Set(ctx context.Context, key string, value []byte) error {
+	ttl := strconv.FormatInt(int64(redisTTL/time.Second), 10)
+	_, err := r.do(ctx, "SET", key, string(value), "EX", ttl)
+	return err
+}
+
+// Close closes the idle connections to Redis.
+func (r *Redis) Close() {
+	for {
+		select {
+		case c := <-r.idle:
+			c.conn.Close() // nolint:errcheck
+		default:
+			return
+		}
+	}
+}
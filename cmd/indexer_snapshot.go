@@ -0,0 +1,210 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexerExportCmd = &cobra.Command{
+		Use:   "indexer:export",
+		Short: "Export the indexer database to a snapshot",
+		Long: `Write a compressed snapshot of the indexer database in
+DATA_DIR/indexer, along with a manifest of its tip and entry
+counts, so a new node can be bootstrapped with indexer:import
+instead of syncing from genesis. The node must be stopped.
+
+When calling this command, you must provide 1 argument:
+[1] the location of where to write the snapshot`,
+		RunE: runIndexerExportCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	indexerImportCmd = &cobra.Command{
+		Use:   "indexer:import",
+		Short: "Import the indexer database from a snapshot",
+		Long: `Import a snapshot written by indexer:export into
+DATA_DIR/indexer, which must not exist yet (or be empty). The
+snapshot is verified against its manifest (network, genesis,
+tip hash and entry counts) before DATA_DIR/indexer is created,
+so a corrupted snapshot leaves no index behind.
+
+When calling this command, you must provide 1 argument:
+[1] the location of the snapshot`,
+		RunE: runIndexerImportCmd,
+		Args: cobra.ExactArgs(1),
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{indexerExportCmd, indexerImportCmd} {
+		for flag, env := range runFlags {
+			cmd.Flags().String(flag, "", fmt.Sprintf("overrides %s", env))
+		}
+	}
+}
+
+// indexerDir returns the directory of the indexer database.
+func indexerDir(cfg *configuration.Configuration) string {
+	return filepath.Join(cfg.DataDir, "indexer")
+}
+
+// loadIndexerConfiguration loads the configuration
+// as the run command would.
+func loadIndexerConfiguration(cmd *cobra.Command) (*configuration.Configuration, error) {
+	overrides, err := flagOverrides(cmd, runFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := configuration.LoadConfiguration(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	return cfg, nil
+}
+
+// openIndexer opens the indexer database in dir, without
+// a client as it is not synced.
+func openIndexer(
+	ctx context.Context,
+	cfg *configuration.Configuration,
+	dir string,
+) (*indexer.Indexer, error) {
+	idx, err := indexer.New(
+		ctx,
+		dir,
+		cfg.Network,
+		cfg.GenesisBlockIdentifier,
+		nil,
+		&indexer.Config{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open indexer", err)
+	}
+
+	return idx, nil
+}
+
+func runIndexerExportCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadIndexerConfiguration(cmd)
+	if err != nil {
+		return err
+	}
+
+	dir := indexerDir(cfg)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w: no indexer database in %s", err, dir)
+	}
+
+	ctx := context.Background()
+	idx, err := openIndexer(ctx, cfg, dir)
+	if err != nil {
+		return err
+	}
+	defer idx.Close(ctx) // nolint:errcheck
+
+	// The snapshot is written to a temporary file,
+	// so a failed export leaves no snapshot behind.
+	tmp := args[0] + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create snapshot %s", err, tmp)
+	}
+	defer os.Remove(tmp) // nolint:errcheck
+
+	manifest, err := idx.Export(ctx, file)
+	if err != nil {
+		file.Close() // nolint:errcheck
+		return fmt.Errorf("%w: unable to export indexer", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("%w: unable to write snapshot %s", err, tmp)
+	}
+
+	if err := os.Rename(tmp, args[0]); err != nil {
+		return fmt.Errorf("%w: unable to write snapshot %s", err, args[0])
+	}
+
+	fmt.Println(types.PrettyPrintStruct(manifest))
+	fmt.Printf("exported indexer to %s\n", args[0])
+	return nil
+}
+
+func runIndexerImportCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadIndexerConfiguration(cmd)
+	if err != nil {
+		return err
+	}
+
+	dir := indexerDir(cfg)
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%w: %s", indexer.ErrIndexNotEmpty, dir)
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("%w: unable to open snapshot %s", err, args[0])
+	}
+	defer file.Close() // nolint:errcheck
+
+	// The snapshot is imported to a temporary directory,
+	// which only replaces dir once it is verified.
+	tmp := dir + ".import"
+	if err := os.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("%w: unable to remove %s", err, tmp)
+	}
+	if err := os.MkdirAll(tmp, configuration.DataDirectoryPermissions); err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, tmp)
+	}
+	defer os.RemoveAll(tmp) // nolint:errcheck
+
+	ctx := context.Background()
+	idx, err := openIndexer(ctx, cfg, tmp)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := idx.Import(ctx, file)
+	if closeErr := idx.Close(ctx); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to import indexer", err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("%w: unable to remove %s", err, dir)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("%w: unable to move %s to %s", err, tmp, dir)
+	}
+
+	fmt.Println(types.PrettyPrintStruct(manifest))
+	fmt.Printf("imported indexer to %s\n", dir)
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexerVerifyCmd = &cobra.Command{
+		Use:     "indexer:verify",
+		Aliases: []string{"verify-index"},
+		Short:   "Verify the indexer database against GETH",
+		Long: `Re-derive the blocks of the indexer database in
+DATA_DIR/indexer from GETH, and check that their headers,
+transactions, balance changes and token transfers match the
+index. All the blocks of the index are checked by default,
+which can be narrowed to a range with --start and --end, and
+to a random sample of it with --sample.
+
+A summary of the discrepancies found is printed. With --repair,
+the blocks from the first discrepancy onwards (from where the
+index forked off GETH, for missed reorgs) are removed, so they
+are synced again the next time rosetta-ethereum runs. The node
+must be stopped, but GETH must be available.`,
+		RunE: runIndexerVerifyCmd,
+	}
+
+	// errDiscrepancies is returned by the verify command
+	// when discrepancies were found and not repaired.
+	errDiscrepancies = errors.New("the index does not match GETH")
+)
+
+func init() {
+	for flag, env := range runFlags {
+		indexerVerifyCmd.Flags().String(flag, "", fmt.Sprintf("overrides %s", env))
+	}
+
+	indexerVerifyCmd.Flags().Int64("start", 0, "index of the first block to check")
+	indexerVerifyCmd.Flags().Int64("end", 0, "index of the last block to check")
+	indexerVerifyCmd.Flags().Int64("sample", 0, "number of blocks of the range to check at random (0 for all)")
+	indexerVerifyCmd.Flags().Bool("repair", false, "remove the blocks from the first discrepancy onwards")
+}
+
+// verifyOptions returns the options
+// of Verify set by the flags of cmd.
+func verifyOptions(cmd *cobra.Command) (*indexer.VerifyOptions, error) {
+	options := &indexer.VerifyOptions{}
+	for flag, value := range map[string]**int64{
+		"start": &options.StartIndex,
+		"end":   &options.EndIndex,
+	} {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+
+		index, err := cmd.Flags().GetInt64(flag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read flag %s", err, flag)
+		}
+
+		*value = &index
+	}
+
+	var err error
+	options.Sample, err = cmd.Flags().GetInt64("sample")
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read flag sample", err)
+	}
+
+	options.Repair, err = cmd.Flags().GetBool("repair")
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read flag repair", err)
+	}
+
+	return options, nil
+}
+
+func runIndexerVerifyCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadIndexerConfiguration(cmd)
+	if err != nil {
+		return err
+	}
+
+	options, err := verifyOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	dir := indexerDir(cfg)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w: no indexer database in %s", err, dir)
+	}
+
+	client, err := ethereum.NewClient(
+		cfg.GethURLs,
+		cfg.Params,
+		cfg.SkipGethAdmin,
+		rpcConfig(cfg),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: cannot initialize ethereum client", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignals([]context.CancelFunc{cancel})
+
+	// Blocks must include the same token
+	// transfers as when they were indexed.
+	if len(cfg.TokenWhitelist) > 0 {
+		if _, err := client.LoadTokens(ctx, cfg.TokenWhitelist); err != nil {
+			return fmt.Errorf("%w: unable to load TOKEN_WHITELIST", err)
+		}
+	}
+
+	idx, err := indexer.New(
+		ctx,
+		dir,
+		cfg.Network,
+		cfg.GenesisBlockIdentifier,
+		client,
+		indexerConfig(cfg),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open indexer", err)
+	}
+	defer idx.Close(context.Background()) // nolint:errcheck
+
+	report, err := idx.Verify(ctx, options)
+	if err != nil {
+		return fmt.Errorf("%w: unable to verify indexer", err)
+	}
+
+	fmt.Println(types.PrettyPrintStruct(report))
+	fmt.Printf(
+		"checked %d blocks from %d to %d: %d discrepancies\n",
+		report.CheckedBlocks,
+		report.StartIndex,
+		report.EndIndex,
+		len(report.Discrepancies),
+	)
+
+	if report.RepairedFrom != nil {
+		fmt.Printf("removed the blocks from %d, to be synced again\n", *report.RepairedFrom)
+		return nil
+	}
+
+	if len(report.Discrepancies) > 0 {
+		return errDiscrepancies
+	}
+
+	return nil
+}
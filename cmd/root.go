@@ -20,8 +20,8 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 var (
@@ -43,21 +43,31 @@ func Execute() error {
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(validateConfigCmd)
 	rootCmd.AddCommand(utilsBootstrapCmd)
+	rootCmd.AddCommand(indexerExportCmd)
+	rootCmd.AddCommand(indexerImportCmd)
+	rootCmd.AddCommand(indexerVerifyCmd)
 }
 
 // handleSignals handles OS signals so we can ensure we close database
 // correctly. We call multiple sigListeners because we
-// may need to cancel more than 1 context.
+// may need to cancel more than 1 context. A second signal
+// exits immediately, without waiting for the shutdown.
 func handleSignals(listeners []context.CancelFunc) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigs
-		color.Red("received signal", "signal", sig)
+		zap.L().Warn("received signal", zap.Stringer("signal", sig))
 		SignalReceived = true
 		for _, listener := range listeners {
 			listener()
 		}
+
+		sig = <-sigs
+		zap.L().Warn("received second signal, exiting", zap.Stringer("signal", sig))
+		zap.L().Sync() // nolint:errcheck
+		os.Exit(1)
 	}()
 }
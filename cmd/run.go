@@ -18,18 +18,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
 	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/diagnostics"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/metrics"
+	"github.com/coinbase/rosetta-ethereum/ratelimit"
+	"github.com/coinbase/rosetta-ethereum/requestlimit"
 	"github.com/coinbase/rosetta-ethereum/services"
+	"github.com/coinbase/rosetta-ethereum/tracing"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -46,22 +59,328 @@ const (
 	// idleTimeout is the maximum amount of time to wait for the
 	// next request when keep-alives are enabled.
 	idleTimeout = 30 * time.Second
+
+	// defaultShutdownTimeout is how long in-flight requests are
+	// waited for on shutdown, when SHUTDOWN_TIMEOUT is not set.
+	defaultShutdownTimeout = 20 * time.Second
 )
 
 var (
 	runCmd = &cobra.Command{
 		Use:   "run",
 		Short: "Run rosetta-ethereum",
-		RunE:  runRunCmd,
+		Long: `Run rosetta-ethereum using the configuration provided
+in the environment (and CONFIG_FILE, if populated).
+
+Each flag mirrors an environment variable and, when
+provided, takes precedence over it.`,
+		RunE: runRunCmd,
+	}
+
+	// runFlags maps each flag of the run command
+	// to the environment variable it overrides.
+	runFlags = map[string]string{
+		"config-file":                      configuration.ConfigFileEnv,
+		"mode":                             configuration.ModeEnv,
+		"network":                          configuration.NetworkEnv,
+		"custom-genesis-hash":              configuration.CustomGenesisHashEnv,
+		"custom-chain-config":              configuration.CustomChainConfigEnv,
+		"port":                             configuration.PortEnv,
+		"geth":                             configuration.GethEnv,
+		"geth-ws":                          configuration.GethWSEnv,
+		"data-dir":                         configuration.DataDirEnv,
+		"log-level":                        configuration.LogLevelEnv,
+		"log-format":                       configuration.LogFormatEnv,
+		"prune-depth":                      configuration.PruneDepthEnv,
+		"skip-geth-admin":                  configuration.SkipGethAdminEnv,
+		"geth-ca-cert":                     configuration.GethCACertEnv,
+		"geth-tls-insecure":                configuration.GethTLSInsecureEnv,
+		"geth-auth-header":                 configuration.GethAuthHeaderEnv,
+		"geth-bearer-token":                configuration.GethBearerTokenEnv,
+		"listen-addr":                      configuration.ListenAddrEnv,
+		"listen-socket":                    configuration.ListenSocketEnv,
+		"metrics-addr":                     configuration.MetricsAddrEnv,
+		"otlp-endpoint":                    configuration.OTLPEndpointEnv,
+		"diagnostics-addr":                 configuration.DiagnosticsAddrEnv,
+		"rpc-timeout":                      configuration.RPCTimeoutEnv,
+		"rpc-retries":                      configuration.RPCRetriesEnv,
+		"rpc-backoff":                      configuration.RPCBackoffEnv,
+		"rpc-max-idle-conns":               configuration.RPCMaxIdleConnsEnv,
+		"rpc-max-conns-per-host":           configuration.RPCMaxConnsPerHostEnv,
+		"breaker-threshold":                configuration.BreakerThresholdEnv,
+		"breaker-cooldown":                 configuration.BreakerCooldownEnv,
+		"sync-concurrency":                 configuration.SyncConcurrencyEnv,
+		"block-batch-size":                 configuration.BlockBatchSizeEnv,
+		"trace-mode":                       configuration.TraceModeEnv,
+		"trace-timeout":                    configuration.TraceTimeoutEnv,
+		"trace-cache-size":                 configuration.TraceCacheSizeEnv,
+		"mempool-ttl":                      configuration.MempoolTTLEnv,
+		"genesis-balances":                 configuration.GenesisBalancesEnv,
+		"offline-gas-price":                configuration.OfflineGasPriceEnv,
+		"offline-max-fee-per-gas":          configuration.OfflineMaxFeePerGasEnv,
+		"offline-max-priority-fee-per-gas": configuration.OfflineMaxPriorityFeePerGasEnv,
+		"batch-contract":                   configuration.BatchContractEnv,
+		"gas-limit-multiplier":             configuration.GasLimitMultiplierEnv,
+		"max-fee-cap":                      configuration.MaxFeeCapEnv,
+		"max-gas-limit":                    configuration.MaxGasLimitEnv,
+		"token-whitelist":                  configuration.TokenWhitelistEnv,
+		"balance-exemptions":               configuration.BalanceExemptionsEnv,
+		"block-events":                     configuration.BlockEventsEnv,
+		"transaction-index":                configuration.TransactionIndexEnv,
+		"event-retention":                  configuration.EventRetentionEnv,
+		"transaction-retention":            configuration.TransactionRetentionEnv,
+		"ready-max-lag":                    configuration.ReadyMaxLagEnv,
+		"slow-request-threshold":           configuration.SlowRequestThresholdEnv,
+		"slow-rpc-threshold":               configuration.SlowRPCThresholdEnv,
+		"rate-limit":                       configuration.RateLimitEnv,
+		"rate-limit-per-client":            configuration.RateLimitPerClientEnv,
+		"rate-limit-endpoints":             configuration.RateLimitEndpointsEnv,
+		"rate-limit-key-header":            configuration.RateLimitKeyHeaderEnv,
+		"shutdown-timeout":                 configuration.ShutdownTimeoutEnv,
+		"max-request-size":                 configuration.MaxRequestSizeEnv,
+		"max-construction-items":           configuration.MaxConstructionItemsEnv,
+		"response-cache-size":              configuration.ResponseCacheSizeEnv,
+		"response-cache-redis":             configuration.ResponseCacheRedisEnv,
+		"response-cache-depth":             configuration.ResponseCacheDepthEnv,
 	}
 )
 
+func init() {
+	for flag, env := range runFlags {
+		runCmd.Flags().String(flag, "", fmt.Sprintf("overrides %s", env))
+	}
+}
+
+// flagOverrides returns the values of all flags explicitly
+// set on cmd, keyed by the environment variable they override.
+func flagOverrides(cmd *cobra.Command, flags map[string]string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for flag, env := range flags {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+
+		value, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read flag %s", err, flag)
+		}
+
+		overrides[env] = value
+	}
+
+	return overrides, nil
+}
+
+// rpcConfig returns the policy used to make
+// requests to the geth nodes of cfg.
+func rpcConfig(cfg *configuration.Configuration) *ethereum.RPCConfig {
+	return &ethereum.RPCConfig{
+		Timeout:          cfg.RPCTimeout,
+		Retries:          cfg.RPCRetries,
+		Backoff:          cfg.RPCBackoff,
+		MaxIdleConns:     cfg.RPCMaxIdleConns,
+		MaxConnsPerHost:  cfg.RPCMaxConnsPerHost,
+		WebSocketURL:     cfg.GethWSURL,
+		CACert:           cfg.GethCACert,
+		TLSInsecure:      cfg.GethTLSInsecure,
+		Header:           cfg.GethHeader,
+		Concurrency:      cfg.SyncConcurrency,
+		BatchSize:        cfg.BlockBatchSize,
+		TraceMode:        cfg.TraceMode,
+		TraceTimeout:     cfg.TraceTimeout,
+		TraceCacheSize:   cfg.TraceCacheSize,
+		MempoolTTL:       cfg.MempoolTTL,
+		GenesisBalances:  cfg.GenesisBalances,
+		SlowThreshold:    cfg.SlowRPCThreshold,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerCooldown:  cfg.BreakerCooldown,
+	}
+}
+
+// indexerConfig returns what the indexer
+// records with cfg.
+func indexerConfig(cfg *configuration.Configuration) *indexer.Config {
+	return &indexer.Config{
+		Transactions: cfg.TransactionIndex,
+		Backfill:     cfg.Mode == configuration.Indexer,
+		Balances:     cfg.Mode == configuration.Indexer,
+
+		// Only the transfers of the whitelisted
+		// tokens are included in blocks.
+		TokenTransfers: cfg.TransactionIndex && len(cfg.TokenWhitelist) > 0,
+
+		EventRetention:       cfg.EventRetention,
+		TransactionRetention: cfg.TransactionRetention,
+		Concurrency:          cfg.SyncConcurrency,
+	}
+}
+
+// responseStore returns the store of the responses of
+// immutable blocks: Redis if RESPONSE_CACHE_REDIS is set,
+// memory if RESPONSE_CACHE_SIZE is set, or nil.
+func responseStore(cfg *configuration.Configuration) (cache.Store, error) {
+	switch {
+	case len(cfg.ResponseCacheRedis) > 0:
+		options, err := cache.ParseRedisURL(cfg.ResponseCacheRedis)
+		if err != nil {
+			return nil, err
+		}
+
+		return cache.NewRedis(options), nil
+	case cfg.ResponseCacheSize > 0:
+		return cache.NewMemory(cfg.ResponseCacheSize)
+	default:
+		return nil, nil
+	}
+}
+
+// serverListeners returns the listeners the server should
+// accept connections on: a TCP listener on LISTEN_ADDR (or
+// PORT, where `auto` picks any free port) and a Unix domain
+// socket listener on LISTEN_SOCKET.
+func serverListeners(cfg *configuration.Configuration) ([]net.Listener, error) {
+	listeners := []net.Listener{}
+
+	addr := cfg.ListenAddr
+	switch {
+	case len(addr) > 0:
+	case cfg.AutoPort:
+		addr = ":0"
+	case cfg.Port > 0:
+		addr = fmt.Sprintf(":%d", cfg.Port)
+	}
+
+	if len(addr) > 0 {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to listen on %s", err, addr)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	if len(cfg.ListenSocket) > 0 {
+		// Remove any socket left behind by a previous run.
+		if err := os.Remove(cfg.ListenSocket); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: unable to remove socket %s", err, cfg.ListenSocket)
+		}
+
+		listener, err := net.Listen("unix", cfg.ListenSocket)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to listen on socket %s", err, cfg.ListenSocket)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// shutdownTimeout returns how long in-flight
+// requests are waited for on shutdown.
+func shutdownTimeout(cfg *configuration.Configuration) time.Duration {
+	if cfg.ShutdownTimeout > 0 {
+		return cfg.ShutdownTimeout
+	}
+
+	return defaultShutdownTimeout
+}
+
+// serve serves the connections accepted on listener with
+// server, until server is shut down.
+func serve(server *http.Server, listener net.Listener) error {
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// drain stops servers from accepting new connections and waits
+// up to timeout for their in-flight requests to complete. Any
+// connection still active after timeout is closed.
+func drain(servers []*http.Server, timeout time.Duration) {
+	zap.L().Info("draining requests", zap.Duration("timeout", timeout))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		server := server
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := server.Shutdown(ctx); err != nil {
+				zap.L().Warn("closing connections of requests not drained", zap.Error(err))
+				server.Close() // nolint:errcheck
+			}
+		}()
+	}
+	wg.Wait()
+
+	zap.L().Info("drained requests")
+}
+
+// handleReload reloads the upstream nodes, RPC policy, log level,
+// slow request threshold and rate limits from the configuration
+// each time SIGHUP is received, until ctx is done. Invalid
+// configuration is logged and ignored.
+func handleReload(
+	ctx context.Context,
+	client *ethereum.Client,
+	limiter *ratelimit.Limiter,
+	overrides map[string]string,
+) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sigs:
+		}
+
+		cfg, err := configuration.LoadConfiguration(overrides)
+		if err != nil {
+			zap.L().Error("unable to reload configuration", zap.Error(err))
+			continue
+		}
+
+		if err := logger.SetLevel(cfg.LogLevel); err != nil {
+			zap.L().Error("unable to reload log level", zap.Error(err))
+		}
+		logger.SetSlowRequestThreshold(cfg.SlowRequestThreshold)
+		limiter.SetConfig(cfg.RateLimit)
+
+		if err := client.ReloadNodes(cfg.GethURLs, rpcConfig(cfg)); err != nil {
+			zap.L().Error("unable to reload nodes", zap.Error(err))
+			continue
+		}
+
+		zap.L().Info("reloaded nodes", zap.Strings("urls", cfg.GethURLs))
+	}
+}
+
 func runRunCmd(cmd *cobra.Command, args []string) error {
-	cfg, err := configuration.LoadConfiguration()
+	overrides, err := flagOverrides(cmd, runFlags)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := configuration.LoadConfiguration(overrides)
 	if err != nil {
 		return fmt.Errorf("%w: unable to load configuration", err)
 	}
 
+	if err := logger.Init(cfg.LogLevel, cfg.LogFormat); err != nil {
+		return fmt.Errorf("%w: unable to initialize logger", err)
+	}
+	defer zap.L().Sync() // nolint:errcheck
+	logger.SetSlowRequestThreshold(cfg.SlowRequestThreshold)
+
 	// The asserter automatically rejects incorrectly formatted
 	// requests.
 	asserter, err := asserter.NewServer(
@@ -81,48 +400,264 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	go handleSignals([]context.CancelFunc{cancel})
 
+	// ctx is done when the server must shut down (on a signal or
+	// any failure). The background workers run until in-flight
+	// requests have drained, as they may still need them, and
+	// geth until the workers have stopped.
 	g, ctx := errgroup.WithContext(ctx)
+	workers, workersCtx := errgroup.WithContext(context.Background())
+	workersCtx, stopWorkers := context.WithCancel(workersCtx)
+	defer stopWorkers()
+	gethCtx, stopGeth := context.WithCancel(context.Background())
+	defer stopGeth()
+
+	if len(cfg.OTLPEndpoint) > 0 {
+		exporter := tracing.NewExporter(cfg.OTLPEndpoint)
+		tracing.SetExporter(exporter)
+
+		workers.Go(func() error {
+			return exporter.Run(workersCtx)
+		})
+	}
+
+	// Rate limits are reloaded on SIGHUP, so requests
+	// go through the limiter even if there are none.
+	limiter := ratelimit.New(cfg.RateLimit)
 
 	var client *ethereum.Client
-	if cfg.Mode == configuration.Online {
+	var idx *indexer.Indexer
+	var blockIndexer services.Indexer
+	if cfg.Mode.IsOnline() {
 		if !cfg.RemoteGeth {
+			if err := os.MkdirAll(cfg.DataDir, configuration.DataDirectoryPermissions); err != nil {
+				return fmt.Errorf("%w: unable to create data directory %s", err, cfg.DataDir)
+			}
+
+			gethArguments := fmt.Sprintf("%s --datadir=%s", cfg.GethArguments, cfg.DataDir)
 			g.Go(func() error {
-				return ethereum.StartGeth(ctx, cfg.GethArguments, g)
+				return ethereum.StartGeth(gethCtx, gethArguments, g)
 			})
 		}
 
 		var err error
-		client, err = ethereum.NewClient(cfg.GethURL, cfg.Params, cfg.SkipGethAdmin)
+		client, err = ethereum.NewClient(
+			cfg.GethURLs,
+			cfg.Params,
+			cfg.SkipGethAdmin,
+			rpcConfig(cfg),
+		)
 		if err != nil {
 			return fmt.Errorf("%w: cannot initialize ethereum client", err)
 		}
 		defer client.Close()
+
+		// A local geth instance is always started on the
+		// configured network, so only remote nodes need to
+		// be checked.
+		if cfg.RemoteGeth {
+			err := client.ValidateNetwork(ctx, cfg.GenesisBlockIdentifier)
+			switch {
+			case errors.Is(err, ethereum.ErrNetworkMismatch):
+				return fmt.Errorf("%w: GETH does not serve NETWORK %s", err, cfg.Network.Network)
+			case err != nil:
+				zap.L().Warn("unable to validate network of GETH", zap.Error(err))
+			}
+		}
+
+		if len(cfg.TokenWhitelist) > 0 {
+			cfg.Tokens, err = client.LoadTokens(ctx, cfg.TokenWhitelist)
+			if err != nil {
+				return fmt.Errorf("%w: unable to load TOKEN_WHITELIST", err)
+			}
+		}
+
+		workers.Go(func() error {
+			return client.MonitorNodes(workersCtx)
+		})
+
+		workers.Go(func() error {
+			return client.TrackHeads(workersCtx)
+		})
+
+		workers.Go(func() error {
+			return client.TrackMempool(workersCtx)
+		})
+
+		workers.Go(func() error {
+			return client.LogTraceCacheStats(workersCtx)
+		})
+
+		workers.Go(func() error {
+			return handleReload(workersCtx, client, limiter, overrides)
+		})
+
+		if cfg.BlockEvents || cfg.TransactionIndex {
+			dir := indexerDir(cfg)
+			if err := os.MkdirAll(dir, configuration.DataDirectoryPermissions); err != nil {
+				return fmt.Errorf("%w: unable to create indexer directory %s", err, dir)
+			}
+
+			idx, err = indexer.New(
+				ctx,
+				dir,
+				cfg.Network,
+				cfg.GenesisBlockIdentifier,
+				client,
+				indexerConfig(cfg),
+			)
+			if err != nil {
+				return fmt.Errorf("%w: unable to initialize indexer", err)
+			}
+			defer idx.Close(context.Background()) // nolint:errcheck
+
+			workers.Go(func() error {
+				return idx.Sync(workersCtx)
+			})
+
+			workers.Go(func() error {
+				return idx.Prune(workersCtx)
+			})
+
+			blockIndexer = idx
+		}
 	}
 
-	router := services.NewBlockchainRouter(cfg, client, asserter)
+	// A failing worker shuts the server down.
+	g.Go(workers.Wait)
 
-	loggedRouter := server.LoggerMiddleware(router)
+	responses, err := responseStore(cfg)
+	if err != nil {
+		return err
+	}
+	if redis, ok := responses.(*cache.Redis); ok {
+		defer redis.Close()
+	}
+
+	var router http.Handler = services.NewBlockchainRouter(
+		cfg,
+		client,
+		blockIndexer,
+		responses,
+		asserter,
+	)
+	router = requestlimit.Middleware(
+		&requestlimit.Config{
+			MaxSize:  cfg.MaxRequestSize,
+			MaxItems: cfg.MaxConstructionItems,
+		},
+		&requestlimit.Errors{
+			TooLarge: services.ErrRequestTooLarge,
+			Invalid:  services.ErrInvalidRequest,
+		},
+		router,
+	)
+	router = limiter.Middleware(services.ErrRateLimited, router)
+
+	loggedRouter := logger.Middleware(tracing.Middleware(metrics.Middleware(router)))
 	corsRouter := server.CorsMiddleware(loggedRouter)
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      corsRouter,
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
 		IdleTimeout:  idleTimeout,
 	}
+	servers := []*http.Server{server}
 
-	g.Go(func() error {
-		log.Printf("server listening on port %d", cfg.Port)
-		return server.ListenAndServe()
-	})
+	listeners, err := serverListeners(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, listener := range listeners {
+		listener := listener
+		g.Go(func() error {
+			zap.L().Info("server listening", zap.Stringer("address", listener.Addr()))
+			return serve(server, listener)
+		})
+	}
+
+	if len(cfg.MetricsAddr) > 0 {
+		listener, err := net.Listen("tcp", cfg.MetricsAddr)
+		if err != nil {
+			return fmt.Errorf("%w: unable to listen on %s", err, cfg.MetricsAddr)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.DefaultRegistry)
+		metricsServer := &http.Server{
+			Handler:      mux,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  idleTimeout,
+		}
+		servers = append(servers, metricsServer)
+
+		g.Go(func() error {
+			zap.L().Info("metrics listening", zap.Stringer("address", listener.Addr()))
+			return serve(metricsServer, listener)
+		})
+	}
+
+	if len(cfg.DiagnosticsAddr) > 0 {
+		listener, err := net.Listen("tcp", cfg.DiagnosticsAddr)
+		if err != nil {
+			return fmt.Errorf("%w: unable to listen on %s", err, cfg.DiagnosticsAddr)
+		}
+
+		handler := diagnostics.NewHandler()
+		if client != nil {
+			handler.AddCache("traces", func() interface{} {
+				return client.TraceCacheStats()
+			})
+			handler.AddCache("mempool", func() interface{} {
+				return client.MempoolMirrorSize()
+			})
+		}
+
+		// CPU profiles and execution traces are written for
+		// as long as requested, up to writeTimeout.
+		diagnosticsServer := &http.Server{
+			Handler:      handler,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  idleTimeout,
+		}
+		servers = append(servers, diagnosticsServer)
+
+		g.Go(func() error {
+			zap.L().Info("diagnostics listening", zap.Stringer("address", listener.Addr()))
+			return serve(diagnosticsServer, listener)
+		})
+	}
 
 	g.Go(func() error {
-		// If we don't shutdown server in errgroup, it will
-		// never stop because server.ListenAndServe doesn't
-		// take any context.
+		// If we don't shutdown servers in errgroup, they will
+		// never stop because server.Serve doesn't take any
+		// context.
 		<-ctx.Done()
 
-		return server.Shutdown(ctx)
+		drain(servers, shutdownTimeout(cfg))
+
+		// Workers return once their WebSocket
+		// subscriptions are unsubscribed.
+		stopWorkers()
+		workers.Wait() // nolint:errcheck
+		zap.L().Info("stopped workers")
+
+		if idx != nil {
+			if err := idx.Close(context.Background()); err != nil {
+				zap.L().Error("unable to close index", zap.Error(err))
+			} else {
+				zap.L().Info("closed index")
+			}
+		}
+
+		if client != nil {
+			client.Close()
+		}
+
+		stopGeth()
+		return nil
 	})
 
 	err = g.Wait()
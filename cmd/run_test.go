@@ -0,0 +1,86 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// envVars returns the environment variables declared
+// (as constants named *Env) in the configuration package.
+func envVars(t *testing.T) map[string]string {
+	file, err := parser.ParseFile(
+		token.NewFileSet(),
+		"../configuration/configuration.go",
+		nil,
+		0,
+	)
+	assert.NoError(t, err)
+
+	vars := map[string]string{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasSuffix(name.Name, "Env") || i >= len(value.Values) {
+					continue
+				}
+
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+
+				env, err := strconv.Unquote(lit.Value)
+				assert.NoError(t, err)
+				vars[name.Name] = env
+			}
+		}
+	}
+
+	return vars
+}
+
+func TestRunFlags(t *testing.T) {
+	vars := envVars(t)
+	assert.NotEmpty(t, vars)
+
+	flags := map[string]string{}
+	for flag, env := range runFlags {
+		// Each environment variable has a single flag.
+		other, ok := flags[env]
+		assert.False(t, ok, "%s has flags %s and %s", env, flag, other)
+		flags[env] = flag
+	}
+
+	// Every environment variable can be set with a flag.
+	for name, env := range vars {
+		_, ok := flags[env]
+		assert.True(t, ok, "configuration.%s (%s) has no run flag", name, env)
+	}
+	assert.Len(t, runFlags, len(vars))
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// pingFlag is the flag of the validate-config
+	// command used to enable pinging GETH.
+	pingFlag = "ping"
+
+	// pingTimeout is the maximum duration to
+	// wait for GETH to respond to a ping.
+	pingTimeout = 30 * time.Second
+
+	// redacted replaces secrets in the
+	// printed configuration.
+	redacted = "xxxxx"
+)
+
+var (
+	validateConfigCmd = &cobra.Command{
+		Use:   "validate-config",
+		Short: "Validate the configuration and exit",
+		Long: `Load the configuration exactly as the run command would
+(from the environment, CONFIG_FILE and flags), print the
+resolved configuration with secrets redacted and exit.
+
+When --ping is provided, the remote GETH node is also
+checked to be reachable and serving the configured NETWORK.
+
+The command exits with a non-zero status if any problem
+is found.`,
+		RunE: runValidateConfigCmd,
+		Args: cobra.NoArgs,
+	}
+)
+
+func init() {
+	for flag, env := range runFlags {
+		validateConfigCmd.Flags().String(flag, "", fmt.Sprintf("overrides %s", env))
+	}
+
+	validateConfigCmd.Flags().Bool(
+		pingFlag,
+		false,
+		"check that the remote GETH node is reachable and serves NETWORK",
+	)
+}
+
+func runValidateConfigCmd(cmd *cobra.Command, args []string) error {
+	overrides, err := flagOverrides(cmd, runFlags)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := configuration.LoadConfiguration(overrides)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load configuration", err)
+	}
+
+	if err := printConfiguration(os.Stdout, cfg); err != nil {
+		return fmt.Errorf("%w: unable to print configuration", err)
+	}
+
+	ping, err := cmd.Flags().GetBool(pingFlag)
+	if err != nil {
+		return fmt.Errorf("%w: unable to read flag %s", err, pingFlag)
+	}
+
+	if ping {
+		if err := pingGeth(context.Background(), cfg); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("configuration is valid")
+	return nil
+}
+
+// pingGeth checks that the remote GETH node is reachable
+// and serves the configured network. Nothing is checked
+// when geth would be started locally or in offline mode.
+func pingGeth(ctx context.Context, cfg *configuration.Configuration) error {
+	if !cfg.Mode.IsOnline() || !cfg.RemoteGeth {
+		fmt.Println("skipping ping: no remote GETH node is configured")
+		return nil
+	}
+
+	client, err := ethereum.NewClient(
+		cfg.GethURLs,
+		cfg.Params,
+		cfg.SkipGethAdmin,
+		rpcConfig(cfg),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: cannot initialize ethereum client", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := client.ValidateNetwork(ctx, cfg.GenesisBlockIdentifier); err != nil {
+		return fmt.Errorf("%w: unable to validate GETH", err)
+	}
+
+	fmt.Println("ping succeeded: GETH serves NETWORK", cfg.Network.Network)
+	return nil
+}
+
+// printConfiguration writes a summary of cfg to w.
+// Credentials in node URLs are redacted.
+func printConfiguration(w io.Writer, cfg *configuration.Configuration) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) // nolint:gomnd
+
+	rows := [][2]string{
+		{"MODE", string(cfg.Mode)},
+		{"NETWORK", fmt.Sprintf("%s %s", cfg.Network.Blockchain, cfg.Network.Network)},
+	}
+
+	if cfg.Params != nil && cfg.Params.ChainID != nil {
+		rows = append(rows, [2]string{"CHAIN ID", cfg.Params.ChainID.String()})
+	}
+
+	if cfg.GenesisBlockIdentifier != nil {
+		rows = append(rows, [2]string{
+			"GENESIS",
+			fmt.Sprintf("%d %s", cfg.GenesisBlockIdentifier.Index, cfg.GenesisBlockIdentifier.Hash),
+		})
+	}
+
+	port := fmt.Sprintf("%d", cfg.Port)
+	if cfg.AutoPort {
+		port = configuration.AutoPort
+	}
+
+	gethURLs := make([]string, len(cfg.GethURLs))
+	for i, gethURL := range cfg.GethURLs {
+		gethURLs[i] = redactURL(gethURL)
+	}
+
+	rows = append(rows, [][2]string{
+		{"PORT", port},
+		{"LISTEN_ADDR", cfg.ListenAddr},
+		{"LISTEN_SOCKET", cfg.ListenSocket},
+		{"METRICS_ADDR", cfg.MetricsAddr},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT", redactURL(cfg.OTLPEndpoint)},
+		{"DIAGNOSTICS_ADDR", cfg.DiagnosticsAddr},
+		{"DATA_DIR", cfg.DataDir},
+		{"PRUNE_DEPTH", fmt.Sprintf("%d", cfg.PruneDepth)},
+		{"GETH", strings.Join(gethURLs, ",")},
+		{"GETH_WS", redactURL(cfg.GethWSURL)},
+		{"REMOTE GETH", fmt.Sprintf("%t", cfg.RemoteGeth)},
+		{"SKIP_GETH_ADMIN", fmt.Sprintf("%t", cfg.SkipGethAdmin)},
+		{"GETH_CA_CERT", cfg.GethCACert},
+		{"GETH_TLS_INSECURE", fmt.Sprintf("%t", cfg.GethTLSInsecure)},
+		{"GETH HEADERS", redactHeader(cfg.GethHeader)},
+		{"RPC_TIMEOUT", cfg.RPCTimeout.String()},
+		{"RPC_RETRIES", fmt.Sprintf("%d", cfg.RPCRetries)},
+		{"RPC_BACKOFF", cfg.RPCBackoff.String()},
+		{"RPC_MAX_IDLE_CONNS", fmt.Sprintf("%d", cfg.RPCMaxIdleConns)},
+		{"RPC_MAX_CONNS_PER_HOST", fmt.Sprintf("%d", cfg.RPCMaxConnsPerHost)},
+		{"BREAKER_THRESHOLD", fmt.Sprintf("%d", cfg.BreakerThreshold)},
+		{"BREAKER_COOLDOWN", cfg.BreakerCooldown.String()},
+		{"SYNC_CONCURRENCY", fmt.Sprintf("%d", cfg.SyncConcurrency)},
+		{"BLOCK_BATCH_SIZE", fmt.Sprintf("%d", cfg.BlockBatchSize)},
+		{"TRACE_MODE", string(cfg.TraceMode)},
+		{"TRACE_TIMEOUT", cfg.TraceTimeout.String()},
+		{"TRACE_CACHE_SIZE", fmt.Sprintf("%d", cfg.TraceCacheSize)},
+		{"MEMPOOL_TTL", cfg.MempoolTTL.String()},
+		{"GENESIS_BALANCES", fmt.Sprintf("%t", cfg.GenesisBalances)},
+		{"BLOCK_EVENTS", fmt.Sprintf("%t", cfg.BlockEvents)},
+		{"TRANSACTION_INDEX", fmt.Sprintf("%t", cfg.TransactionIndex)},
+		{"EVENT_RETENTION", cfg.EventRetention.String()},
+		{"TRANSACTION_RETENTION", fmt.Sprintf("%d", cfg.TransactionRetention)},
+		{"READY_MAX_LAG", fmt.Sprintf("%d", cfg.ReadyMaxLag)},
+		{"SLOW_REQUEST_THRESHOLD", cfg.SlowRequestThreshold.String()},
+		{"SLOW_RPC_THRESHOLD", cfg.SlowRPCThreshold.String()},
+		{"SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout.String()},
+		{"MAX_REQUEST_SIZE", fmt.Sprintf("%d", cfg.MaxRequestSize)},
+		{"MAX_CONSTRUCTION_ITEMS", fmt.Sprintf("%d", cfg.MaxConstructionItems)},
+		{"RESPONSE_CACHE_SIZE", fmt.Sprintf("%d", cfg.ResponseCacheSize)},
+		{"RESPONSE_CACHE_REDIS", redactURL(cfg.ResponseCacheRedis)},
+		{"RESPONSE_CACHE_DEPTH", fmt.Sprintf("%d", cfg.ResponseCacheDepth)},
+		{"LOG_LEVEL", cfg.LogLevel},
+		{"LOG_FORMAT", cfg.LogFormat},
+	}...)
+
+	if cfg.RateLimit != nil {
+		endpoints := make([]string, 0, len(cfg.RateLimit.Endpoints))
+		for path, rate := range cfg.RateLimit.Endpoints {
+			endpoints = append(endpoints, fmt.Sprintf("%s=%g", path, rate))
+		}
+		sort.Strings(endpoints)
+
+		rows = append(rows, [][2]string{
+			{"RATE_LIMIT", fmt.Sprintf("%g", cfg.RateLimit.Global)},
+			{"RATE_LIMIT_PER_CLIENT", fmt.Sprintf("%g", cfg.RateLimit.PerClient)},
+			{"RATE_LIMIT_ENDPOINTS", strings.Join(endpoints, ",")},
+			{"RATE_LIMIT_KEY_HEADER", cfg.RateLimit.KeyHeader},
+		}...)
+	}
+
+	if !cfg.RemoteGeth && cfg.Mode.IsOnline() {
+		rows = append(rows, [2]string{"GETH ARGUMENTS", cfg.GethArguments})
+	}
+
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1]); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+// redactHeader returns the names of the fields
+// of header, with all values redacted.
+func redactHeader(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, fmt.Sprintf("%s: %s", name, redacted))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// redactURL replaces the password, path, query string
+// and fragment of rawURL, which commonly carry API keys
+// of hosted node providers. IPC paths are returned as-is.
+func redactURL(rawURL string) string {
+	if len(rawURL) == 0 || strings.HasPrefix(rawURL, "ipc://") {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	if len(strings.Trim(u.Path, "/")) > 0 {
+		u.Path = "/" + redacted
+		u.RawPath = ""
+	}
+
+	if len(u.RawQuery) > 0 {
+		u.RawQuery = redacted
+	}
+
+	if len(u.Fragment) > 0 {
+		u.Fragment = redacted
+	}
+
+	return u.String()
+}
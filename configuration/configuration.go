@@ -15,19 +15,38 @@
 package configuration
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
+	"github.com/coinbase/rosetta-ethereum/diagnostics"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/ratelimit"
 
+	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/yaml.v3"
 )
 
 // Mode is the setting that determines if
-// the implementation is "online" or "offline".
+// the implementation is "online", "offline"
+// or "indexer".
 type Mode string
 
 const (
@@ -39,6 +58,11 @@ const (
 	// to make outbound connections.
 	Offline Mode = "OFFLINE"
 
+	// Indexer is when the implementation is online and
+	// serves blocks from a local index of the chain,
+	// synced from genesis.
+	Indexer Mode = "INDEXER"
+
 	// Mainnet is the Ethereum Mainnet.
 	Mainnet string = "MAINNET"
 
@@ -51,6 +75,13 @@ const (
 	// Goerli is the Ethereum Görli testnet.
 	Goerli string = "GOERLI"
 
+	// Sepolia is the Ethereum Sepolia testnet.
+	Sepolia string = "SEPOLIA"
+
+	// Custom is a private Ethereum network described by
+	// CustomGenesisHashEnv and CustomChainConfigEnv.
+	Custom string = "CUSTOM"
+
 	// Testnet defaults to `Ropsten` for backwards compatibility.
 	Testnet string = "TESTNET"
 
@@ -58,6 +89,10 @@ const (
 	// persistent data.
 	DataDirectory = "/data"
 
+	// DataDirectoryPermissions are the permissions used
+	// when creating the data directory.
+	DataDirectoryPermissions = 0700
+
 	// ModeEnv is the environment variable read
 	// to determine mode.
 	ModeEnv = "MODE"
@@ -71,11 +106,80 @@ const (
 	// implementation.
 	PortEnv = "PORT"
 
+	// AutoPort is the value of PortEnv that lets the
+	// operating system pick any free port. The chosen
+	// port is logged when the server starts.
+	AutoPort = "auto"
+
+	// ListenAddrEnv is an optional environment variable
+	// used to set the address the Rosetta implementation
+	// listens on (i.e. `127.0.0.1:8080`). When populated,
+	// PortEnv is ignored.
+	ListenAddrEnv = "LISTEN_ADDR"
+
+	// ListenSocketEnv is an optional environment variable
+	// used to serve the Rosetta implementation on a Unix
+	// domain socket at the provided path.
+	ListenSocketEnv = "LISTEN_SOCKET"
+
+	// MetricsAddrEnv is an optional environment variable
+	// used to set the address (i.e. `127.0.0.1:9090`) on
+	// which Prometheus metrics are served at /metrics,
+	// separately from the Rosetta implementation.
+	MetricsAddrEnv = "METRICS_ADDR"
+
+	// OTLPEndpointEnv is an optional environment variable
+	// used to set the OTLP/HTTP collector (i.e.
+	// `http://localhost:4318`) to which traces of the
+	// requests served are exported. When not set, no
+	// traces are recorded.
+	OTLPEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+	// DiagnosticsAddrEnv is an optional environment variable
+	// used to set the loopback address (i.e. `127.0.0.1:6060`)
+	// on which runtime profiles (pprof), exported variables
+	// (expvar) and a summary of goroutines, memory and caches
+	// are served. When not set, they are not served.
+	DiagnosticsAddrEnv = "DIAGNOSTICS_ADDR"
+
+	// DataDirEnv is an optional environment variable
+	// used to set the location of all persistent data
+	// (including the data of a local geth node). When not
+	// set, defaults to DataDirectory.
+	DataDirEnv = "DATA_DIR"
+
+	// PruneDepthEnv is an optional environment variable
+	// used to set the number of recent blocks for which
+	// geth keeps state. Requests for older blocks return
+	// an error and /network/status advertises the oldest
+	// block that can be served. When not set, defaults to
+	// 0 (an archive node with all state).
+	PruneDepthEnv = "PRUNE_DEPTH"
+
+	// LogLevelEnv is an optional environment variable
+	// used to set the minimum level of logs (i.e. `debug`,
+	// `info`, `warn`, `error`). When not set, defaults to
+	// `info`.
+	LogLevelEnv = "LOG_LEVEL"
+
+	// LogFormatEnv is an optional environment variable
+	// used to set the format of logs (`json` or `console`).
+	// When not set, defaults to `console`.
+	LogFormatEnv = "LOG_FORMAT"
+
 	// GethEnv is an optional environment variable
 	// used to connect rosetta-ethereum to an already
-	// running geth node.
+	// running geth node. Multiple nodes can be provided
+	// as a comma-separated list, in order of priority.
 	GethEnv = "GETH"
 
+	// GethWSEnv is an optional environment variable
+	// used to set the WebSocket URL of geth, which is
+	// used for subscriptions. When not set, it is derived
+	// from the first GethEnv URL (i.e. `http://host:8545`
+	// becomes `ws://host:8545`).
+	GethWSEnv = "GETH_WS"
+
 	// DefaultGethURL is the default URL for
 	// a running geth node. This is used
 	// when GethEnv is not populated.
@@ -86,99 +190,873 @@ const (
 	// by hosted node services. When not set, defaults to false.
 	SkipGethAdminEnv = "SKIP_GETH_ADMIN"
 
+	// GethCACertEnv is an optional environment variable
+	// pointing to a PEM bundle of certificate authorities
+	// trusted, in addition to the system ones, when
+	// connecting to geth over TLS.
+	GethCACertEnv = "GETH_CA_CERT"
+
+	// GethTLSInsecureEnv is an optional environment variable
+	// used to disable the verification of the certificate of
+	// geth. When not set, defaults to false.
+	GethTLSInsecureEnv = "GETH_TLS_INSECURE"
+
+	// GethAuthHeaderEnv is an optional environment variable
+	// containing a header (i.e. `X-Api-Key: secret`) added
+	// to every HTTP request made to geth.
+	GethAuthHeaderEnv = "GETH_AUTH_HEADER"
+
+	// GethBearerTokenEnv is an optional environment variable
+	// containing a token sent in the `Authorization` header
+	// of every HTTP request made to geth.
+	GethBearerTokenEnv = "GETH_BEARER_TOKEN"
+
+	// ConfigFileEnv is an optional environment variable
+	// pointing to a YAML file with configuration values.
+	// Each key in the file is the lowercase name of the
+	// environment variable it replaces (i.e. `mode`, `network`,
+	// `port`). Environment variables take precedence over
+	// values in the file.
+	ConfigFileEnv = "CONFIG_FILE"
+
+	// RPCTimeoutEnv is an optional environment variable
+	// used to set the timeout of each request made to geth
+	// (i.e. `30s`, `5m`). When not set, defaults to 120s for
+	// JSON-RPC requests and 15s for GraphQL requests.
+	RPCTimeoutEnv = "RPC_TIMEOUT"
+
+	// RPCRetriesEnv is an optional environment variable
+	// used to set the number of times a request is retried
+	// when no geth node can serve it. When not set, defaults
+	// to 0.
+	RPCRetriesEnv = "RPC_RETRIES"
+
+	// RPCBackoffEnv is an optional environment variable
+	// used to set the delay before the first retry of a
+	// request (i.e. `500ms`). The delay doubles on each
+	// subsequent retry. When not set, defaults to 1s.
+	RPCBackoffEnv = "RPC_BACKOFF"
+
+	// RPCMaxIdleConnsEnv is an optional environment variable
+	// used to set the number of idle HTTP connections kept
+	// open to each geth node, to be reused by later requests.
+	// When not set, defaults to 100.
+	RPCMaxIdleConnsEnv = "RPC_MAX_IDLE_CONNS"
+
+	// RPCMaxConnsPerHostEnv is an optional environment
+	// variable used to set the maximum number of HTTP
+	// connections open to each geth node. Requests wait for
+	// a connection once it is reached. When not set,
+	// connections are not limited.
+	RPCMaxConnsPerHostEnv = "RPC_MAX_CONNS_PER_HOST"
+
+	// BreakerThresholdEnv is an optional environment variable
+	// used to set the number of consecutive requests that no
+	// geth node could serve after which requests fail fast,
+	// until a request probing geth succeeds. When not set,
+	// defaults to 5.
+	BreakerThresholdEnv = "BREAKER_THRESHOLD"
+
+	// BreakerCooldownEnv is an optional environment variable
+	// used to set how long requests fail fast before one is
+	// sent to probe geth (i.e. `10s`). When not set, defaults
+	// to 30s.
+	BreakerCooldownEnv = "BREAKER_COOLDOWN"
+
+	// SyncConcurrencyEnv is an optional environment variable
+	// used to set the maximum number of concurrent trace
+	// requests made to geth while fetching blocks. When not
+	// set, defaults to 16.
+	SyncConcurrencyEnv = "SYNC_CONCURRENCY"
+
+	// BlockBatchSizeEnv is an optional environment variable
+	// used to set the maximum number of calls in each JSON-RPC
+	// batch made to geth while fetching blocks. When not set,
+	// batches are not split.
+	BlockBatchSizeEnv = "BLOCK_BATCH_SIZE"
+
+	// TraceModeEnv is an optional environment variable
+	// used to choose how transactions are traced: with
+	// geth's native tracer (`call`), with the JavaScript
+	// tracer (`js`) or not at all (`off`). When not set,
+	// defaults to `js`.
+	TraceModeEnv = "TRACE_MODE"
+
+	// TraceTimeoutEnv is an optional environment variable
+	// used to set the timeout of each trace computed by
+	// geth (i.e. `30s`). When not set, defaults to 120s.
+	TraceTimeoutEnv = "TRACE_TIMEOUT"
+
+	// TraceCacheSizeEnv is an optional environment variable
+	// used to set the number of blocks whose traces are
+	// cached. When not set, defaults to 32.
+	TraceCacheSizeEnv = "TRACE_CACHE_SIZE"
+
+	// MempoolTTLEnv is an optional environment variable
+	// used to set how long a transaction stays in the
+	// mempool mirror after it was last seen (i.e. `30s`).
+	// When not set, defaults to 1m.
+	MempoolTTLEnv = "MEMPOOL_TTL"
+
+	// GenesisBalancesEnv is an optional environment variable
+	// used to credit the balances allocated at genesis in the
+	// genesis block (instead of bootstrapping them). When not
+	// set, defaults to false.
+	GenesisBalancesEnv = "GENESIS_BALANCES"
+
+	// BlockEventsEnv is an optional environment variable
+	// used to record the blocks added to and removed from
+	// the canonical chain in DATA_DIR and serve them from
+	// /events/blocks. When not set, defaults to false.
+	BlockEventsEnv = "BLOCK_EVENTS"
+
+	// TransactionIndexEnv is an optional environment variable
+	// used to index the transactions of the canonical chain in
+	// DATA_DIR and serve /search/transactions from them. When
+	// not set, defaults to false.
+	TransactionIndexEnv = "TRANSACTION_INDEX"
+
+	// EventRetentionEnv is an optional environment variable
+	// used to set how long block events are kept, by the
+	// timestamp of their block (i.e. `720h`). When not set,
+	// defaults to 0 (all events are kept).
+	EventRetentionEnv = "EVENT_RETENTION"
+
+	// TransactionRetentionEnv is an optional environment
+	// variable used to set the number of recent blocks whose
+	// transactions are kept in the transaction index. When not
+	// set, defaults to 0 (all transactions are kept).
+	TransactionRetentionEnv = "TRANSACTION_RETENTION"
+
+	// ReadyMaxLagEnv is an optional environment variable
+	// used to set how many blocks geth (and the index, if
+	// any) may be behind the tip of the chain while /readyz
+	// reports the implementation as ready. When not set,
+	// defaults to 10.
+	ReadyMaxLagEnv = "READY_MAX_LAG"
+
+	// SlowRequestThresholdEnv is an optional environment
+	// variable used to set the duration (i.e. `5s`) above
+	// which Rosetta requests are logged as slow, with the
+	// block and trace size involved. When not set, slow
+	// requests are not logged.
+	SlowRequestThresholdEnv = "SLOW_REQUEST_THRESHOLD"
+
+	// SlowRPCThresholdEnv is an optional environment variable
+	// used to set the duration (i.e. `2s`) above which calls
+	// to geth are logged as slow, with their parameters and
+	// the size of their result. When not set, slow calls are
+	// not logged.
+	SlowRPCThresholdEnv = "SLOW_RPC_THRESHOLD"
+
+	// RateLimitEnv is an optional environment variable used
+	// to set the maximum rate (in requests per second) of the
+	// Rosetta requests served to all clients. Requests above
+	// it are rejected with 429 Too Many Requests. When not
+	// set, requests are not limited.
+	RateLimitEnv = "RATE_LIMIT"
+
+	// RateLimitPerClientEnv is an optional environment
+	// variable used to set the maximum rate (in requests per
+	// second) of the Rosetta requests served to each client.
+	// When not set, clients are not limited.
+	RateLimitPerClientEnv = "RATE_LIMIT_PER_CLIENT"
+
+	// RateLimitEndpointsEnv is an optional environment
+	// variable used to set the maximum rate (in requests per
+	// second) of the requests of each client to some
+	// endpoints, as a comma-separated list of `path=rate`
+	// (i.e. `/block=5,/account/balance=20`).
+	RateLimitEndpointsEnv = "RATE_LIMIT_ENDPOINTS"
+
+	// RateLimitKeyHeaderEnv is an optional environment
+	// variable used to set the header identifying clients
+	// for rate limits (i.e. an API key set by a proxy).
+	// When not set, or missing from a request, clients are
+	// identified by their IP address.
+	RateLimitKeyHeaderEnv = "RATE_LIMIT_KEY_HEADER"
+
+	// ShutdownTimeoutEnv is an optional environment variable
+	// used to set how long in-flight requests are waited for
+	// on SIGINT or SIGTERM before their connections are closed
+	// (i.e. `10s`). When not set, defaults to 20s.
+	ShutdownTimeoutEnv = "SHUTDOWN_TIMEOUT"
+
+	// MaxRequestSizeEnv is an optional environment variable
+	// used to set the largest request body (in bytes) that
+	// is served. When not set, defaults to 1048576 (1 MiB).
+	MaxRequestSizeEnv = "MAX_REQUEST_SIZE"
+
+	// MaxConstructionItemsEnv is an optional environment
+	// variable used to set the longest array (i.e. of
+	// operations or signatures) of construction requests.
+	// When not set, defaults to 1000.
+	MaxConstructionItemsEnv = "MAX_CONSTRUCTION_ITEMS"
+
+	// ResponseCacheSizeEnv is an optional environment
+	// variable used to set the number of /block and
+	// /block/transaction responses of immutable blocks
+	// cached in memory. When not set (and RESPONSE_CACHE_REDIS
+	// is not set), responses are not cached.
+	ResponseCacheSizeEnv = "RESPONSE_CACHE_SIZE"
+
+	// ResponseCacheRedisEnv is an optional environment
+	// variable used to cache the responses of immutable
+	// blocks in Redis (i.e. `redis://:password@host:6379/0`),
+	// shared by instances, instead of in memory.
+	ResponseCacheRedisEnv = "RESPONSE_CACHE_REDIS"
+
+	// ResponseCacheDepthEnv is an optional environment
+	// variable used to set the number of blocks below
+	// the tip a block must be for its responses to be
+	// cached. When not set, defaults to 64.
+	ResponseCacheDepthEnv = "RESPONSE_CACHE_DEPTH"
+
+	// OfflineGasPriceEnv is an optional environment variable
+	// used to set the gas price (in wei) of transactions
+	// constructed without /construction/metadata, which are
+	// then legacy transactions. It replaces the offline fees
+	// of the network (see ethereum.OfflineFees).
+	OfflineGasPriceEnv = "OFFLINE_GAS_PRICE"
+
+	// OfflineMaxFeePerGasEnv and OfflineMaxPriorityFeePerGasEnv
+	// are optional environment variables used to set the fee
+	// cap and tip cap (in wei) of transactions constructed
+	// without /construction/metadata. Both must be set, and
+	// they replace the offline fees of the network.
+	OfflineMaxFeePerGasEnv         = "OFFLINE_MAX_FEE_PER_GAS"
+	OfflineMaxPriorityFeePerGasEnv = "OFFLINE_MAX_PRIORITY_FEE_PER_GAS"
+
+	// BatchContractEnv is an optional environment variable
+	// used to set the address of the contract batch transfers
+	// are sent through, with a payable
+	// `multisend(address[],uint256[])` method. When not set,
+	// batch transfers cannot be constructed.
+	BatchContractEnv = "BATCH_CONTRACT"
+
+	// GasLimitMultiplierEnv is an optional environment variable
+	// used to set the safety margin applied to gas limits
+	// estimated by /construction/metadata (i.e. `1.2` adds 20%).
+	// When not set, defaults to 1.
+	GasLimitMultiplierEnv = "GAS_LIMIT_MULTIPLIER"
+
+	// MaxGasLimitEnv is an optional environment variable used
+	// to set the largest gas limit of constructed transactions.
+	// When not set, gas limits are not capped.
+	MaxGasLimitEnv = "MAX_GAS_LIMIT"
+
+	// MaxFeeCapEnv is an optional environment variable used to
+	// set the largest fee per gas (in wei) constructed
+	// transactions may pay: their gas price or, for dynamic fee
+	// transactions, their fee cap. When not set, fees are not
+	// capped.
+	MaxFeeCapEnv = "MAX_FEE_CAP"
+
+	// TokenWhitelistEnv is an optional environment variable
+	// used to set the ERC-20 tokens whose transfers are
+	// included in blocks: a comma-separated list of contract
+	// addresses, or the path of a JSON file holding an array
+	// of them. Their symbol and decimals are looked up when
+	// rosetta-ethereum starts.
+	TokenWhitelistEnv = "TOKEN_WHITELIST"
+
+	// BalanceExemptionsEnv is an optional environment variable
+	// used to set the path of a JSON file holding an array of
+	// balance exemptions, advertised in /network/options for
+	// currencies whose balances change without operations (for
+	// example rebasing tokens).
+	BalanceExemptionsEnv = "BALANCE_EXEMPTIONS"
+
+	// CustomGenesisHashEnv is the environment variable
+	// read to determine the genesis block hash when
+	// NETWORK is CUSTOM.
+	CustomGenesisHashEnv = "CUSTOM_GENESIS_HASH"
+
+	// CustomChainConfigEnv is the environment variable
+	// read to determine the path of the JSON chain config
+	// (in the format of geth's genesis `config`) when
+	// NETWORK is CUSTOM.
+	CustomChainConfigEnv = "CUSTOM_CHAIN_CONFIG"
+
 	// MiddlewareVersion is the version of rosetta-ethereum.
 	MiddlewareVersion = "0.0.4"
 )
 
+// IsOnline returns whether the implementation
+// is permitted to make outbound connections.
+func (m Mode) IsOnline() bool {
+	return m == Online || m == Indexer
+}
+
 // Configuration determines how
 type Configuration struct {
 	Mode                   Mode
 	Network                *types.NetworkIdentifier
 	GenesisBlockIdentifier *types.BlockIdentifier
-	GethURL                string
+	GethURLs               []string
+	GethWSURL              string
 	RemoteGeth             bool
 	Port                   int
+	AutoPort               bool
+	ListenAddr             string
+	ListenSocket           string
+	MetricsAddr            string
+	OTLPEndpoint           string
+	DiagnosticsAddr        string
+	DataDir                string
+	PruneDepth             int64
+	LogLevel               string
+	LogFormat              string
 	GethArguments          string
 	SkipGethAdmin          bool
+	GethCACert             string
+	GethTLSInsecure        bool
+	GethHeader             http.Header
+	RPCTimeout             time.Duration
+	RPCRetries             int
+	RPCMaxIdleConns        int
+	RPCMaxConnsPerHost     int
+	RPCBackoff             time.Duration
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+	SyncConcurrency        int64
+	BlockBatchSize         int
+	TraceMode              ethereum.TraceMode
+	TraceTimeout           time.Duration
+	TraceCacheSize         int
+	MempoolTTL             time.Duration
+	GenesisBalances        bool
+	BlockEvents            bool
+	TransactionIndex       bool
+	EventRetention         time.Duration
+	TransactionRetention   int64
+	ReadyMaxLag            int64
+	SlowRequestThreshold   time.Duration
+	SlowRPCThreshold       time.Duration
+	RateLimit              *ratelimit.Config
+	ShutdownTimeout        time.Duration
+	MaxRequestSize         int64
+	MaxConstructionItems   int
+	ResponseCacheSize      int
+	ResponseCacheRedis     string
+	ResponseCacheDepth     int
+	OfflineFees            *ethereum.Fees
+	BatchContract          string
+	GasLimitMultiplier     float64
+	MaxGasLimit            uint64
+	MaxFeeCap              *big.Int
+	TokenWhitelist         []string
+	BalanceExemptions      []*types.BalanceExemption
+
+	// Tokens are the currencies of TokenWhitelist,
+	// resolved from their contracts on startup.
+	Tokens []*types.Currency
 
 	// Block Reward Data
 	Params *params.ChainConfig
 }
 
+// NetworkSettings are the settings used when
+// NETWORK is set to a registered network name.
+type NetworkSettings struct {
+	// Network is the value of the network
+	// in the NetworkIdentifier.
+	Network                string
+	GenesisBlockIdentifier *types.BlockIdentifier
+	Params                 *params.ChainConfig
+	GethArguments          string
+}
+
+var (
+	networksMutex sync.RWMutex
+	networks      = map[string]*NetworkSettings{
+		Mainnet: {
+			Network:                ethereum.MainnetNetwork,
+			GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+			Params:                 params.MainnetChainConfig,
+			GethArguments:          ethereum.MainnetGethArguments,
+		},
+		Ropsten: {
+			Network:                ethereum.RopstenNetwork,
+			GenesisBlockIdentifier: ethereum.RopstenGenesisBlockIdentifier,
+			Params:                 params.RopstenChainConfig,
+			GethArguments:          ethereum.RopstenGethArguments,
+		},
+		Rinkeby: {
+			Network:                ethereum.RinkebyNetwork,
+			GenesisBlockIdentifier: ethereum.RinkebyGenesisBlockIdentifier,
+			Params:                 params.RinkebyChainConfig,
+			GethArguments:          ethereum.RinkebyGethArguments,
+		},
+		Goerli: {
+			Network:                ethereum.GoerliNetwork,
+			GenesisBlockIdentifier: ethereum.GoerliGenesisBlockIdentifier,
+			Params:                 params.GoerliChainConfig,
+			GethArguments:          ethereum.GoerliGethArguments,
+		},
+		Sepolia: {
+			Network:                ethereum.SepoliaNetwork,
+			GenesisBlockIdentifier: ethereum.SepoliaGenesisBlockIdentifier,
+			Params:                 params.SepoliaChainConfig,
+			GethArguments:          ethereum.SepoliaGethArguments,
+		},
+		Testnet: {
+			Network:                ethereum.DevNetwork,
+			GenesisBlockIdentifier: nil,
+			Params:                 params.AllCliqueProtocolChanges,
+			GethArguments:          ethereum.DevGethArguments,
+		},
+	}
+)
+
+// RegisterNetwork makes a named network available as a
+// NETWORK value. It returns an error if name is empty or
+// already registered.
+func RegisterNetwork(name string, settings *NetworkSettings) error {
+	if len(name) == 0 {
+		return errors.New("network name must be populated")
+	}
+
+	if settings == nil || len(settings.Network) == 0 || settings.Params == nil {
+		return fmt.Errorf("network %s must have a network name and params", name)
+	}
+
+	networksMutex.Lock()
+	defer networksMutex.Unlock()
+
+	if _, ok := networks[name]; ok {
+		return fmt.Errorf("network %s is already registered", name)
+	}
+
+	networks[name] = settings
+	return nil
+}
+
+// lookupNetwork returns the settings registered
+// under name, if any.
+func lookupNetwork(name string) (*NetworkSettings, bool) {
+	networksMutex.RLock()
+	defer networksMutex.RUnlock()
+
+	settings, ok := networks[name]
+	return settings, ok
+}
+
+// loadCustomNetwork creates NetworkSettings from the
+// genesis hash and chain config provided in src.
+func loadCustomNetwork(src *source) (*NetworkSettings, error) {
+	genesisHash := src.get(CustomGenesisHashEnv)
+	if len(genesisHash) == 0 {
+		return nil, errors.New("CUSTOM_GENESIS_HASH must be populated")
+	}
+
+	hash, err := hexutil.Decode(genesisHash)
+	if err != nil || len(hash) != common.HashLength {
+		return nil, fmt.Errorf("%w: unable to parse CUSTOM_GENESIS_HASH %s", err, genesisHash)
+	}
+
+	chainConfigPath := src.get(CustomChainConfigEnv)
+	if len(chainConfigPath) == 0 {
+		return nil, errors.New("CUSTOM_CHAIN_CONFIG must be populated")
+	}
+
+	contents, err := ioutil.ReadFile(chainConfigPath) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read chain config %s", err, chainConfigPath)
+	}
+
+	chainConfig := &params.ChainConfig{}
+	if err := json.Unmarshal(contents, chainConfig); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse chain config %s", err, chainConfigPath)
+	}
+
+	if chainConfig.ChainID == nil {
+		return nil, fmt.Errorf("chain config %s must contain chainId", chainConfigPath)
+	}
+
+	return &NetworkSettings{
+		Network: ethereum.CustomNetwork,
+		GenesisBlockIdentifier: &types.BlockIdentifier{
+			Hash:  common.BytesToHash(hash).Hex(),
+			Index: ethereum.GenesisBlockIndex,
+		},
+		Params: chainConfig,
+	}, nil
+}
+
+// source resolves configuration values from command-line
+// overrides and the environment, falling back to any values
+// loaded from CONFIG_FILE.
+type source struct {
+	overrides map[string]string
+	file      map[string]string
+}
+
+// get returns the value of key, preferring overrides, then
+// the environment, then the configuration file.
+func (s *source) get(key string) string {
+	if value := s.overrides[key]; len(value) > 0 {
+		return value
+	}
+
+	if value := os.Getenv(key); len(value) > 0 {
+		return value
+	}
+
+	return s.file[key]
+}
+
+// loadConfigurationFile parses the YAML file at path into
+// a map keyed by environment variable name. List values
+// are joined with commas.
+func loadConfigurationFile(path string) (map[string]string, error) {
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read configuration file %s", err, path)
+	}
+
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(contents, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse configuration file %s", err, path)
+	}
+
+	values := map[string]string{}
+	for key, value := range parsed {
+		if list, ok := value.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+
+			values[strings.ToUpper(key)] = strings.Join(items, ",")
+			continue
+		}
+
+		values[strings.ToUpper(key)] = fmt.Sprint(value)
+	}
+
+	return values, nil
+}
+
+// splitList splits a comma-separated value, ignoring
+// surrounding whitespace and empty items.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// loadGethHeader returns the header added to each request
+// made to geth or nil if there is none. Values are secrets,
+// so they are never included in errors.
+func loadGethHeader(src *source) (http.Header, error) {
+	header := http.Header{}
+
+	if authHeader := src.get(GethAuthHeaderEnv); len(authHeader) > 0 {
+		parts := strings.SplitN(authHeader, ":", 2) // nolint:gomnd
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || len(name) == 0 || strings.ContainsAny(name, " \t") {
+			return nil, errors.New("unable to parse GETH_AUTH_HEADER: expected `Name: value`")
+		}
+
+		header.Set(name, strings.TrimSpace(parts[1]))
+	}
+
+	if token := src.get(GethBearerTokenEnv); len(token) > 0 {
+		if len(header.Get("Authorization")) > 0 {
+			return nil, errors.New("GETH_AUTH_HEADER and GETH_BEARER_TOKEN both set Authorization")
+		}
+
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	if len(header) == 0 {
+		return nil, nil
+	}
+
+	return header, nil
+}
+
+// loadTokenWhitelist returns the checksummed contract addresses
+// of value: a comma-separated list of addresses, or the path of
+// a JSON file holding an array of them.
+func loadTokenWhitelist(value string) ([]string, error) {
+	addresses := strings.Split(value, ",")
+	if !strings.HasPrefix(value, "0x") {
+		contents, err := ioutil.ReadFile(value) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read token whitelist %s", err, value)
+		}
+
+		if err := json.Unmarshal(contents, &addresses); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse token whitelist %s", err, value)
+		}
+	}
+
+	tokens := make([]string, 0, len(addresses))
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		token, ok := ethereum.ChecksumAddress(strings.TrimSpace(address))
+		if !ok {
+			return nil, fmt.Errorf("%s is not a valid address", address)
+		}
+		if seen[token] {
+			continue
+		}
+
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// loadBalanceExemptions returns the balance exemptions
+// in the JSON file at path.
+func loadBalanceExemptions(path string) ([]*types.BalanceExemption, error) {
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read balance exemptions %s", err, path)
+	}
+
+	var exemptions []*types.BalanceExemption
+	if err := json.Unmarshal(contents, &exemptions); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse balance exemptions %s", err, path)
+	}
+
+	if err := asserter.BalanceExemptions(exemptions); err != nil {
+		return nil, fmt.Errorf("%w: invalid balance exemptions %s", err, path)
+	}
+
+	return exemptions, nil
+}
+
+// loadRateLimit returns the rate limits set in src
+// or nil if there are none.
+func loadRateLimit(src *source) (*ratelimit.Config, error) {
+	config := &ratelimit.Config{
+		KeyHeader: strings.TrimSpace(src.get(RateLimitKeyHeaderEnv)),
+	}
+
+	for key, rate := range map[string]*float64{
+		RateLimitEnv:          &config.Global,
+		RateLimitPerClientEnv: &config.PerClient,
+	} {
+		value := src.get(key)
+		if len(value) == 0 {
+			continue
+		}
+
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse %s %s", err, key, value)
+		}
+		*rate = val
+	}
+
+	if value := src.get(RateLimitEndpointsEnv); len(value) > 0 {
+		config.Endpoints = map[string]float64{}
+		for _, item := range splitList(value) {
+			parts := strings.SplitN(item, "=", 2) // nolint:gomnd
+			if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[0]), "/") {
+				return nil, fmt.Errorf("unable to parse RATE_LIMIT_ENDPOINTS %s: expected `path=rate`", item)
+			}
+
+			val, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if err != nil || val <= 0 {
+				return nil, fmt.Errorf("%w: unable to parse RATE_LIMIT_ENDPOINTS %s", err, item)
+			}
+			config.Endpoints[strings.TrimSpace(parts[0])] = val
+		}
+	}
+
+	if !config.Enabled() {
+		if len(config.KeyHeader) > 0 {
+			return nil, errors.New("RATE_LIMIT_KEY_HEADER is set without any rate limit")
+		}
+
+		return nil, nil
+	}
+
+	return config, nil
+}
+
+// loadOfflineFees returns the offline fees set in src
+// or nil if there are none.
+func loadOfflineFees(src *source) (*ethereum.Fees, error) {
+	fees := &ethereum.Fees{}
+	for key, fee := range map[string]**big.Int{
+		OfflineGasPriceEnv:             &fees.GasPrice,
+		OfflineMaxFeePerGasEnv:         &fees.GasFeeCap,
+		OfflineMaxPriorityFeePerGasEnv: &fees.GasTipCap,
+	} {
+		value := src.get(key)
+		if len(value) == 0 {
+			continue
+		}
+
+		val, ok := new(big.Int).SetString(value, 10) // nolint:gomnd
+		if !ok || val.Sign() < 0 {
+			return nil, fmt.Errorf("unable to parse %s %s", key, value)
+		}
+		*fee = val
+	}
+
+	switch {
+	case fees.GasPrice == nil && fees.GasFeeCap == nil && fees.GasTipCap == nil:
+		return nil, nil
+	case fees.GasPrice != nil && (fees.GasFeeCap != nil || fees.GasTipCap != nil):
+		return nil, fmt.Errorf(
+			"%s cannot be combined with %s or %s",
+			OfflineGasPriceEnv,
+			OfflineMaxFeePerGasEnv,
+			OfflineMaxPriorityFeePerGasEnv,
+		)
+	case fees.GasPrice == nil && (fees.GasFeeCap == nil || fees.GasTipCap == nil):
+		return nil, fmt.Errorf(
+			"%s and %s must be set together",
+			OfflineMaxFeePerGasEnv,
+			OfflineMaxPriorityFeePerGasEnv,
+		)
+	case fees.GasFeeCap != nil && fees.GasTipCap.Cmp(fees.GasFeeCap) > 0:
+		return nil, fmt.Errorf(
+			"%s exceeds %s",
+			OfflineMaxPriorityFeePerGasEnv,
+			OfflineMaxFeePerGasEnv,
+		)
+	}
+
+	return fees, nil
+}
+
 // LoadConfiguration attempts to create a new Configuration
-// using the ENVs in the environment.
-func LoadConfiguration() (*Configuration, error) {
+// using the ENVs in the environment and, if CONFIG_FILE is
+// populated, the values in the configuration file. Any
+// values in overrides (keyed by ENV name) take precedence
+// over both.
+func LoadConfiguration(overrides map[string]string) (*Configuration, error) {
 	config := &Configuration{}
 
-	modeValue := Mode(os.Getenv(ModeEnv))
+	src := &source{overrides: overrides}
+	if configFile := src.get(ConfigFileEnv); len(configFile) > 0 {
+		values, err := loadConfigurationFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		src.file = values
+	}
+
+	modeValue := Mode(src.get(ModeEnv))
 	switch modeValue {
 	case Online:
 		config.Mode = Online
 	case Offline:
 		config.Mode = Offline
+	case Indexer:
+		config.Mode = Indexer
 	case "":
 		return nil, errors.New("MODE must be populated")
 	default:
 		return nil, fmt.Errorf("%s is not a valid mode", modeValue)
 	}
 
-	networkValue := os.Getenv(NetworkEnv)
-	switch networkValue {
-	case Mainnet:
-		config.Network = &types.NetworkIdentifier{
-			Blockchain: ethereum.Blockchain,
-			Network:    ethereum.MainnetNetwork,
-		}
-		config.GenesisBlockIdentifier = ethereum.MainnetGenesisBlockIdentifier
-		config.Params = params.MainnetChainConfig
-		config.GethArguments = ethereum.MainnetGethArguments
-	case Ropsten:
-		config.Network = &types.NetworkIdentifier{
-			Blockchain: ethereum.Blockchain,
-			Network:    ethereum.RopstenNetwork,
-		}
-		config.GenesisBlockIdentifier = ethereum.RopstenGenesisBlockIdentifier
-		config.Params = params.RopstenChainConfig
-		config.GethArguments = ethereum.RopstenGethArguments
-	case Rinkeby:
-		config.Network = &types.NetworkIdentifier{
-			Blockchain: ethereum.Blockchain,
-			Network:    ethereum.RinkebyNetwork,
-		}
-		config.GenesisBlockIdentifier = ethereum.RinkebyGenesisBlockIdentifier
-		config.Params = params.RinkebyChainConfig
-		config.GethArguments = ethereum.RinkebyGethArguments
-	case Goerli:
-		config.Network = &types.NetworkIdentifier{
-			Blockchain: ethereum.Blockchain,
-			Network:    ethereum.GoerliNetwork,
-		}
-		config.GenesisBlockIdentifier = ethereum.GoerliGenesisBlockIdentifier
-		config.Params = params.GoerliChainConfig
-		config.GethArguments = ethereum.GoerliGethArguments
-	case Testnet:
-		config.Network = &types.NetworkIdentifier{
-			Blockchain: ethereum.Blockchain,
-			Network:    ethereum.DevNetwork,
-		}
-		config.GenesisBlockIdentifier = nil
-		config.Params = params.AllCliqueProtocolChanges
-		config.GethArguments = ethereum.DevGethArguments
-	case "":
+	networkValue := src.get(NetworkEnv)
+	if len(networkValue) == 0 {
 		return nil, errors.New("NETWORK must be populated")
-	default:
-		return nil, fmt.Errorf("%s is not a valid network", networkValue)
 	}
 
-	config.GethURL = DefaultGethURL
-	envGethURL := os.Getenv(GethEnv)
+	var network *NetworkSettings
+	if networkValue == Custom {
+		customNetwork, err := loadCustomNetwork(src)
+		if err != nil {
+			return nil, err
+		}
+
+		network = customNetwork
+	} else {
+		registeredNetwork, ok := lookupNetwork(networkValue)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a valid network", networkValue)
+		}
+
+		network = registeredNetwork
+	}
+
+	config.Network = &types.NetworkIdentifier{
+		Blockchain: ethereum.Blockchain,
+		Network:    network.Network,
+	}
+	config.GenesisBlockIdentifier = network.GenesisBlockIdentifier
+	config.Params = network.Params
+	config.GethArguments = network.GethArguments
+
+	config.DataDir = DataDirectory
+	if envDataDir := src.get(DataDirEnv); len(envDataDir) > 0 {
+		dataDir, err := filepath.Abs(envDataDir)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse DATA_DIR %s", err, envDataDir)
+		}
+		config.DataDir = dataDir
+	}
+
+	if envPruneDepth := src.get(PruneDepthEnv); len(envPruneDepth) > 0 {
+		val, err := strconv.ParseInt(envPruneDepth, 10, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse PRUNE_DEPTH %s", err, envPruneDepth)
+		}
+		config.PruneDepth = val
+	}
+
+	// A local geth instance only needs to keep recent
+	// state when pruning is enabled.
+	if config.PruneDepth > 0 {
+		config.GethArguments = strings.Replace(
+			config.GethArguments,
+			"--gcmode=archive",
+			"--gcmode=full",
+			1,
+		)
+	}
+
+	config.LogLevel = src.get(LogLevelEnv)
+	if _, err := logger.ParseLevel(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse LOG_LEVEL %s", err, config.LogLevel)
+	}
+
+	config.LogFormat = src.get(LogFormatEnv)
+	if err := logger.ValidateFormat(config.LogFormat); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse LOG_FORMAT %s", err, config.LogFormat)
+	}
+
+	config.GethURLs = []string{DefaultGethURL}
+	envGethURL := src.get(GethEnv)
 	if len(envGethURL) > 0 {
 		config.RemoteGeth = true
-		config.GethURL = envGethURL
+		config.GethURLs = splitList(envGethURL)
+		if len(config.GethURLs) == 0 {
+			return nil, fmt.Errorf("unable to parse GETH %s", envGethURL)
+		}
+	}
+
+	config.GethWSURL = src.get(GethWSEnv)
+
+	// There are no arguments to start geth on a custom
+	// network, so it must already be running.
+	if networkValue == Custom && config.Mode.IsOnline() && !config.RemoteGeth {
+		return nil, errors.New("GETH must be populated when NETWORK is CUSTOM")
 	}
 
 	config.SkipGethAdmin = false
-	envSkipGethAdmin := os.Getenv(SkipGethAdminEnv)
+	envSkipGethAdmin := src.get(SkipGethAdminEnv)
 	if len(envSkipGethAdmin) > 0 {
 		val, err := strconv.ParseBool(envSkipGethAdmin)
 		if err != nil {
@@ -187,11 +1065,391 @@ func LoadConfiguration() (*Configuration, error) {
 		config.SkipGethAdmin = val
 	}
 
-	portValue := os.Getenv(PortEnv)
+	config.GethCACert = src.get(GethCACertEnv)
+	if len(config.GethCACert) > 0 {
+		if _, err := ethereum.LoadCertPool(config.GethCACert); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse GETH_CA_CERT %s", err, config.GethCACert)
+		}
+	}
+
+	envGethTLSInsecure := src.get(GethTLSInsecureEnv)
+	if len(envGethTLSInsecure) > 0 {
+		val, err := strconv.ParseBool(envGethTLSInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse GETH_TLS_INSECURE %s", err, envGethTLSInsecure)
+		}
+		config.GethTLSInsecure = val
+	}
+
+	header, err := loadGethHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	config.GethHeader = header
+
+	envRPCTimeout := src.get(RPCTimeoutEnv)
+	if len(envRPCTimeout) > 0 {
+		val, err := time.ParseDuration(envRPCTimeout)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse RPC_TIMEOUT %s", err, envRPCTimeout)
+		}
+		config.RPCTimeout = val
+	}
+
+	envRPCRetries := src.get(RPCRetriesEnv)
+	if len(envRPCRetries) > 0 {
+		val, err := strconv.Atoi(envRPCRetries)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse RPC_RETRIES %s", err, envRPCRetries)
+		}
+		config.RPCRetries = val
+	}
+
+	envRPCBackoff := src.get(RPCBackoffEnv)
+	if len(envRPCBackoff) > 0 {
+		val, err := time.ParseDuration(envRPCBackoff)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse RPC_BACKOFF %s", err, envRPCBackoff)
+		}
+		config.RPCBackoff = val
+	}
+
+	envRPCMaxIdleConns := src.get(RPCMaxIdleConnsEnv)
+	if len(envRPCMaxIdleConns) > 0 {
+		val, err := strconv.Atoi(envRPCMaxIdleConns)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse RPC_MAX_IDLE_CONNS %s", err, envRPCMaxIdleConns)
+		}
+		config.RPCMaxIdleConns = val
+	}
+
+	envRPCMaxConnsPerHost := src.get(RPCMaxConnsPerHostEnv)
+	if len(envRPCMaxConnsPerHost) > 0 {
+		val, err := strconv.Atoi(envRPCMaxConnsPerHost)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse RPC_MAX_CONNS_PER_HOST %s", err, envRPCMaxConnsPerHost)
+		}
+		config.RPCMaxConnsPerHost = val
+	}
+
+	envBreakerThreshold := src.get(BreakerThresholdEnv)
+	if len(envBreakerThreshold) > 0 {
+		val, err := strconv.Atoi(envBreakerThreshold)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse BREAKER_THRESHOLD %s", err, envBreakerThreshold)
+		}
+		config.BreakerThreshold = val
+	}
+
+	envBreakerCooldown := src.get(BreakerCooldownEnv)
+	if len(envBreakerCooldown) > 0 {
+		val, err := time.ParseDuration(envBreakerCooldown)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse BREAKER_COOLDOWN %s", err, envBreakerCooldown)
+		}
+		config.BreakerCooldown = val
+	}
+
+	envSyncConcurrency := src.get(SyncConcurrencyEnv)
+	if len(envSyncConcurrency) > 0 {
+		val, err := strconv.ParseInt(envSyncConcurrency, 10, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse SYNC_CONCURRENCY %s", err, envSyncConcurrency)
+		}
+		config.SyncConcurrency = val
+	}
+
+	envBlockBatchSize := src.get(BlockBatchSizeEnv)
+	if len(envBlockBatchSize) > 0 {
+		val, err := strconv.Atoi(envBlockBatchSize)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse BLOCK_BATCH_SIZE %s", err, envBlockBatchSize)
+		}
+		config.BlockBatchSize = val
+	}
+
+	if envTraceMode := src.get(TraceModeEnv); len(envTraceMode) > 0 {
+		config.TraceMode = ethereum.TraceMode(strings.ToLower(envTraceMode))
+		if err := ethereum.ValidateTraceMode(config.TraceMode); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse TRACE_MODE %s", err, envTraceMode)
+		}
+	}
+
+	envTraceTimeout := src.get(TraceTimeoutEnv)
+	if len(envTraceTimeout) > 0 {
+		val, err := time.ParseDuration(envTraceTimeout)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse TRACE_TIMEOUT %s", err, envTraceTimeout)
+		}
+		config.TraceTimeout = val
+	}
+
+	envTraceCacheSize := src.get(TraceCacheSizeEnv)
+	if len(envTraceCacheSize) > 0 {
+		val, err := strconv.Atoi(envTraceCacheSize)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse TRACE_CACHE_SIZE %s", err, envTraceCacheSize)
+		}
+		config.TraceCacheSize = val
+	}
+
+	envMempoolTTL := src.get(MempoolTTLEnv)
+	if len(envMempoolTTL) > 0 {
+		val, err := time.ParseDuration(envMempoolTTL)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse MEMPOOL_TTL %s", err, envMempoolTTL)
+		}
+		config.MempoolTTL = val
+	}
+
+	envGenesisBalances := src.get(GenesisBalancesEnv)
+	if len(envGenesisBalances) > 0 {
+		val, err := strconv.ParseBool(envGenesisBalances)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse GENESIS_BALANCES %s", err, envGenesisBalances)
+		}
+		config.GenesisBalances = val
+	}
+
+	envBlockEvents := src.get(BlockEventsEnv)
+	if len(envBlockEvents) > 0 {
+		val, err := strconv.ParseBool(envBlockEvents)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse BLOCK_EVENTS %s", err, envBlockEvents)
+		}
+		config.BlockEvents = val
+	}
+
+	envTransactionIndex := src.get(TransactionIndexEnv)
+	if len(envTransactionIndex) > 0 {
+		val, err := strconv.ParseBool(envTransactionIndex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse TRANSACTION_INDEX %s", err, envTransactionIndex)
+		}
+		config.TransactionIndex = val
+	}
+
+	envEventRetention := src.get(EventRetentionEnv)
+	if len(envEventRetention) > 0 {
+		val, err := time.ParseDuration(envEventRetention)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse EVENT_RETENTION %s", err, envEventRetention)
+		}
+		config.EventRetention = val
+	}
+
+	envTransactionRetention := src.get(TransactionRetentionEnv)
+	if len(envTransactionRetention) > 0 {
+		val, err := strconv.ParseInt(envTransactionRetention, 10, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("%w: unable to parse TRANSACTION_RETENTION %s", err, envTransactionRetention)
+		}
+		config.TransactionRetention = val
+	}
+
+	envReadyMaxLag := src.get(ReadyMaxLagEnv)
+	if len(envReadyMaxLag) > 0 {
+		val, err := strconv.ParseInt(envReadyMaxLag, 10, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse READY_MAX_LAG %s", err, envReadyMaxLag)
+		}
+		config.ReadyMaxLag = val
+	}
+
+	envSlowRequestThreshold := src.get(SlowRequestThresholdEnv)
+	if len(envSlowRequestThreshold) > 0 {
+		val, err := time.ParseDuration(envSlowRequestThreshold)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse SLOW_REQUEST_THRESHOLD %s", err, envSlowRequestThreshold)
+		}
+		config.SlowRequestThreshold = val
+	}
+
+	envSlowRPCThreshold := src.get(SlowRPCThresholdEnv)
+	if len(envSlowRPCThreshold) > 0 {
+		val, err := time.ParseDuration(envSlowRPCThreshold)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse SLOW_RPC_THRESHOLD %s", err, envSlowRPCThreshold)
+		}
+		config.SlowRPCThreshold = val
+	}
+
+	envShutdownTimeout := src.get(ShutdownTimeoutEnv)
+	if len(envShutdownTimeout) > 0 {
+		val, err := time.ParseDuration(envShutdownTimeout)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse SHUTDOWN_TIMEOUT %s", err, envShutdownTimeout)
+		}
+		config.ShutdownTimeout = val
+	}
+
+	envMaxRequestSize := src.get(MaxRequestSizeEnv)
+	if len(envMaxRequestSize) > 0 {
+		val, err := strconv.ParseInt(envMaxRequestSize, 10, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse MAX_REQUEST_SIZE %s", err, envMaxRequestSize)
+		}
+		config.MaxRequestSize = val
+	}
+
+	envMaxConstructionItems := src.get(MaxConstructionItemsEnv)
+	if len(envMaxConstructionItems) > 0 {
+		val, err := strconv.Atoi(envMaxConstructionItems)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse MAX_CONSTRUCTION_ITEMS %s", err, envMaxConstructionItems)
+		}
+		config.MaxConstructionItems = val
+	}
+
+	envResponseCacheSize := src.get(ResponseCacheSizeEnv)
+	if len(envResponseCacheSize) > 0 {
+		val, err := strconv.Atoi(envResponseCacheSize)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse RESPONSE_CACHE_SIZE %s", err, envResponseCacheSize)
+		}
+		config.ResponseCacheSize = val
+	}
+
+	envResponseCacheRedis := src.get(ResponseCacheRedisEnv)
+	if len(envResponseCacheRedis) > 0 {
+		if _, err := cache.ParseRedisURL(envResponseCacheRedis); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse RESPONSE_CACHE_REDIS", err)
+		}
+		config.ResponseCacheRedis = envResponseCacheRedis
+	}
+
+	envResponseCacheDepth := src.get(ResponseCacheDepthEnv)
+	if len(envResponseCacheDepth) > 0 {
+		val, err := strconv.Atoi(envResponseCacheDepth)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("%w: unable to parse RESPONSE_CACHE_DEPTH %s", err, envResponseCacheDepth)
+		}
+		config.ResponseCacheDepth = val
+	}
+
+	rateLimit, err := loadRateLimit(src)
+	if err != nil {
+		return nil, err
+	}
+	config.RateLimit = rateLimit
+
+	// The index of INDEXER mode holds both.
+	if config.Mode == Indexer {
+		config.BlockEvents = true
+		config.TransactionIndex = true
+	}
+
+	offlineFees, err := loadOfflineFees(src)
+	if err != nil {
+		return nil, err
+	}
+	config.OfflineFees = offlineFees
+
+	if envBatchContract := src.get(BatchContractEnv); len(envBatchContract) > 0 {
+		batchContract, ok := ethereum.ChecksumAddress(envBatchContract)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse BATCH_CONTRACT %s", envBatchContract)
+		}
+		config.BatchContract = batchContract
+	}
+
+	if envTokenWhitelist := src.get(TokenWhitelistEnv); len(envTokenWhitelist) > 0 {
+		tokenWhitelist, err := loadTokenWhitelist(envTokenWhitelist)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse TOKEN_WHITELIST %s", err, envTokenWhitelist)
+		}
+		config.TokenWhitelist = tokenWhitelist
+	}
+
+	if envBalanceExemptions := src.get(BalanceExemptionsEnv); len(envBalanceExemptions) > 0 {
+		balanceExemptions, err := loadBalanceExemptions(envBalanceExemptions)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse BALANCE_EXEMPTIONS %s", err, envBalanceExemptions)
+		}
+		config.BalanceExemptions = balanceExemptions
+	}
+
+	envGasLimitMultiplier := src.get(GasLimitMultiplierEnv)
+	if len(envGasLimitMultiplier) > 0 {
+		val, err := strconv.ParseFloat(envGasLimitMultiplier, 64)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("%w: unable to parse GAS_LIMIT_MULTIPLIER %s", err, envGasLimitMultiplier)
+		}
+		config.GasLimitMultiplier = val
+	}
+
+	envMaxGasLimit := src.get(MaxGasLimitEnv)
+	if len(envMaxGasLimit) > 0 {
+		val, err := strconv.ParseUint(envMaxGasLimit, 10, 64)
+		if err != nil || val == 0 {
+			return nil, fmt.Errorf("%w: unable to parse MAX_GAS_LIMIT %s", err, envMaxGasLimit)
+		}
+		config.MaxGasLimit = val
+	}
+
+	if envMaxFeeCap := src.get(MaxFeeCapEnv); len(envMaxFeeCap) > 0 {
+		val, ok := new(big.Int).SetString(envMaxFeeCap, 10) // nolint:gomnd
+		if !ok || val.Sign() <= 0 {
+			return nil, fmt.Errorf("unable to parse MAX_FEE_CAP %s", envMaxFeeCap)
+		}
+		config.MaxFeeCap = val
+	}
+
+	config.MetricsAddr = src.get(MetricsAddrEnv)
+	if len(config.MetricsAddr) > 0 {
+		if _, _, err := net.SplitHostPort(config.MetricsAddr); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse METRICS_ADDR %s", err, config.MetricsAddr)
+		}
+	}
+
+	config.DiagnosticsAddr = src.get(DiagnosticsAddrEnv)
+	if len(config.DiagnosticsAddr) > 0 {
+		host, _, err := net.SplitHostPort(config.DiagnosticsAddr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse DIAGNOSTICS_ADDR %s", err, config.DiagnosticsAddr)
+		}
+
+		// Profiles expose the memory of the process.
+		if !diagnostics.IsLoopback(host) {
+			return nil, fmt.Errorf("DIAGNOSTICS_ADDR %s is not a loopback address", config.DiagnosticsAddr)
+		}
+	}
+
+	config.OTLPEndpoint = src.get(OTLPEndpointEnv)
+	if len(config.OTLPEndpoint) > 0 {
+		u, err := url.Parse(config.OTLPEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse OTEL_EXPORTER_OTLP_ENDPOINT %s", err, config.OTLPEndpoint)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("unable to parse OTEL_EXPORTER_OTLP_ENDPOINT %s", config.OTLPEndpoint)
+		}
+	}
+
+	config.ListenAddr = src.get(ListenAddrEnv)
+	if len(config.ListenAddr) > 0 {
+		if _, _, err := net.SplitHostPort(config.ListenAddr); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse LISTEN_ADDR %s", err, config.ListenAddr)
+		}
+	}
+
+	config.ListenSocket = src.get(ListenSocketEnv)
+
+	portValue := src.get(PortEnv)
 	if len(portValue) == 0 {
+		if len(config.ListenAddr) > 0 || len(config.ListenSocket) > 0 {
+			return config, nil
+		}
+
 		return nil, errors.New("PORT must be populated")
 	}
 
+	if strings.EqualFold(portValue, AutoPort) {
+		config.AutoPort = true
+		return config, nil
+	}
+
 	port, err := strconv.Atoi(portValue)
 	if err != nil || len(portValue) == 0 || port <= 0 {
 		return nil, fmt.Errorf("%w: unable to parse port %s", err, portValue)
@@ -16,10 +16,17 @@ package configuration
 
 import (
 	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/ratelimit"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/ethereum/go-ethereum/params"
@@ -32,7 +39,30 @@ func TestLoadConfiguration(t *testing.T) {
 		Network       string
 		Port          string
 		Geth          string
+		GethWS        string
+		DataDir       string
+		PruneDepth    string
 		SkipGethAdmin string
+		RPCTimeout    string
+		RPCRetries    string
+		RPCBackoff    string
+		ListenAddr    string
+		ListenSocket  string
+		MetricsAddr   string
+		OTLPEndpoint  string
+		Diagnostics   string
+		LogLevel      string
+		LogFormat     string
+		CACert        string
+		TLSInsecure   string
+		AuthHeader    string
+		BearerToken   string
+		Concurrency   string
+		BatchSize     string
+		TraceMode     string
+		TraceTimeout  string
+		TraceCache    string
+		Genesis       string
 
 		cfg *Configuration
 		err error
@@ -63,7 +93,8 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.MainnetChainConfig,
 				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
 				Port:                   1000,
-				GethURL:                DefaultGethURL,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
 				GethArguments:          ethereum.MainnetGethArguments,
 				SkipGethAdmin:          false,
 			},
@@ -83,12 +114,489 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.MainnetChainConfig,
 				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
 				Port:                   1000,
-				GethURL:                "http://blah",
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
 				RemoteGeth:             true,
 				GethArguments:          ethereum.MainnetGethArguments,
 				SkipGethAdmin:          true,
 			},
 		},
+		"all set (mainnet) + geth ws": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			Geth:    "http://blah",
+			GethWS:  "ws://blah:8546",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
+				GethWSURL:              "ws://blah:8546",
+				RemoteGeth:             true,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"all set (mainnet) + data dir": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			DataDir: "/var/lib/rosetta",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                "/var/lib/rosetta",
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"all set (mainnet) + prune depth": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "1000",
+			PruneDepth: "128",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				PruneDepth:             128,
+				GethArguments:          "--config=/app/ethereum/geth.toml --gcmode=full --graphql",
+			},
+		},
+		"invalid prune depth": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "1000",
+			PruneDepth: "-1",
+			err:        errors.New("unable to parse PRUNE_DEPTH -1"),
+		},
+		"all set (mainnet) + auto port": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "auto",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				AutoPort:               true,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"all set (mainnet) + logging": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			LogLevel:  "debug",
+			LogFormat: "json",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				LogLevel:               "debug",
+				LogFormat:              "json",
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"all set (mainnet) + tls insecure": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Geth:        "https://blah",
+			TLSInsecure: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"https://blah"},
+				RemoteGeth:             true,
+				GethTLSInsecure:        true,
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"invalid tls insecure": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			TLSInsecure: "maybe",
+			err:         errors.New("unable to parse GETH_TLS_INSECURE maybe"),
+		},
+		"missing ca cert": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			CACert:  "/does/not/exist.pem",
+			err:     errors.New("unable to parse GETH_CA_CERT /does/not/exist.pem"),
+		},
+		"all set (mainnet) + auth": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Geth:        "https://blah",
+			AuthHeader:  "X-Api-Key: secret: with colon",
+			BearerToken: "token",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"https://blah"},
+				RemoteGeth:             true,
+				GethHeader: http.Header{
+					"X-Api-Key":     []string{"secret: with colon"},
+					"Authorization": []string{"Bearer token"},
+				},
+				DataDir:       DataDirectory,
+				GethArguments: ethereum.MainnetGethArguments,
+			},
+		},
+		"invalid auth header": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "1000",
+			AuthHeader: "secret",
+			err:        errors.New("unable to parse GETH_AUTH_HEADER"),
+		},
+		"conflicting auth header": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			AuthHeader:  "Authorization: Basic secret",
+			BearerToken: "token",
+			err:         errors.New("GETH_AUTH_HEADER and GETH_BEARER_TOKEN both set Authorization"),
+		},
+		"invalid log level": {
+			Mode:     string(Online),
+			Network:  Mainnet,
+			Port:     "1000",
+			LogLevel: "loud",
+			err:      errors.New("unable to parse LOG_LEVEL loud"),
+		},
+		"invalid log format": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			LogFormat: "xml",
+			err:       errors.New("unable to parse LOG_FORMAT xml"),
+		},
+		"all set (mainnet) + multiple geth": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			Geth:    "http://blah, http://blah2,",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"http://blah", "http://blah2"},
+				DataDir:                DataDirectory,
+				RemoteGeth:             true,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"all set (mainnet) + rpc policy": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "1000",
+			RPCTimeout: "5m",
+			RPCRetries: "3",
+			RPCBackoff: "500ms",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+				RPCTimeout:             5 * time.Minute,
+				RPCRetries:             3,
+				RPCBackoff:             500 * time.Millisecond,
+			},
+		},
+		"all set (mainnet) + sync tuning": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Concurrency: "4",
+			BatchSize:   "25",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+				SyncConcurrency:        4,
+				BlockBatchSize:         25,
+			},
+		},
+		"all set (mainnet) + tracing": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			TraceMode:    "OFF",
+			TraceTimeout: "30s",
+			TraceCache:   "64",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+				TraceMode:              ethereum.TraceModeOff,
+				TraceTimeout:           30 * time.Second,
+				TraceCacheSize:         64,
+			},
+		},
+		"invalid trace mode": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			TraceMode: "prestate",
+			err:       errors.New("unable to parse TRACE_MODE prestate"),
+		},
+		"invalid trace timeout": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			TraceTimeout: "-1s",
+			err:          errors.New("unable to parse TRACE_TIMEOUT -1s"),
+		},
+		"invalid trace cache size": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "1000",
+			TraceCache: "-1",
+			err:        errors.New("unable to parse TRACE_CACHE_SIZE -1"),
+		},
+		"all set (mainnet) + genesis balances": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			Genesis: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+				GenesisBalances:        true,
+			},
+		},
+		"invalid genesis balances": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			Genesis: "sometimes",
+			err:     errors.New("unable to parse GENESIS_BALANCES sometimes"),
+		},
+		"invalid sync concurrency": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Concurrency: "0",
+			err:         errors.New("unable to parse SYNC_CONCURRENCY 0"),
+		},
+		"invalid block batch size": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			BatchSize: "many",
+			err:       errors.New("unable to parse BLOCK_BATCH_SIZE many"),
+		},
+		"listen addr without port": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			ListenAddr: "127.0.0.1:8080",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				ListenAddr:             "127.0.0.1:8080",
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"listen socket and port": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			ListenSocket: "/tmp/rosetta.sock",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				ListenSocket:           "/tmp/rosetta.sock",
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"metrics addr": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			MetricsAddr: ":9090",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				MetricsAddr:            ":9090",
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"invalid metrics addr": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			MetricsAddr: "9090",
+			err:         errors.New("unable to parse METRICS_ADDR 9090"),
+		},
+		"otlp endpoint": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			OTLPEndpoint: "http://localhost:4318",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				OTLPEndpoint:           "http://localhost:4318",
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"diagnostics addr": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Diagnostics: "127.0.0.1:6060",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				DiagnosticsAddr:        "127.0.0.1:6060",
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.MainnetGethArguments,
+			},
+		},
+		"public diagnostics addr": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Diagnostics: ":6060",
+			err:         errors.New("DIAGNOSTICS_ADDR :6060 is not a loopback address"),
+		},
+		"invalid otlp endpoint": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			OTLPEndpoint: "localhost:4318",
+			err:          errors.New("unable to parse OTEL_EXPORTER_OTLP_ENDPOINT localhost:4318"),
+		},
+		"invalid listen addr": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			ListenAddr: "localhost",
+			err:        errors.New("unable to parse LISTEN_ADDR localhost"),
+		},
 		"all set (ropsten)": {
 			Mode:    string(Online),
 			Network: Ropsten,
@@ -102,7 +610,8 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.RopstenChainConfig,
 				GenesisBlockIdentifier: ethereum.RopstenGenesisBlockIdentifier,
 				Port:                   1000,
-				GethURL:                DefaultGethURL,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
 				GethArguments:          ethereum.RopstenGethArguments,
 			},
 		},
@@ -119,7 +628,8 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.RinkebyChainConfig,
 				GenesisBlockIdentifier: ethereum.RinkebyGenesisBlockIdentifier,
 				Port:                   1000,
-				GethURL:                DefaultGethURL,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
 				GethArguments:          ethereum.RinkebyGethArguments,
 			},
 		},
@@ -136,10 +646,29 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.GoerliChainConfig,
 				GenesisBlockIdentifier: ethereum.GoerliGenesisBlockIdentifier,
 				Port:                   1000,
-				GethURL:                DefaultGethURL,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
 				GethArguments:          ethereum.GoerliGethArguments,
 			},
 		},
+		"all set (sepolia)": {
+			Mode:    string(Online),
+			Network: Sepolia,
+			Port:    "1000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.SepoliaNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.SepoliaChainConfig,
+				GenesisBlockIdentifier: ethereum.SepoliaGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
+				GethArguments:          ethereum.SepoliaGethArguments,
+			},
+		},
 		"all set (testnet)": {
 			Mode:          string(Online),
 			Network:       Testnet,
@@ -154,7 +683,8 @@ func TestLoadConfiguration(t *testing.T) {
 				Params:                 params.AllCliqueProtocolChanges,
 				GenesisBlockIdentifier: nil,
 				Port:                   1000,
-				GethURL:                DefaultGethURL,
+				GethURLs:               []string{DefaultGethURL},
+				DataDir:                DataDirectory,
 				GethArguments:          ethereum.DevGethArguments,
 				SkipGethAdmin:          true,
 			},
@@ -177,6 +707,27 @@ func TestLoadConfiguration(t *testing.T) {
 			Port:    "bad port",
 			err:     errors.New("unable to parse port bad port"),
 		},
+		"invalid rpc timeout": {
+			Mode:       string(Offline),
+			Network:    Ropsten,
+			Port:       "1000",
+			RPCTimeout: "10",
+			err:        errors.New("unable to parse RPC_TIMEOUT 10"),
+		},
+		"invalid rpc retries": {
+			Mode:       string(Offline),
+			Network:    Ropsten,
+			Port:       "1000",
+			RPCRetries: "-1",
+			err:        errors.New("unable to parse RPC_RETRIES -1"),
+		},
+		"invalid rpc backoff": {
+			Mode:       string(Offline),
+			Network:    Ropsten,
+			Port:       "1000",
+			RPCBackoff: "soon",
+			err:        errors.New("unable to parse RPC_BACKOFF soon"),
+		},
 	}
 
 	for name, test := range tests {
@@ -185,9 +736,32 @@ func TestLoadConfiguration(t *testing.T) {
 			os.Setenv(NetworkEnv, test.Network)
 			os.Setenv(PortEnv, test.Port)
 			os.Setenv(GethEnv, test.Geth)
+			os.Setenv(GethWSEnv, test.GethWS)
+			os.Setenv(DataDirEnv, test.DataDir)
+			os.Setenv(PruneDepthEnv, test.PruneDepth)
 			os.Setenv(SkipGethAdminEnv, test.SkipGethAdmin)
+			os.Setenv(RPCTimeoutEnv, test.RPCTimeout)
+			os.Setenv(RPCRetriesEnv, test.RPCRetries)
+			os.Setenv(RPCBackoffEnv, test.RPCBackoff)
+			os.Setenv(ListenAddrEnv, test.ListenAddr)
+			os.Setenv(ListenSocketEnv, test.ListenSocket)
+			os.Setenv(MetricsAddrEnv, test.MetricsAddr)
+			os.Setenv(OTLPEndpointEnv, test.OTLPEndpoint)
+			os.Setenv(DiagnosticsAddrEnv, test.Diagnostics)
+			os.Setenv(LogLevelEnv, test.LogLevel)
+			os.Setenv(LogFormatEnv, test.LogFormat)
+			os.Setenv(GethCACertEnv, test.CACert)
+			os.Setenv(GethTLSInsecureEnv, test.TLSInsecure)
+			os.Setenv(GethAuthHeaderEnv, test.AuthHeader)
+			os.Setenv(GethBearerTokenEnv, test.BearerToken)
+			os.Setenv(SyncConcurrencyEnv, test.Concurrency)
+			os.Setenv(BlockBatchSizeEnv, test.BatchSize)
+			os.Setenv(TraceModeEnv, test.TraceMode)
+			os.Setenv(TraceTimeoutEnv, test.TraceTimeout)
+			os.Setenv(TraceCacheSizeEnv, test.TraceCache)
+			os.Setenv(GenesisBalancesEnv, test.Genesis)
 
-			cfg, err := LoadConfiguration()
+			cfg, err := LoadConfiguration(nil)
 			if test.err != nil {
 				assert.Nil(t, cfg)
 				assert.Contains(t, err.Error(), test.err.Error())
@@ -198,3 +772,904 @@ func TestLoadConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterNetwork(t *testing.T) {
+	settings := &NetworkSettings{
+		Network:                "Custom",
+		GenesisBlockIdentifier: ethereum.GoerliGenesisBlockIdentifier,
+		Params:                 params.GoerliChainConfig,
+		GethArguments:          ethereum.GoerliGethArguments,
+	}
+	assert.NoError(t, RegisterNetwork("REGISTERED", settings))
+	defer delete(networks, "REGISTERED")
+	assert.EqualError(
+		t,
+		RegisterNetwork("REGISTERED", settings),
+		"network REGISTERED is already registered",
+	)
+	assert.EqualError(
+		t,
+		RegisterNetwork(Mainnet, settings),
+		"network MAINNET is already registered",
+	)
+	assert.EqualError(t, RegisterNetwork("", settings), "network name must be populated")
+	assert.Error(t, RegisterNetwork("EMPTY", &NetworkSettings{}))
+
+	os.Setenv(ModeEnv, string(Online))
+	os.Setenv(NetworkEnv, "REGISTERED")
+	os.Setenv(PortEnv, "1000")
+	os.Setenv(GethEnv, "")
+	os.Setenv(GethWSEnv, "")
+	os.Setenv(DataDirEnv, "")
+	os.Setenv(PruneDepthEnv, "")
+	os.Setenv(SkipGethAdminEnv, "")
+	os.Setenv(RPCTimeoutEnv, "")
+	os.Setenv(RPCRetriesEnv, "")
+	os.Setenv(RPCBackoffEnv, "")
+	os.Setenv(ListenAddrEnv, "")
+	os.Setenv(ListenSocketEnv, "")
+	os.Setenv(MetricsAddrEnv, "")
+	os.Setenv(OTLPEndpointEnv, "")
+	os.Setenv(DiagnosticsAddrEnv, "")
+	os.Setenv(LogLevelEnv, "")
+	os.Setenv(LogFormatEnv, "")
+	os.Setenv(GethCACertEnv, "")
+	os.Setenv(GethTLSInsecureEnv, "")
+	os.Setenv(GethAuthHeaderEnv, "")
+	os.Setenv(GethBearerTokenEnv, "")
+	os.Setenv(SyncConcurrencyEnv, "")
+	os.Setenv(BlockBatchSizeEnv, "")
+	os.Setenv(TraceModeEnv, "")
+	os.Setenv(TraceTimeoutEnv, "")
+	os.Setenv(TraceCacheSizeEnv, "")
+	os.Setenv(GenesisBalancesEnv, "")
+	defer os.Setenv(NetworkEnv, "")
+
+	cfg, err := LoadConfiguration(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &Configuration{
+		Mode: Online,
+		Network: &types.NetworkIdentifier{
+			Network:    "Custom",
+			Blockchain: ethereum.Blockchain,
+		},
+		Params:                 params.GoerliChainConfig,
+		GenesisBlockIdentifier: ethereum.GoerliGenesisBlockIdentifier,
+		Port:                   1000,
+		GethURLs:               []string{DefaultGethURL},
+		DataDir:                DataDirectory,
+		GethArguments:          ethereum.GoerliGethArguments,
+	}, cfg)
+}
+
+func TestLoadConfiguration_Custom(t *testing.T) {
+	dir := t.TempDir()
+	chainConfigFile := filepath.Join(dir, "chain.json")
+	assert.NoError(t, ioutil.WriteFile(chainConfigFile, []byte(`{
+	"chainId": 1337,
+	"homesteadBlock": 0,
+	"eip150Block": 0,
+	"eip155Block": 0,
+	"byzantiumBlock": 0
+}`), 0600))
+	badChainConfigFile := filepath.Join(dir, "bad.json")
+	assert.NoError(t, ioutil.WriteFile(badChainConfigFile, []byte(`{"homesteadBlock": 0}`), 0600))
+
+	genesisHash := "0x25a5cc106eea7138acab33231d7160d69cb777ee0c2c553fcddf5138993e6dd9"
+	tests := map[string]struct {
+		Mode        string
+		Geth        string
+		GenesisHash string
+		ChainConfig string
+
+		cfg *Configuration
+		err error
+	}{
+		"online": {
+			Mode:        string(Online),
+			Geth:        "http://blah",
+			GenesisHash: genesisHash,
+			ChainConfig: chainConfigFile,
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.CustomNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params: &params.ChainConfig{
+					ChainID:        big.NewInt(1337),
+					HomesteadBlock: big.NewInt(0),
+					EIP150Block:    big.NewInt(0),
+					EIP155Block:    big.NewInt(0),
+					ByzantiumBlock: big.NewInt(0),
+				},
+				GenesisBlockIdentifier: &types.BlockIdentifier{
+					Hash:  genesisHash,
+					Index: 0,
+				},
+				Port:       1000,
+				GethURLs:   []string{"http://blah"},
+				DataDir:    DataDirectory,
+				RemoteGeth: true,
+			},
+		},
+		"offline": {
+			Mode:        string(Offline),
+			GenesisHash: genesisHash,
+			ChainConfig: chainConfigFile,
+			cfg: &Configuration{
+				Mode: Offline,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.CustomNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params: &params.ChainConfig{
+					ChainID:        big.NewInt(1337),
+					HomesteadBlock: big.NewInt(0),
+					EIP150Block:    big.NewInt(0),
+					EIP155Block:    big.NewInt(0),
+					ByzantiumBlock: big.NewInt(0),
+				},
+				GenesisBlockIdentifier: &types.BlockIdentifier{
+					Hash:  genesisHash,
+					Index: 0,
+				},
+				Port:     1000,
+				GethURLs: []string{DefaultGethURL},
+				DataDir:  DataDirectory,
+			},
+		},
+		"online without geth": {
+			Mode:        string(Online),
+			GenesisHash: genesisHash,
+			ChainConfig: chainConfigFile,
+			err:         errors.New("GETH must be populated when NETWORK is CUSTOM"),
+		},
+		"missing genesis hash": {
+			Mode:        string(Offline),
+			ChainConfig: chainConfigFile,
+			err:         errors.New("CUSTOM_GENESIS_HASH must be populated"),
+		},
+		"invalid genesis hash": {
+			Mode:        string(Offline),
+			GenesisHash: "0x1234",
+			ChainConfig: chainConfigFile,
+			err:         errors.New("unable to parse CUSTOM_GENESIS_HASH 0x1234"),
+		},
+		"missing chain config": {
+			Mode:        string(Offline),
+			GenesisHash: genesisHash,
+			err:         errors.New("CUSTOM_CHAIN_CONFIG must be populated"),
+		},
+		"unreadable chain config": {
+			Mode:        string(Offline),
+			GenesisHash: genesisHash,
+			ChainConfig: filepath.Join(dir, "missing.json"),
+			err:         errors.New("unable to read chain config"),
+		},
+		"chain config without chain id": {
+			Mode:        string(Offline),
+			GenesisHash: genesisHash,
+			ChainConfig: badChainConfigFile,
+			err:         errors.New("must contain chainId"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv(ModeEnv, test.Mode)
+			os.Setenv(NetworkEnv, Custom)
+			os.Setenv(PortEnv, "1000")
+			os.Setenv(GethEnv, test.Geth)
+			os.Setenv(GethWSEnv, "")
+			os.Setenv(DataDirEnv, "")
+			os.Setenv(PruneDepthEnv, "")
+			os.Setenv(SkipGethAdminEnv, "")
+			os.Setenv(RPCTimeoutEnv, "")
+			os.Setenv(RPCRetriesEnv, "")
+			os.Setenv(RPCBackoffEnv, "")
+			os.Setenv(ListenAddrEnv, "")
+			os.Setenv(ListenSocketEnv, "")
+			os.Setenv(MetricsAddrEnv, "")
+			os.Setenv(OTLPEndpointEnv, "")
+			os.Setenv(DiagnosticsAddrEnv, "")
+			os.Setenv(LogLevelEnv, "")
+			os.Setenv(LogFormatEnv, "")
+			os.Setenv(GethCACertEnv, "")
+			os.Setenv(GethTLSInsecureEnv, "")
+			os.Setenv(GethAuthHeaderEnv, "")
+			os.Setenv(GethBearerTokenEnv, "")
+			os.Setenv(SyncConcurrencyEnv, "")
+			os.Setenv(BlockBatchSizeEnv, "")
+			os.Setenv(TraceModeEnv, "")
+			os.Setenv(TraceTimeoutEnv, "")
+			os.Setenv(TraceCacheSizeEnv, "")
+			os.Setenv(GenesisBalancesEnv, "")
+			os.Setenv(CustomGenesisHashEnv, test.GenesisHash)
+			os.Setenv(CustomChainConfigEnv, test.ChainConfig)
+			defer os.Setenv(NetworkEnv, "")
+
+			cfg, err := LoadConfiguration(nil)
+			if test.err != nil {
+				assert.Nil(t, cfg)
+				assert.Contains(t, err.Error(), test.err.Error())
+			} else {
+				assert.Equal(t, test.cfg, cfg)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfiguration_File(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(`
+mode: ONLINE
+network: MAINNET
+port: 1000
+geth: http://blah
+skip_geth_admin: true
+`), 0600))
+
+	tests := map[string]struct {
+		Port       string
+		ConfigFile string
+		Overrides  map[string]string
+
+		cfg *Configuration
+		err error
+	}{
+		"file only": {
+			ConfigFile: configFile,
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
+				RemoteGeth:             true,
+				GethArguments:          ethereum.MainnetGethArguments,
+				SkipGethAdmin:          true,
+			},
+		},
+		"env overrides file": {
+			Port:       "2000",
+			ConfigFile: configFile,
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   2000,
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
+				RemoteGeth:             true,
+				GethArguments:          ethereum.MainnetGethArguments,
+				SkipGethAdmin:          true,
+			},
+		},
+		"overrides take precedence": {
+			Port:       "2000",
+			ConfigFile: configFile,
+			Overrides: map[string]string{
+				PortEnv:    "3000",
+				NetworkEnv: Goerli,
+			},
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.GoerliNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.GoerliChainConfig,
+				GenesisBlockIdentifier: ethereum.GoerliGenesisBlockIdentifier,
+				Port:                   3000,
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
+				RemoteGeth:             true,
+				GethArguments:          ethereum.GoerliGethArguments,
+				SkipGethAdmin:          true,
+			},
+		},
+		"config file override": {
+			Overrides: map[string]string{
+				ConfigFileEnv: configFile,
+			},
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    ethereum.MainnetNetwork,
+					Blockchain: ethereum.Blockchain,
+				},
+				Params:                 params.MainnetChainConfig,
+				GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				GethURLs:               []string{"http://blah"},
+				DataDir:                DataDirectory,
+				RemoteGeth:             true,
+				GethArguments:          ethereum.MainnetGethArguments,
+				SkipGethAdmin:          true,
+			},
+		},
+		"missing file": {
+			ConfigFile: filepath.Join(dir, "missing.yaml"),
+			err:        errors.New("unable to read configuration file"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv(ModeEnv, "")
+			os.Setenv(NetworkEnv, "")
+			os.Setenv(PortEnv, test.Port)
+			os.Setenv(GethEnv, "")
+			os.Setenv(GethWSEnv, "")
+			os.Setenv(DataDirEnv, "")
+			os.Setenv(PruneDepthEnv, "")
+			os.Setenv(SkipGethAdminEnv, "")
+			os.Setenv(RPCTimeoutEnv, "")
+			os.Setenv(RPCRetriesEnv, "")
+			os.Setenv(RPCBackoffEnv, "")
+			os.Setenv(ListenAddrEnv, "")
+			os.Setenv(ListenSocketEnv, "")
+			os.Setenv(MetricsAddrEnv, "")
+			os.Setenv(OTLPEndpointEnv, "")
+			os.Setenv(DiagnosticsAddrEnv, "")
+			os.Setenv(LogLevelEnv, "")
+			os.Setenv(LogFormatEnv, "")
+			os.Setenv(GethCACertEnv, "")
+			os.Setenv(GethTLSInsecureEnv, "")
+			os.Setenv(GethAuthHeaderEnv, "")
+			os.Setenv(GethBearerTokenEnv, "")
+			os.Setenv(SyncConcurrencyEnv, "")
+			os.Setenv(BlockBatchSizeEnv, "")
+			os.Setenv(TraceModeEnv, "")
+			os.Setenv(TraceTimeoutEnv, "")
+			os.Setenv(TraceCacheSizeEnv, "")
+			os.Setenv(GenesisBalancesEnv, "")
+			os.Setenv(ConfigFileEnv, test.ConfigFile)
+			defer os.Setenv(ConfigFileEnv, "")
+
+			cfg, err := LoadConfiguration(test.Overrides)
+			if test.err != nil {
+				assert.Nil(t, cfg)
+				assert.Contains(t, err.Error(), test.err.Error())
+			} else {
+				assert.Equal(t, test.cfg, cfg)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfiguration_OfflineFees(t *testing.T) {
+	tests := map[string]struct {
+		Overrides map[string]string
+
+		fees *ethereum.Fees
+		err  error
+	}{
+		"none": {},
+		"gas price": {
+			Overrides: map[string]string{
+				OfflineGasPriceEnv: "1000000000",
+			},
+			fees: &ethereum.Fees{
+				GasPrice: big.NewInt(1000000000),
+			},
+		},
+		"fee caps": {
+			Overrides: map[string]string{
+				OfflineMaxFeePerGasEnv:         "50000000000",
+				OfflineMaxPriorityFeePerGasEnv: "2000000000",
+			},
+			fees: &ethereum.Fees{
+				GasTipCap: big.NewInt(2000000000),
+				GasFeeCap: big.NewInt(50000000000),
+			},
+		},
+		"invalid gas price": {
+			Overrides: map[string]string{
+				OfflineGasPriceEnv: "0x10",
+			},
+			err: errors.New("unable to parse OFFLINE_GAS_PRICE 0x10"),
+		},
+		"gas price and fee caps": {
+			Overrides: map[string]string{
+				OfflineGasPriceEnv:             "1000000000",
+				OfflineMaxFeePerGasEnv:         "50000000000",
+				OfflineMaxPriorityFeePerGasEnv: "2000000000",
+			},
+			err: errors.New("OFFLINE_GAS_PRICE cannot be combined"),
+		},
+		"only fee cap": {
+			Overrides: map[string]string{
+				OfflineMaxFeePerGasEnv: "50000000000",
+			},
+			err: errors.New("must be set together"),
+		},
+		"tip cap above fee cap": {
+			Overrides: map[string]string{
+				OfflineMaxFeePerGasEnv:         "1000000000",
+				OfflineMaxPriorityFeePerGasEnv: "2000000000",
+			},
+			err: errors.New("OFFLINE_MAX_PRIORITY_FEE_PER_GAS exceeds OFFLINE_MAX_FEE_PER_GAS"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			overrides := map[string]string{
+				ModeEnv:    string(Offline),
+				NetworkEnv: Mainnet,
+				PortEnv:    "1000",
+			}
+			for key, value := range test.Overrides {
+				overrides[key] = value
+			}
+
+			cfg, err := LoadConfiguration(overrides)
+			if test.err != nil {
+				assert.Nil(t, cfg)
+				assert.Contains(t, err.Error(), test.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.fees, cfg.OfflineFees)
+			}
+		})
+	}
+}
+
+func TestLoadConfiguration_BatchContract(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:          string(Offline),
+		NetworkEnv:       Mainnet,
+		PortEnv:          "1000",
+		BatchContractEnv: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", cfg.BatchContract)
+
+	overrides[BatchContractEnv] = "0x1234"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse BATCH_CONTRACT 0x1234")
+}
+
+func TestLoadConfiguration_FeeLimits(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:               string(Offline),
+		NetworkEnv:            Mainnet,
+		PortEnv:               "1000",
+		GasLimitMultiplierEnv: "1.2",
+		MaxGasLimitEnv:        "500000",
+		MaxFeeCapEnv:          "500000000000",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.2, cfg.GasLimitMultiplier)
+	assert.Equal(t, uint64(500000), cfg.MaxGasLimit)
+	assert.Equal(t, big.NewInt(500000000000), cfg.MaxFeeCap)
+
+	overrides[GasLimitMultiplierEnv] = "0.5"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse GAS_LIMIT_MULTIPLIER 0.5")
+
+	overrides[GasLimitMultiplierEnv] = "1"
+	overrides[MaxGasLimitEnv] = "0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse MAX_GAS_LIMIT 0")
+
+	overrides[MaxGasLimitEnv] = "500000"
+	overrides[MaxFeeCapEnv] = "0x10"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse MAX_FEE_CAP 0x10")
+}
+
+func TestLoadConfiguration_TokenWhitelist(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:           string(Offline),
+		NetworkEnv:        Mainnet,
+		PortEnv:           "1000",
+		TokenWhitelistEnv: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48, 0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		"0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2",
+	}, cfg.TokenWhitelist)
+
+	// The whitelist can be a file
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	assert.NoError(t, ioutil.WriteFile(
+		path,
+		[]byte(`["0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"]`),
+		0600,
+	))
+	overrides[TokenWhitelistEnv] = path
+	cfg, err = LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"}, cfg.TokenWhitelist)
+
+	overrides[TokenWhitelistEnv] = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48,0x1234"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "0x1234 is not a valid address")
+
+	overrides[TokenWhitelistEnv] = filepath.Join(t.TempDir(), "missing.json")
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to read token whitelist")
+}
+
+func TestLoadConfiguration_MempoolTTL(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:       string(Offline),
+		NetworkEnv:    Mainnet,
+		PortEnv:       "1000",
+		MempoolTTLEnv: "30s",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.MempoolTTL)
+
+	overrides[MempoolTTLEnv] = "0s"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse MEMPOOL_TTL 0s")
+}
+
+func TestLoadConfiguration_BalanceExemptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exemptions.json")
+	assert.NoError(t, ioutil.WriteFile(
+		path,
+		[]byte(`[{"currency":{"symbol":"stETH","decimals":18},"exemption_type":"greater_or_equal"}]`),
+		0600,
+	))
+
+	overrides := map[string]string{
+		ModeEnv:              string(Offline),
+		NetworkEnv:           Mainnet,
+		PortEnv:              "1000",
+		BalanceExemptionsEnv: path,
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BalanceExemption{
+		{
+			Currency:      &types.Currency{Symbol: "stETH", Decimals: 18},
+			ExemptionType: types.BalanceGreaterOrEqual,
+		},
+	}, cfg.BalanceExemptions)
+
+	assert.NoError(t, ioutil.WriteFile(
+		path,
+		[]byte(`[{"currency":{"symbol":"stETH","decimals":18},"exemption_type":"sometimes"}]`),
+		0600,
+	))
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "invalid balance exemptions")
+
+	overrides[BalanceExemptionsEnv] = filepath.Join(t.TempDir(), "missing.json")
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to read balance exemptions")
+}
+
+func TestLoadConfiguration_BlockEvents(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:        string(Offline),
+		NetworkEnv:     Mainnet,
+		PortEnv:        "1000",
+		BlockEventsEnv: "true",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.True(t, cfg.BlockEvents)
+
+	overrides[BlockEventsEnv] = "sometimes"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse BLOCK_EVENTS sometimes")
+}
+
+func TestLoadConfiguration_TransactionIndex(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:             string(Offline),
+		NetworkEnv:          Mainnet,
+		PortEnv:             "1000",
+		TransactionIndexEnv: "true",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.True(t, cfg.TransactionIndex)
+
+	overrides[TransactionIndexEnv] = "sometimes"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse TRANSACTION_INDEX sometimes")
+}
+
+func TestLoadConfiguration_Retention(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:                 string(Offline),
+		NetworkEnv:              Mainnet,
+		PortEnv:                 "1000",
+		EventRetentionEnv:       "720h",
+		TransactionRetentionEnv: "100000",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, cfg.EventRetention)
+	assert.Equal(t, int64(100000), cfg.TransactionRetention)
+
+	overrides[EventRetentionEnv] = "-1h"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse EVENT_RETENTION -1h")
+
+	overrides[EventRetentionEnv] = "720h"
+	overrides[TransactionRetentionEnv] = "recent"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse TRANSACTION_RETENTION recent")
+}
+
+func TestLoadConfiguration_Indexer(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:    string(Indexer),
+		NetworkEnv: Mainnet,
+		PortEnv:    "1000",
+		GethEnv:    "http://localhost:8545",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, Indexer, cfg.Mode)
+	assert.True(t, cfg.Mode.IsOnline())
+
+	// The index of INDEXER mode holds block
+	// events and transactions.
+	assert.True(t, cfg.BlockEvents)
+	assert.True(t, cfg.TransactionIndex)
+
+	assert.True(t, Online.IsOnline())
+	assert.False(t, Offline.IsOnline())
+}
+
+func TestLoadConfiguration_ReadyMaxLag(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:        string(Offline),
+		NetworkEnv:     Mainnet,
+		PortEnv:        "1000",
+		ReadyMaxLagEnv: "64",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(64), cfg.ReadyMaxLag)
+
+	overrides[ReadyMaxLagEnv] = "0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse READY_MAX_LAG 0")
+}
+
+func TestLoadConfiguration_ShutdownTimeout(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:            string(Offline),
+		NetworkEnv:         Mainnet,
+		PortEnv:            "1000",
+		ShutdownTimeoutEnv: "45s",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 45*time.Second, cfg.ShutdownTimeout)
+
+	overrides[ShutdownTimeoutEnv] = "0s"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse SHUTDOWN_TIMEOUT 0s")
+}
+
+func TestLoadConfiguration_RequestLimits(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:                 string(Offline),
+		NetworkEnv:              Mainnet,
+		PortEnv:                 "1000",
+		MaxRequestSizeEnv:       "65536",
+		MaxConstructionItemsEnv: "100",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(65536), cfg.MaxRequestSize)
+	assert.Equal(t, 100, cfg.MaxConstructionItems)
+
+	overrides[MaxRequestSizeEnv] = "0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse MAX_REQUEST_SIZE 0")
+
+	overrides[MaxRequestSizeEnv] = "65536"
+	overrides[MaxConstructionItemsEnv] = "many"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse MAX_CONSTRUCTION_ITEMS many")
+}
+
+func TestLoadConfiguration_ResponseCache(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:               string(Offline),
+		NetworkEnv:            Mainnet,
+		PortEnv:               "1000",
+		ResponseCacheSizeEnv:  "10000",
+		ResponseCacheRedisEnv: "redis://:secret@cache:6379/1",
+		ResponseCacheDepthEnv: "128",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 10000, cfg.ResponseCacheSize)
+	assert.Equal(t, "redis://:secret@cache:6379/1", cfg.ResponseCacheRedis)
+	assert.Equal(t, 128, cfg.ResponseCacheDepth)
+
+	overrides[ResponseCacheSizeEnv] = "-1"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RESPONSE_CACHE_SIZE -1")
+
+	overrides[ResponseCacheSizeEnv] = "10000"
+	overrides[ResponseCacheRedisEnv] = "cache:6379"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.True(t, errors.Is(err, cache.ErrInvalidRedisURL))
+
+	overrides[ResponseCacheRedisEnv] = "redis://cache"
+	overrides[ResponseCacheDepthEnv] = "0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RESPONSE_CACHE_DEPTH 0")
+}
+
+func TestLoadConfiguration_SlowThresholds(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:                 string(Offline),
+		NetworkEnv:              Mainnet,
+		PortEnv:                 "1000",
+		SlowRequestThresholdEnv: "5s",
+		SlowRPCThresholdEnv:     "1500ms",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.SlowRequestThreshold)
+	assert.Equal(t, 1500*time.Millisecond, cfg.SlowRPCThreshold)
+
+	delete(overrides, SlowRequestThresholdEnv)
+	delete(overrides, SlowRPCThresholdEnv)
+	cfg, err = LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), cfg.SlowRequestThreshold)
+	assert.Equal(t, time.Duration(0), cfg.SlowRPCThreshold)
+
+	overrides[SlowRequestThresholdEnv] = "0s"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse SLOW_REQUEST_THRESHOLD 0s")
+
+	overrides[SlowRequestThresholdEnv] = "5s"
+	overrides[SlowRPCThresholdEnv] = "slow"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse SLOW_RPC_THRESHOLD slow")
+}
+
+func TestLoadConfiguration_Breaker(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:             string(Offline),
+		NetworkEnv:          Mainnet,
+		PortEnv:             "1000",
+		BreakerThresholdEnv: "3",
+		BreakerCooldownEnv:  "10s",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cfg.BreakerThreshold)
+	assert.Equal(t, 10*time.Second, cfg.BreakerCooldown)
+
+	overrides[BreakerThresholdEnv] = "0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse BREAKER_THRESHOLD 0")
+
+	overrides[BreakerThresholdEnv] = "3"
+	overrides[BreakerCooldownEnv] = "-1s"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse BREAKER_COOLDOWN -1s")
+}
+
+func TestLoadConfiguration_RateLimit(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:    string(Offline),
+		NetworkEnv: Mainnet,
+		PortEnv:    "1000",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.RateLimit)
+
+	overrides[RateLimitEnv] = "100"
+	overrides[RateLimitPerClientEnv] = "2.5"
+	overrides[RateLimitEndpointsEnv] = "/block=5, /account/balance=0.5"
+	overrides[RateLimitKeyHeaderEnv] = "X-Api-Key"
+	cfg, err = LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, &ratelimit.Config{
+		Global:    100,
+		PerClient: 2.5,
+		Endpoints: map[string]float64{
+			"/block":           5,
+			"/account/balance": 0.5,
+		},
+		KeyHeader: "X-Api-Key",
+	}, cfg.RateLimit)
+
+	overrides[RateLimitEndpointsEnv] = "/block=0"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RATE_LIMIT_ENDPOINTS /block=0")
+
+	overrides[RateLimitEndpointsEnv] = "block=5"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RATE_LIMIT_ENDPOINTS block=5")
+
+	delete(overrides, RateLimitEndpointsEnv)
+	overrides[RateLimitPerClientEnv] = "-1"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RATE_LIMIT_PER_CLIENT -1")
+
+	delete(overrides, RateLimitEnv)
+	delete(overrides, RateLimitPerClientEnv)
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "RATE_LIMIT_KEY_HEADER is set without any rate limit")
+}
+
+func TestLoadConfiguration_RPCConnections(t *testing.T) {
+	overrides := map[string]string{
+		ModeEnv:               string(Offline),
+		NetworkEnv:            Mainnet,
+		PortEnv:               "1000",
+		RPCMaxIdleConnsEnv:    "200",
+		RPCMaxConnsPerHostEnv: "50",
+	}
+	cfg, err := LoadConfiguration(overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, cfg.RPCMaxIdleConns)
+	assert.Equal(t, 50, cfg.RPCMaxConnsPerHost)
+
+	overrides[RPCMaxIdleConnsEnv] = "-1"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RPC_MAX_IDLE_CONNS -1")
+
+	overrides[RPCMaxIdleConnsEnv] = "200"
+	overrides[RPCMaxConnsPerHostEnv] = "many"
+	cfg, err = LoadConfiguration(overrides)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "unable to parse RPC_MAX_CONNS_PER_HOST many")
+}
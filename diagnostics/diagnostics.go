@@ -0,0 +1,148 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics serves the runtime profiles of the
+// process (net/http/pprof), its exported variables (expvar)
+// and a summary of its goroutines, memory and caches, to
+// diagnose it in place.
+package diagnostics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+)
+
+// Paths of the diagnostics endpoints.
+const (
+	PprofPath   = "/debug/pprof/"
+	VarsPath    = "/debug/vars"
+	RuntimePath = "/debug/runtime"
+)
+
+// IsLoopback returns whether host (of a host:port address)
+// only accepts local connections: `localhost` or a loopback
+// IP. An empty host accepts connections on all interfaces.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Memory is a summary of runtime.MemStats.
+type Memory struct {
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse_bytes"`
+	Sys          uint64 `json:"sys_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+// Runtime is the body of RuntimePath.
+type Runtime struct {
+	GoVersion  string                 `json:"go_version"`
+	NumCPU     int                    `json:"num_cpu"`
+	GOMAXPROCS int                    `json:"gomaxprocs"`
+	Goroutines int                    `json:"goroutines"`
+	Memory     *Memory                `json:"memory"`
+	Caches     map[string]interface{} `json:"caches"`
+}
+
+// Handler serves the diagnostics endpoints.
+type Handler struct {
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	caches map[string]func() interface{}
+}
+
+// NewHandler returns a Handler serving the pprof profiles at
+// PprofPath, the expvar variables at VarsPath and a Runtime
+// summary at RuntimePath.
+func NewHandler() *Handler {
+	h := &Handler{caches: map[string]func() interface{}{}}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc(PprofPath, pprof.Index)
+	h.mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	h.mux.HandleFunc(PprofPath+"profile", pprof.Profile)
+	h.mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	h.mux.HandleFunc(PprofPath+"trace", pprof.Trace)
+	h.mux.Handle(VarsPath, expvar.Handler())
+	h.mux.HandleFunc(RuntimePath, h.serveRuntime)
+
+	return h
+}
+
+// AddCache adds the cache name to the Runtime summary, with
+// the size (or usage statistics) returned by fn.
+func (h *Handler) AddCache(name string, fn func() interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.caches[name] = fn
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// runtime returns the current Runtime summary.
+func (h *Handler) runtime() *Runtime {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	h.mu.Lock()
+	caches := make(map[string]interface{}, len(h.caches))
+	for name, fn := range h.caches {
+		caches[name] = fn()
+	}
+	h.mu.Unlock()
+
+	return &Runtime{
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: &Memory{
+			HeapAlloc:    stats.HeapAlloc,
+			HeapInuse:    stats.HeapInuse,
+			HeapObjects:  stats.HeapObjects,
+			StackInuse:   stats.StackInuse,
+			Sys:          stats.Sys,
+			NumGC:        stats.NumGC,
+			PauseTotalNs: stats.PauseTotalNs,
+		},
+		Caches: caches,
+	}
+}
+
+func (h *Handler) serveRuntime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(h.runtime()) // nolint:errcheck
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLoopback(t *testing.T) {
+	assert.True(t, IsLoopback("localhost"))
+	assert.True(t, IsLoopback("127.0.0.1"))
+	assert.True(t, IsLoopback("::1"))
+	assert.False(t, IsLoopback(""))
+	assert.False(t, IsLoopback("0.0.0.0"))
+	assert.False(t, IsLoopback("10.0.0.1"))
+	assert.False(t, IsLoopback("example.com"))
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler()
+	h.AddCache("traces", func() interface{} {
+		return map[string]int{"size": 3}
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := get(RuntimePath)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var summary Runtime
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &summary))
+	assert.Greater(t, summary.Goroutines, 0)
+	assert.Greater(t, summary.Memory.HeapAlloc, uint64(0))
+	assert.Equal(t, map[string]interface{}{
+		"traces": map[string]interface{}{"size": float64(3)},
+	}, summary.Caches)
+
+	recorder = get(VarsPath)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"memstats"`)
+
+	recorder = get(PprofPath + "goroutine?debug=1")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "goroutine profile")
+
+	assert.Equal(t, http.StatusNotFound, get("/block").Code)
+}
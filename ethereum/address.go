@@ -15,8 +15,6 @@
 package ethereum
 
 import (
-	"log"
-
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -31,15 +29,3 @@ func ChecksumAddress(address string) (string, bool) {
 
 	return addr.Address().Hex(), true
 }
-
-// MustChecksum ensures an address can be converted
-// into a valid checksum. If it does not, the program
-// will exit.
-func MustChecksum(address string) string {
-	addr, ok := ChecksumAddress(address)
-	if !ok {
-		log.Fatalf("invalid address %s", address)
-	}
-
-	return addr
-}
@@ -22,8 +22,21 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/storage/modules"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
 )
 
+// genesisBlocks are the genesis specifications of the
+// networks known to geth, keyed by genesis block hash.
+var genesisBlocks = map[common.Hash]func() *core.Genesis{
+	params.MainnetGenesisHash: core.DefaultGenesisBlock,
+	params.RopstenGenesisHash: core.DefaultRopstenGenesisBlock,
+	params.RinkebyGenesisHash: core.DefaultRinkebyGenesisBlock,
+	params.GoerliGenesisHash:  core.DefaultGoerliGenesisBlock,
+	params.SepoliaGenesisHash: core.DefaultSepoliaGenesisBlock,
+}
+
 type genesis struct {
 	Alloc map[string]genesisAllocation `json:"alloc"`
 }
@@ -81,3 +94,49 @@ func GenerateBootstrapFile(genesisFile string, outputFile string) error {
 
 	return nil
 }
+
+// genesisOps returns an operation crediting each balance
+// allocated in the genesis block with hash genesisHash,
+// starting at operation index startIndex. It returns nil
+// if the network is not known to geth.
+func genesisOps(genesisHash common.Hash, startIndex int) []*types.Operation {
+	genesisBlock, ok := genesisBlocks[genesisHash]
+	if !ok {
+		return nil
+	}
+
+	// Sort addresses for deterministic operations
+	alloc := genesisBlock().Alloc
+	keys := make([]string, 0, len(alloc))
+	balances := map[string]*big.Int{}
+	for address, account := range alloc {
+		if account.Balance == nil || account.Balance.Sign() == 0 {
+			continue
+		}
+
+		checkAddr := address.Hex()
+		keys = append(keys, checkAddr)
+		balances[checkAddr] = account.Balance
+	}
+	sort.Strings(keys)
+
+	ops := make([]*types.Operation, len(keys))
+	for i, k := range keys {
+		ops[i] = &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(startIndex + i),
+			},
+			Type:   GenesisOpType,
+			Status: types.String(SuccessStatus),
+			Account: &types.AccountIdentifier{
+				Address: k,
+			},
+			Amount: &types.Amount{
+				Value:    balances[k].String(),
+				Currency: Currency,
+			},
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultBreakerThreshold is the number of consecutive
+	// requests no node could serve after which the breaker
+	// opens, when no threshold is configured.
+	defaultBreakerThreshold = 5
+
+	// defaultBreakerCooldown is how long the breaker stays
+	// open before letting a request through to probe the
+	// nodes, when no cooldown is configured.
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerState is the state of the circuit
+// breaker of the requests made to geth.
+type BreakerState string
+
+const (
+	// BreakerClosed is the state of a breaker
+	// letting all requests through.
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen is the state of a breaker failing
+	// all requests fast with ErrCircuitOpen.
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen is the state of a breaker letting
+	// a single request through to probe the nodes, and
+	// failing the others with ErrCircuitOpen.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// breakerStates are the values of the
+// rpcBreakerState metric of each state.
+var breakerStates = map[BreakerState]float64{
+	BreakerClosed:   0,
+	BreakerHalfOpen: 1,
+	BreakerOpen:     2,
+}
+
+// breaker is a circuit breaker over the requests made to
+// the nodes of a nodePool. After threshold consecutive
+// requests that no node could serve, it opens: requests
+// fail fast with ErrCircuitOpen instead of waiting on nodes
+// that are down. Once cooldown has passed, it is half-open:
+// the next request is let through to probe the nodes. If
+// it is served, the breaker closes. Otherwise, it opens
+// again for another cooldown.
+type breaker struct {
+	mu sync.Mutex
+
+	threshold int
+	cooldown  time.Duration
+
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker() *breaker {
+	b := &breaker{state: BreakerClosed}
+	b.configure(0, 0)
+	rpcBreakerState.Set(breakerStates[BreakerClosed])
+
+	return b
+}
+
+// configure sets the threshold and cooldown of the breaker
+// (their defaults when not positive), without changing its
+// state.
+func (b *breaker) configure(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// current returns the current state of the breaker.
+func (b *breaker) current() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !time.Now().Before(b.openUntil) {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if a request
+// must not be sent to the nodes.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !time.Now().Before(b.openUntil) {
+		b.setState(BreakerHalfOpen)
+	}
+
+	switch {
+	case b.state == BreakerOpen, b.state == BreakerHalfOpen && b.probing:
+		rpcBreakerRejections.Inc()
+		return ErrCircuitOpen
+	case b.state == BreakerHalfOpen:
+		b.probing = true
+	}
+
+	return nil
+}
+
+// success records that a request was served.
+func (b *breaker) success(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+		logger.FromContext(ctx).Info("circuit breaker closed")
+	}
+}
+
+// failure records that no node could serve a request.
+func (b *breaker) failure(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerClosed && b.failures < b.threshold {
+		return
+	}
+
+	b.openUntil = time.Now().Add(b.cooldown)
+	if b.state != BreakerOpen {
+		b.setState(BreakerOpen)
+		logger.FromContext(ctx).Warn(
+			"circuit breaker opened",
+			zap.Int("failures", b.failures),
+			zap.Duration("retry_in", b.cooldown),
+		)
+	}
+}
+
+// cancel records that a request let through was
+// abandoned (i.e. its context was done) before any
+// node could serve it.
+func (b *breaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// setState sets the state of the breaker
+// and reports it. b.mu must be held.
+func (b *breaker) setState(state BreakerState) {
+	b.state = state
+	rpcBreakerState.Set(breakerStates[state])
+}
+
+// BreakerState returns the state of the circuit
+// breaker of the requests made to geth.
+func (ec *Client) BreakerState() BreakerState {
+	if ec.nodes == nil {
+		return BreakerClosed
+	}
+
+	return ec.nodes.breaker.current()
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	b := newBreaker()
+	b.configure(2, 50*time.Millisecond)
+	assert.Equal(t, BreakerClosed, b.current())
+
+	// Failures below the threshold, or interrupted by
+	// a success, do not open the breaker.
+	assert.NoError(t, b.allow())
+	b.failure(ctx)
+	assert.NoError(t, b.allow())
+	b.success(ctx)
+	assert.NoError(t, b.allow())
+	b.failure(ctx)
+	assert.Equal(t, BreakerClosed, b.current())
+
+	assert.NoError(t, b.allow())
+	b.failure(ctx)
+	assert.Equal(t, BreakerOpen, b.current())
+	assert.True(t, errors.Is(b.allow(), ErrCircuitOpen))
+
+	// Once half-open, a single request probes the nodes.
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, b.current())
+	assert.NoError(t, b.allow())
+	assert.True(t, errors.Is(b.allow(), ErrCircuitOpen))
+
+	// A failed probe opens the breaker again.
+	b.failure(ctx)
+	assert.Equal(t, BreakerOpen, b.current())
+	assert.True(t, errors.Is(b.allow(), ErrCircuitOpen))
+
+	// An abandoned probe lets another request probe.
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.allow())
+	b.cancel()
+	assert.Equal(t, BreakerHalfOpen, b.current())
+
+	// A successful probe closes the breaker.
+	assert.NoError(t, b.allow())
+	b.success(ctx)
+	assert.Equal(t, BreakerClosed, b.current())
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+}
+
+func TestNodePool_Breaker(t *testing.T) {
+	nodeRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{{url: "primary", rpc: nodeRPC}}, 0, 0)
+	assert.NoError(t, err)
+	pool.breaker.configure(1, time.Minute)
+
+	ctx := context.Background()
+	nodeRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("connection refused"),
+	).Once()
+
+	// The node is only called until the breaker opens.
+	assert.EqualError(t, pool.CallContext(ctx, nil, "eth_blockNumber"), "connection refused")
+	assert.True(t, errors.Is(pool.CallContext(ctx, nil, "eth_blockNumber"), ErrCircuitOpen))
+
+	client := &Client{nodes: pool}
+	assert.Equal(t, BreakerOpen, client.BreakerState())
+	assert.Equal(t, BreakerClosed, (&Client{}).BreakerState())
+
+	nodeRPC.AssertExpectations(t)
+}
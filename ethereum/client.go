@@ -16,15 +16,21 @@ package ethereum
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
 	"net/http"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/tracing"
+
 	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -32,11 +38,13 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
 	EthTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
-	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -46,6 +54,20 @@ const (
 	maxTraceConcurrency  = int64(16) // nolint:gomnd
 	semaphoreTraceWeight = int64(1)  // nolint:gomnd
 
+	// maxBlocksRange is the maximum number of
+	// blocks that can be fetched with Blocks.
+	maxBlocksRange = 100
+
+	// methodNotFoundCode is the JSON-RPC error code
+	// returned for methods the node does not support.
+	methodNotFoundCode = -32601
+
+	// peerInbound and peerOutbound are the directions
+	// of the connections to peers: opened by the peer,
+	// or by geth.
+	peerInbound  = "inbound"
+	peerOutbound = "outbound"
+
 	// eip1559TxType is the EthTypes.Transaction.Type() value that indicates this transaction
 	// follows EIP-1559.
 	eip1559TxType = 2
@@ -63,36 +85,386 @@ type Client struct {
 	c JSONRPC
 	g GraphQL
 
+	// nodes is populated when the Client is created
+	// with NewClient.
+	nodes *nodePool
+
+	// ws is used for subscriptions and is populated
+	// when the Client is created with NewClient.
+	ws *wsConn
+
+	// heads caches the latest header while
+	// TrackHeads is running.
+	heads *headTracker
+
+	// mempool mirrors the hashes of the transactions
+	// in the mempool while TrackMempool is running.
+	mempool *mempoolMirror
+
 	traceSemaphore *semaphore.Weighted
 
+	// traces caches block traces by block hash. It is
+	// nil when the Client is not created with NewClient
+	// or when tracing is off.
+	traces *traceCache
+
+	// batchSize is the maximum number of calls in each
+	// JSON-RPC batch (0 means unlimited).
+	batchSize int
+
+	// batchConcurrency is the maximum number of the
+	// batches split by batchSize sent at the same time
+	// (0 means one at a time).
+	batchConcurrency int
+
+	// blockReceipts is 1 while receipts should be fetched
+	// with eth_getBlockReceipts. It is set to 0 (atomically)
+	// when the node does not support the method.
+	blockReceipts int32
+
+	// genesisBalances is true if the balances allocated
+	// at genesis are credited in the genesis block.
+	genesisBalances bool
+
+	// tokens are the currencies of the ERC-20 tokens
+	// whose transfers are included in blocks, by
+	// contract address (see LoadTokens).
+	tokens map[common.Address]*RosettaTypes.Currency
+
 	skipAdminCalls bool
 }
 
-// NewClient creates a Client that from the provided url and params.
-func NewClient(url string, params *params.ChainConfig, skipAdminCalls bool) (*Client, error) {
-	c, err := rpc.DialHTTPWithClient(url, &http.Client{
-		Timeout: gethHTTPTimeout,
-	})
+// RPCConfig determines how the Client makes requests
+// to geth. Zero values fall back to the defaults.
+type RPCConfig struct {
+	// Timeout is the HTTP timeout of each JSON-RPC and
+	// GraphQL request.
+	Timeout time.Duration
+
+	// Retries is the number of times a request is retried
+	// when no node is able to serve it.
+	Retries int
+
+	// Backoff is the delay before the first retry. It is
+	// doubled on each subsequent retry.
+	Backoff time.Duration
+
+	// MaxIdleConns is the number of idle HTTP connections
+	// kept open to each node.
+	MaxIdleConns int
+
+	// MaxConnsPerHost is the maximum number of HTTP
+	// connections open to each node (0 means unlimited).
+	MaxConnsPerHost int
+
+	// WebSocketURL is the URL used for subscriptions. When
+	// empty, it is derived from the first node URL.
+	WebSocketURL string
+
+	// CACert is the path of a PEM bundle of certificate
+	// authorities trusted (in addition to the system ones)
+	// when connecting to nodes over TLS.
+	CACert string
+
+	// TLSInsecure disables the verification of the
+	// certificates of nodes.
+	TLSInsecure bool
+
+	// Header is added to every HTTP request made to
+	// nodes (i.e. to authenticate with hosted providers).
+	Header http.Header
+
+	// Concurrency is the maximum number of concurrent
+	// trace requests made while fetching blocks, and of
+	// the batches (split by BatchSize) of a block sent
+	// at the same time.
+	Concurrency int64
+
+	// BatchSize is the maximum number of calls in each
+	// JSON-RPC batch made while fetching blocks. Larger
+	// batches are split and sent concurrently.
+	BatchSize int
+
+	// TraceMode determines how transactions are traced
+	// (TraceModeJS when empty).
+	TraceMode TraceMode
+
+	// TraceTimeout is the timeout of each trace
+	// computed by geth.
+	TraceTimeout time.Duration
+
+	// TraceCacheSize is the number of blocks whose
+	// traces are cached.
+	TraceCacheSize int
+
+	// MempoolTTL is how long a transaction stays in the
+	// mempool mirror after it was last seen.
+	MempoolTTL time.Duration
+
+	// GenesisBalances credits the balances allocated at
+	// genesis in the genesis block, so they do not need
+	// to be bootstrapped. Only networks known to geth
+	// are supported.
+	GenesisBalances bool
+
+	// SlowThreshold is the duration above which
+	// requests to nodes are logged as slow (never
+	// when 0).
+	SlowThreshold time.Duration
+
+	// BreakerThreshold is the number of consecutive
+	// requests no node could serve after which requests
+	// fail fast with ErrCircuitOpen (5 when 0).
+	BreakerThreshold int
+
+	// BreakerCooldown is how long requests fail fast
+	// before one is let through to probe the nodes
+	// (30s when 0).
+	BreakerCooldown time.Duration
+}
+
+// webSocketURL returns the configured WebSocket URL or,
+// if none is configured, one derived from urls.
+func (r *RPCConfig) webSocketURL(urls []string) (string, error) {
+	if len(r.WebSocketURL) > 0 || len(urls) == 0 {
+		return r.WebSocketURL, nil
+	}
+
+	wsURL, err := websocketURL(urls[0])
 	if err != nil {
-		return nil, fmt.Errorf("%w: unable to dial node", err)
+		return "", fmt.Errorf("%w: unable to derive WebSocket URL", err)
 	}
 
-	tc, err := loadTraceConfig()
+	return wsURL, nil
+}
+
+// dialNodes creates a node for each of the provided urls.
+func dialNodes(urls []string, rpcConfig *RPCConfig, tlsConfig *tls.Config) ([]*node, error) {
+	rpcTimeout := gethHTTPTimeout
+	graphQLTimeout := graphQLHTTPTimeout
+	if rpcConfig.Timeout > 0 {
+		rpcTimeout = rpcConfig.Timeout
+		graphQLTimeout = rpcConfig.Timeout
+	}
+
+	transport := rpcConfig.httpTransport(tlsConfig)
+	nodes := make([]*node, len(urls))
+	for i, url := range urls {
+		if path, ok := ipcPath(url); ok {
+			c, err := rpc.DialIPC(context.Background(), path)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to dial node %s", err, url)
+			}
+
+			// geth does not serve GraphQL over IPC, so
+			// GraphQL queries are sent to other nodes.
+			nodes[i] = &node{url: url, rpc: c}
+			continue
+		}
+
+		c, err := rpc.DialHTTPWithClient(url, &http.Client{
+			Timeout:   rpcTimeout,
+			Transport: withHeader(withConnMetrics(transport), rpcConfig.Header),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to dial node %s", err, url)
+		}
+
+		g, err := newGraphQLClient(url, graphQLTimeout, transport, rpcConfig.Header)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to create GraphQL client for %s", err, url)
+		}
+
+		nodes[i] = &node{url: url, rpc: c, graphql: g}
+	}
+
+	return nodes, nil
+}
+
+// NewClient creates a Client that from the provided urls and params.
+// When more than one url is provided, requests are routed to the
+// first healthy node and fail over to the next on connection errors.
+func NewClient(
+	urls []string,
+	params *params.ChainConfig,
+	skipAdminCalls bool,
+	rpcConfig *RPCConfig,
+) (*Client, error) {
+	if rpcConfig == nil {
+		rpcConfig = &RPCConfig{}
+	}
+
+	tlsConfig, err := rpcConfig.tlsConfig()
 	if err != nil {
-		return nil, fmt.Errorf("%w: unable to load trace config", err)
+		return nil, err
+	}
+
+	nodes, err := dialNodes(urls, rpcConfig, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := newNodePool(nodes, rpcConfig.Retries, rpcConfig.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create node pool", err)
 	}
+	pool.setSlowThreshold(rpcConfig.SlowThreshold)
+	pool.breaker.configure(rpcConfig.BreakerThreshold, rpcConfig.BreakerCooldown)
 
-	g, err := newGraphQLClient(url)
+	wsURL, err := rpcConfig.webSocketURL(urls)
 	if err != nil {
-		return nil, fmt.Errorf("%w: unable to create GraphQL client", err)
+		return nil, err
+	}
+
+	tc, err := loadTraceConfig(rpcConfig.TraceMode, rpcConfig.TraceTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load trace config", err)
+	}
+
+	var traces *traceCache
+	if tc != nil {
+		traces, err = newTraceCache(rpcConfig.TraceCacheSize)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &Client{params, tc, c, g, semaphore.NewWeighted(maxTraceConcurrency), skipAdminCalls}, nil
+	concurrency := maxTraceConcurrency
+	if rpcConfig.Concurrency > 0 {
+		concurrency = rpcConfig.Concurrency
+	}
+
+	return &Client{
+		p:                params,
+		tc:               tc,
+		c:                pool,
+		g:                pool,
+		nodes:            pool,
+		ws:               &wsConn{url: wsURL, tls: tlsConfig},
+		heads:            &headTracker{},
+		mempool:          newMempoolMirror(rpcConfig.MempoolTTL),
+		traceSemaphore:   semaphore.NewWeighted(concurrency),
+		traces:           traces,
+		batchSize:        rpcConfig.BatchSize,
+		batchConcurrency: int(concurrency),
+		blockReceipts:    1,
+		genesisBalances:  rpcConfig.GenesisBalances,
+		skipAdminCalls:   skipAdminCalls,
+	}, nil
 }
 
 // Close shuts down the RPC client connection.
 func (ec *Client) Close() {
 	ec.c.Close()
+	if ec.ws != nil {
+		ec.ws.reset("", nil)
+	}
+}
+
+// Subscribe creates a subscription in namespace (i.e. `eth`)
+// over the WebSocket connection, which is dialed on first use.
+// Notifications are delivered to channel.
+func (ec *Client) Subscribe(
+	ctx context.Context,
+	namespace string,
+	channel interface{},
+	args ...interface{},
+) (*rpc.ClientSubscription, error) {
+	if ec.ws == nil {
+		return nil, ErrWebSocketUnavailable
+	}
+
+	return ec.ws.subscribe(ctx, namespace, channel, args...)
+}
+
+// ReloadNodes replaces the upstream nodes (and the policy used to
+// make requests to them) without interrupting in-flight requests,
+// which complete on the nodes they were sent to.
+func (ec *Client) ReloadNodes(urls []string, rpcConfig *RPCConfig) error {
+	if ec.nodes == nil {
+		return errors.New("client was not created with NewClient")
+	}
+
+	if rpcConfig == nil {
+		rpcConfig = &RPCConfig{}
+	}
+
+	tlsConfig, err := rpcConfig.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := dialNodes(urls, rpcConfig, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	wsURL, err := rpcConfig.webSocketURL(urls)
+	if err != nil {
+		return err
+	}
+
+	if err := ec.nodes.replace(nodes, rpcConfig.Retries, rpcConfig.Backoff); err != nil {
+		return err
+	}
+	ec.nodes.setSlowThreshold(rpcConfig.SlowThreshold)
+	ec.nodes.breaker.configure(rpcConfig.BreakerThreshold, rpcConfig.BreakerCooldown)
+
+	ec.ws.reset(wsURL, tlsConfig)
+	return nil
+}
+
+// ValidateNetwork returns ErrNetworkMismatch if the chain ID or
+// genesis block of the node do not match the configured params
+// and genesis (genesis is not checked when nil).
+func (ec *Client) ValidateNetwork(
+	ctx context.Context,
+	genesis *RosettaTypes.BlockIdentifier,
+) error {
+	var chainID hexutil.Big
+	if err := ec.c.CallContext(ctx, &chainID, "eth_chainId"); err != nil {
+		return fmt.Errorf("%w: unable to get chain ID", err)
+	}
+
+	if ec.p.ChainID != nil && ec.p.ChainID.Cmp((*big.Int)(&chainID)) != 0 {
+		return fmt.Errorf(
+			"%w: expected chain ID %s but node has %s",
+			ErrNetworkMismatch,
+			ec.p.ChainID.String(),
+			(*big.Int)(&chainID).String(),
+		)
+	}
+
+	if genesis == nil {
+		return nil
+	}
+
+	header, err := ec.blockHeaderByNumber(ctx, big.NewInt(genesis.Index))
+	if err != nil {
+		return fmt.Errorf("%w: unable to get genesis block", err)
+	}
+
+	if header.Hash().Hex() != genesis.Hash {
+		return fmt.Errorf(
+			"%w: expected genesis block %s but node has %s",
+			ErrNetworkMismatch,
+			genesis.Hash,
+			header.Hash().Hex(),
+		)
+	}
+
+	return nil
+}
+
+// MonitorNodes periodically health-checks any upstream nodes
+// that have failed so they are promoted back as soon as they
+// recover. It returns when ctx is done.
+func (ec *Client) MonitorNodes(ctx context.Context) error {
+	if ec.nodes == nil {
+		return nil
+	}
+
+	return ec.nodes.monitor(ctx)
 }
 
 // Status returns geth status information
@@ -104,7 +476,7 @@ func (ec *Client) Status(ctx context.Context) (
 	[]*RosettaTypes.Peer,
 	error,
 ) {
-	header, err := ec.blockHeaderByNumber(ctx, nil)
+	header, err := ec.latestHeader(ctx)
 	if err != nil {
 		return nil, -1, nil, nil, err
 	}
@@ -114,16 +486,7 @@ func (ec *Client) Status(ctx context.Context) (
 		return nil, -1, nil, nil, err
 	}
 
-	var syncStatus *RosettaTypes.SyncStatus
-	if progress != nil {
-		currentIndex := int64(progress.CurrentBlock)
-		targetIndex := int64(progress.HighestBlock)
-
-		syncStatus = &RosettaTypes.SyncStatus{
-			CurrentIndex: &currentIndex,
-			TargetIndex:  &targetIndex,
-		}
-	}
+	syncStatus := newSyncStatus(header, progress)
 
 	peers, err := ec.peers(ctx)
 	if err != nil {
@@ -140,6 +503,72 @@ func (ec *Client) Status(ctx context.Context) (
 		nil
 }
 
+// newSyncStatus returns the sync status of geth given its latest
+// header and its sync progress (nil when it is not syncing). geth
+// is not syncing either when it is synced or before it has found
+// peers to sync from, when it is still at the genesis block.
+func newSyncStatus(
+	header *types.Header,
+	progress *ethereum.SyncProgress,
+) *RosettaTypes.SyncStatus {
+	if progress == nil {
+		index := header.Number.Int64()
+		synced := index > 0
+		stage := SyncStageSynced
+		if !synced {
+			stage = SyncStageNotStarted
+		}
+
+		return &RosettaTypes.SyncStatus{
+			CurrentIndex: &index,
+			TargetIndex:  &index,
+			Stage:        &stage,
+			Synced:       &synced,
+		}
+	}
+
+	currentIndex := int64(progress.CurrentBlock)
+	targetIndex := int64(progress.HighestBlock)
+
+	// Once all blocks are downloaded, geth keeps
+	// syncing until it has the state of the head.
+	stage := SyncStageBlocks
+	if currentIndex >= targetIndex {
+		stage = SyncStageState
+	}
+
+	return &RosettaTypes.SyncStatus{
+		CurrentIndex: &currentIndex,
+		TargetIndex:  &targetIndex,
+		Stage:        &stage,
+		Synced:       RosettaTypes.Bool(false),
+	}
+}
+
+// BlockIdentifier returns the identifier of the block at index
+// in the canonical chain. If index is nil, the identifier of the
+// latest block is returned.
+func (ec *Client) BlockIdentifier(
+	ctx context.Context,
+	index *int64,
+) (*RosettaTypes.BlockIdentifier, error) {
+	var header *types.Header
+	var err error
+	if index != nil {
+		header, err = ec.blockHeaderByNumber(ctx, big.NewInt(*index))
+	} else {
+		header, err = ec.latestHeader(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &RosettaTypes.BlockIdentifier{
+		Hash:  header.Hash().Hex(),
+		Index: header.Number.Int64(),
+	}, nil
+}
+
 // PendingNonceAt returns the account nonce of the given account in the pending state.
 // This is the nonce that should be used for the next transaction.
 func (ec *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
@@ -158,6 +587,124 @@ func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
 	return (*big.Int)(&hex), nil
 }
 
+// SuggestGasTipCap retrieves the currently suggested gas tip cap after 1559 to
+// allow a timely execution of a transaction.
+func (ec *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	var hex hexutil.Big
+	if err := ec.c.CallContext(ctx, &hex, "eth_maxPriorityFeePerGas"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&hex), nil
+}
+
+// FeeHistory is the fee market history of
+// a range of blocks (see eth_feeHistory).
+type FeeHistory struct {
+	OldestBlock *big.Int
+
+	// Reward holds, for each block, the tips paid
+	// at the requested percentiles.
+	Reward [][]*big.Int
+
+	// BaseFee holds the base fee of each block, followed
+	// by the base fee of the block after the newest one.
+	// It is empty (or zero) before London.
+	BaseFee []*big.Int
+
+	GasUsedRatio []float64
+}
+
+type feeHistoryResultMarshaling struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory retrieves the fee market history of the blockCount
+// most recent blocks, with the tips paid at rewardPercentiles.
+func (ec *Client) FeeHistory(
+	ctx context.Context,
+	blockCount uint64,
+	rewardPercentiles []float64,
+) (*FeeHistory, error) {
+	var res feeHistoryResultMarshaling
+	if err := ec.c.CallContext(
+		ctx,
+		&res,
+		"eth_feeHistory",
+		hexutil.Uint64(blockCount),
+		toBlockNumArg(nil),
+		rewardPercentiles,
+	); err != nil {
+		return nil, err
+	}
+
+	reward := make([][]*big.Int, len(res.Reward))
+	for i, r := range res.Reward {
+		reward[i] = make([]*big.Int, len(r))
+		for j, r := range r {
+			reward[i][j] = (*big.Int)(r)
+		}
+	}
+	baseFee := make([]*big.Int, len(res.BaseFee))
+	for i, b := range res.BaseFee {
+		baseFee[i] = (*big.Int)(b)
+	}
+
+	return &FeeHistory{
+		OldestBlock:  (*big.Int)(res.OldestBlock),
+		Reward:       reward,
+		BaseFee:      baseFee,
+		GasUsedRatio: res.GasUsedRatio,
+	}, nil
+}
+
+// CallContract executes msg at blockNumber (the latest block if
+// nil, or the pending block if -1) without creating a
+// transaction and returns its output.
+func (ec *Client) CallContract(
+	ctx context.Context,
+	msg ethereum.CallMsg,
+	blockNumber *big.Int,
+) ([]byte, error) {
+	var hex hexutil.Bytes
+	if err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
+// EstimateGas returns the gas msg would use
+// if it were included in the pending block.
+func (ec *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var hex hexutil.Uint64
+	if err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg)); err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
+
 // Peers retrieves all peers of the node.
 func (ec *Client) peers(ctx context.Context) ([]*RosettaTypes.Peer, error) {
 	var info []*p2p.PeerInfo
@@ -166,20 +713,36 @@ func (ec *Client) peers(ctx context.Context) ([]*RosettaTypes.Peer, error) {
 		return []*RosettaTypes.Peer{}, nil
 	}
 
-	if err := ec.c.CallContext(ctx, &info, "admin_peers"); err != nil {
+	// Nodes that do not expose the admin API (i.e. most
+	// hosted providers) are reported without peers.
+	err := ec.c.CallContext(ctx, &info, "admin_peers")
+	if isMethodNotFound(err) {
+		return []*RosettaTypes.Peer{}, nil
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	peers := make([]*RosettaTypes.Peer, len(info))
 	for i, peerInfo := range info {
+		direction := peerOutbound
+		if peerInfo.Network.Inbound {
+			direction = peerInbound
+		}
+
 		peers[i] = &RosettaTypes.Peer{
 			PeerID: peerInfo.ID,
 			Metadata: map[string]interface{}{
-				"name":      peerInfo.Name,
-				"enode":     peerInfo.Enode,
-				"caps":      peerInfo.Caps,
-				"enr":       peerInfo.ENR,
-				"protocols": peerInfo.Protocols,
+				"name":           peerInfo.Name,
+				"enode":          peerInfo.Enode,
+				"caps":           peerInfo.Caps,
+				"enr":            peerInfo.ENR,
+				"protocols":      peerInfo.Protocols,
+				"direction":      direction,
+				"local_address":  peerInfo.Network.LocalAddress,
+				"remote_address": peerInfo.Network.RemoteAddress,
+				"trusted":        peerInfo.Network.Trusted,
+				"static":         peerInfo.Network.Static,
 			},
 		}
 	}
@@ -192,7 +755,7 @@ func (ec *Client) peers(ctx context.Context) ([]*RosettaTypes.Peer, error) {
 // If the transaction was a contract creation use the TransactionReceipt method to get the
 // contract address after the transaction has been mined.
 func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return err
 	}
@@ -210,6 +773,28 @@ func toBlockNumArg(number *big.Int) string {
 	return hexutil.EncodeBig(number)
 }
 
+// TransactionByHash returns the transaction with the given hash
+// and whether it is still pending. It returns ethereum.NotFound
+// if geth does not know the transaction.
+func (ec *Client) TransactionByHash(
+	ctx context.Context,
+	hash common.Hash,
+) (*types.Transaction, bool, error) {
+	var raw json.RawMessage
+	if err := ec.c.CallContext(ctx, &raw, "eth_getTransactionByHash", hash); err != nil {
+		return nil, false, fmt.Errorf("%w: transaction fetch failed", err)
+	} else if len(raw) == 0 || string(raw) == "null" {
+		return nil, false, ethereum.NotFound
+	}
+
+	var body rpcTransaction
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, false, err
+	}
+
+	return body.tx, body.BlockNumber == nil, nil
+}
+
 // Transaction returns the transaction response of the Transaction identified
 // by *RosettaTypes.TransactionIdentifier hash
 func (ec *Client) Transaction(
@@ -263,7 +848,7 @@ func (ec *Client) Transaction(
 	var traces *Call
 	var rawTraces json.RawMessage
 	var addTraces bool
-	if header.Number.Int64() != GenesisBlockIndex { // not possible to get traces at genesis
+	if ec.traceable(header) {
 		addTraces = true
 		traces, rawTraces, err = ec.getTransactionTraces(ctx, body.tx.Hash())
 		if err != nil {
@@ -279,7 +864,7 @@ func (ec *Client) Transaction(
 	}
 	loadedTx.FeeAmount = feeAmount
 	loadedTx.FeeBurned = feeBurned
-	loadedTx.Miner = MustChecksum(header.Coinbase.Hex())
+	loadedTx.Miner = header.Coinbase.Hex()
 	loadedTx.Receipt = receipt
 
 	if addTraces {
@@ -300,6 +885,28 @@ func (ec *Client) Transaction(
 func (ec *Client) Block(
 	ctx context.Context,
 	blockIdentifier *RosettaTypes.PartialBlockIdentifier,
+) (*RosettaTypes.Block, error) {
+	ctx, span := tracing.Start(ctx, "ethereum.Block", tracing.KindInternal)
+	defer span.End()
+
+	block, err := ec.block(ctx, blockIdentifier)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("block.index", block.BlockIdentifier.Index)
+	span.SetAttribute("block.hash", block.BlockIdentifier.Hash)
+	span.SetAttribute("block.transactions", len(block.Transactions))
+
+	return block, nil
+}
+
+// block returns the block with the provided
+// identifier (or the current block if it is nil).
+func (ec *Client) block(
+	ctx context.Context,
+	blockIdentifier *RosettaTypes.PartialBlockIdentifier,
 ) (*RosettaTypes.Block, error) {
 	if blockIdentifier != nil {
 		if blockIdentifier.Hash != nil {
@@ -319,6 +926,194 @@ func (ec *Client) Block(
 	return ec.getParsedBlock(ctx, "eth_getBlockByNumber", toBlockNumArg(nil), true)
 }
 
+// BlockHeader returns the block with the provided identifier (or the
+// latest block if it is nil) without its transactions, so that the
+// chain can be followed without tracing each block.
+func (ec *Client) BlockHeader(
+	ctx context.Context,
+	blockIdentifier *RosettaTypes.PartialBlockIdentifier,
+) (*RosettaTypes.Block, error) {
+	var header *types.Header
+	var err error
+	switch {
+	case blockIdentifier != nil && blockIdentifier.Hash != nil:
+		header, err = ec.blockHeaderByHash(ctx, *blockIdentifier.Hash)
+	case blockIdentifier != nil && blockIdentifier.Index != nil:
+		header, err = ec.blockHeaderByNumber(ctx, big.NewInt(*blockIdentifier.Index))
+	default:
+		header, err = ec.blockHeaderByNumber(ctx, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identifier := &RosettaTypes.BlockIdentifier{
+		Hash:  header.Hash().Hex(),
+		Index: header.Number.Int64(),
+	}
+
+	parentIdentifier := identifier
+	if identifier.Index != GenesisBlockIndex {
+		parentIdentifier = &RosettaTypes.BlockIdentifier{
+			Hash:  header.ParentHash.Hex(),
+			Index: identifier.Index - 1,
+		}
+	}
+
+	return &RosettaTypes.Block{
+		BlockIdentifier:       identifier,
+		ParentBlockIdentifier: parentIdentifier,
+		Timestamp:             convertTime(header.Time),
+	}, nil
+}
+
+// Blocks returns the populated blocks from index from to index to
+// (inclusive). The blocks are fetched in one JSON-RPC batch and their
+// uncles and receipts in another, so a range takes a few round trips
+// instead of a few per block. Traces are fetched concurrently for
+// each block, along with uncles and receipts, and the transactions
+// of each block are parsed concurrently.
+//
+// ErrBlockOrphaned is returned if the blocks do not form a chain
+// (i.e. a reorg happened while they were fetched).
+func (ec *Client) Blocks(
+	ctx context.Context,
+	from int64,
+	to int64,
+) ([]*RosettaTypes.Block, error) {
+	if from < 0 || to < from || to-from >= maxBlocksRange {
+		return nil, fmt.Errorf("%w: %d to %d", ErrInvalidBlockRange, from, to)
+	}
+
+	count := int(to-from) + 1
+	raws := make([]json.RawMessage, count)
+	reqs := make([]rpc.BatchElem, count)
+	for i := range reqs {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{toBlockNumArg(big.NewInt(from + int64(i))), true},
+			Result: &raws[i],
+		}
+	}
+	if err := ec.batchCallContext(ctx, reqs); err != nil {
+		return nil, fmt.Errorf("%w: block fetch failed", err)
+	}
+
+	heads := make([]*types.Header, count)
+	bodies := make([]*rpcBlock, count)
+	uncles := make([][]*types.Header, count)
+	receipts := make([][]*types.Receipt, count)
+	uncleOffsets := make([]int, count)
+	receiptOffsets := make([]int, count)
+	dataReqs := []rpc.BatchElem{}
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return nil, fmt.Errorf("%w: block fetch failed", reqs[i].Error)
+		}
+
+		head, body, err := decodeBlock(raws[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not get block %d", err, from+int64(i))
+		}
+
+		if i > 0 && head.ParentHash != bodies[i-1].Hash {
+			return nil, fmt.Errorf(
+				"%w: block %d has parent %s but got block %s",
+				ErrBlockOrphaned,
+				head.Number.Int64(),
+				head.ParentHash.Hex(),
+				bodies[i-1].Hash.Hex(),
+			)
+		}
+
+		blockUncles, uncleReqs, err := unclesRequest(head, body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get uncles", err)
+		}
+
+		blockReceipts, receiptReqs := receiptsRequest(body.Transactions)
+
+		heads[i], bodies[i] = head, body
+		uncles[i], receipts[i] = blockUncles, blockReceipts
+		uncleOffsets[i] = len(dataReqs)
+		dataReqs = append(dataReqs, uncleReqs...)
+		receiptOffsets[i] = len(dataReqs)
+		dataReqs = append(dataReqs, receiptReqs...)
+	}
+
+	// Uncles and receipts are fetched while the blocks are
+	// traced (the number of concurrent traces is limited
+	// by the trace semaphore). Traces are canceled if
+	// uncles or receipts cannot be fetched, and the fetch
+	// of uncles and receipts is canceled if a trace fails.
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if len(dataReqs) > 0 {
+			if err := ec.batchCallContext(gctx, dataReqs); err != nil {
+				return fmt.Errorf("%w: unable to get uncles and receipts", err)
+			}
+		}
+
+		for i := range bodies {
+			uncleReqs := dataReqs[uncleOffsets[i] : uncleOffsets[i]+len(uncles[i])]
+			if err := checkUncles(bodies[i], uncles[i], uncleReqs); err != nil {
+				return fmt.Errorf("%w: unable to get uncles", err)
+			}
+
+			receiptReqs := dataReqs[receiptOffsets[i] : receiptOffsets[i]+len(receipts[i])]
+			if err := checkReceipts(bodies[i].Hash, bodies[i].Transactions, receipts[i], receiptReqs); err != nil {
+				return fmt.Errorf("%w: could not get receipts for %x", err, bodies[i].Hash[:])
+			}
+		}
+
+		return nil
+	})
+
+	traces := make([][]*rpcCall, count)
+	rawTraces := make([][]*rpcRawCall, count)
+	for i := range heads {
+		i := i
+		if !ec.traceable(heads[i]) {
+			continue
+		}
+
+		g.Go(func() error {
+			var err error
+			traces[i], rawTraces[i], err = ec.getBlockTraces(gctx, bodies[i].Hash)
+			if err != nil {
+				return fmt.Errorf("%w: could not get traces for %x", err, bodies[i].Hash[:])
+			}
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*RosettaTypes.Block, count)
+	for i := range heads {
+		block, loadedTxs, err := loadBlock(
+			heads[i],
+			bodies[i],
+			uncles[i],
+			receipts[i],
+			traces[i],
+			rawTraces[i],
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not get block", err)
+		}
+
+		blocks[i], err = ec.parseBlock(block, loadedTxs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return blocks, nil
+}
+
 // Header returns a block header from the current canonical chain. If number is
 // nil, the latest known header is returned.
 func (ec *Client) blockHeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
@@ -357,57 +1152,87 @@ func (ec *Client) getUncles(
 	head *types.Header,
 	body *rpcBlock,
 ) ([]*types.Header, error) {
+	uncles, reqs, err := unclesRequest(head, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reqs) > 0 {
+		if err := ec.batchCallContext(ctx, reqs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkUncles(body, uncles, reqs); err != nil {
+		return nil, err
+	}
+
+	return uncles, nil
+}
+
+// unclesRequest returns the batch of calls used to load the
+// uncles of a block, which are not included in the block
+// response, and the slice the uncles are loaded into.
+func unclesRequest(
+	head *types.Header,
+	body *rpcBlock,
+) ([]*types.Header, []rpc.BatchElem, error) {
 	// Quick-verify transaction and uncle lists. This mostly helps with debugging the server.
 	if head.UncleHash == types.EmptyUncleHash && len(body.UncleHashes) > 0 {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"server returned non-empty uncle list but block header indicates no uncles",
 		)
 	}
 	if head.UncleHash != types.EmptyUncleHash && len(body.UncleHashes) == 0 {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"server returned empty uncle list but block header indicates uncles",
 		)
 	}
 	if head.TxHash == types.EmptyRootHash && len(body.Transactions) > 0 {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"server returned non-empty transaction list but block header indicates no transactions",
 		)
 	}
 	if head.TxHash != types.EmptyRootHash && len(body.Transactions) == 0 {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"server returned empty transaction list but block header indicates transactions",
 		)
 	}
-	// Load uncles because they are not included in the block response.
-	var uncles []*types.Header
-	if len(body.UncleHashes) > 0 {
-		uncles = make([]*types.Header, len(body.UncleHashes))
-		reqs := make([]rpc.BatchElem, len(body.UncleHashes))
-		for i := range reqs {
-			reqs[i] = rpc.BatchElem{
-				Method: "eth_getUncleByBlockHashAndIndex",
-				Args:   []interface{}{body.Hash, hexutil.EncodeUint64(uint64(i))},
-				Result: &uncles[i],
-			}
+
+	if len(body.UncleHashes) == 0 {
+		return nil, nil, nil
+	}
+
+	uncles := make([]*types.Header, len(body.UncleHashes))
+	reqs := make([]rpc.BatchElem, len(body.UncleHashes))
+	for i := range reqs {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getUncleByBlockHashAndIndex",
+			Args:   []interface{}{body.Hash, hexutil.EncodeUint64(uint64(i))},
+			Result: &uncles[i],
 		}
-		if err := ec.c.BatchCallContext(ctx, reqs); err != nil {
-			return nil, err
+	}
+
+	return uncles, reqs, nil
+}
+
+// checkUncles returns an error if any call
+// made by unclesRequest failed.
+func checkUncles(body *rpcBlock, uncles []*types.Header, reqs []rpc.BatchElem) error {
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return reqs[i].Error
 		}
-		for i := range reqs {
-			if reqs[i].Error != nil {
-				return nil, reqs[i].Error
-			}
-			if uncles[i] == nil {
-				return nil, fmt.Errorf(
-					"got null header for uncle %d of block %x",
-					i,
-					body.Hash[:],
-				)
-			}
+		if uncles[i] == nil {
+			return fmt.Errorf(
+				"got null header for uncle %d of block %x",
+				i,
+				body.Hash[:],
+			)
 		}
 	}
 
-	return uncles, nil
+	return nil
 }
 
 func (ec *Client) getBlock(
@@ -423,7 +1248,67 @@ func (ec *Client) getBlock(
 	err := ec.c.CallContext(ctx, &raw, blockMethod, args...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%w: block fetch failed", err)
-	} else if len(raw) == 0 {
+	}
+
+	head, body, err := decodeBlock(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Uncles, receipts and traces are fetched concurrently
+	// (the number of concurrent traces is limited by the
+	// trace semaphore, to avoid overwhelming geth). A failed
+	// fetch does not cancel the others, which are bounded
+	// by the timeout of requests to geth.
+	var uncles []*types.Header
+	var receipts []*types.Receipt
+	var traces []*rpcCall
+	var rawTraces []*rpcRawCall
+	var g errgroup.Group
+	g.Go(func() error {
+		var err error
+		uncles, err = ec.getUncles(ctx, head, body)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get uncles", err)
+		}
+
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		receipts, err = ec.getBlockReceipts(ctx, body.Hash, body.Transactions)
+		if err != nil {
+			return fmt.Errorf("%w: could not get receipts for %x", err, body.Hash[:])
+		}
+
+		return nil
+	})
+
+	// Get block traces (not possible to make idempotent
+	// block transaction trace requests)
+	if ec.traceable(head) {
+		g.Go(func() error {
+			var err error
+			traces, rawTraces, err = ec.getBlockTraces(ctx, body.Hash)
+			if err != nil {
+				return fmt.Errorf("%w: could not get traces for %x", err, body.Hash[:])
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	return loadBlock(head, body, uncles, receipts, traces, rawTraces)
+}
+
+// decodeBlock decodes the header and body of a block
+// returned by eth_getBlockByHash or eth_getBlockByNumber.
+func decodeBlock(raw json.RawMessage) (*types.Header, *rpcBlock, error) {
+	if len(raw) == 0 || string(raw) == "null" {
 		return nil, nil, ethereum.NotFound
 	}
 
@@ -437,56 +1322,45 @@ func (ec *Client) getBlock(
 		return nil, nil, err
 	}
 
-	uncles, err := ec.getUncles(ctx, &head, &body)
-	if err != nil {
-		return nil, nil, fmt.Errorf("%w: unable to get uncles", err)
-	}
-
-	// Get all transaction receipts
-	receipts, err := ec.getBlockReceipts(ctx, body.Hash, body.Transactions)
-	if err != nil {
-		return nil, nil, fmt.Errorf("%w: could not get receipts for %x", err, body.Hash[:])
-	}
-
-	// Get block traces (not possible to make idempotent block transaction trace requests)
-	//
-	// We fetch traces last because we want to avoid limiting the number of other
-	// block-related data fetches we perform concurrently (we limit the number of
-	// concurrent traces that are computed to 16 to avoid overwhelming geth).
-	var traces []*rpcCall
-	var rawTraces []*rpcRawCall
-	var addTraces bool
-	if head.Number.Int64() != GenesisBlockIndex { // not possible to get traces at genesis
-		addTraces = true
-		traces, rawTraces, err = ec.getBlockTraces(ctx, body.Hash)
-		if err != nil {
-			return nil, nil, fmt.Errorf("%w: could not get traces for %x", err, body.Hash[:])
-		}
-	}
+	return &head, &body, nil
+}
 
+// loadBlock assembles a block and its loaded transactions
+// from the data fetched from geth. traces are nil at genesis
+// and when tracing is off.
+func loadBlock(
+	head *types.Header,
+	body *rpcBlock,
+	uncles []*types.Header,
+	receipts []*types.Receipt,
+	traces []*rpcCall,
+	rawTraces []*rpcRawCall,
+) (
+	*types.Block,
+	[]*loadedTransaction,
+	error,
+) {
 	// Convert all txs to loaded txs
 	txs := make([]*types.Transaction, len(body.Transactions))
 	loadedTxs := make([]*loadedTransaction, len(body.Transactions))
 	for i, tx := range body.Transactions {
 		txs[i] = tx.tx
 		receipt := receipts[i]
-		if err != nil {
-			return nil, nil, fmt.Errorf("%w: failure getting effective gas price", err)
-		}
 		loadedTxs[i] = tx.LoadedTransaction()
 		loadedTxs[i].Transaction = txs[i]
 
-		feeAmount, feeBurned, err := calculateGas(txs[i], receipt, head)
+		feeAmount, feeBurned, err := calculateGas(txs[i], receipt, *head)
 		if err != nil {
 			return nil, nil, err
 		}
 		loadedTxs[i].FeeAmount = feeAmount
 		loadedTxs[i].FeeBurned = feeBurned
-		loadedTxs[i].Miner = MustChecksum(head.Coinbase.Hex())
+		loadedTxs[i].Miner = head.Coinbase.Hex()
 		loadedTxs[i].Receipt = receipt
 
-		// Continue if calls does not exist (occurs at genesis)
-		if !addTraces {
+		// Continue if calls does not exist (occurs at genesis
+		// and when tracing is off)
+		if traces == nil {
 			continue
 		}
 
@@ -494,7 +1368,7 @@ func (ec *Client) getBlock(
 		loadedTxs[i].RawTrace = rawTraces[i].Result
 	}
 
-	return types.NewBlockWithHeader(&head).WithBody(txs, uncles), loadedTxs, nil
+	return types.NewBlockWithHeader(head).WithBody(txs, uncles), loadedTxs, nil
 }
 
 func calculateGas(
@@ -533,6 +1407,13 @@ func effectiveGasPrice(tx *EthTypes.Transaction, baseFee *big.Int) (*big.Int, er
 	return new(big.Int).Add(tip, baseFee), nil
 }
 
+// traceable returns true if the transactions of
+// the block with header head should be traced.
+func (ec *Client) traceable(head *types.Header) bool {
+	// not possible to get traces at genesis
+	return ec.tc != nil && head.Number.Int64() != GenesisBlockIndex
+}
+
 func (ec *Client) getTransactionTraces(
 	ctx context.Context,
 	transactionHash common.Hash,
@@ -561,18 +1442,32 @@ func (ec *Client) getBlockTraces(
 	ctx context.Context,
 	blockHash common.Hash,
 ) ([]*rpcCall, []*rpcRawCall, error) {
-	if err := ec.traceSemaphore.Acquire(ctx, semaphoreTraceWeight); err != nil {
-		return nil, nil, err
+	ctx, span := tracing.Start(ctx, "ethereum.getBlockTraces", tracing.KindInternal)
+	defer span.End()
+
+	raw, cached := ec.traces.get(blockHash)
+	span.SetAttribute("block.hash", blockHash.Hex())
+	span.SetAttribute("trace.cached", cached)
+	if !cached {
+		var err error
+		raw, err = ec.traceBlock(ctx, blockHash)
+		if err != nil {
+			span.SetError(err)
+			return nil, nil, err
+		}
 	}
-	defer ec.traceSemaphore.Release(semaphoreTraceWeight)
+	span.SetAttribute("trace.bytes", len(raw))
+
+	// Slow requests are logged with the traces they needed.
+	logger.Annotate(
+		ctx,
+		zap.String("trace_block_hash", blockHash.Hex()),
+		zap.Int("trace_bytes", len(raw)),
+		zap.Bool("trace_cached", cached),
+	)
 
 	var calls []*rpcCall
 	var rawCalls []*rpcRawCall
-	var raw json.RawMessage
-	err := ec.c.CallContext(ctx, &raw, "debug_traceBlockByHash", blockHash, ec.tc)
-	if err != nil {
-		return nil, nil, err
-	}
 
 	// Decode []*rpcCall
 	if err := json.Unmarshal(raw, &calls); err != nil {
@@ -584,19 +1479,129 @@ func (ec *Client) getBlockTraces(
 		return nil, nil, err
 	}
 
+	if !cached {
+		ec.traces.add(blockHash, raw)
+	}
+
 	return calls, rawCalls, nil
 }
 
+// traceBlock returns the raw traces of the block with
+// hash blockHash, computed by geth.
+func (ec *Client) traceBlock(
+	ctx context.Context,
+	blockHash common.Hash,
+) (json.RawMessage, error) {
+	// Waiting for other traces to complete is
+	// recorded separately from tracing the block.
+	_, span := tracing.Start(ctx, "ethereum.traceSemaphore", tracing.KindInternal)
+	err := ec.traceSemaphore.Acquire(ctx, semaphoreTraceWeight)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	defer ec.traceSemaphore.Release(semaphoreTraceWeight)
+
+	var raw json.RawMessage
+	err = ec.c.CallContext(ctx, &raw, "debug_traceBlockByHash", blockHash, ec.tc)
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// batchCallContext sends reqs in batches of at most
+// batchSize calls, at most batchConcurrency at a time.
+func (ec *Client) batchCallContext(ctx context.Context, reqs []rpc.BatchElem) error {
+	if ec.batchSize <= 0 || len(reqs) <= ec.batchSize {
+		return ec.c.BatchCallContext(ctx, reqs)
+	}
+
+	batches := (len(reqs) + ec.batchSize - 1) / ec.batchSize
+	return parallel(batches, ec.batchConcurrency, func(i int) error {
+		start := i * ec.batchSize
+		end := start + ec.batchSize
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+
+		return ec.c.BatchCallContext(ctx, reqs[start:end])
+	})
+}
+
 func (ec *Client) getBlockReceipts(
 	ctx context.Context,
 	blockHash common.Hash,
 	txs []rpcTransaction,
 ) ([]*types.Receipt, error) {
-	receipts := make([]*types.Receipt, len(txs))
-	if len(txs) == 0 {
+	if len(txs) > 0 && atomic.LoadInt32(&ec.blockReceipts) == 1 {
+		receipts, err := ec.getBlockReceiptsByHash(ctx, blockHash, txs)
+		if !isMethodNotFound(err) {
+			return receipts, err
+		}
+
+		// Nodes that do not serve eth_getBlockReceipts (i.e. geth
+		// before v1.13) are sent a batch of receipt requests instead.
+		atomic.StoreInt32(&ec.blockReceipts, 0)
+	}
+
+	receipts, reqs := receiptsRequest(txs)
+	if len(reqs) == 0 {
 		return receipts, nil
 	}
 
+	if err := ec.batchCallContext(ctx, reqs); err != nil {
+		return nil, err
+	}
+
+	if err := checkReceipts(blockHash, txs, receipts, reqs); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// getBlockReceiptsByHash fetches all receipts of a block
+// with a single eth_getBlockReceipts call.
+func (ec *Client) getBlockReceiptsByHash(
+	ctx context.Context,
+	blockHash common.Hash,
+	txs []rpcTransaction,
+) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	if err := ec.c.CallContext(ctx, &receipts, "eth_getBlockReceipts", blockHash.Hex()); err != nil {
+		return nil, err
+	}
+
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf(
+			"expected %d receipts for block %s but got %d",
+			len(txs),
+			blockHash.Hex(),
+			len(receipts),
+		)
+	}
+
+	if err := validateReceipts(blockHash, txs, receipts); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// isMethodNotFound returns true if err was returned
+// for a method the node does not support.
+func isMethodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode
+}
+
+// receiptsRequest returns the batch of calls used to load the
+// receipts of txs and the slice the receipts are loaded into.
+func receiptsRequest(txs []rpcTransaction) ([]*types.Receipt, []rpc.BatchElem) {
+	receipts := make([]*types.Receipt, len(txs))
 	reqs := make([]rpc.BatchElem, len(txs))
 	for i := range reqs {
 		reqs[i] = rpc.BatchElem{
@@ -605,19 +1610,50 @@ func (ec *Client) getBlockReceipts(
 			Result: &receipts[i],
 		}
 	}
-	if err := ec.c.BatchCallContext(ctx, reqs); err != nil {
-		return nil, err
-	}
+
+	return receipts, reqs
+}
+
+// checkReceipts returns an error if any call made by
+// receiptsRequest failed or returned a receipt of
+// another block.
+func checkReceipts(
+	blockHash common.Hash,
+	txs []rpcTransaction,
+	receipts []*types.Receipt,
+	reqs []rpc.BatchElem,
+) error {
 	for i := range reqs {
 		if reqs[i].Error != nil {
-			return nil, reqs[i].Error
+			return reqs[i].Error
 		}
+	}
+
+	return validateReceipts(blockHash, txs, receipts)
+}
+
+// validateReceipts returns an error if any receipt is
+// missing, out of order or belongs to another block.
+func validateReceipts(
+	blockHash common.Hash,
+	txs []rpcTransaction,
+	receipts []*types.Receipt,
+) error {
+	for i := range receipts {
 		if receipts[i] == nil {
-			return nil, fmt.Errorf("got empty receipt for %x", txs[i].tx.Hash().Hex())
+			return fmt.Errorf("got empty receipt for %x", txs[i].tx.Hash().Hex())
+		}
+
+		if receipts[i].TxHash != txs[i].tx.Hash() {
+			return fmt.Errorf(
+				"expected receipt for transaction %s but got %s",
+				txs[i].tx.Hash().Hex(),
+				receipts[i].TxHash.Hex(),
+			)
 		}
 
 		if receipts[i].BlockHash != blockHash {
-			return nil, fmt.Errorf(
+			return fmt.Errorf(
 				"%w: expected block hash %s for transaction but got %s",
 				ErrBlockOrphaned,
 				blockHash.Hex(),
@@ -626,7 +1662,7 @@ func (ec *Client) getBlockReceipts(
 		}
 	}
 
-	return receipts, nil
+	return nil
 }
 
 type rpcCall struct {
@@ -711,9 +1747,31 @@ func (t *Call) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
-// flattenTraces recursively flattens all traces.
+// untracedCall returns the top-level call of tx, which
+// is used in place of its traces when tracing is off.
+func untracedCall(tx *loadedTransaction) *Call {
+	call := &Call{
+		Type:    CallOpType,
+		From:    *tx.From,
+		Value:   tx.Transaction.Value(),
+		GasUsed: new(big.Int).SetUint64(tx.Receipt.GasUsed),
+		Revert:  tx.Receipt.Status == types.ReceiptStatusFailed,
+	}
+
+	if to := tx.Transaction.To(); to != nil {
+		call.To = *to
+	} else {
+		call.Type = CreateOpType
+		call.To = tx.Receipt.ContractAddress
+	}
+
+	return call
+}
+
+// flattenTraces recursively flattens all traces, appending
+// them (in depth-first order) to flattened.
 func flattenTraces(data *Call, flattened []*flatCall) []*flatCall {
-	results := append(flattened, data.flatten())
+	flattened = append(flattened, data.flatten())
 	for _, child := range data.Calls {
 		// Ensure all children of a reverted call
 		// are also reverted!
@@ -727,20 +1785,26 @@ func flattenTraces(data *Call, flattened []*flatCall) []*flatCall {
 			}
 		}
 
-		children := flattenTraces(child, flattened)
-		results = append(results, children...)
+		flattened = flattenTraces(child, flattened)
 	}
-	return results
+	return flattened
 }
 
 // traceOps returns all *RosettaTypes.Operation for a given
-// array of flattened traces.
-func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { // nolint: gocognit
+// array of flattened traces. It errors if an account destroyed
+// by the traces is left with a negative balance.
+func traceOps( // nolint: gocognit
+	calls []*flatCall,
+	startIndex int,
+) ([]*RosettaTypes.Operation, error) {
 	var ops []*RosettaTypes.Operation
 	if len(calls) == 0 {
-		return ops
+		return ops, nil
 	}
 
+	// Most calls have a from and a to operation.
+	ops = make([]*RosettaTypes.Operation, 0, 2*len(calls)) // nolint:gomnd
+
 	destroyedAccounts := map[string]*big.Int{}
 	for _, trace := range calls {
 		// Handle partial transaction success
@@ -766,8 +1830,8 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 		}
 
 		// Checksum addresses
-		from := MustChecksum(trace.From.String())
-		to := MustChecksum(trace.To.String())
+		from := trace.From.Hex()
+		to := trace.To.Hex()
 
 		if shouldAdd {
 			fromOp := &RosettaTypes.Operation{
@@ -780,7 +1844,7 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 					Address: from,
 				},
 				Amount: &RosettaTypes.Amount{
-					Value:    new(big.Int).Neg(trace.Value).String(),
+					Value:    negString(trace.Value),
 					Currency: Currency,
 				},
 				Metadata: metadata,
@@ -790,7 +1854,7 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 			} else {
 				_, destroyed := destroyedAccounts[from]
 				if destroyed && opStatus == SuccessStatus {
-					destroyedAccounts[from] = new(big.Int).Sub(destroyedAccounts[from], trace.Value)
+					destroyedAccounts[from].Sub(destroyedAccounts[from], trace.Value)
 				}
 			}
 
@@ -851,7 +1915,7 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 			} else {
 				_, destroyed := destroyedAccounts[to]
 				if destroyed && opStatus == SuccessStatus {
-					destroyedAccounts[to] = new(big.Int).Add(destroyedAccounts[to], trace.Value)
+					destroyedAccounts[to].Add(destroyedAccounts[to], trace.Value)
 				}
 			}
 
@@ -867,7 +1931,7 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 		}
 
 		if val.Sign() < 0 {
-			log.Fatalf("negative balance for suicided account %s: %s\n", acct, val.String())
+			return nil, fmt.Errorf("%w: %s has balance %s", ErrNegativeBalance, acct, val.String())
 		}
 
 		ops = append(ops, &RosettaTypes.Operation{
@@ -880,13 +1944,19 @@ func traceOps(calls []*flatCall, startIndex int) []*RosettaTypes.Operation { //
 				Address: acct,
 			},
 			Amount: &RosettaTypes.Amount{
-				Value:    new(big.Int).Neg(val).String(),
+				Value:    negString(val),
 				Currency: Currency,
 			},
 		})
 	}
 
-	return ops
+	// Transactions without operations
+	// are encoded with null operations.
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	return ops, nil
 }
 
 type txExtraInfo struct {
@@ -932,13 +2002,16 @@ type loadedTransaction struct {
 	Receipt  *types.Receipt
 }
 
+// feeOps returns the fee operations of tx: the tip moved from
+// the sender to the miner and, after EIP-1559, the base fee
+// burned from the sender.
 func feeOps(tx *loadedTransaction) []*RosettaTypes.Operation {
-	var minerEarnedAmount *big.Int
-	if tx.FeeBurned == nil {
-		minerEarnedAmount = tx.FeeAmount
-	} else {
-		minerEarnedAmount = new(big.Int).Sub(tx.FeeAmount, tx.FeeBurned)
+	minerEarnedAmount := tx.FeeAmount
+	if tx.FeeBurned != nil {
+		minerEarnedAmount = bigIntPool.Get().(*big.Int).Sub(tx.FeeAmount, tx.FeeBurned)
+		defer bigIntPool.Put(minerEarnedAmount)
 	}
+
 	ops := []*RosettaTypes.Operation{
 		{
 			OperationIdentifier: &RosettaTypes.OperationIdentifier{
@@ -947,12 +2020,15 @@ func feeOps(tx *loadedTransaction) []*RosettaTypes.Operation {
 			Type:   FeeOpType,
 			Status: RosettaTypes.String(SuccessStatus),
 			Account: &RosettaTypes.AccountIdentifier{
-				Address: MustChecksum(tx.From.String()),
+				Address: tx.From.Hex(),
 			},
 			Amount: &RosettaTypes.Amount{
-				Value:    new(big.Int).Neg(minerEarnedAmount).String(),
+				Value:    negString(minerEarnedAmount),
 				Currency: Currency,
 			},
+			Metadata: map[string]interface{}{
+				FeeTypeKey: TipFeeType,
+			},
 		},
 
 		{
@@ -967,12 +2043,15 @@ func feeOps(tx *loadedTransaction) []*RosettaTypes.Operation {
 			Type:   FeeOpType,
 			Status: RosettaTypes.String(SuccessStatus),
 			Account: &RosettaTypes.AccountIdentifier{
-				Address: MustChecksum(tx.Miner),
+				Address: tx.Miner,
 			},
 			Amount: &RosettaTypes.Amount{
 				Value:    minerEarnedAmount.String(),
 				Currency: Currency,
 			},
+			Metadata: map[string]interface{}{
+				FeeTypeKey: TipFeeType,
+			},
 		},
 	}
 	if tx.FeeBurned == nil {
@@ -985,12 +2064,15 @@ func feeOps(tx *loadedTransaction) []*RosettaTypes.Operation {
 		Type:   FeeOpType,
 		Status: RosettaTypes.String(SuccessStatus),
 		Account: &RosettaTypes.AccountIdentifier{
-			Address: MustChecksum(tx.From.String()),
+			Address: tx.From.Hex(),
 		},
 		Amount: &RosettaTypes.Amount{
-			Value:    new(big.Int).Neg(tx.FeeBurned).String(),
+			Value:    negString(tx.FeeBurned),
 			Currency: Currency,
 		},
+		Metadata: map[string]interface{}{
+			FeeTypeKey: BurnFeeType,
+		},
 	}
 	return append(ops, burntOp)
 }
@@ -1012,6 +2094,76 @@ func (ec *Client) transactionReceipt(
 	return r, err
 }
 
+// txStatus returns the status of the transaction with
+// the provided hash: pending while it is in the mempool,
+// confirmed (with its block, number of confirmations and
+// whether it succeeded) once it is included in a block,
+// and dropped if geth does not know about it.
+func (ec *Client) txStatus(
+	ctx context.Context,
+	txHash string,
+) (map[string]interface{}, error) {
+	hash, err := hexutil.Decode(txHash)
+	if err != nil || len(hash) != common.HashLength {
+		return nil, fmt.Errorf("%w:tx_hash is missing or invalid", ErrCallParametersInvalid)
+	}
+
+	_, pending, err := ec.TransactionByHash(ctx, common.BytesToHash(hash))
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		return map[string]interface{}{
+			"status":        TxStatusDropped,
+			"confirmations": int64(0),
+		}, nil
+	case err != nil:
+		return nil, err
+	case pending:
+		return map[string]interface{}{
+			"status":        TxStatusPending,
+			"confirmations": int64(0),
+		}, nil
+	}
+
+	receipt, err := ec.transactionReceipt(ctx, common.BytesToHash(hash))
+	if errors.Is(err, ethereum.NotFound) {
+		// The block including the transaction was
+		// reorganized out after it was fetched.
+		return map[string]interface{}{
+			"status":        TxStatusPending,
+			"confirmations": int64(0),
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not get receipt for %s", err, txHash)
+	}
+
+	latest, err := ec.latestHeader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not get latest block header", err)
+	}
+
+	// The latest header pushed by the newHeads subscription
+	// may lag behind the block of the receipt.
+	confirmations := new(big.Int).Sub(latest.Number, receipt.BlockNumber).Int64() + 1
+	if confirmations < 1 {
+		confirmations = 1
+	}
+
+	// Receipts from before Byzantium have a state root
+	// instead of a status, so their failure is unknown.
+	successful := receipt.Status == types.ReceiptStatusSuccessful || len(receipt.PostState) > 0
+
+	return map[string]interface{}{
+		"status": TxStatusConfirmed,
+		"block_identifier": &RosettaTypes.BlockIdentifier{
+			Hash:  receipt.BlockHash.Hex(),
+			Index: receipt.BlockNumber.Int64(),
+		},
+		"confirmations": confirmations,
+		"successful":    successful,
+	}, nil
+}
+
 func (ec *Client) blockByNumber(
 	ctx context.Context,
 	index *int64,
@@ -1118,6 +2270,65 @@ func (ec *Client) estimateGas(
 	}, nil
 }
 
+// getProof returns the account and storage proofs of an address
+// at the block identified by input, along with the identifier and
+// state root of that block so the proofs can be verified.
+func (ec *Client) getProof(
+	ctx context.Context,
+	input *GetProofInput,
+) (map[string]interface{}, error) {
+	if _, ok := ChecksumAddress(input.Address); !ok {
+		return nil, fmt.Errorf("%w:address is missing or invalid", ErrCallParametersInvalid)
+	}
+
+	var header *types.Header
+	var err error
+	switch {
+	case len(input.BlockHash) > 0:
+		header, err = ec.blockHeaderByHash(ctx, input.BlockHash)
+	case input.BlockIndex != nil:
+		header, err = ec.blockHeaderByNumber(ctx, big.NewInt(*input.BlockIndex))
+	default:
+		header, err = ec.blockHeaderByNumber(ctx, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not get block header", err)
+	}
+
+	storageKeys := input.StorageKeys
+	if storageKeys == nil {
+		storageKeys = []string{}
+	}
+
+	// The proof is requested by hash so it matches
+	// the header even if the chain reorganizes.
+	var raw json.RawMessage
+	if err := ec.c.CallContext(
+		ctx,
+		&raw,
+		"eth_getProof",
+		input.Address,
+		storageKeys,
+		header.Hash().Hex(),
+	); err != nil {
+		return nil, err
+	}
+
+	var proof map[string]interface{}
+	if err := json.Unmarshal(raw, &proof); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallOutputMarshal, err.Error())
+	}
+
+	return map[string]interface{}{
+		"block_identifier": &RosettaTypes.BlockIdentifier{
+			Hash:  header.Hash().Hex(),
+			Index: header.Number.Int64(),
+		},
+		"state_root": header.Root.Hex(),
+		"proof":      proof,
+	}, nil
+}
+
 func validateCallInput(params map[string]interface{}) (*GetCallInput, error) {
 	var input GetCallInput
 	if err := RosettaTypes.UnmarshalMap(params, &input); err != nil {
@@ -1148,6 +2359,15 @@ func (ec *Client) getParsedBlock(
 		return nil, fmt.Errorf("%w: could not get block", err)
 	}
 
+	return ec.parseBlock(block, loadedTransactions)
+}
+
+// parseBlock converts block and its loaded
+// transactions to a Rosetta block.
+func (ec *Client) parseBlock(
+	block *types.Block,
+	loadedTransactions []*loadedTransaction,
+) (*RosettaTypes.Block, error) {
 	blockIdentifier := &RosettaTypes.BlockIdentifier{
 		Hash:  block.Hash().String(),
 		Index: block.Number().Int64(),
@@ -1166,11 +2386,19 @@ func (ec *Client) getParsedBlock(
 		return nil, err
 	}
 
+	var metadata map[string]interface{}
+	if ec.tc == nil && blockIdentifier.Index != GenesisBlockIndex {
+		metadata = map[string]interface{}{
+			traceIncompleteKey: true,
+		}
+	}
+
 	return &RosettaTypes.Block{
 		BlockIdentifier:       blockIdentifier,
 		ParentBlockIdentifier: parentBlockIdentifier,
 		Timestamp:             convertTime(block.Time()),
 		Transactions:          txs,
+		Metadata:              metadata,
 	}, nil
 }
 
@@ -1191,19 +2419,36 @@ func (ec *Client) populateTransactions(
 	// Compute reward transaction (block + uncle reward)
 	transactions[0] = ec.blockRewardTransaction(
 		blockIdentifier,
-		block.Coinbase().String(),
+		block.Coinbase().Hex(),
 		block.Uncles(),
 	)
 
-	for i, tx := range loadedTransactions {
+	// Credit genesis allocations alongside
+	// the genesis block reward
+	if ec.genesisBalances && blockIdentifier.Index == GenesisBlockIndex {
+		rewardTx := transactions[0]
+		rewardTx.Operations = append(
+			rewardTx.Operations,
+			genesisOps(block.Hash(), len(rewardTx.Operations))...,
+		)
+	}
+
+	// Transactions are parsed (decoding their receipts and
+	// traces) concurrently, on at most one goroutine per CPU.
+	err := parallel(len(loadedTransactions), runtime.GOMAXPROCS(0), func(i int) error {
+		tx := loadedTransactions[i]
 		transaction, err := ec.populateTransaction(
 			tx,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("%w: cannot parse %s", err, tx.Transaction.Hash().Hex())
+			return fmt.Errorf("%w: cannot parse %s", err, tx.Transaction.Hash().Hex())
 		}
 
 		transactions[i+1] = transaction
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return transactions, nil
@@ -1212,17 +2457,33 @@ func (ec *Client) populateTransactions(
 func (ec *Client) populateTransaction(
 	tx *loadedTransaction,
 ) (*RosettaTypes.Transaction, error) {
-	var ops []*RosettaTypes.Operation
-
 	// Compute fee operations
 	feeOps := feeOps(tx)
-	ops = append(ops, feeOps...)
 
-	// Compute trace operations
-	traces := flattenTraces(tx.Trace, []*flatCall{})
+	// Compute trace operations (only the top-level
+	// call is known when tracing is off). Traces are
+	// flattened into a pooled slice, which is only
+	// used while the operations are computed.
+	trace := tx.Trace
+	if trace == nil {
+		trace = untracedCall(tx)
+	}
+	traces := getFlatCalls()
+	defer putFlatCalls(traces)
+	*traces = flattenTraces(trace, *traces)
+
+	traceOps, err := traceOps(*traces, len(feeOps))
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute token transfer operations
+	tokenOps := tokenTransferOps(ec.tokens, tx.Receipt, len(feeOps)+len(traceOps))
 
-	traceOps := traceOps(traces, len(ops))
+	ops := make([]*RosettaTypes.Operation, 0, len(feeOps)+len(traceOps)+len(tokenOps))
+	ops = append(ops, feeOps...)
 	ops = append(ops, traceOps...)
+	ops = append(ops, tokenOps...)
 
 	// Marshal receipt and trace data
 	// TODO: replace with marshalJSONMap (used in `services`)
@@ -1236,9 +2497,20 @@ func (ec *Client) populateTransaction(
 		return nil, err
 	}
 
-	var traceMap map[string]interface{}
-	if err := json.Unmarshal(tx.RawTrace, &traceMap); err != nil {
-		return nil, err
+	metadata := map[string]interface{}{
+		"gas_limit": hexutil.EncodeUint64(tx.Transaction.Gas()),
+		"gas_price": hexutil.EncodeBig(tx.Transaction.GasPrice()),
+		"receipt":   receiptMap,
+	}
+
+	if tx.Trace == nil {
+		metadata[traceIncompleteKey] = true
+	} else {
+		var traceMap map[string]interface{}
+		if err := json.Unmarshal(tx.RawTrace, &traceMap); err != nil {
+			return nil, err
+		}
+		metadata["trace"] = traceMap
 	}
 
 	populatedTransaction := &RosettaTypes.Transaction{
@@ -1246,12 +2518,7 @@ func (ec *Client) populateTransaction(
 			Hash: tx.Transaction.Hash().Hex(),
 		},
 		Operations: ops,
-		Metadata: map[string]interface{}{
-			"gas_limit": hexutil.EncodeUint64(tx.Transaction.Gas()),
-			"gas_price": hexutil.EncodeBig(tx.Transaction.GasPrice()),
-			"receipt":   receiptMap,
-			"trace":     traceMap,
-		},
+		Metadata:   metadata,
 	}
 
 	return populatedTransaction, nil
@@ -1303,7 +2570,7 @@ func (ec *Client) blockRewardTransaction(
 		Type:   MinerRewardOpType,
 		Status: RosettaTypes.String(SuccessStatus),
 		Account: &RosettaTypes.AccountIdentifier{
-			Address: MustChecksum(miner),
+			Address: miner,
 		},
 		Amount: &RosettaTypes.Amount{
 			Value:    strconv.FormatInt(minerReward, 10),
@@ -1314,7 +2581,7 @@ func (ec *Client) blockRewardTransaction(
 
 	// Calculate uncle rewards
 	for _, b := range uncles {
-		uncleMiner := b.Coinbase.String()
+		uncleMiner := b.Coinbase.Hex()
 		uncleBlock := b.Number.Int64()
 		uncleRewardBlock := new(
 			big.Int,
@@ -1330,7 +2597,7 @@ func (ec *Client) blockRewardTransaction(
 			Type:   UncleRewardOpType,
 			Status: RosettaTypes.String(SuccessStatus),
 			Account: &RosettaTypes.AccountIdentifier{
-				Address: MustChecksum(uncleMiner),
+				Address: uncleMiner,
 			},
 			Amount: &RosettaTypes.Amount{
 				Value:    uncleRewardBlock.String(),
@@ -1401,39 +2668,178 @@ type graphqlBalance struct {
 	} `json:"data"`
 }
 
+// graphqlTokenBalance is the result of the balanceOf
+// call of a token in a graphql balance query.
+type graphqlTokenBalance struct {
+	Data string `json:"data"`
+}
+
+// tokenBalanceField returns the alias of the
+// balanceOf call of the i-th requested currency.
+func tokenBalanceField(i int) string {
+	return fmt.Sprintf("token%d", i)
+}
+
+// tokenBalanceQueries returns the balanceOf calls of
+// the tokens in currencies to add to a graphql balance
+// query, aliased with tokenBalanceField.
+func tokenBalanceQueries(
+	account *RosettaTypes.AccountIdentifier,
+	currencies []*RosettaTypes.Currency,
+) (string, error) {
+	data := append(
+		append([]byte{}, balanceOfMethodID...),
+		common.LeftPadBytes(common.HexToAddress(account.Address).Bytes(), 32)..., // nolint:gomnd
+	)
+
+	queries := ""
+	for i, currency := range currencies {
+		if RosettaTypes.Hash(currency) == RosettaTypes.Hash(Currency) {
+			continue
+		}
+
+		contract, ok := currency.Metadata[ContractAddressKey].(string)
+		if !ok || !common.IsHexAddress(contract) {
+			return "", fmt.Errorf("currency %s has no valid %s", currency.Symbol, ContractAddressKey)
+		}
+
+		queries += fmt.Sprintf(`
+				%s: call(data:{to:"%s", data:"%s"}){
+					data
+				}`, tokenBalanceField(i), contract, hexutil.Encode(data))
+	}
+
+	return queries, nil
+}
+
+const (
+	// codeHashKey and isContractKey are the keys of the
+	// balance metadata holding the code hash of the account
+	// and whether it is a contract (i.e. it has code).
+	codeHashKey   = "code_hash"
+	isContractKey = "is_contract"
+)
+
+// maxBalanceAttempts is the number of times a balance not
+// requested by block hash is looked up when the state of the
+// block it is pinned to is unavailable.
+const maxBalanceAttempts = 3
+
+// stateUnavailableMessages are the messages of the errors
+// returned by geth when the state of a block is unavailable:
+// it is pruned, or the block is unknown to the node serving
+// the query (i.e. it was just mined or reorganized out).
+var stateUnavailableMessages = []string{
+	"missing trie node",
+	"header for hash not found",
+	"required historical state unavailable",
+}
+
+// StateUnavailable returns whether the message of an error
+// returned by geth (i.e. a graphql or JSON-RPC error) means
+// the state of the block is unavailable.
+func StateUnavailable(message string) bool {
+	for _, unavailable := range stateUnavailableMessages {
+		if strings.Contains(message, unavailable) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Balance returns the balance of a *RosettaTypes.AccountIdentifier
-// at a *RosettaTypes.PartialBlockIdentifier.
+// at a *RosettaTypes.PartialBlockIdentifier in each of currencies
+// (ETH, or ERC-20 tokens with a ContractAddressKey in their
+// metadata), or in ETH when no currencies are provided.
 //
-// We must use graphql to get the balance atomically (the
-// rpc method for balance does not allow for querying
-// by block hash nor return the block hash where
-// the balance was fetched).
+// All balances are looked up in a single block pinned by its
+// hash: when the block is requested by index (or the latest
+// block is), its hash is resolved first, and the lookup is
+// retried on a newly resolved block if the state of the pinned
+// one is unavailable. Otherwise, each field of the query could
+// be resolved in a different block while the chain advances
+// or reorganizes.
 func (ec *Client) Balance(
 	ctx context.Context,
 	account *RosettaTypes.AccountIdentifier,
 	block *RosettaTypes.PartialBlockIdentifier,
+	currencies []*RosettaTypes.Currency,
 ) (*RosettaTypes.AccountBalanceResponse, error) {
-	blockQuery := ""
-	if block != nil {
-		if block.Hash != nil {
-			blockQuery = fmt.Sprintf(`hash: "%s"`, *block.Hash)
+	pinned := block != nil && block.Hash != nil
+	for attempt := 1; ; attempt++ {
+		var blockHash string
+		if pinned {
+			blockHash = *block.Hash
+		} else {
+			var index *int64
+			if block != nil {
+				index = block.Index
+			}
+
+			blockIdentifier, err := ec.BlockIdentifier(ctx, index)
+			if err != nil {
+				return nil, err
+			}
+			blockHash = blockIdentifier.Hash
+		}
+
+		balance, err := ec.balanceAt(ctx, account, blockHash, currencies)
+		if errors.Is(err, ErrStateUnavailable) && !pinned && attempt < maxBalanceAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
-		if block.Hash == nil && block.Index != nil {
-			blockQuery = fmt.Sprintf("number: %d", *block.Index)
+
+		// The balance is looked up by hash, so the
+		// requested index must match it.
+		if block != nil && block.Index != nil &&
+			*block.Index != balance.BlockIdentifier.Index {
+			return nil, fmt.Errorf(
+				"%w: block %s has index %d, not %d",
+				ErrBlockMismatch,
+				blockHash,
+				balance.BlockIdentifier.Index,
+				*block.Index,
+			)
 		}
+
+		return balance, nil
+	}
+}
+
+// balanceAt returns the balances of account in
+// currencies at the block with hash blockHash.
+//
+// We must use graphql to get the balance atomically (the
+// rpc method for balance does not allow for querying
+// by block hash nor return the block hash where
+// the balance was fetched). Token balances are fetched
+// with balanceOf calls in the same block.
+func (ec *Client) balanceAt(
+	ctx context.Context,
+	account *RosettaTypes.AccountIdentifier,
+	blockHash string,
+	currencies []*RosettaTypes.Currency,
+) (*RosettaTypes.AccountBalanceResponse, error) {
+
+	tokenQueries, err := tokenBalanceQueries(account, currencies)
+	if err != nil {
+		return nil, err
 	}
 
 	result, err := ec.g.Query(ctx, fmt.Sprintf(`{
-			block(%s){
+			block(hash: "%s"){
 				hash
 				number
 				account(address:"%s"){
 					balance
 					transactionCount
 					code
-				}
+				}%s
 			}
-		}`, blockQuery, account.Address))
+		}`, blockHash, account.Address, tokenQueries))
 	if err != nil {
 		return nil, err
 	}
@@ -1444,7 +2850,14 @@ func (ec *Client) Balance(
 	}
 
 	if len(bal.Errors) > 0 {
-		return nil, errors.New(RosettaTypes.PrintStruct(bal.Errors))
+		err := errors.New(RosettaTypes.PrintStruct(bal.Errors))
+		for _, graphqlErr := range bal.Errors {
+			if StateUnavailable(graphqlErr.Message) {
+				return nil, fmt.Errorf("%w: %s", ErrStateUnavailable, err)
+			}
+		}
+
+		return nil, err
 	}
 
 	balance, ok := new(big.Int).SetString(bal.Data.Block.Account.Balance[2:], 16)
@@ -1461,14 +2874,30 @@ func (ec *Client) Balance(
 			bal.Data.Block.Account.Nonce,
 		)
 	}
+	code, err := hexutil.Decode(bal.Data.Block.Account.Code)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: could not extract account code from %s",
+			err,
+			bal.Data.Block.Account.Code,
+		)
+	}
 
-	return &RosettaTypes.AccountBalanceResponse{
-		Balances: []*RosettaTypes.Amount{
-			{
-				Value:    balance.String(),
-				Currency: Currency,
-			},
+	balances := []*RosettaTypes.Amount{
+		{
+			Value:    balance.String(),
+			Currency: Currency,
 		},
+	}
+	if len(currencies) > 0 {
+		balances, err = currencyBalances(result, balance, currencies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &RosettaTypes.AccountBalanceResponse{
+		Balances: balances,
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
 			Hash:  bal.Data.Block.Hash,
 			Index: bal.Data.Block.Number,
@@ -1476,10 +2905,66 @@ func (ec *Client) Balance(
 		Metadata: map[string]interface{}{
 			"nonce": nonce.Int64(),
 			"code":  bal.Data.Block.Account.Code,
+			// The code hash and contract flag tell contracts apart
+			// from externally owned accounts without the code.
+			codeHashKey:   crypto.Keccak256Hash(code).Hex(),
+			isContractKey: len(code) > 0,
 		},
 	}, nil
 }
 
+// currencyBalances returns the balances in currencies of the
+// graphql balance query result, with balance as the ETH balance.
+func currencyBalances(
+	result string,
+	balance *big.Int,
+	currencies []*RosettaTypes.Currency,
+) ([]*RosettaTypes.Amount, error) {
+	var tokens struct {
+		Data struct {
+			Block map[string]json.RawMessage `json:"block"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(result), &tokens); err != nil {
+		return nil, err
+	}
+
+	balances := make([]*RosettaTypes.Amount, len(currencies))
+	for i, currency := range currencies {
+		if RosettaTypes.Hash(currency) == RosettaTypes.Hash(Currency) {
+			balances[i] = &RosettaTypes.Amount{
+				Value:    balance.String(),
+				Currency: currency,
+			}
+			continue
+		}
+
+		var tokenBalance graphqlTokenBalance
+		if err := json.Unmarshal(tokens.Data.Block[tokenBalanceField(i)], &tokenBalance); err != nil {
+			return nil, fmt.Errorf("%w: could not extract %s balance", err, currency.Symbol)
+		}
+
+		// balanceOf returns a single uint256 (anything
+		// else is a revert or a contract that is not
+		// a token).
+		data, err := hexutil.Decode(tokenBalance.Data)
+		if err != nil || len(data) != 32 { // nolint:gomnd
+			return nil, fmt.Errorf(
+				"could not extract %s balance from %s",
+				currency.Symbol,
+				tokenBalance.Data,
+			)
+		}
+
+		balances[i] = &RosettaTypes.Amount{
+			Value:    new(big.Int).SetBytes(data).String(),
+			Currency: currency,
+		}
+	}
+
+	return balances, nil
+}
+
 // GetBlockByNumberInput is the input to the call
 // method "eth_getBlockByNumber".
 type GetBlockByNumberInput struct {
@@ -1493,6 +2978,12 @@ type GetTransactionReceiptInput struct {
 	TxHash string `json:"tx_hash"`
 }
 
+// TxStatusInput is the input to the call
+// method "tx_status".
+type TxStatusInput struct {
+	TxHash string `json:"tx_hash"`
+}
+
 // GetCallInput is the input to the call
 // method "eth_call", "eth_estimateGas".
 type GetCallInput struct {
@@ -1506,78 +2997,187 @@ type GetCallInput struct {
 	Data       string `json:"data"`
 }
 
+// GetProofInput is the input to the call
+// method "eth_getProof". When neither the block
+// index nor hash is provided, the proof is taken
+// at the latest block.
+type GetProofInput struct {
+	BlockIndex  *int64   `json:"index,omitempty"`
+	BlockHash   string   `json:"hash,omitempty"`
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storage_keys"`
+}
+
+// callMethod is a method of the /call endpoint.
+type callMethod struct {
+	name   string
+	handle func(*Client, context.Context, map[string]interface{}) (*RosettaTypes.CallResponse, error)
+}
+
+// callMethods are all supported call methods. CallMethods is
+// derived from them, so the methods advertised in /network/options
+// are always the ones handled by Call.
+var callMethods = []*callMethod{
+	{name: "eth_getBlockByNumber", handle: (*Client).callGetBlockByNumber},
+	{name: "eth_getTransactionReceipt", handle: (*Client).callGetTransactionReceipt},
+	{name: "eth_call", handle: (*Client).callContractCall},
+	{name: "eth_estimateGas", handle: (*Client).callEstimateGas},
+	{name: "eth_getProof", handle: (*Client).callGetProof},
+	{name: "tx_status", handle: (*Client).callTxStatus},
+}
+
+// callMethodNames returns the names of callMethods.
+func callMethodNames() []string {
+	names := make([]string, len(callMethods))
+	for i, method := range callMethods {
+		names[i] = method.name
+	}
+
+	return names
+}
+
 // Call handles calls to the /call endpoint.
 func (ec *Client) Call(
 	ctx context.Context,
 	request *RosettaTypes.CallRequest,
 ) (*RosettaTypes.CallResponse, error) {
-	switch request.Method { // nolint:gocritic
-	case "eth_getBlockByNumber":
-		var input GetBlockByNumberInput
-		if err := RosettaTypes.UnmarshalMap(request.Parameters, &input); err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
+	for _, method := range callMethods {
+		if method.name == request.Method {
+			return method.handle(ec, ctx, request.Parameters)
 		}
+	}
 
-		res, err := ec.blockByNumber(ctx, input.Index, input.ShowTxDetails)
-		if err != nil {
-			return nil, err
-		}
+	return nil, fmt.Errorf("%w: %s", ErrCallMethodInvalid, request.Method)
+}
 
-		return &RosettaTypes.CallResponse{
-			Result: res,
-		}, nil
-	case "eth_getTransactionReceipt":
-		var input GetTransactionReceiptInput
-		if err := RosettaTypes.UnmarshalMap(request.Parameters, &input); err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
-		}
+// callGetBlockByNumber handles the call method "eth_getBlockByNumber".
+func (ec *Client) callGetBlockByNumber(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	var input GetBlockByNumberInput
+	if err := RosettaTypes.UnmarshalMap(params, &input); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
+	}
 
-		if len(input.TxHash) == 0 {
-			return nil, fmt.Errorf("%w:tx_hash missing from params", ErrCallParametersInvalid)
-		}
+	res, err := ec.blockByNumber(ctx, input.Index, input.ShowTxDetails)
+	if err != nil {
+		return nil, err
+	}
 
-		receipt, err := ec.transactionReceipt(ctx, common.HexToHash(input.TxHash))
-		if err != nil {
-			return nil, err
-		}
+	return &RosettaTypes.CallResponse{
+		Result: res,
+	}, nil
+}
 
-		// We cannot use RosettaTypes.MarshalMap because geth uses a custom
-		// marshaler to convert *types.Receipt to JSON.
-		jsonOutput, err := receipt.MarshalJSON()
-		if err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrCallOutputMarshal, err.Error())
-		}
+// callGetTransactionReceipt handles the call
+// method "eth_getTransactionReceipt".
+func (ec *Client) callGetTransactionReceipt(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	var input GetTransactionReceiptInput
+	if err := RosettaTypes.UnmarshalMap(params, &input); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
+	}
 
-		var receiptMap map[string]interface{}
-		if err := json.Unmarshal(jsonOutput, &receiptMap); err != nil {
-			return nil, fmt.Errorf("%w: %s", ErrCallOutputMarshal, err.Error())
-		}
+	if len(input.TxHash) == 0 {
+		return nil, fmt.Errorf("%w:tx_hash missing from params", ErrCallParametersInvalid)
+	}
 
-		// We must encode data over the wire so we can unmarshal correctly
-		return &RosettaTypes.CallResponse{
-			Result: receiptMap,
-		}, nil
-	case "eth_call":
-		resp, err := ec.contractCall(ctx, request.Parameters)
-		if err != nil {
-			return nil, err
-		}
+	receipt, err := ec.transactionReceipt(ctx, common.HexToHash(input.TxHash))
+	if err != nil {
+		return nil, err
+	}
 
-		return &RosettaTypes.CallResponse{
-			Result: resp,
-		}, nil
-	case "eth_estimateGas":
-		resp, err := ec.estimateGas(ctx, request.Parameters)
-		if err != nil {
-			return nil, err
-		}
+	// We cannot use RosettaTypes.MarshalMap because geth uses a custom
+	// marshaler to convert *types.Receipt to JSON.
+	jsonOutput, err := receipt.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallOutputMarshal, err.Error())
+	}
 
-		return &RosettaTypes.CallResponse{
-			Result: resp,
-		}, nil
+	var receiptMap map[string]interface{}
+	if err := json.Unmarshal(jsonOutput, &receiptMap); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallOutputMarshal, err.Error())
 	}
 
-	return nil, fmt.Errorf("%w: %s", ErrCallMethodInvalid, request.Method)
+	// We must encode data over the wire so we can unmarshal correctly
+	return &RosettaTypes.CallResponse{
+		Result: receiptMap,
+	}, nil
+}
+
+// callContractCall handles the call method "eth_call".
+func (ec *Client) callContractCall(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	resp, err := ec.contractCall(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RosettaTypes.CallResponse{
+		Result: resp,
+	}, nil
+}
+
+// callEstimateGas handles the call method "eth_estimateGas".
+func (ec *Client) callEstimateGas(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	resp, err := ec.estimateGas(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RosettaTypes.CallResponse{
+		Result: resp,
+	}, nil
+}
+
+// callGetProof handles the call method "eth_getProof".
+func (ec *Client) callGetProof(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	var input GetProofInput
+	if err := RosettaTypes.UnmarshalMap(params, &input); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
+	}
+
+	resp, err := ec.getProof(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	// A proof at a requested block never changes.
+	return &RosettaTypes.CallResponse{
+		Result:     resp,
+		Idempotent: input.BlockIndex != nil || len(input.BlockHash) > 0,
+	}, nil
+}
+
+// callTxStatus handles the call method "tx_status".
+func (ec *Client) callTxStatus(
+	ctx context.Context,
+	params map[string]interface{},
+) (*RosettaTypes.CallResponse, error) {
+	var input TxStatusInput
+	if err := RosettaTypes.UnmarshalMap(params, &input); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCallParametersInvalid, err.Error())
+	}
+
+	resp, err := ec.txStatus(ctx, input.TxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RosettaTypes.CallResponse{
+		Result: resp,
+	}, nil
 }
 
 // txPoolContentResponse represents the response for a call to
@@ -1589,10 +3189,28 @@ type txPoolContentResponse struct {
 
 type txPool map[string]txPoolInner
 
+const (
+	// mempoolPending and mempoolQueued are the pools of
+	// the Ethereum TxPool: transactions that can be
+	// included in the next block, and transactions
+	// waiting for a nonce gap to be filled.
+	mempoolPending = "pending"
+	mempoolQueued  = "queued"
+)
+
 type txPoolInner map[string]rpcTransaction
 
 // GetMempool get and returns all the transactions on Ethereum TxPool (pending and queued).
 func (ec *Client) GetMempool(ctx context.Context) (*RosettaTypes.MempoolResponse, error) {
+	if hashes, ok := ec.mempool.hashes(); ok {
+		identifiers := make([]*RosettaTypes.TransactionIdentifier, len(hashes))
+		for i, hash := range hashes {
+			identifiers[i] = &RosettaTypes.TransactionIdentifier{Hash: hash.String()}
+		}
+
+		return &RosettaTypes.MempoolResponse{TransactionIdentifiers: identifiers}, nil
+	}
+
 	var response txPoolContentResponse
 	if err := ec.c.CallContext(ctx, &response, "txpool_content"); err != nil {
 		return nil, err
@@ -1618,3 +3236,79 @@ func (ec *Client) GetMempool(ctx context.Context) (*RosettaTypes.MempoolResponse
 
 	return &RosettaTypes.MempoolResponse{TransactionIdentifiers: identifiers}, nil
 }
+
+// MempoolTransaction returns the transaction with the provided hash in
+// the Ethereum TxPool (pending or queued), or ethereum.NotFound if it is
+// not there. As the transaction has not been executed, its operations
+// (the transfer of its value, or the creation of a contract) have no
+// status and its fee is unknown.
+func (ec *Client) MempoolTransaction(
+	ctx context.Context,
+	hash string,
+) (*RosettaTypes.Transaction, error) {
+	var response txPoolContentResponse
+	if err := ec.c.CallContext(ctx, &response, "txpool_content"); err != nil {
+		return nil, err
+	}
+
+	for pool, content := range map[string]txPool{
+		mempoolPending: response.Pending,
+		mempoolQueued:  response.Queued,
+	} {
+		for from, inner := range content {
+			for _, info := range inner {
+				if info.tx.Hash().Hex() != hash {
+					continue
+				}
+
+				return mempoolTransaction(common.HexToAddress(from), info.tx, pool)
+			}
+		}
+	}
+
+	return nil, ethereum.NotFound
+}
+
+// mempoolTransaction returns the transaction tx sent by
+// from, which is in the pool of the Ethereum TxPool.
+func mempoolTransaction(
+	from common.Address,
+	tx *types.Transaction,
+	pool string,
+) (*RosettaTypes.Transaction, error) {
+	call := &Call{
+		Type:    CallOpType,
+		From:    from,
+		Value:   tx.Value(),
+		GasUsed: new(big.Int),
+	}
+	if to := tx.To(); to != nil {
+		call.To = *to
+	} else {
+		call.Type = CreateOpType
+		call.To = crypto.CreateAddress(from, tx.Nonce())
+	}
+
+	// Operations that have not been
+	// executed have no status.
+	ops, err := traceOps(flattenTraces(call, []*flatCall{}), 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		op.Status = nil
+	}
+
+	return &RosettaTypes.Transaction{
+		TransactionIdentifier: &RosettaTypes.TransactionIdentifier{
+			Hash: tx.Hash().Hex(),
+		},
+		Operations: ops,
+		Metadata: map[string]interface{}{
+			"pool":      pool,
+			"nonce":     hexutil.EncodeUint64(tx.Nonce()),
+			"gas_limit": hexutil.EncodeUint64(tx.Gas()),
+			"gas_price": hexutil.EncodeBig(tx.GasPrice()),
+		},
+	}, nil
+}
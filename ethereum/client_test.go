@@ -24,6 +24,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
 
@@ -145,7 +146,12 @@ func TestStatus_NotSyncing(t *testing.T) {
 		Index: 8916656,
 	}, block)
 	assert.Equal(t, int64(1603225195000), timestamp)
-	assert.Nil(t, syncStatus)
+	assert.Equal(t, &RosettaTypes.SyncStatus{
+		CurrentIndex: RosettaTypes.Int64(8916656),
+		TargetIndex:  RosettaTypes.Int64(8916656),
+		Stage:        RosettaTypes.String(SyncStageSynced),
+		Synced:       RosettaTypes.Bool(true),
+	}, syncStatus)
 	assert.Equal(t, []*RosettaTypes.Peer{
 		{
 			PeerID: "16dedaa93519f9ba41a50d77876aae4bfcddfa7cecf232b9abe3ab5bf0b871f3",
@@ -155,9 +161,14 @@ func TestStatus_NotSyncing(t *testing.T) {
 					"eth/64",
 					"eth/65",
 				},
-				"enode": "enode://5654cc39fd278c994c451434dfa7b1a44977c52018a87e911368b54daf795955d5a2dc2ece98be5a7e8d0eb245c8ef573c92e04e8b15363f9c713a8127fe7c7b@35.183.116.112:57510", // nolint
-				"enr":   "",
-				"name":  "Geth/v1.9.22-stable-c71a7e26/linux-amd64/go1.15",
+				"enode":          "enode://5654cc39fd278c994c451434dfa7b1a44977c52018a87e911368b54daf795955d5a2dc2ece98be5a7e8d0eb245c8ef573c92e04e8b15363f9c713a8127fe7c7b@35.183.116.112:57510", // nolint
+				"enr":            "",
+				"name":           "Geth/v1.9.22-stable-c71a7e26/linux-amd64/go1.15",
+				"direction":      "inbound",
+				"local_address":  "172.31.2.163:30303",
+				"remote_address": "35.183.116.112:57510",
+				"trusted":        false,
+				"static":         false,
 				"protocols": map[string]interface{}{
 					"eth": map[string]interface{}{
 						"difficulty": float64(31779242235308530),
@@ -175,9 +186,14 @@ func TestStatus_NotSyncing(t *testing.T) {
 					"eth/64",
 					"eth/65",
 				},
-				"enode": "enode://bead1278155bfabdd51f04a6e896356da2f5687aa1f550bebc540828579522b87e22c67edf90efa651582e40c8c8037eb0f998208cab4a69b52c5e3387671b59@174.129.122.13:30303",                                                    // nolint
-				"enr":   "enr:-Je4QICGSLfIHa7vX3bdWnKqWIS7YwmLUP6JVqU5nBhxPpH_X_Uz1pZwVS8a48uESHay1nvz9FtxLYFftpMr3wvFZJ4Qg2V0aMfGhGcn75CAgmlkgnY0gmlwhK6Beg2Jc2VjcDI1NmsxoQO-rRJ4FVv6vdUfBKboljVtovVoeqH1UL68VAgoV5UiuIN0Y3CCdl-DdWRwgnZf", // nolint
-				"name":  "Geth/v1.9.15-omnibus-75eb5240/linux-amd64/go1.14.4",
+				"enode":          "enode://bead1278155bfabdd51f04a6e896356da2f5687aa1f550bebc540828579522b87e22c67edf90efa651582e40c8c8037eb0f998208cab4a69b52c5e3387671b59@174.129.122.13:30303",                                                    // nolint
+				"enr":            "enr:-Je4QICGSLfIHa7vX3bdWnKqWIS7YwmLUP6JVqU5nBhxPpH_X_Uz1pZwVS8a48uESHay1nvz9FtxLYFftpMr3wvFZJ4Qg2V0aMfGhGcn75CAgmlkgnY0gmlwhK6Beg2Jc2VjcDI1NmsxoQO-rRJ4FVv6vdUfBKboljVtovVoeqH1UL68VAgoV5UiuIN0Y3CCdl-DdWRwgnZf", // nolint
+				"name":           "Geth/v1.9.15-omnibus-75eb5240/linux-amd64/go1.14.4",
+				"direction":      "outbound",
+				"local_address":  "172.31.2.163:37908",
+				"remote_address": "174.129.122.13:30303",
+				"trusted":        false,
+				"static":         false,
 				"protocols": map[string]interface{}{
 					"eth": map[string]interface{}{
 						"difficulty": float64(31779248439556308),
@@ -194,6 +210,62 @@ func TestStatus_NotSyncing(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestNewSyncStatus(t *testing.T) {
+	genesis := &types.Header{Number: big.NewInt(0)}
+	head := &types.Header{Number: big.NewInt(100)}
+
+	tests := map[string]struct {
+		header   *types.Header
+		progress *ethereum.SyncProgress
+		expected *RosettaTypes.SyncStatus
+	}{
+		"not started": {
+			header: genesis,
+			expected: &RosettaTypes.SyncStatus{
+				CurrentIndex: RosettaTypes.Int64(0),
+				TargetIndex:  RosettaTypes.Int64(0),
+				Stage:        RosettaTypes.String(SyncStageNotStarted),
+				Synced:       RosettaTypes.Bool(false),
+			},
+		},
+		"blocks": {
+			header:   genesis,
+			progress: &ethereum.SyncProgress{CurrentBlock: 50, HighestBlock: 100},
+			expected: &RosettaTypes.SyncStatus{
+				CurrentIndex: RosettaTypes.Int64(50),
+				TargetIndex:  RosettaTypes.Int64(100),
+				Stage:        RosettaTypes.String(SyncStageBlocks),
+				Synced:       RosettaTypes.Bool(false),
+			},
+		},
+		"state": {
+			header:   genesis,
+			progress: &ethereum.SyncProgress{CurrentBlock: 100, HighestBlock: 100, KnownStates: 10},
+			expected: &RosettaTypes.SyncStatus{
+				CurrentIndex: RosettaTypes.Int64(100),
+				TargetIndex:  RosettaTypes.Int64(100),
+				Stage:        RosettaTypes.String(SyncStageState),
+				Synced:       RosettaTypes.Bool(false),
+			},
+		},
+		"synced": {
+			header: head,
+			expected: &RosettaTypes.SyncStatus{
+				CurrentIndex: RosettaTypes.Int64(100),
+				TargetIndex:  RosettaTypes.Int64(100),
+				Stage:        RosettaTypes.String(SyncStageSynced),
+				Synced:       RosettaTypes.Bool(true),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, newSyncStatus(test.header, test.progress))
+		})
+	}
+}
+
 func TestStatus_NotSyncing_SkipAdminCalls(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -263,7 +335,12 @@ func TestStatus_NotSyncing_SkipAdminCalls(t *testing.T) {
 		Index: 8916656,
 	}, block)
 	assert.Equal(t, int64(1603225195000), timestamp)
-	assert.Nil(t, syncStatus)
+	assert.Equal(t, &RosettaTypes.SyncStatus{
+		CurrentIndex: RosettaTypes.Int64(8916656),
+		TargetIndex:  RosettaTypes.Int64(8916656),
+		Stage:        RosettaTypes.String(SyncStageSynced),
+		Synced:       RosettaTypes.Bool(true),
+	}, syncStatus)
 	assert.Equal(t, []*RosettaTypes.Peer{}, peers)
 	assert.NoError(t, err)
 
@@ -271,6 +348,30 @@ func TestStatus_NotSyncing_SkipAdminCalls(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestPeers_AdminUnavailable(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	c := &Client{
+		c:              mockJSONRPC,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"admin_peers",
+	).Return(
+		&methodNotFoundError{},
+	).Once()
+
+	peers, err := c.peers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*RosettaTypes.Peer{}, peers)
+
+	mockJSONRPC.AssertExpectations(t)
+}
+
 func TestStatus_Syncing(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -347,6 +448,8 @@ func TestStatus_Syncing(t *testing.T) {
 	assert.Equal(t, &RosettaTypes.SyncStatus{
 		CurrentIndex: RosettaTypes.Int64(25),
 		TargetIndex:  RosettaTypes.Int64(8916760),
+		Stage:        RosettaTypes.String(SyncStageBlocks),
+		Synced:       RosettaTypes.Bool(false),
 	}, syncStatus)
 	assert.Equal(t, []*RosettaTypes.Peer{
 		{
@@ -357,9 +460,14 @@ func TestStatus_Syncing(t *testing.T) {
 					"eth/64",
 					"eth/65",
 				},
-				"enode": "enode://5654cc39fd278c994c451434dfa7b1a44977c52018a87e911368b54daf795955d5a2dc2ece98be5a7e8d0eb245c8ef573c92e04e8b15363f9c713a8127fe7c7b@35.183.116.112:57510", // nolint
-				"enr":   "",
-				"name":  "Geth/v1.9.22-stable-c71a7e26/linux-amd64/go1.15",
+				"enode":          "enode://5654cc39fd278c994c451434dfa7b1a44977c52018a87e911368b54daf795955d5a2dc2ece98be5a7e8d0eb245c8ef573c92e04e8b15363f9c713a8127fe7c7b@35.183.116.112:57510", // nolint
+				"enr":            "",
+				"name":           "Geth/v1.9.22-stable-c71a7e26/linux-amd64/go1.15",
+				"direction":      "inbound",
+				"local_address":  "172.31.2.163:30303",
+				"remote_address": "35.183.116.112:57510",
+				"trusted":        false,
+				"static":         false,
 				"protocols": map[string]interface{}{
 					"eth": map[string]interface{}{
 						"difficulty": float64(31779242235308530),
@@ -377,9 +485,14 @@ func TestStatus_Syncing(t *testing.T) {
 					"eth/64",
 					"eth/65",
 				},
-				"enode": "enode://bead1278155bfabdd51f04a6e896356da2f5687aa1f550bebc540828579522b87e22c67edf90efa651582e40c8c8037eb0f998208cab4a69b52c5e3387671b59@174.129.122.13:30303",                                                    // nolint
-				"enr":   "enr:-Je4QICGSLfIHa7vX3bdWnKqWIS7YwmLUP6JVqU5nBhxPpH_X_Uz1pZwVS8a48uESHay1nvz9FtxLYFftpMr3wvFZJ4Qg2V0aMfGhGcn75CAgmlkgnY0gmlwhK6Beg2Jc2VjcDI1NmsxoQO-rRJ4FVv6vdUfBKboljVtovVoeqH1UL68VAgoV5UiuIN0Y3CCdl-DdWRwgnZf", // nolint
-				"name":  "Geth/v1.9.15-omnibus-75eb5240/linux-amd64/go1.14.4",
+				"enode":          "enode://bead1278155bfabdd51f04a6e896356da2f5687aa1f550bebc540828579522b87e22c67edf90efa651582e40c8c8037eb0f998208cab4a69b52c5e3387671b59@174.129.122.13:30303",                                                    // nolint
+				"enr":            "enr:-Je4QICGSLfIHa7vX3bdWnKqWIS7YwmLUP6JVqU5nBhxPpH_X_Uz1pZwVS8a48uESHay1nvz9FtxLYFftpMr3wvFZJ4Qg2V0aMfGhGcn75CAgmlkgnY0gmlwhK6Beg2Jc2VjcDI1NmsxoQO-rRJ4FVv6vdUfBKboljVtovVoeqH1UL68VAgoV5UiuIN0Y3CCdl-DdWRwgnZf", // nolint
+				"name":           "Geth/v1.9.15-omnibus-75eb5240/linux-amd64/go1.14.4",
+				"direction":      "outbound",
+				"local_address":  "172.31.2.163:37908",
+				"remote_address": "174.129.122.13:30303",
+				"trusted":        false,
+				"static":         false,
 				"protocols": map[string]interface{}{
 					"eth": map[string]interface{}{
 						"difficulty": float64(31779248439556308),
@@ -470,6 +583,8 @@ func TestStatus_Syncing_SkipAdminCalls(t *testing.T) {
 	assert.Equal(t, &RosettaTypes.SyncStatus{
 		CurrentIndex: RosettaTypes.Int64(25),
 		TargetIndex:  RosettaTypes.Int64(8916760),
+		Stage:        RosettaTypes.String(SyncStageBlocks),
+		Synced:       RosettaTypes.Bool(false),
 	}, syncStatus)
 	assert.Equal(t, []*RosettaTypes.Peer{}, peers)
 	assert.NoError(t, err)
@@ -478,6 +593,35 @@ func TestStatus_Syncing_SkipAdminCalls(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+// mockBalanceBlock mocks the lookup of the header of the block
+// with number pinning a balance lookup, and returns its hash.
+func mockBalanceBlock(
+	ctx context.Context,
+	mockJSONRPC *mocks.JSONRPC,
+	number string,
+	index int64,
+) string {
+	header := &types.Header{Number: big.NewInt(index)}
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		number,
+		false,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(**types.Header)
+
+			*r = header
+		},
+	).Once()
+
+	return header.Hash().Hex()
+}
+
 func TestBalance(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -493,11 +637,12 @@ func TestBalance(t *testing.T) {
 		"testdata/account_balance_0x4cfc400fed52f9681b42454c2db4b18ab98f8de1.json",
 	)
 	assert.NoError(t, err)
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
 	mockGraphQL.On(
 		"Query",
 		ctx,
-		`{
-			block(){
+		fmt.Sprintf(`{
+			block(hash: "%s"){
 				hash
 				number
 				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
@@ -506,7 +651,7 @@ func TestBalance(t *testing.T) {
 					code
 				}
 			}
-		}`,
+		}`, hash),
 	).Return(
 		string(result),
 		nil,
@@ -518,6 +663,7 @@ func TestBalance(t *testing.T) {
 			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
 		},
 		nil,
+		nil,
 	)
 	assert.Equal(t, &RosettaTypes.AccountBalanceResponse{
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
@@ -531,8 +677,10 @@ func TestBalance(t *testing.T) {
 			},
 		},
 		Metadata: map[string]interface{}{
-			"code":  "0x",
-			"nonce": int64(0),
+			"code":        "0x",
+			"nonce":       int64(0),
+			"code_hash":   "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+			"is_contract": false,
 		},
 	}, resp)
 	assert.NoError(t, err)
@@ -541,7 +689,7 @@ func TestBalance(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestBalance_Historical_Hash(t *testing.T) {
+func TestBalance_Tokens(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
 
@@ -552,15 +700,22 @@ func TestBalance_Historical_Hash(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	result, err := ioutil.ReadFile(
-		"testdata/account_balance_0x4cfc400fed52f9681b42454c2db4b18ab98f8de1.json",
-	)
+	result, err := ioutil.ReadFile("testdata/account_balance_token.json")
 	assert.NoError(t, err)
+
+	usdc := &RosettaTypes.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+	}
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
 	mockGraphQL.On(
 		"Query",
 		ctx,
-		`{
-			block(hash: "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda"){
+		fmt.Sprintf(`{
+			block(hash: "%s"){
 				hash
 				number
 				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
@@ -568,8 +723,11 @@ func TestBalance_Historical_Hash(t *testing.T) {
 					transactionCount
 					code
 				}
+				token0: call(data:{to:"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", data:"0x70a082310000000000000000000000002f93b2f047e05cdf602820ac4b3178efc2b43d55"}){
+					data
+				}
 			}
-		}`,
+		}`, hash), // nolint
 	).Return(
 		string(result),
 		nil,
@@ -580,12 +738,8 @@ func TestBalance_Historical_Hash(t *testing.T) {
 		&RosettaTypes.AccountIdentifier{
 			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
 		},
-		&RosettaTypes.PartialBlockIdentifier{
-			Hash: RosettaTypes.String(
-				"0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
-			),
-			Index: RosettaTypes.Int64(8165),
-		},
+		nil,
+		[]*RosettaTypes.Currency{usdc, Currency},
 	)
 	assert.Equal(t, &RosettaTypes.AccountBalanceResponse{
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
@@ -593,23 +747,217 @@ func TestBalance_Historical_Hash(t *testing.T) {
 			Index: 8165,
 		},
 		Balances: []*RosettaTypes.Amount{
+			{
+				Value:    "1000000",
+				Currency: usdc,
+			},
 			{
 				Value:    "10372550232136640000000",
 				Currency: Currency,
 			},
 		},
 		Metadata: map[string]interface{}{
-			"code":  "0x",
-			"nonce": int64(0),
+			"code":        "0x",
+			"nonce":       int64(0),
+			"code_hash":   "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+			"is_contract": false,
 		},
 	}, resp)
 	assert.NoError(t, err)
 
+	// Contracts not returning a balance are errors
+	notToken := &RosettaTypes.Currency{
+		Symbol:   "NOPE",
+		Decimals: 18,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d",
+		},
+	}
+	hash = mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
+	mockGraphQL.On(
+		"Query",
+		ctx,
+		fmt.Sprintf(`{
+			block(hash: "%s"){
+				hash
+				number
+				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
+					balance
+					transactionCount
+					code
+				}
+				token0: call(data:{to:"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", data:"0x70a082310000000000000000000000002f93b2f047e05cdf602820ac4b3178efc2b43d55"}){
+					data
+				}
+				token2: call(data:{to:"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d", data:"0x70a082310000000000000000000000002f93b2f047e05cdf602820ac4b3178efc2b43d55"}){
+					data
+				}
+			}
+		}`, hash), // nolint
+	).Return(
+		string(result),
+		nil,
+	).Once()
+
+	resp, err = c.Balance(
+		ctx,
+		&RosettaTypes.AccountIdentifier{
+			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+		},
+		nil,
+		[]*RosettaTypes.Currency{usdc, Currency, notToken},
+	)
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "could not extract NOPE balance from 0x")
+
+	// Tokens must have a contract address
+	mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
+	resp, err = c.Balance(
+		ctx,
+		&RosettaTypes.AccountIdentifier{
+			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+		},
+		nil,
+		[]*RosettaTypes.Currency{{Symbol: "USDC", Decimals: 6}},
+	)
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+
 	mockJSONRPC.AssertExpectations(t)
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestBalance_Historical_Index(t *testing.T) {
+func TestBalance_Contract(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	result, err := ioutil.ReadFile("testdata/account_balance_contract.json")
+	assert.NoError(t, err)
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
+	mockGraphQL.On(
+		"Query",
+		ctx,
+		fmt.Sprintf(`{
+			block(hash: "%s"){
+				hash
+				number
+				account(address:"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"){
+					balance
+					transactionCount
+					code
+				}
+			}
+		}`, hash),
+	).Return(
+		string(result),
+		nil,
+	).Once()
+
+	resp, err := c.Balance(
+		ctx,
+		&RosettaTypes.AccountIdentifier{
+			Address: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"code":        "0x6080604052",
+		"nonce":       int64(1),
+		"code_hash":   "0x1c3374235d773b2189aed115aa13143020fcdbbe86e38f358cf3e4771b2f0244",
+		"is_contract": true,
+	}, resp.Metadata)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBalance_StateUnavailable(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	missing, err := ioutil.ReadFile("testdata/account_balance_missing_state.json")
+	assert.NoError(t, err)
+	result, err := ioutil.ReadFile(
+		"testdata/account_balance_0x4cfc400fed52f9681b42454c2db4b18ab98f8de1.json",
+	)
+	assert.NoError(t, err)
+	query := `{
+			block(hash: "%s"){
+				hash
+				number
+				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
+					balance
+					transactionCount
+					code
+				}
+			}
+		}`
+	account := &RosettaTypes.AccountIdentifier{
+		Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+	}
+
+	// The latest block is pinned again when
+	// its state is unavailable
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "latest", 8164)
+	mockGraphQL.On("Query", ctx, fmt.Sprintf(query, hash)).Return(string(missing), nil).Once()
+	hash = mockBalanceBlock(ctx, mockJSONRPC, "latest", 8165)
+	mockGraphQL.On("Query", ctx, fmt.Sprintf(query, hash)).Return(string(result), nil).Once()
+
+	resp, err := c.Balance(ctx, account, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &RosettaTypes.BlockIdentifier{
+		Hash:  "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
+		Index: 8165,
+	}, resp.BlockIdentifier)
+
+	// Retries are bounded
+	for i := 0; i < maxBalanceAttempts; i++ {
+		hash = mockBalanceBlock(ctx, mockJSONRPC, "0x1fe5", 8165)
+		mockGraphQL.On("Query", ctx, fmt.Sprintf(query, hash)).Return(string(missing), nil).Once()
+	}
+
+	resp, err = c.Balance(ctx, account, &RosettaTypes.PartialBlockIdentifier{
+		Index: RosettaTypes.Int64(8165),
+	}, nil)
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, ErrStateUnavailable))
+
+	// Blocks requested by hash are not retried
+	mockGraphQL.On(
+		"Query",
+		ctx,
+		fmt.Sprintf(query, "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda"),
+	).Return(string(missing), nil).Once()
+
+	resp, err = c.Balance(ctx, account, &RosettaTypes.PartialBlockIdentifier{
+		Hash: RosettaTypes.String(
+			"0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
+		),
+	}, nil)
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, ErrStateUnavailable))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBalance_Historical_Hash(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
 
@@ -628,7 +976,7 @@ func TestBalance_Historical_Index(t *testing.T) {
 		"Query",
 		ctx,
 		`{
-			block(number: 8165){
+			block(hash: "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda"){
 				hash
 				number
 				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
@@ -649,8 +997,12 @@ func TestBalance_Historical_Index(t *testing.T) {
 			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
 		},
 		&RosettaTypes.PartialBlockIdentifier{
+			Hash: RosettaTypes.String(
+				"0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
+			),
 			Index: RosettaTypes.Int64(8165),
 		},
+		nil,
 	)
 	assert.Equal(t, &RosettaTypes.AccountBalanceResponse{
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
@@ -664,8 +1016,10 @@ func TestBalance_Historical_Index(t *testing.T) {
 			},
 		},
 		Metadata: map[string]interface{}{
-			"code":  "0x",
-			"nonce": int64(0),
+			"code":        "0x",
+			"nonce":       int64(0),
+			"code_hash":   "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+			"is_contract": false,
 		},
 	}, resp)
 	assert.NoError(t, err)
@@ -674,7 +1028,7 @@ func TestBalance_Historical_Index(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestBalance_InvalidAddress(t *testing.T) {
+func TestBalance_Historical_Mismatch(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
 
@@ -685,16 +1039,18 @@ func TestBalance_InvalidAddress(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	result, err := ioutil.ReadFile("testdata/account_balance_invalid.json")
+	result, err := ioutil.ReadFile(
+		"testdata/account_balance_0x4cfc400fed52f9681b42454c2db4b18ab98f8de1.json",
+	)
 	assert.NoError(t, err)
 	mockGraphQL.On(
 		"Query",
 		ctx,
 		`{
-			block(){
+			block(hash: "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda"){
 				hash
 				number
-				account(address:"0x4cfc400fed52f9681b42454c2db4b18ab98f8de"){
+				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
 					balance
 					transactionCount
 					code
@@ -709,18 +1065,24 @@ func TestBalance_InvalidAddress(t *testing.T) {
 	resp, err := c.Balance(
 		ctx,
 		&RosettaTypes.AccountIdentifier{
-			Address: "0x4cfc400fed52f9681b42454c2db4b18ab98f8de",
+			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+		},
+		&RosettaTypes.PartialBlockIdentifier{
+			Hash: RosettaTypes.String(
+				"0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
+			),
+			Index: RosettaTypes.Int64(8166),
 		},
 		nil,
 	)
 	assert.Nil(t, resp)
-	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBlockMismatch))
 
 	mockJSONRPC.AssertExpectations(t)
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestBalance_InvalidHash(t *testing.T) {
+func TestBalance_Historical_Index(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
 
@@ -731,13 +1093,16 @@ func TestBalance_InvalidHash(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	result, err := ioutil.ReadFile("testdata/account_balance_invalid_block.json")
+	result, err := ioutil.ReadFile(
+		"testdata/account_balance_0x4cfc400fed52f9681b42454c2db4b18ab98f8de1.json",
+	)
 	assert.NoError(t, err)
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "0x1fe5", 8165)
 	mockGraphQL.On(
 		"Query",
 		ctx,
-		`{
-			block(hash: "0x7d2a2713026a0e66f131878de2bb2df2fff6c24562c1df61ec0265e5fedf2626"){
+		fmt.Sprintf(`{
+			block(hash: "%s"){
 				hash
 				number
 				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
@@ -746,7 +1111,7 @@ func TestBalance_InvalidHash(t *testing.T) {
 					code
 				}
 			}
-		}`,
+		}`, hash),
 	).Return(
 		string(result),
 		nil,
@@ -758,19 +1123,35 @@ func TestBalance_InvalidHash(t *testing.T) {
 			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
 		},
 		&RosettaTypes.PartialBlockIdentifier{
-			Hash: RosettaTypes.String(
-				"0x7d2a2713026a0e66f131878de2bb2df2fff6c24562c1df61ec0265e5fedf2626",
-			),
+			Index: RosettaTypes.Int64(8165),
 		},
+		nil,
 	)
-	assert.Nil(t, resp)
-	assert.Error(t, err)
+	assert.Equal(t, &RosettaTypes.AccountBalanceResponse{
+		BlockIdentifier: &RosettaTypes.BlockIdentifier{
+			Hash:  "0x9999286598edf07606228ba0233736e544a086a8822c61f9db3706887fc25dda",
+			Index: 8165,
+		},
+		Balances: []*RosettaTypes.Amount{
+			{
+				Value:    "10372550232136640000000",
+				Currency: Currency,
+			},
+		},
+		Metadata: map[string]interface{}{
+			"code":        "0x",
+			"nonce":       int64(0),
+			"code_hash":   "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+			"is_contract": false,
+		},
+	}, resp)
+	assert.NoError(t, err)
 
 	mockJSONRPC.AssertExpectations(t)
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestCall_GetBlockByNumber(t *testing.T) {
+func TestBalance_InvalidAddress(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
 
@@ -781,7 +1162,106 @@ func TestCall_GetBlockByNumber(t *testing.T) {
 	}
 
 	ctx := context.Background()
-
+	result, err := ioutil.ReadFile("testdata/account_balance_invalid.json")
+	assert.NoError(t, err)
+	hash := mockBalanceBlock(ctx, mockJSONRPC, "latest", 8916933)
+	mockGraphQL.On(
+		"Query",
+		ctx,
+		fmt.Sprintf(`{
+			block(hash: "%s"){
+				hash
+				number
+				account(address:"0x4cfc400fed52f9681b42454c2db4b18ab98f8de"){
+					balance
+					transactionCount
+					code
+				}
+			}
+		}`, hash),
+	).Return(
+		string(result),
+		nil,
+	).Once()
+
+	resp, err := c.Balance(
+		ctx,
+		&RosettaTypes.AccountIdentifier{
+			Address: "0x4cfc400fed52f9681b42454c2db4b18ab98f8de",
+		},
+		nil,
+		nil,
+	)
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBalance_InvalidHash(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	result, err := ioutil.ReadFile("testdata/account_balance_invalid_block.json")
+	assert.NoError(t, err)
+	mockGraphQL.On(
+		"Query",
+		ctx,
+		`{
+			block(hash: "0x7d2a2713026a0e66f131878de2bb2df2fff6c24562c1df61ec0265e5fedf2626"){
+				hash
+				number
+				account(address:"0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55"){
+					balance
+					transactionCount
+					code
+				}
+			}
+		}`,
+	).Return(
+		string(result),
+		nil,
+	).Once()
+
+	resp, err := c.Balance(
+		ctx,
+		&RosettaTypes.AccountIdentifier{
+			Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+		},
+		&RosettaTypes.PartialBlockIdentifier{
+			Hash: RosettaTypes.String(
+				"0x7d2a2713026a0e66f131878de2bb2df2fff6c24562c1df61ec0265e5fedf2626",
+			),
+		},
+		nil,
+	)
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestCall_GetBlockByNumber(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+
 	mockJSONRPC.On(
 		"CallContext",
 		ctx,
@@ -1135,6 +1615,272 @@ func TestCall_EstimateGas_InvalidArgs(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestCall_GetProof(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	header := &types.Header{
+		Number:     big.NewInt(10992),
+		Difficulty: big.NewInt(1),
+		Root:       common.HexToHash("0x1"),
+	}
+	address := "0xE550f300E477C60CE7e7172d12e5a27e9379D2e3"
+	storageKey := "0x0000000000000000000000000000000000000000000000000000000000000000"
+	proof := `{"address":"0xe550f300e477c60ce7e7172d12e5a27e9379d2e3","accountProof":["0xf90211"],` +
+		`"balance":"0x0","codeHash":"0xc5d2","nonce":"0x0","storageHash":"0x56e8",` +
+		`"storageProof":[{"key":"0x0","value":"0x0","proof":[]}]}`
+
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"0x2af0",
+		false,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(**types.Header)
+			*r = header
+		},
+	).Once()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getProof",
+		address,
+		[]string{storageKey},
+		header.Hash().Hex(),
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+			*r = json.RawMessage(proof)
+		},
+	).Once()
+
+	var correctProof map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(proof), &correctProof))
+
+	resp, err := c.Call(
+		ctx,
+		&RosettaTypes.CallRequest{
+			Method: "eth_getProof",
+			Parameters: map[string]interface{}{
+				"index":        10992,
+				"address":      address,
+				"storage_keys": []string{storageKey},
+			},
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &RosettaTypes.CallResponse{
+		Result: map[string]interface{}{
+			"block_identifier": &RosettaTypes.BlockIdentifier{
+				Hash:  header.Hash().Hex(),
+				Index: 10992,
+			},
+			"state_root": header.Root.Hex(),
+			"proof":      correctProof,
+		},
+		Idempotent: true,
+	}, resp)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestCall_GetProof_InvalidArgs(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	resp, err := c.Call(
+		ctx,
+		&RosettaTypes.CallRequest{
+			Method: "eth_getProof",
+			Parameters: map[string]interface{}{
+				"address": "0xinvalid",
+			},
+		},
+	)
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, ErrCallParametersInvalid))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestCall_TxStatus(t *testing.T) {
+	ctx := context.Background()
+	txHash := "0x9cc8e6a09ae9cbdb7da77515110a8e343a945df4269c53842dd26969d32c6cc4"
+
+	file, err := ioutil.ReadFile("testdata/transaction_" + txHash + ".json")
+	assert.NoError(t, err)
+
+	var pendingTx map[string]interface{}
+	assert.NoError(t, json.Unmarshal(file, &pendingTx))
+	pendingTx["blockHash"] = nil
+	pendingTx["blockNumber"] = nil
+	pendingTx["transactionIndex"] = nil
+	pendingFile, err := json.Marshal(pendingTx)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		tx       []byte
+		receipt  bool
+		expected map[string]interface{}
+	}{
+		"confirmed": {
+			tx:      file,
+			receipt: true,
+			expected: map[string]interface{}{
+				"status": TxStatusConfirmed,
+				"block_identifier": &RosettaTypes.BlockIdentifier{
+					Hash:  "0xc10a51a3898a85c7165a9d883acc9a68f139934d0cb91dfad4c7d3a7c1a1960d",
+					Index: 45000,
+				},
+				"confirmations": int64(10),
+				"successful":    true,
+			},
+		},
+		"pending": {
+			tx: pendingFile,
+			expected: map[string]interface{}{
+				"status":        TxStatusPending,
+				"confirmations": int64(0),
+			},
+		},
+		"dropped": {
+			tx: []byte("null"),
+			expected: map[string]interface{}{
+				"status":        TxStatusDropped,
+				"confirmations": int64(0),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockJSONRPC := &mocks.JSONRPC{}
+			c := &Client{
+				c:              mockJSONRPC,
+				traceSemaphore: semaphore.NewWeighted(100),
+			}
+
+			mockJSONRPC.On(
+				"CallContext",
+				ctx,
+				mock.Anything,
+				"eth_getTransactionByHash",
+				common.HexToHash(txHash),
+			).Return(
+				nil,
+			).Run(
+				func(args mock.Arguments) {
+					r := args.Get(1).(*json.RawMessage)
+					*r = json.RawMessage(test.tx)
+				},
+			).Once()
+
+			if test.receipt {
+				mockJSONRPC.On(
+					"CallContext",
+					ctx,
+					mock.Anything,
+					"eth_getTransactionReceipt",
+					common.HexToHash(txHash),
+				).Return(
+					nil,
+				).Run(
+					func(args mock.Arguments) {
+						receiptFile, err := ioutil.ReadFile("testdata/tx_receipt_" + txHash + ".json")
+						assert.NoError(t, err)
+
+						r := args.Get(1).(**types.Receipt)
+						*r = new(types.Receipt)
+						assert.NoError(t, (*r).UnmarshalJSON(receiptFile))
+					},
+				).Once()
+				mockJSONRPC.On(
+					"CallContext",
+					ctx,
+					mock.Anything,
+					"eth_getBlockByNumber",
+					"latest",
+					false,
+				).Return(
+					nil,
+				).Run(
+					func(args mock.Arguments) {
+						r := args.Get(1).(**types.Header)
+						*r = &types.Header{Number: big.NewInt(45009), Difficulty: big.NewInt(1)}
+					},
+				).Once()
+			}
+
+			resp, err := c.Call(
+				ctx,
+				&RosettaTypes.CallRequest{
+					Method: "tx_status",
+					Parameters: map[string]interface{}{
+						"tx_hash": txHash,
+					},
+				},
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, &RosettaTypes.CallResponse{
+				Result: test.expected,
+			}, resp)
+
+			mockJSONRPC.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCall_TxStatus_InvalidArgs(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	resp, err := c.Call(
+		ctx,
+		&RosettaTypes.CallRequest{
+			Method: "tx_status",
+			Parameters: map[string]interface{}{
+				"tx_hash": "0x1234",
+			},
+		},
+	)
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, ErrCallParametersInvalid))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
 func TestCall_InvalidMethod(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -1166,8 +1912,9 @@ func testTraceConfig() (*tracers.TraceConfig, error) {
 	}
 
 	loadedTracer := string(loadedFile)
+	timeout := tracerTimeout.String()
 	return &tracers.TraceConfig{
-		Timeout: &tracerTimeout,
+		Timeout: &timeout,
 		Tracer:  &loadedTracer,
 	}, nil
 }
@@ -1244,17 +1991,10 @@ func TestBlock_Current(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
-func TestBlock_Hash(t *testing.T) {
+func TestBlockHeader(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
-	mockGraphQL := &mocks.GraphQL{}
-
-	tc, err := testTraceConfig()
-	assert.NoError(t, err)
 	c := &Client{
 		c:              mockJSONRPC,
-		g:              mockGraphQL,
-		tc:             tc,
-		p:              params.RopstenChainConfig,
 		traceSemaphore: semaphore.NewWeighted(100),
 	}
 
@@ -1263,24 +2003,78 @@ func TestBlock_Hash(t *testing.T) {
 		"CallContext",
 		ctx,
 		mock.Anything,
-		"eth_getBlockByHash",
-		"0xba9ded5ca1ec9adb9451bf062c9de309d9552fa0f0254a7b982d3daf7ae436ae",
-		true,
+		"eth_getBlockByNumber",
+		"0x880eb0",
+		false,
 	).Return(
 		nil,
 	).Run(
 		func(args mock.Arguments) {
-			r := args.Get(1).(*json.RawMessage)
-
-			file, err := ioutil.ReadFile("testdata/block_10992.json")
+			header := args.Get(1).(**types.Header)
+			file, err := ioutil.ReadFile("testdata/basic_header.json")
 			assert.NoError(t, err)
 
-			*r = json.RawMessage(file)
+			*header = new(types.Header)
+			assert.NoError(t, (*header).UnmarshalJSON(file))
 		},
 	).Once()
-	mockJSONRPC.On(
-		"CallContext",
-		ctx,
+
+	block, err := c.BlockHeader(ctx, &RosettaTypes.PartialBlockIdentifier{
+		Index: RosettaTypes.Int64(8916656),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &RosettaTypes.Block{
+		BlockIdentifier: &RosettaTypes.BlockIdentifier{
+			Hash:  "0x48269a339ce1489cff6bab70eff432289c4f490b81dbd00ff1f81c68de06b842",
+			Index: 8916656,
+		},
+		ParentBlockIdentifier: &RosettaTypes.BlockIdentifier{
+			Hash:  "0x6b9f8f5388ea4ff227d1d9b79594694a554dc3c75cd26f73ccc548ebe884b0b1",
+			Index: 8916655,
+		},
+		Timestamp: 1603225195000,
+	}, block)
+
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func TestBlock_Hash(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		tc:             tc,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByHash",
+		"0xba9ded5ca1ec9adb9451bf062c9de309d9552fa0f0254a7b982d3daf7ae436ae",
+		true,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+
+			file, err := ioutil.ReadFile("testdata/block_10992.json")
+			assert.NoError(t, err)
+
+			*r = json.RawMessage(file)
+		},
+	).Once()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
 		mock.Anything,
 		"debug_traceBlockByHash",
 		common.HexToHash("0xba9ded5ca1ec9adb9451bf062c9de309d9552fa0f0254a7b982d3daf7ae436ae"),
@@ -1447,6 +2241,77 @@ func TestBlock_FirstBlock(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestBlock_GenesisBalances(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	c := &Client{
+		c:               mockJSONRPC,
+		g:               mockGraphQL,
+		tc:              tc,
+		p:               params.MainnetChainConfig,
+		traceSemaphore:  semaphore.NewWeighted(100),
+		genesisBalances: true,
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"0x0",
+		true,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+
+			file, err := ioutil.ReadFile("testdata/block_0.json")
+			assert.NoError(t, err)
+
+			*r = file
+		},
+	).Once()
+
+	correctRaw, err := ioutil.ReadFile("testdata/block_response_0.json")
+	assert.NoError(t, err)
+	var correct *RosettaTypes.BlockResponse
+	assert.NoError(t, json.Unmarshal(correctRaw, &correct))
+
+	resp, err := c.Block(
+		ctx,
+		&RosettaTypes.PartialBlockIdentifier{
+			Index: RosettaTypes.Int64(0),
+		},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Transactions, 1)
+
+	// The block reward is followed by one operation
+	// for each mainnet genesis allocation.
+	ops := resp.Transactions[0].Operations
+	correctOps := correct.Block.Transactions[0].Operations
+	assert.Equal(t, correctOps, ops[:len(correctOps)])
+	assert.Len(t, ops, len(correctOps)+8891)
+
+	supply := new(big.Int)
+	for i, op := range ops[len(correctOps):] {
+		assert.Equal(t, int64(len(correctOps)+i), op.OperationIdentifier.Index)
+		assert.Equal(t, GenesisOpType, op.Type)
+
+		value, ok := new(big.Int).SetString(op.Amount.Value, 10)
+		assert.True(t, ok)
+		supply.Add(supply, value)
+	}
+	assert.Equal(t, "72009990499480000000000000", supply.String())
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
 func jsonifyTransaction(b *RosettaTypes.Transaction) (*RosettaTypes.Transaction, error) {
 	bytes, err := json.Marshal(b)
 	if err != nil {
@@ -2677,6 +3542,189 @@ func TestSuggestGasPrice(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestSuggestGasTipCap(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_maxPriorityFeePerGas",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*hexutil.Big)
+
+			*r = *(*hexutil.Big)(big.NewInt(1500000000))
+		},
+	).Once()
+	resp, err := c.SuggestGasTipCap(
+		ctx,
+	)
+	assert.Equal(t, big.NewInt(1500000000), resp)
+	assert.NoError(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestFeeHistory(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_feeHistory",
+		hexutil.Uint64(2),
+		"latest",
+		[]float64{50},
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*feeHistoryResultMarshaling)
+
+			assert.NoError(t, json.Unmarshal([]byte(`{
+				"oldestBlock": "0xe4e1c0",
+				"reward": [["0x3b9aca00"], ["0x59682f00"]],
+				"baseFeePerGas": ["0x2540be400", "0x2363e7f00", "0x22ecb25c00"],
+				"gasUsedRatio": [0.2, 0.45]
+			}`), r))
+		},
+	).Once()
+	resp, err := c.FeeHistory(
+		ctx,
+		2,
+		[]float64{50},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &FeeHistory{
+		OldestBlock: big.NewInt(15000000),
+		Reward: [][]*big.Int{
+			{big.NewInt(1000000000)},
+			{big.NewInt(1500000000)},
+		},
+		BaseFee: []*big.Int{
+			big.NewInt(10000000000),
+			big.NewInt(9500000000),
+			big.NewInt(150000000000),
+		},
+		GasUsedRatio: []float64{0.2, 0.45},
+	}, resp)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestCallContract(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	msg := ethereum.CallMsg{
+		From:  common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"),
+		To:    &to,
+		Value: big.NewInt(1000),
+		Data:  []byte{0x01},
+	}
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_call",
+		map[string]interface{}{
+			"from":  msg.From,
+			"to":    msg.To,
+			"value": (*hexutil.Big)(big.NewInt(1000)),
+			"data":  hexutil.Bytes{0x01},
+		},
+		"pending",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*hexutil.Bytes)
+
+			*r = hexutil.Bytes{0x02}
+		},
+	).Once()
+	resp, err := c.CallContract(ctx, msg, big.NewInt(-1))
+	assert.Equal(t, []byte{0x02}, resp)
+	assert.NoError(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestEstimateGas(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	msg := ethereum.CallMsg{
+		From: common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"),
+		To:   &to,
+		Gas:  50000,
+	}
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_estimateGas",
+		map[string]interface{}{
+			"from": msg.From,
+			"to":   msg.To,
+			"gas":  hexutil.Uint64(50000),
+		},
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*hexutil.Uint64)
+
+			*r = hexutil.Uint64(21000)
+		},
+	).Once()
+	resp, err := c.EstimateGas(ctx, msg)
+	assert.Equal(t, uint64(21000), resp)
+	assert.NoError(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
 func TestSendTransaction(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -2713,6 +3761,71 @@ func TestSendTransaction(t *testing.T) {
 	mockGraphQL.AssertExpectations(t)
 }
 
+func TestTransactionByHash(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	pendingHash := common.HexToHash("0xf5aaf8c5c14fc1e7ad200e3d3b5ce64fc6a211c204194a9585ac38417921ad27")
+	minedHash := common.HexToHash("0x9cc8e6a09ae9cbdb7da77515110a8e343a945df4269c53842dd26969d32c6cc4")
+	unknownHash := common.HexToHash("0x01")
+
+	pendingTx, err := ioutil.ReadFile("testdata/submitted_tx.json")
+	assert.NoError(t, err)
+	minedTx, err := ioutil.ReadFile(
+		"testdata/transaction_0x9cc8e6a09ae9cbdb7da77515110a8e343a945df4269c53842dd26969d32c6cc4.json",
+	)
+	assert.NoError(t, err)
+
+	for hash, raw := range map[common.Hash][]byte{
+		pendingHash: pendingTx,
+		minedHash:   minedTx,
+		unknownHash: []byte("null"),
+	} {
+		raw := raw
+		mockJSONRPC.On(
+			"CallContext",
+			ctx,
+			mock.Anything,
+			"eth_getTransactionByHash",
+			hash,
+		).Return(
+			nil,
+		).Run(
+			func(args mock.Arguments) {
+				r := args.Get(1).(*json.RawMessage)
+
+				*r = json.RawMessage(raw)
+			},
+		).Once()
+	}
+
+	tx, pending, err := c.TransactionByHash(ctx, pendingHash)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	assert.Equal(t, pendingHash, tx.Hash())
+	assert.Equal(t, uint64(0), tx.Nonce())
+
+	tx, pending, err = c.TransactionByHash(ctx, minedHash)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Equal(t, minedHash, tx.Hash())
+
+	tx, pending, err = c.TransactionByHash(ctx, unknownHash)
+	assert.True(t, errors.Is(err, ethereum.NotFound))
+	assert.Nil(t, tx)
+	assert.False(t, pending)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
 func TestGetMempool(t *testing.T) {
 	mockJSONRPC := &mocks.JSONRPC{}
 	mockGraphQL := &mocks.GraphQL{}
@@ -2779,3 +3892,735 @@ func TestGetMempool(t *testing.T) {
 
 	mockJSONRPC.AssertExpectations(t)
 }
+
+func TestMempoolTransaction(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+	ctx := context.Background()
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	mockJSONRPC.On(
+		"CallContext", ctx, mock.Anything, "txpool_content",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r, ok := args.Get(1).(*txPoolContentResponse)
+			assert.True(t, ok)
+
+			file, err := ioutil.ReadFile("testdata/txpool_content.json")
+			assert.NoError(t, err)
+
+			err = json.Unmarshal(file, r)
+			assert.NoError(t, err)
+		},
+	).Times(3)
+
+	tx, err := c.MempoolTransaction(
+		ctx,
+		"0x994024ef9f05d1cb25d01572642c1f550c78d214a52c306bb100d22c025b59d4",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &RosettaTypes.Transaction{
+		TransactionIdentifier: &RosettaTypes.TransactionIdentifier{
+			Hash: "0x994024ef9f05d1cb25d01572642c1f550c78d214a52c306bb100d22c025b59d4",
+		},
+		Operations: []*RosettaTypes.Operation{
+			{
+				OperationIdentifier: &RosettaTypes.OperationIdentifier{Index: 0},
+				Type:                CallOpType,
+				Account: &RosettaTypes.AccountIdentifier{
+					Address: "0x0297215e64d312d3A239995345E574F73Ef59B02",
+				},
+				Amount: &RosettaTypes.Amount{
+					Value:    "-2176430000000000",
+					Currency: Currency,
+				},
+				Metadata: map[string]interface{}{},
+			},
+			{
+				OperationIdentifier: &RosettaTypes.OperationIdentifier{Index: 1},
+				RelatedOperations:   []*RosettaTypes.OperationIdentifier{{Index: 0}},
+				Type:                CallOpType,
+				Account: &RosettaTypes.AccountIdentifier{
+					Address: "0x6efF3372fa352b239Bb24ff91b423A572347000D",
+				},
+				Amount: &RosettaTypes.Amount{
+					Value:    "2176430000000000",
+					Currency: Currency,
+				},
+				Metadata: map[string]interface{}{},
+			},
+		},
+		Metadata: map[string]interface{}{
+			"pool":      "pending",
+			"nonce":     "0x3",
+			"gas_limit": "0x5208",
+			"gas_price": "0x9502f9000",
+		},
+	}, tx)
+
+	// Calls without value have no operations
+	tx, err = c.MempoolTransaction(
+		ctx,
+		"0x1e53751e1312cae3324a6b36c67dc95bfec993d7b4939c0de8c0dc761a0afd31",
+	)
+	assert.NoError(t, err)
+	assert.Empty(t, tx.Operations)
+	assert.Equal(t, "queued", tx.Metadata["pool"])
+
+	tx, err = c.MempoolTransaction(
+		ctx,
+		"0x0000000000000000000000000000000000000000000000000000000000000000",
+	)
+	assert.Nil(t, tx)
+	assert.True(t, errors.Is(err, ethereum.NotFound))
+
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func mockGenesis(ctx context.Context, t *testing.T, mockJSONRPC *mocks.JSONRPC) {
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"0x0",
+		false,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(**types.Header)
+
+			file, err := ioutil.ReadFile("testdata/block_0.json")
+			assert.NoError(t, err)
+
+			*r = new(types.Header)
+
+			assert.NoError(t, (*r).UnmarshalJSON(file))
+		},
+	).Once()
+}
+
+func mockChainID(ctx context.Context, mockJSONRPC *mocks.JSONRPC, chainID int64) {
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_chainId",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*hexutil.Big)
+
+			*r = hexutil.Big(*big.NewInt(chainID))
+		},
+	).Once()
+}
+
+func TestValidateNetwork(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		p:              params.MainnetChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockChainID(ctx, mockJSONRPC, 1)
+	mockGenesis(ctx, t, mockJSONRPC)
+
+	assert.NoError(t, c.ValidateNetwork(ctx, MainnetGenesisBlockIdentifier))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestValidateNetwork_ChainIDMismatch(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		p:              params.GoerliChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockChainID(ctx, mockJSONRPC, 1)
+
+	err := c.ValidateNetwork(ctx, GoerliGenesisBlockIdentifier)
+	assert.True(t, errors.Is(err, ErrNetworkMismatch))
+	assert.Contains(t, err.Error(), "expected chain ID 5 but node has 1")
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestValidateNetwork_GenesisMismatch(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		p:              params.MainnetChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockChainID(ctx, mockJSONRPC, 1)
+	mockGenesis(ctx, t, mockJSONRPC)
+
+	err := c.ValidateNetwork(ctx, GoerliGenesisBlockIdentifier)
+	assert.True(t, errors.Is(err, ErrNetworkMismatch))
+	assert.Contains(t, err.Error(), "expected genesis block")
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBatchCallContext(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+		batchSize:      2,
+	}
+
+	ctx := context.Background()
+	reqs := make([]rpc.BatchElem, 5)
+	for i := range reqs {
+		reqs[i] = rpc.BatchElem{Method: "eth_getTransactionReceipt"}
+	}
+
+	for _, size := range []int{2, 2, 1} {
+		size := size
+		mockJSONRPC.On(
+			"BatchCallContext",
+			ctx,
+			mock.MatchedBy(func(b []rpc.BatchElem) bool {
+				return len(b) == size
+			}),
+		).Return(
+			nil,
+		).Once()
+	}
+
+	assert.NoError(t, c.batchCallContext(ctx, reqs))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBlocks(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		tc:             tc,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"BatchCallContext",
+		ctx,
+		mock.MatchedBy(func(b []rpc.BatchElem) bool {
+			return b[0].Method == "eth_getBlockByNumber"
+		}),
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).([]rpc.BatchElem)
+
+			assert.Len(t, r, 2)
+			for i, index := range []string{"10991", "10992"} {
+				assert.Equal(t, toBlockNumArg(big.NewInt(10991+int64(i))), r[i].Args[0])
+
+				file, err := ioutil.ReadFile("testdata/block_" + index + ".json")
+				assert.NoError(t, err)
+
+				*(r[i].Result.(*json.RawMessage)) = json.RawMessage(file)
+			}
+		},
+	).Once()
+	mockJSONRPC.On(
+		"BatchCallContext",
+		mock.Anything,
+		mock.MatchedBy(func(b []rpc.BatchElem) bool {
+			return b[0].Method == "eth_getUncleByBlockHashAndIndex"
+		}),
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).([]rpc.BatchElem)
+
+			assert.Len(t, r, 1)
+			assert.Equal(
+				t,
+				common.HexToHash(
+					"0x4cd21f49705529e2628f8ae1a248bcd0e3cafd21bf6d741bdee2820af82cff95",
+				),
+				r[0].Args[0],
+			)
+
+			file, err := ioutil.ReadFile(
+				"testdata/uncle_0x8e585e32e6beb4b1f60377d53210a521ace5c30395c34398d535ea56edcf8899.json",
+			) // nolint
+			assert.NoError(t, err)
+
+			header := new(types.Header)
+			assert.NoError(t, header.UnmarshalJSON(file))
+			*(r[0].Result.(**types.Header)) = header
+		},
+	).Once()
+	for _, hash := range []string{
+		"0x4cd21f49705529e2628f8ae1a248bcd0e3cafd21bf6d741bdee2820af82cff95",
+		"0xba9ded5ca1ec9adb9451bf062c9de309d9552fa0f0254a7b982d3daf7ae436ae",
+	} {
+		hash := hash
+		mockJSONRPC.On(
+			"CallContext",
+			mock.Anything,
+			mock.Anything,
+			"debug_traceBlockByHash",
+			common.HexToHash(hash),
+			tc,
+		).Return(
+			nil,
+		).Run(
+			func(args mock.Arguments) {
+				r := args.Get(1).(*json.RawMessage)
+
+				file, err := ioutil.ReadFile("testdata/block_trace_" + hash + ".json")
+				assert.NoError(t, err)
+
+				*r = json.RawMessage(file)
+			},
+		).Once()
+	}
+
+	blocks, err := c.Blocks(ctx, 10991, 10992)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+
+	for i, index := range []string{"10991", "10992"} {
+		correctRaw, err := ioutil.ReadFile("testdata/block_response_" + index + ".json")
+		assert.NoError(t, err)
+		var correct *RosettaTypes.BlockResponse
+		assert.NoError(t, json.Unmarshal(correctRaw, &correct))
+
+		assert.Equal(t, correct.Block, blocks[i])
+	}
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBlocks_Orphaned(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"BatchCallContext",
+		ctx,
+		mock.Anything,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).([]rpc.BatchElem)
+
+			assert.Len(t, r, 2)
+			for i, index := range []string{"10992", "10994"} {
+				file, err := ioutil.ReadFile("testdata/block_" + index + ".json")
+				assert.NoError(t, err)
+
+				*(r[i].Result.(*json.RawMessage)) = json.RawMessage(file)
+			}
+		},
+	).Once()
+
+	blocks, err := c.Blocks(ctx, 10992, 10993)
+	assert.Nil(t, blocks)
+	assert.True(t, errors.Is(err, ErrBlockOrphaned))
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBlocks_InvalidRange(t *testing.T) {
+	c := &Client{}
+	ctx := context.Background()
+
+	_, err := c.Blocks(ctx, 10, 9)
+	assert.True(t, errors.Is(err, ErrInvalidBlockRange))
+
+	_, err = c.Blocks(ctx, -1, 1)
+	assert.True(t, errors.Is(err, ErrInvalidBlockRange))
+
+	_, err = c.Blocks(ctx, 0, maxBlocksRange)
+	assert.True(t, errors.Is(err, ErrInvalidBlockRange))
+}
+
+// methodNotFoundError is returned by nodes
+// for unsupported methods.
+type methodNotFoundError struct{}
+
+func (e *methodNotFoundError) Error() string  { return "method not found" }
+func (e *methodNotFoundError) ErrorCode() int { return methodNotFoundCode }
+
+// mockBlock10994 mocks the block and trace
+// calls made to fetch block 10994.
+func mockBlock10994(
+	ctx context.Context,
+	t *testing.T,
+	mockJSONRPC *mocks.JSONRPC,
+	tc *tracers.TraceConfig,
+) {
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"0x2af2",
+		true,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+
+			file, err := ioutil.ReadFile("testdata/block_10994.json")
+			assert.NoError(t, err)
+
+			*r = json.RawMessage(file)
+		},
+	).Once()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"debug_traceBlockByHash",
+		common.HexToHash("0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50"),
+		tc,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+
+			file, err := ioutil.ReadFile(
+				"testdata/block_trace_0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50.json",
+			) // nolint
+			assert.NoError(t, err)
+
+			*r = json.RawMessage(file)
+		},
+	).Once()
+}
+
+// loadReceipt10994 returns the receipt of
+// the only transaction in block 10994.
+func loadReceipt10994(t *testing.T) *types.Receipt {
+	file, err := ioutil.ReadFile(
+		"testdata/tx_receipt_0xd83b1dcf7d47c4115d78ce0361587604e8157591b118bd64ada02e86c9d5ca7e.json",
+	) // nolint
+	assert.NoError(t, err)
+
+	receipt := new(types.Receipt)
+	assert.NoError(t, receipt.UnmarshalJSON(file))
+
+	return receipt
+}
+
+func TestBlock_BlockReceipts(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		tc:             tc,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+		blockReceipts:  1,
+	}
+
+	ctx := context.Background()
+	mockBlock10994(ctx, t, mockJSONRPC, tc)
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockReceipts",
+		"0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*[]*types.Receipt)
+			*r = []*types.Receipt{loadReceipt10994(t)}
+		},
+	).Once()
+
+	correctRaw, err := ioutil.ReadFile("testdata/block_response_10994.json")
+	assert.NoError(t, err)
+	var correctResp *RosettaTypes.BlockResponse
+	assert.NoError(t, json.Unmarshal(correctRaw, &correctResp))
+
+	resp, err := c.Block(
+		ctx,
+		&RosettaTypes.PartialBlockIdentifier{
+			Index: RosettaTypes.Int64(10994),
+		},
+	)
+	assert.NoError(t, err)
+
+	jsonResp, err := jsonifyBlock(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, correctResp.Block, jsonResp)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestBlock_TraceModeOff(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := loadTraceConfig(TraceModeOff, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, tc)
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		tc:             tc,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+		blockReceipts:  1,
+	}
+
+	ctx := context.Background()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"0x2af2",
+		true,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+
+			file, err := ioutil.ReadFile("testdata/block_10994.json")
+			assert.NoError(t, err)
+
+			*r = json.RawMessage(file)
+		},
+	).Once()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockReceipts",
+		"0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*[]*types.Receipt)
+			*r = []*types.Receipt{loadReceipt10994(t)}
+		},
+	).Once()
+
+	correctRaw, err := ioutil.ReadFile("testdata/block_response_10994.json")
+	assert.NoError(t, err)
+	var correctResp *RosettaTypes.BlockResponse
+	assert.NoError(t, json.Unmarshal(correctRaw, &correctResp))
+
+	resp, err := c.Block(
+		ctx,
+		&RosettaTypes.PartialBlockIdentifier{
+			Index: RosettaTypes.Int64(10994),
+		},
+	)
+	assert.NoError(t, err)
+
+	jsonResp, err := jsonifyBlock(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"trace_incomplete": true}, jsonResp.Metadata)
+
+	// The transaction has no internal calls, so only
+	// the trace metadata differs.
+	assert.Len(t, jsonResp.Transactions, len(correctResp.Block.Transactions))
+	tx := jsonResp.Transactions[1]
+	correctTx := correctResp.Block.Transactions[1]
+	assert.Equal(t, correctTx.Operations, tx.Operations)
+	assert.Equal(t, true, tx.Metadata["trace_incomplete"])
+	assert.NotContains(t, tx.Metadata, "trace")
+	assert.Equal(t, correctTx.Metadata["receipt"], tx.Metadata["receipt"])
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestLoadTraceConfig(t *testing.T) {
+	tc, err := loadTraceConfig(TraceModeCall, 30*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "callTracer", *tc.Tracer)
+	assert.Equal(t, "30s", *tc.Timeout)
+
+	tc, err = loadTraceConfig(TraceModeCall, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "2m0s", *tc.Timeout)
+
+	_, err = loadTraceConfig("prestate", 0)
+	assert.Error(t, err)
+}
+
+func TestBlock_BlockReceiptsUnsupported(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		tc:             tc,
+		p:              params.RopstenChainConfig,
+		traceSemaphore: semaphore.NewWeighted(100),
+		blockReceipts:  1,
+	}
+
+	ctx := context.Background()
+	mockBlock10994(ctx, t, mockJSONRPC, tc)
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockReceipts",
+		"0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50",
+	).Return(
+		&methodNotFoundError{},
+	).Once()
+	mockJSONRPC.On(
+		"BatchCallContext",
+		ctx,
+		mock.Anything,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).([]rpc.BatchElem)
+
+			assert.Len(t, r, 1)
+			*(r[0].Result.(**types.Receipt)) = loadReceipt10994(t)
+		},
+	).Once()
+
+	_, err = c.Block(
+		ctx,
+		&RosettaTypes.PartialBlockIdentifier{
+			Index: RosettaTypes.Int64(10994),
+		},
+	)
+	assert.NoError(t, err)
+
+	// The method is not tried again.
+	assert.Equal(t, int32(0), c.blockReceipts)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestFlattenTraces(t *testing.T) {
+	call := &Call{
+		Type:         CallOpType,
+		Value:        big.NewInt(1),
+		Revert:       true,
+		ErrorMessage: "execution reverted",
+		Calls: []*Call{
+			{
+				Type:  CallOpType,
+				Value: big.NewInt(2),
+				Calls: []*Call{
+					{Type: CallOpType, Value: big.NewInt(3)},
+				},
+			},
+			{Type: CallOpType, Value: big.NewInt(4)},
+		},
+	}
+
+	// Traces are appended in depth-first order, and the
+	// children of reverted calls are reverted.
+	flattened := flattenTraces(call, []*flatCall{{Value: big.NewInt(0)}})
+	assert.Len(t, flattened, 5)
+	for i, trace := range flattened {
+		assert.Equal(t, int64(i), trace.Value.Int64())
+		if i > 0 {
+			assert.True(t, trace.Revert)
+			assert.Equal(t, "execution reverted", trace.ErrorMessage)
+		}
+	}
+}
+
+func TestTraceOpsNegativeBalance(t *testing.T) {
+	destroyed := common.HexToAddress("0x1")
+	beneficiary := common.HexToAddress("0x2")
+
+	// An account that sends value after it
+	// self-destructs is left with a negative
+	// balance.
+	ops, err := traceOps([]*flatCall{
+		{
+			Type:  SelfDestructOpType,
+			From:  destroyed,
+			To:    beneficiary,
+			Value: big.NewInt(0),
+		},
+		{
+			Type:  CallOpType,
+			From:  destroyed,
+			To:    beneficiary,
+			Value: big.NewInt(1),
+		},
+	}, 0)
+	assert.Nil(t, ops)
+	assert.True(t, errors.Is(err, ErrNegativeBalance))
+}
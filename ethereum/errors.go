@@ -22,4 +22,12 @@ var (
 	ErrCallParametersInvalid = errors.New("call parameters invalid")
 	ErrCallOutputMarshal     = errors.New("call output marshal")
 	ErrCallMethodInvalid     = errors.New("call method invalid")
+	ErrNetworkMismatch       = errors.New("network mismatch")
+	ErrWebSocketUnavailable  = errors.New("websocket unavailable")
+	ErrGraphQLUnavailable    = errors.New("graphql unavailable")
+	ErrInvalidBlockRange     = errors.New("invalid block range")
+	ErrBlockMismatch         = errors.New("block hash and index do not match")
+	ErrStateUnavailable      = errors.New("state unavailable")
+	ErrCircuitOpen           = errors.New("circuit breaker open")
+	ErrNegativeBalance       = errors.New("negative balance for suicided account")
 )
@@ -19,11 +19,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
 	"strings"
 
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -39,12 +39,12 @@ func logPipe(pipe io.ReadCloser, identifier string) error {
 	for {
 		str, err := reader.ReadString('\n')
 		if err != nil {
-			log.Println("closing", identifier, err)
+			zap.L().Info("closing log pipe", zap.String("logger", identifier), zap.Error(err))
 			return err
 		}
 
 		message := strings.ReplaceAll(str, "\n", "")
-		log.Println(identifier, message)
+		zap.L().Info(message, zap.String("logger", identifier))
 	}
 }
 
@@ -82,7 +82,7 @@ func StartGeth(ctx context.Context, arguments string, g *errgroup.Group) error {
 	g.Go(func() error {
 		<-ctx.Done()
 
-		log.Println("sending interrupt to geth")
+		zap.L().Info("sending interrupt to geth")
 		return cmd.Process.Signal(os.Interrupt)
 	})
 
@@ -18,17 +18,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"go.uber.org/zap"
 )
 
 const (
 	graphQLIdleConnectionTimeout = 30 * time.Second
-	graphQLMaxIdle               = 100
 	graphQLHTTPTimeout           = 15 * time.Second
 	graphQLPath                  = "graphql"
 )
@@ -67,8 +69,7 @@ func (g *GraphQLClient) Query(ctx context.Context, input string) (string, error)
 	}
 	defer func() {
 		if cerr := response.Body.Close(); cerr != nil {
-			// Log or handle the error
-			fmt.Printf("Failed to close response body: %v", cerr)
+			logger.FromContext(ctx).Warn("failed to close response body", zap.Error(cerr))
 		}
 	}()
 
@@ -80,7 +81,12 @@ func (g *GraphQLClient) Query(ctx context.Context, input string) (string, error)
 	return string(data), nil
 }
 
-func newGraphQLClient(baseURL string) (*GraphQLClient, error) {
+func newGraphQLClient(
+	baseURL string,
+	timeout time.Duration,
+	transport *http.Transport,
+	header http.Header,
+) (*GraphQLClient, error) {
 	// Compute GraphQL Endpoint
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -90,17 +96,15 @@ func newGraphQLClient(baseURL string) (*GraphQLClient, error) {
 
 	// Setup HTTP Client
 	client := &http.Client{
-		Timeout: graphQLHTTPTimeout,
+		Timeout: timeout,
 	}
 	// Override transport idle connection settings
 	//
 	// See this conversation around why `.Clone()` is used here:
 	// https://github.com/golang/go/issues/26013
-	customTransport := http.DefaultTransport.(*http.Transport).Clone()
+	customTransport := transport.Clone()
 	customTransport.IdleConnTimeout = graphQLIdleConnectionTimeout
-	customTransport.MaxIdleConns = graphQLMaxIdle
-	customTransport.MaxIdleConnsPerHost = graphQLMaxIdle
-	client.Transport = customTransport
+	client.Transport = withHeader(withConnMetrics(customTransport), header)
 
 	return &GraphQLClient{
 		client: client,
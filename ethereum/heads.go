@@ -0,0 +1,154 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// headsInitialBackoff is how long to wait before
+	// resubscribing after the first failure.
+	headsInitialBackoff = 1 * time.Second
+
+	// headsMaxBackoff is the longest to wait
+	// before resubscribing.
+	headsMaxBackoff = 2 * time.Minute
+
+	// headsBuffer is the number of headers buffered
+	// by the newHeads subscription.
+	headsBuffer = 16
+)
+
+// headTracker caches the latest header pushed by
+// the newHeads subscription. It is empty while
+// there is no active subscription.
+type headTracker struct {
+	mu     sync.RWMutex
+	header *types.Header
+}
+
+// get returns the latest header or nil
+// if there is no active subscription.
+func (h *headTracker) get() *types.Header {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.header
+}
+
+// set replaces the latest header.
+func (h *headTracker) set(header *types.Header) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header = header
+}
+
+// TrackHeads keeps the latest block up to date with a newHeads
+// subscription until ctx is done, so that Status does not need to
+// poll geth. While the subscription is down, the latest block is
+// polled and the subscription is retried with exponential backoff.
+// If no WebSocket URL is configured, it returns immediately.
+func (ec *Client) TrackHeads(ctx context.Context) error {
+	if ec.heads == nil {
+		return nil
+	}
+
+	backoff := headsInitialBackoff
+	for {
+		subscribed, err := ec.trackHeads(ctx)
+		ec.heads.set(nil)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrWebSocketUnavailable) {
+			logger.FromContext(ctx).Info("no websocket configured, polling for new heads")
+			return nil
+		}
+
+		if subscribed {
+			backoff = headsInitialBackoff
+		}
+
+		logger.FromContext(ctx).Warn(
+			"newHeads subscription failed, polling for new heads",
+			zap.Error(err),
+			zap.Duration("retry_in", backoff),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > headsMaxBackoff {
+			backoff = headsMaxBackoff
+		}
+	}
+}
+
+// trackHeads caches each header pushed by a newHeads subscription
+// until the subscription fails or ctx is done. subscribed is true
+// if the subscription was created.
+func (ec *Client) trackHeads(ctx context.Context) (bool, error) {
+	headers := make(chan *types.Header, headsBuffer)
+	sub, err := ec.Subscribe(ctx, "eth", headers, "newHeads")
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case err := <-sub.Err():
+			return true, err
+		case header := <-headers:
+			ec.heads.set(header)
+		}
+	}
+}
+
+// latestHeader returns the header pushed by the newHeads
+// subscription or, if there is none, polls geth for it.
+func (ec *Client) latestHeader(ctx context.Context) (*types.Header, error) {
+	header := ec.heads.get()
+	if ec.heads != nil {
+		metrics.CacheLookup(headsCacheName, header != nil)
+	}
+	if header != nil {
+		return header, nil
+	}
+
+	return ec.blockHeaderByNumber(ctx, nil)
+}
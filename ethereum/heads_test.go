@@ -0,0 +1,129 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// headsService implements eth_subscribe("newHeads"),
+// pushing header to each new subscription.
+type headsService struct {
+	header *types.Header
+}
+
+func (s *headsService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+	go func() {
+		_ = notifier.Notify(sub.ID, s.header)
+	}()
+
+	return sub, nil
+}
+
+func TestTrackHeads(t *testing.T) {
+	header := &types.Header{
+		Number:     big.NewInt(100),
+		Difficulty: big.NewInt(1),
+	}
+
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("eth", &headsService{header: header}))
+	defer server.Stop()
+
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpServer.Close()
+
+	mockJSONRPC := &mocks.JSONRPC{}
+	c := &Client{
+		c:     mockJSONRPC,
+		ws:    &wsConn{url: "ws" + strings.TrimPrefix(httpServer.URL, "http")},
+		heads: &headTracker{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.TrackHeads(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return c.heads.get() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The pushed header is used without polling geth.
+	latest, err := c.latestHeader(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, header.Hash(), latest.Hash())
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Nil(t, c.heads.get())
+
+	c.ws.reset("", nil)
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func TestTrackHeads_Unavailable(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	c := &Client{
+		c:     mockJSONRPC,
+		ws:    &wsConn{},
+		heads: &headTracker{},
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, c.TrackHeads(ctx))
+
+	// Without a subscription, the latest header is polled.
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_getBlockByNumber",
+		"latest",
+		false,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(**types.Header)
+			*r = &types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(1)}
+		},
+	).Once()
+
+	latest, err := c.latestHeader(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), latest.Number.Int64())
+
+	mockJSONRPC.AssertExpectations(t)
+}
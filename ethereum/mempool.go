@@ -0,0 +1,247 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMempoolTTL is how long a transaction stays in
+	// the mempool mirror after it was last seen.
+	defaultMempoolTTL = 1 * time.Minute
+
+	// mempoolBuffer is the number of hashes buffered by
+	// the newPendingTransactions subscription.
+	mempoolBuffer = 256
+)
+
+// mempoolMirror caches the hashes of the transactions pushed by the
+// newPendingTransactions subscription and returned by txpool_content,
+// with the time each was last seen. It is inactive while there is no
+// active subscription.
+type mempoolMirror struct {
+	mu     sync.RWMutex
+	active bool
+	seen   map[common.Hash]time.Time
+	ttl    time.Duration
+}
+
+// newMempoolMirror returns an inactive mempoolMirror evicting
+// transactions not seen for ttl (defaultMempoolTTL if 0).
+func newMempoolMirror(ttl time.Duration) *mempoolMirror {
+	if ttl <= 0 {
+		ttl = defaultMempoolTTL
+	}
+
+	return &mempoolMirror{ttl: ttl}
+}
+
+// hashes returns the hashes in the mirror (sorted) and whether
+// the mirror is active.
+func (m *mempoolMirror) hashes() ([]common.Hash, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.active {
+		return nil, false
+	}
+
+	hashes := make([]common.Hash, 0, len(m.seen))
+	for hash := range m.seen {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].Hex() < hashes[j].Hex()
+	})
+
+	return hashes, true
+}
+
+// size returns the number of transactions in the mirror.
+func (m *mempoolMirror) size() int {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.seen)
+}
+
+// MempoolMirrorSize returns the number of transactions in
+// the mempool mirror (0 while it is inactive).
+func (ec *Client) MempoolMirrorSize() int {
+	return ec.mempool.size()
+}
+
+// add records that hashes were seen at now.
+func (m *mempoolMirror) add(now time.Time, hashes ...common.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.seen == nil {
+		m.seen = map[common.Hash]time.Time{}
+	}
+	for _, hash := range hashes {
+		m.seen[hash] = now
+	}
+}
+
+// evict removes the transactions not seen since now-ttl.
+func (m *mempoolMirror) evict(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, seen := range m.seen {
+		if now.Sub(seen) > m.ttl {
+			delete(m.seen, hash)
+		}
+	}
+}
+
+// setActive activates the mirror or, when active is false,
+// deactivates and empties it.
+func (m *mempoolMirror) setActive(active bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.active = active
+	if !active {
+		m.seen = nil
+	}
+}
+
+// TrackMempool keeps a mirror of the mempool up to date with a
+// newPendingTransactions subscription until ctx is done, so that
+// GetMempool does not need to call txpool_content on every request.
+// The mirror is seeded from txpool_content when subscribing and
+// refreshed from it every TTL, so transactions that are mined or
+// dropped are evicted once they have not been seen for the TTL.
+// While the subscription is down, GetMempool calls txpool_content and
+// the subscription is retried with exponential backoff. If no
+// WebSocket URL is configured, it returns immediately.
+func (ec *Client) TrackMempool(ctx context.Context) error {
+	if ec.mempool == nil {
+		return nil
+	}
+
+	backoff := headsInitialBackoff
+	for {
+		subscribed, err := ec.trackMempool(ctx)
+		ec.mempool.setActive(false)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrWebSocketUnavailable) {
+			logger.FromContext(ctx).Info("no websocket configured, polling txpool_content for the mempool")
+			return nil
+		}
+
+		if subscribed {
+			backoff = headsInitialBackoff
+		}
+
+		logger.FromContext(ctx).Warn(
+			"newPendingTransactions subscription failed, polling txpool_content for the mempool",
+			zap.Error(err),
+			zap.Duration("retry_in", backoff),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > headsMaxBackoff {
+			backoff = headsMaxBackoff
+		}
+	}
+}
+
+// trackMempool adds each hash pushed by a newPendingTransactions
+// subscription to the mirror until the subscription fails or ctx is
+// done. subscribed is true if the subscription was created.
+func (ec *Client) trackMempool(ctx context.Context) (bool, error) {
+	hashes := make(chan common.Hash, mempoolBuffer)
+	sub, err := ec.Subscribe(ctx, "eth", hashes, "newPendingTransactions")
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	// Transactions that were pending before the subscription
+	// was created are only returned by txpool_content.
+	if err := ec.refreshMempool(ctx); err != nil {
+		return true, err
+	}
+	ec.mempool.setActive(true)
+
+	ticker := time.NewTicker(ec.mempool.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case err := <-sub.Err():
+			return true, err
+		case hash := <-hashes:
+			ec.mempool.add(time.Now(), hash)
+		case <-ticker.C:
+			if err := ec.refreshMempool(ctx); err != nil {
+				logger.FromContext(ctx).Warn("unable to refresh mempool", zap.Error(err))
+			}
+			ec.mempool.evict(time.Now())
+		}
+	}
+}
+
+// refreshMempool marks every transaction returned
+// by txpool_content as seen now.
+func (ec *Client) refreshMempool(ctx context.Context) error {
+	var response txPoolContentResponse
+	if err := ec.c.CallContext(ctx, &response, "txpool_content"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, pool := range []txPool{response.Pending, response.Queued} {
+		for _, inner := range pool {
+			for _, info := range inner {
+				ec.mempool.add(now, info.tx.Hash())
+			}
+		}
+	}
+
+	return nil
+}
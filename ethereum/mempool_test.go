@@ -0,0 +1,177 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// pendingService implements eth_subscribe("newPendingTransactions"),
+// pushing hash to each new subscription.
+type pendingService struct {
+	hash common.Hash
+}
+
+func (s *pendingService) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+	go func() {
+		_ = notifier.Notify(sub.ID, s.hash)
+	}()
+
+	return sub, nil
+}
+
+func TestTrackMempool(t *testing.T) {
+	pushed := common.HexToHash("0x0c0bb57da2f2e0f4d7bcd4d5c8a9f0a0e0b2b4b4a2c6b1a8f2e7c7a9b1d3e5f7")
+
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("eth", &pendingService{hash: pushed}))
+	defer server.Stop()
+
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpServer.Close()
+
+	mockJSONRPC := &mocks.JSONRPC{}
+	c := &Client{
+		c:       mockJSONRPC,
+		ws:      &wsConn{url: "ws" + strings.TrimPrefix(httpServer.URL, "http")},
+		mempool: newMempoolMirror(0),
+	}
+
+	// The mirror is seeded from txpool_content once.
+	mockJSONRPC.On(
+		"CallContext", mock.Anything, mock.Anything, "txpool_content",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*txPoolContentResponse)
+
+			file, err := ioutil.ReadFile("testdata/txpool_content.json")
+			assert.NoError(t, err)
+			assert.NoError(t, json.Unmarshal(file, r))
+		},
+	).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.TrackMempool(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		hashes, ok := c.mempool.hashes()
+		return ok && len(hashes) == 17
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The mempool is served from the mirror without
+	// calling txpool_content.
+	mempool, err := c.GetMempool(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, mempool.TransactionIdentifiers, 17)
+	assert.Contains(t, mempool.TransactionIdentifiers, &RosettaTypes.TransactionIdentifier{
+		Hash: pushed.Hex(),
+	})
+	assert.Contains(t, mempool.TransactionIdentifiers, &RosettaTypes.TransactionIdentifier{
+		Hash: "0x994024ef9f05d1cb25d01572642c1f550c78d214a52c306bb100d22c025b59d4",
+	})
+
+	cancel()
+	assert.NoError(t, <-done)
+	_, ok := c.mempool.hashes()
+	assert.False(t, ok)
+
+	c.ws.reset("", nil)
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func TestTrackMempool_Unavailable(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	c := &Client{
+		c:       mockJSONRPC,
+		ws:      &wsConn{},
+		mempool: newMempoolMirror(0),
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, c.TrackMempool(ctx))
+
+	// Without a subscription, txpool_content is called.
+	mockJSONRPC.On(
+		"CallContext", ctx, mock.Anything, "txpool_content",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*txPoolContentResponse)
+
+			file, err := ioutil.ReadFile("testdata/txpool_content.json")
+			assert.NoError(t, err)
+			assert.NoError(t, json.Unmarshal(file, r))
+		},
+	).Once()
+
+	mempool, err := c.GetMempool(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, mempool.TransactionIdentifiers, 16)
+
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func TestMempoolMirror_Evict(t *testing.T) {
+	m := newMempoolMirror(time.Minute)
+	m.setActive(true)
+
+	now := time.Now()
+	stale := common.HexToHash("0x01")
+	fresh := common.HexToHash("0x02")
+	m.add(now.Add(-2*time.Minute), stale)
+	m.add(now.Add(-30*time.Second), fresh)
+
+	m.evict(now)
+	hashes, ok := m.hashes()
+	assert.True(t, ok)
+	assert.Equal(t, []common.Hash{fresh}, hashes)
+
+	// Seeing a transaction again keeps it in the mirror.
+	m.add(now, fresh)
+	m.evict(now.Add(45 * time.Second))
+	hashes, _ = m.hashes()
+	assert.Equal(t, []common.Hash{fresh}, hashes)
+
+	m.setActive(false)
+	hashes, ok = m.hashes()
+	assert.False(t, ok)
+	assert.Nil(t, hashes)
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"github.com/coinbase/rosetta-ethereum/metrics"
+)
+
+const (
+	// batchMethod is the method label of
+	// JSON-RPC batch calls.
+	batchMethod = "batch"
+
+	// graphQLMethod is the method
+	// label of GraphQL queries.
+	graphQLMethod = "graphql"
+
+	// traceCacheName and headsCacheName are the cache
+	// labels of the trace cache and of the latest
+	// header pushed by the newHeads subscription.
+	traceCacheName = "traces"
+	headsCacheName = "heads"
+)
+
+var (
+	rpcDuration = metrics.DefaultRegistry.NewHistogram(
+		"rosetta_rpc_duration_seconds",
+		"Time taken by geth to serve requests, by method.",
+		metrics.DefaultBuckets,
+		"method",
+	)
+
+	rpcErrors = metrics.DefaultRegistry.NewCounter(
+		"rosetta_rpc_errors_total",
+		"Number of requests to geth that failed, by method.",
+		"method",
+	)
+
+	rpcSlow = metrics.DefaultRegistry.NewCounter(
+		"rosetta_slow_rpcs_total",
+		"Number of requests to geth slower than SLOW_RPC_THRESHOLD, by method.",
+		"method",
+	)
+
+	rpcBreakerState = metrics.DefaultRegistry.NewGauge(
+		"rosetta_rpc_breaker_state",
+		"State of the circuit breaker of requests to geth (0 closed, 1 half-open, 2 open).",
+	)
+
+	rpcBreakerRejections = metrics.DefaultRegistry.NewCounter(
+		"rosetta_rpc_breaker_rejections_total",
+		"Number of requests to geth failed fast by the open circuit breaker.",
+	)
+
+	rpcConnections = metrics.DefaultRegistry.NewCounter(
+		"rosetta_rpc_connections_total",
+		"Number of HTTP requests to geth sent on reused or new connections, by connection.",
+		"connection",
+	)
+
+	websocketReconnects = metrics.DefaultRegistry.NewCounter(
+		"rosetta_websocket_reconnects_total",
+		"Number of times the WebSocket (or IPC) connection to geth was dialed again.",
+	)
+)
@@ -0,0 +1,519 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/tracing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+const (
+	// nodeInitialBackoff is how long a node is skipped
+	// after its first consecutive failure.
+	nodeInitialBackoff = 1 * time.Second
+
+	// nodeMaxBackoff is the longest a failing node
+	// is skipped before it is tried again.
+	nodeMaxBackoff = 2 * time.Minute
+
+	// nodeHealthCheckInterval is how often nodes in
+	// backoff are probed by MonitorNodes.
+	nodeHealthCheckInterval = 10 * time.Second
+
+	// defaultRetryBackoff is the delay before the first
+	// retry when no backoff is configured.
+	defaultRetryBackoff = 1 * time.Second
+
+	// maxSlowParamsLength is the longest the parameters
+	// of a slow JSON-RPC call are logged.
+	maxSlowParamsLength = 256
+)
+
+// ErrNoNodes is returned when a nodePool is
+// created without any nodes.
+var ErrNoNodes = errors.New("no nodes provided")
+
+// node is a single upstream geth endpoint.
+type node struct {
+	url     string
+	rpc     JSONRPC
+	graphql GraphQL
+
+	failures int
+	retryAt  time.Time
+
+	// active is the number of requests in flight on
+	// the node. Once the node is replaced (retired), it
+	// is closed when its last request completes.
+	active  int
+	retired bool
+}
+
+// nodePool implements JSONRPC and GraphQL over an ordered
+// list of nodes. Each request is routed to the first node
+// that is not in backoff. If a node cannot be reached, it is
+// put in backoff (doubling on each consecutive failure) and
+// the request is retried on the next node. Once its backoff
+// expires, a node is promoted back ahead of lower-priority
+// nodes.
+//
+// If no node can serve a request, the request is retried
+// up to retries times, waiting backoff before the first
+// retry and doubling the wait on each subsequent one.
+//
+// Requests to a node that take longer than slowThreshold
+// (if not 0) are logged as slow. When nodes keep failing,
+// breaker fails requests fast instead.
+type nodePool struct {
+	mu    sync.Mutex
+	nodes []*node
+
+	breaker *breaker
+
+	retries       int
+	backoff       time.Duration
+	slowThreshold time.Duration
+}
+
+func newNodePool(nodes []*node, retries int, backoff time.Duration) (*nodePool, error) {
+	p := &nodePool{breaker: newBreaker()}
+	if err := p.replace(nodes, retries, backoff); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// replace swaps the nodes and retry policy of the pool.
+// Requests already in progress finish on the nodes they
+// were started on, which are closed once they complete
+// (closing an IPC client fails its requests in flight).
+func (p *nodePool) replace(nodes []*node, retries int, backoff time.Duration) error {
+	if len(nodes) == 0 {
+		return ErrNoNodes
+	}
+
+	if retries < 0 {
+		retries = 0
+	}
+
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	p.mu.Lock()
+	idle := []*node{}
+	for _, n := range p.nodes {
+		n.retired = true
+		if n.active == 0 {
+			idle = append(idle, n)
+		}
+	}
+	p.nodes = nodes
+	p.retries = retries
+	p.backoff = backoff
+	p.mu.Unlock()
+
+	for _, n := range idle {
+		n.rpc.Close()
+	}
+
+	return nil
+}
+
+// release marks the requests on nodes (returned by
+// candidates) as completed, closing the nodes that
+// were replaced and have no other request in flight.
+func (p *nodePool) release(nodes []*node) {
+	p.mu.Lock()
+	idle := []*node{}
+	for _, n := range nodes {
+		n.active--
+		if n.retired && n.active == 0 {
+			idle = append(idle, n)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, n := range idle {
+		n.rpc.Close()
+	}
+}
+
+// policy returns the retry policy of the pool.
+func (p *nodePool) policy() (int, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.retries, p.backoff
+}
+
+// setSlowThreshold logs the requests to nodes that
+// take longer than threshold as slow (0 to stop).
+func (p *nodePool) setSlowThreshold(threshold time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.slowThreshold = threshold
+}
+
+// slow returns the slow request threshold of the pool.
+func (p *nodePool) slow() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.slowThreshold
+}
+
+// candidates returns all nodes in the order they should be
+// tried: nodes that are available first (by priority), then
+// nodes in backoff (by priority). The nodes are not closed
+// until they are released.
+func (p *nodePool) candidates() []*node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	available := make([]*node, 0, len(p.nodes))
+	backoff := []*node{}
+	for _, n := range p.nodes {
+		n.active++
+		if n.retryAt.After(now) {
+			backoff = append(backoff, n)
+			continue
+		}
+
+		available = append(available, n)
+	}
+
+	return append(available, backoff...)
+}
+
+func (p *nodePool) markSuccess(ctx context.Context, n *node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n.failures > 0 {
+		logger.FromContext(ctx).Info(
+			"node recovered",
+			zap.String("node", n.url),
+			zap.Int("failures", n.failures),
+		)
+	}
+
+	n.failures = 0
+	n.retryAt = time.Time{}
+}
+
+func (p *nodePool) markFailure(ctx context.Context, n *node, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n.failures++
+	backoff := nodeInitialBackoff << uint(n.failures-1)
+	if backoff > nodeMaxBackoff || backoff <= 0 {
+		backoff = nodeMaxBackoff
+	}
+	n.retryAt = time.Now().Add(backoff)
+
+	logger.FromContext(ctx).Warn(
+		"node failed",
+		zap.String("node", n.url),
+		zap.Duration("retry_in", backoff),
+		zap.Error(err),
+	)
+}
+
+// isNodeFailure returns a boolean indicating if err
+// means the node could not serve the request (as opposed
+// to the node rejecting the request itself).
+func isNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// do invokes f on each candidate node until one serves
+// the request, retrying with backoff if none can. It
+// returns ErrCircuitOpen without invoking f while the
+// breaker is open.
+func (p *nodePool) do(ctx context.Context, f func(n *node) error) error {
+	retries, backoff := p.policy()
+	for attempt := 0; ; attempt++ {
+		if err := p.breaker.allow(); err != nil {
+			return err
+		}
+
+		served, err := p.try(ctx, f)
+		switch {
+		case ctx.Err() != nil, errors.Is(err, ErrGraphQLUnavailable):
+			// Neither says anything about the health of the nodes.
+			p.breaker.cancel()
+		case served:
+			p.breaker.success(ctx)
+		default:
+			p.breaker.failure(ctx)
+		}
+
+		if served || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > nodeMaxBackoff {
+			backoff = nodeMaxBackoff
+		}
+	}
+}
+
+// try invokes f on each candidate node until one serves
+// the request. It returns a boolean indicating if the
+// request was served (or ctx is done) along with the
+// last error returned by f.
+func (p *nodePool) try(ctx context.Context, f func(n *node) error) (bool, error) {
+	nodes := p.candidates()
+	defer p.release(nodes)
+
+	var err error
+	for _, n := range nodes {
+		err = f(n)
+		if ctx.Err() != nil {
+			return true, err
+		}
+
+		// The node cannot serve this kind of request,
+		// which does not mean it is unhealthy.
+		if errors.Is(err, ErrGraphQLUnavailable) {
+			continue
+		}
+
+		if !isNodeFailure(err) {
+			p.markSuccess(ctx, n)
+			return true, err
+		}
+
+		p.markFailure(ctx, n, err)
+	}
+
+	return false, err
+}
+
+// startRPC starts the span of a request of method
+// to a node, and returns it with the request start.
+func startRPC(ctx context.Context, method string) (*tracing.Span, time.Time) {
+	_, span := tracing.Start(ctx, "rpc "+method, tracing.KindClient)
+	span.SetAttribute("rpc.method", method)
+
+	return span, time.Now()
+}
+
+// observeRPC records the duration of a request of method
+// to a node started at start, and whether it failed with
+// err, which it returns. It ends the span of the request.
+// If the request was slow, it is logged with the fields
+// returned by details (if not nil), i.e. its parameters
+// and the size of its result.
+func (p *nodePool) observeRPC(
+	ctx context.Context,
+	span *tracing.Span,
+	method string,
+	start time.Time,
+	err error,
+	details func() []zap.Field,
+) error {
+	duration := time.Since(start)
+	rpcDuration.Observe(duration.Seconds(), method)
+	if err != nil {
+		rpcErrors.Inc(method)
+	}
+
+	span.SetError(err)
+	span.End()
+
+	if threshold := p.slow(); threshold > 0 && duration > threshold {
+		rpcSlow.Inc(method)
+
+		fields := []zap.Field{
+			zap.String("method", method),
+			zap.Duration("duration", duration),
+			zap.Duration("threshold", threshold),
+		}
+		if details != nil {
+			fields = append(fields, details()...)
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+
+		logger.FromContext(ctx).Warn("slow rpc", fields...)
+	}
+
+	return err
+}
+
+// callDetails returns the parameters of a JSON-RPC call
+// (truncated to maxSlowParamsLength) and, if result is raw
+// JSON (i.e. traces), its size.
+func callDetails(result interface{}, args []interface{}) []zap.Field {
+	var fields []zap.Field
+	if params, err := json.Marshal(args); err == nil {
+		if len(params) > maxSlowParamsLength {
+			params = append(params[:maxSlowParamsLength], "..."...)
+		}
+		fields = append(fields, zap.ByteString("params", params))
+	}
+
+	if raw, ok := result.(*json.RawMessage); ok && raw != nil {
+		fields = append(fields, zap.Int("result_bytes", len(*raw)))
+	}
+
+	return fields
+}
+
+// CallContext performs a JSON-RPC call on the first
+// available node.
+func (p *nodePool) CallContext(
+	ctx context.Context,
+	result interface{},
+	method string,
+	args ...interface{},
+) error {
+	return p.do(ctx, func(n *node) error {
+		span, start := startRPC(ctx, method)
+		err := n.rpc.CallContext(ctx, result, method, args...)
+		return p.observeRPC(ctx, span, method, start, err, func() []zap.Field {
+			return callDetails(result, args)
+		})
+	})
+}
+
+// BatchCallContext performs a JSON-RPC batch call on the
+// first available node.
+func (p *nodePool) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return p.do(ctx, func(n *node) error {
+		span, start := startRPC(ctx, batchMethod)
+		span.SetAttribute("rpc.batch_size", len(b))
+		if len(b) > 0 {
+			span.SetAttribute("rpc.batch_method", b[0].Method)
+		}
+
+		err := n.rpc.BatchCallContext(ctx, b)
+		return p.observeRPC(ctx, span, batchMethod, start, err, func() []zap.Field {
+			fields := []zap.Field{zap.Int("batch_size", len(b))}
+			if len(b) > 0 {
+				fields = append(fields, zap.String("batch_method", b[0].Method))
+			}
+
+			return fields
+		})
+	})
+}
+
+// Query performs a GraphQL query on the first
+// available node that serves GraphQL.
+func (p *nodePool) Query(ctx context.Context, input string) (string, error) {
+	var result string
+	err := p.do(ctx, func(n *node) error {
+		if n.graphql == nil {
+			return ErrGraphQLUnavailable
+		}
+
+		span, start := startRPC(ctx, graphQLMethod)
+		var err error
+		result, err = n.graphql.Query(ctx, input)
+		return p.observeRPC(ctx, span, graphQLMethod, start, err, func() []zap.Field {
+			return []zap.Field{zap.Int("result_bytes", len(result))}
+		})
+	})
+
+	return result, err
+}
+
+// Close closes the JSON-RPC connection of all nodes.
+func (p *nodePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, n := range p.nodes {
+		n.rpc.Close()
+	}
+}
+
+// healthCheck probes all nodes that have recently failed
+// and promotes any that respond, closing the breaker.
+func (p *nodePool) healthCheck(ctx context.Context) {
+	nodes := p.candidates()
+	defer p.release(nodes)
+
+	for _, n := range nodes {
+		p.mu.Lock()
+		failing := n.failures > 0
+		p.mu.Unlock()
+		if !failing {
+			continue
+		}
+
+		var result string
+		err := n.rpc.CallContext(ctx, &result, "eth_blockNumber")
+		if ctx.Err() != nil {
+			return
+		}
+
+		if isNodeFailure(err) {
+			p.markFailure(ctx, n, err)
+			continue
+		}
+
+		p.markSuccess(ctx, n)
+
+		// A node recovered, so requests need
+		// not wait for the breaker to probe it.
+		p.breaker.success(ctx)
+	}
+}
+
+// monitor runs healthCheck every nodeHealthCheckInterval
+// until ctx is done.
+func (p *nodePool) monitor(ctx context.Context) error {
+	ticker := time.NewTicker(nodeHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.healthCheck(ctx)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testRPCError struct{}
+
+func (e *testRPCError) Error() string  { return "execution reverted" }
+func (e *testRPCError) ErrorCode() int { return 3 }
+
+func TestNodePool_Failover(t *testing.T) {
+	primaryRPC := &mocks.JSONRPC{}
+	backupRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "primary", rpc: primaryRPC},
+		{url: "backup", rpc: backupRPC},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("connection refused"),
+	).Once()
+	backupRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Twice()
+
+	var result string
+	assert.NoError(t, pool.CallContext(ctx, &result, "eth_blockNumber"))
+	assert.Equal(t, 1, pool.nodes[0].failures)
+	assert.True(t, pool.nodes[0].retryAt.After(time.Now()))
+
+	// The primary is in backoff, so the backup serves
+	// requests until the primary is promoted again.
+	assert.NoError(t, pool.CallContext(ctx, &result, "eth_blockNumber"))
+
+	pool.nodes[0].retryAt = time.Now()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Once()
+	assert.NoError(t, pool.CallContext(ctx, &result, "eth_blockNumber"))
+	assert.Equal(t, 0, pool.nodes[0].failures)
+
+	primaryRPC.AssertExpectations(t)
+	backupRPC.AssertExpectations(t)
+}
+
+func TestNodePool_RPCErrorDoesNotFailover(t *testing.T) {
+	primaryRPC := &mocks.JSONRPC{}
+	backupRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "primary", rpc: primaryRPC},
+		{url: "backup", rpc: backupRPC},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	rpcErr := &testRPCError{}
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_call",
+	).Return(
+		rpcErr,
+	).Once()
+
+	var result string
+	assert.Equal(t, rpcErr, pool.CallContext(ctx, &result, "eth_call"))
+	assert.Equal(t, 0, pool.nodes[0].failures)
+
+	primaryRPC.AssertExpectations(t)
+	backupRPC.AssertExpectations(t)
+}
+
+func TestNodePool_AllNodesFail(t *testing.T) {
+	primaryRPC := &mocks.JSONRPC{}
+	backupRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "primary", rpc: primaryRPC},
+		{url: "backup", rpc: backupRPC},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("connection refused"),
+	).Once()
+	backupRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("i/o timeout"),
+	).Once()
+
+	var result string
+	err = pool.CallContext(ctx, &result, "eth_blockNumber")
+	assert.EqualError(t, err, "i/o timeout")
+	assert.Equal(t, 1, pool.nodes[0].failures)
+	assert.Equal(t, 1, pool.nodes[1].failures)
+
+	primaryRPC.AssertExpectations(t)
+	backupRPC.AssertExpectations(t)
+}
+
+func TestNewNodePool_Empty(t *testing.T) {
+	pool, err := newNodePool(nil, 0, 0)
+	assert.Nil(t, pool)
+	assert.True(t, errors.Is(err, ErrNoNodes))
+}
+
+func TestNodePool_Retries(t *testing.T) {
+	primaryRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "primary", rpc: primaryRPC},
+	}, 2, time.Millisecond)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("connection refused"),
+	).Twice()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Once()
+
+	var result string
+	assert.NoError(t, pool.CallContext(ctx, &result, "eth_blockNumber"))
+	assert.Equal(t, 0, pool.nodes[0].failures)
+
+	primaryRPC.AssertExpectations(t)
+}
+
+func TestNodePool_RetriesExhausted(t *testing.T) {
+	primaryRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "primary", rpc: primaryRPC},
+	}, 1, time.Millisecond)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	primaryRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		errors.New("connection refused"),
+	).Twice()
+
+	var result string
+	err = pool.CallContext(ctx, &result, "eth_blockNumber")
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 2, pool.nodes[0].failures)
+
+	primaryRPC.AssertExpectations(t)
+}
+
+func TestNodePool_Replace(t *testing.T) {
+	oldRPC := &mocks.JSONRPC{}
+	newRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "old", rpc: oldRPC},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	assert.True(t, errors.Is(pool.replace(nil, 0, 0), ErrNoNodes))
+
+	oldRPC.On("Close").Once()
+	assert.NoError(t, pool.replace([]*node{
+		{url: "new", rpc: newRPC},
+	}, 3, time.Second))
+
+	retries, backoff := pool.policy()
+	assert.Equal(t, 3, retries)
+	assert.Equal(t, time.Second, backoff)
+
+	ctx := context.Background()
+	newRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Once()
+
+	var result string
+	assert.NoError(t, pool.CallContext(ctx, &result, "eth_blockNumber"))
+
+	oldRPC.AssertExpectations(t)
+	newRPC.AssertExpectations(t)
+}
+
+func TestNodePool_ReplaceInFlight(t *testing.T) {
+	oldRPC := &mocks.JSONRPC{}
+	newRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{
+		{url: "old", rpc: oldRPC},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	oldRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			close(started)
+			<-unblock
+		},
+	).Once()
+
+	done := make(chan error)
+	go func() {
+		var result string
+		done <- pool.CallContext(ctx, &result, "eth_blockNumber")
+	}()
+	<-started
+
+	// The old node is not closed while
+	// its request is in flight.
+	assert.NoError(t, pool.replace([]*node{
+		{url: "new", rpc: newRPC},
+	}, 0, 0))
+	oldRPC.AssertNotCalled(t, "Close")
+
+	oldRPC.On("Close").Once()
+	close(unblock)
+	assert.NoError(t, <-done)
+
+	oldRPC.AssertExpectations(t)
+	newRPC.AssertExpectations(t)
+}
+
+func TestNodePool_QuerySkipsNodesWithoutGraphQL(t *testing.T) {
+	ipcRPC := &mocks.JSONRPC{}
+	httpGraphQL := &mocks.GraphQL{}
+	pool, err := newNodePool([]*node{
+		{url: "ipc:///data/geth.ipc", rpc: ipcRPC},
+		{url: "http", rpc: &mocks.JSONRPC{}, graphql: httpGraphQL},
+	}, 0, 0)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	httpGraphQL.On("Query", ctx, "{}").Return("result", nil).Once()
+
+	result, err := pool.Query(ctx, "{}")
+	assert.NoError(t, err)
+	assert.Equal(t, "result", result)
+	assert.Equal(t, 0, pool.nodes[0].failures)
+
+	ipcRPC.AssertExpectations(t)
+	httpGraphQL.AssertExpectations(t)
+}
+
+func TestNodePool_SlowRPC(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	nodeRPC := &mocks.JSONRPC{}
+	pool, err := newNodePool([]*node{{url: "primary", rpc: nodeRPC}}, 0, 0)
+	assert.NoError(t, err)
+	pool.setSlowThreshold(10 * time.Millisecond)
+
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	hash := "0xd83b1dcf7d47c4115d78ce0361587604e8157591b118bd64ada02e86c9d5ca7e"
+	nodeRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"debug_traceBlockByHash",
+		hash,
+	).Run(func(args mock.Arguments) {
+		time.Sleep(20 * time.Millisecond)
+		*args.Get(1).(*json.RawMessage) = json.RawMessage(`[{"result":{}}]`)
+	}).Return(
+		nil,
+	).Once()
+	nodeRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_blockNumber",
+	).Return(
+		nil,
+	).Once()
+
+	var raw json.RawMessage
+	assert.NoError(t, pool.CallContext(ctx, &raw, "debug_traceBlockByHash", hash))
+	assert.NoError(t, pool.CallContext(ctx, nil, "eth_blockNumber"))
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "slow rpc", entries[0].Message)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "debug_traceBlockByHash", fields["method"])
+	assert.Equal(t, `["`+hash+`"]`, fields["params"])
+	assert.Equal(t, int64(len(raw)), fields["result_bytes"])
+	assert.Equal(t, 10*time.Millisecond, fields["threshold"])
+
+	nodeRPC.AssertExpectations(t)
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallel(t *testing.T) {
+	t.Run("all indexes", func(t *testing.T) {
+		done := make([]int32, 100)
+		running := int32(0)
+		maxRunning := int32(0)
+		err := parallel(len(done), 4, func(i int) error {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				seen := atomic.LoadInt32(&maxRunning)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&done[i], 1)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		for i := range done {
+			assert.Equal(t, int32(1), done[i])
+		}
+		assert.True(t, maxRunning <= 4)
+	})
+
+	t.Run("no indexes", func(t *testing.T) {
+		assert.NoError(t, parallel(0, 4, func(i int) error {
+			t.Fatal("unexpected call")
+			return nil
+		}))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		calls := int32(0)
+		err := parallel(100, 1, func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			if i == 2 {
+				return errFailed
+			}
+
+			return nil
+		})
+		assert.True(t, errors.Is(err, errFailed))
+
+		// Indexes after the failure are skipped.
+		assert.Equal(t, int32(3), calls)
+	})
+}
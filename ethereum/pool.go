@@ -0,0 +1,76 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"math/big"
+	"sync"
+)
+
+// maxPooledCalls is the capacity above which slices of
+// flattened calls are not pooled, so that a single
+// transaction with huge traces does not pin memory.
+const maxPooledCalls = 4096
+
+var (
+	// flatCallsPool pools the slices that the traces of
+	// each transaction are flattened into while its
+	// operations are computed.
+	flatCallsPool = sync.Pool{
+		New: func() interface{} {
+			calls := make([]*flatCall, 0, 64) // nolint:gomnd
+			return &calls
+		},
+	}
+
+	// bigIntPool pools scratch values used to compute
+	// amounts that are only needed as strings.
+	bigIntPool = sync.Pool{
+		New: func() interface{} {
+			return new(big.Int)
+		},
+	}
+)
+
+// getFlatCalls returns an empty slice of
+// flattened calls from flatCallsPool.
+func getFlatCalls() *[]*flatCall {
+	return flatCallsPool.Get().(*[]*flatCall)
+}
+
+// putFlatCalls returns calls to flatCallsPool. The
+// calls must not be used after they are returned.
+func putFlatCalls(calls *[]*flatCall) {
+	if cap(*calls) > maxPooledCalls {
+		return
+	}
+
+	// The calls are cleared, so that the traces
+	// they point to can be garbage collected.
+	for i := range *calls {
+		(*calls)[i] = nil
+	}
+	*calls = (*calls)[:0]
+	flatCallsPool.Put(calls)
+}
+
+// negString returns the decimal string of -v,
+// using a pooled scratch value.
+func negString(v *big.Int) string {
+	scratch := bigIntPool.Get().(*big.Int)
+	defer bigIntPool.Put(scratch)
+
+	return scratch.Neg(v).String()
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegString(t *testing.T) {
+	value := big.NewInt(1000)
+	assert.Equal(t, "-1000", negString(value))
+	assert.Equal(t, "1000", negString(big.NewInt(-1000)))
+	assert.Equal(t, "0", negString(new(big.Int)))
+
+	// The value is not modified.
+	assert.Equal(t, "1000", value.String())
+}
+
+func TestFlatCallsPool(t *testing.T) {
+	calls := getFlatCalls()
+	*calls = append(*calls, &flatCall{}, &flatCall{})
+	backing := (*calls)[:2]
+	putFlatCalls(calls)
+
+	// Returned calls are cleared.
+	assert.Len(t, *calls, 0)
+	assert.Nil(t, backing[0])
+	assert.Nil(t, backing[1])
+}
+
+// testCall returns a call with width value
+// transfers, each making depth nested calls.
+func testCall(width int, depth int) *Call {
+	from := common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309")
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	call := &Call{
+		Type:    CallOpType,
+		From:    from,
+		To:      to,
+		Value:   big.NewInt(1000),
+		GasUsed: big.NewInt(21000),
+	}
+	for i := 0; i < width; i++ {
+		child := &Call{
+			Type:    CallOpType,
+			From:    to,
+			To:      from,
+			Value:   big.NewInt(int64(i + 1)),
+			GasUsed: big.NewInt(21000),
+		}
+		parent := child
+		for j := 0; j < depth; j++ {
+			nested := &Call{
+				Type:    CallOpType,
+				From:    from,
+				To:      to,
+				Value:   big.NewInt(int64(j + 1)),
+				GasUsed: big.NewInt(21000),
+			}
+			parent.Calls = []*Call{nested}
+			parent = nested
+		}
+		call.Calls = append(call.Calls, child)
+	}
+
+	return call
+}
+
+// BenchmarkTraceOps computes the trace operations of a
+// transaction making many nested value transfers, as
+// populateTransaction does.
+func BenchmarkTraceOps(b *testing.B) {
+	call := testCall(100, 5)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calls := getFlatCalls()
+		*calls = flattenTraces(call, *calls)
+		if _, err := traceOps(*calls, 0); err != nil {
+			b.Fatal(err)
+		}
+		putFlatCalls(calls)
+	}
+}
+
+func BenchmarkFeeOps(b *testing.B) {
+	from := common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309")
+	tx := &loadedTransaction{
+		From:      &from,
+		FeeAmount: big.NewInt(2000000000000000),
+		FeeBurned: big.NewInt(1500000000000000),
+		Miner:     "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d",
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		feeOps(tx)
+	}
+}
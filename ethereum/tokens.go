@@ -0,0 +1,237 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	EthTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// balanceOfMethodID is the method ID
+	// of balanceOf(address).
+	balanceOfMethodID = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+
+	// symbolMethodID and decimalsMethodID are the
+	// method IDs of symbol() and decimals().
+	symbolMethodID   = crypto.Keccak256([]byte("symbol()"))[:4]
+	decimalsMethodID = crypto.Keccak256([]byte("decimals()"))[:4]
+
+	// transferEventTopic is the topic of
+	// Transfer(address,address,uint256) events.
+	transferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+	// symbolArguments are the outputs of symbol() for
+	// tokens returning a string, parsed once instead
+	// of on every decode.
+	symbolArguments = abi.Arguments{{Type: mustNewType("string")}}
+)
+
+// mustNewType returns the ABI type t,
+// panicking if it is invalid.
+func mustNewType(t string) abi.Type {
+	parsed, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+// isTokenTransfer returns true if log is a
+// Transfer(address,address,uint256) event of an
+// ERC-20 token. ERC-721 transfers have the same
+// signature but also index the token ID.
+func isTokenTransfer(log *EthTypes.Log) bool {
+	return len(log.Topics) == 3 &&
+		log.Topics[0] == transferEventTopic &&
+		len(log.Data) == common.HashLength
+}
+
+// LoadTokens looks up the symbol and decimals of the ERC-20
+// tokens at addresses, and returns their currencies. The
+// transfers of these tokens are included in blocks.
+//
+// LoadTokens must be called before blocks are fetched.
+func (ec *Client) LoadTokens(
+	ctx context.Context,
+	addresses []string,
+) ([]*RosettaTypes.Currency, error) {
+	tokens := map[common.Address]*RosettaTypes.Currency{}
+	currencies := make([]*RosettaTypes.Currency, len(addresses))
+	for i, address := range addresses {
+		contract := common.HexToAddress(address)
+		currency, err := ec.tokenCurrency(ctx, contract)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load token %s", err, address)
+		}
+
+		tokens[contract] = currency
+		currencies[i] = currency
+	}
+
+	ec.tokens = tokens
+	return currencies, nil
+}
+
+// tokenCurrency returns the currency of the
+// ERC-20 token at contract.
+func (ec *Client) tokenCurrency(
+	ctx context.Context,
+	contract common.Address,
+) (*RosettaTypes.Currency, error) {
+	symbolData, err := ec.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: symbolMethodID,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to call symbol()", err)
+	}
+
+	symbol, err := parseTokenSymbol(symbolData)
+	if err != nil {
+		return nil, err
+	}
+
+	decimalsData, err := ec.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: decimalsMethodID,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to call decimals()", err)
+	}
+
+	if len(decimalsData) != common.HashLength {
+		return nil, fmt.Errorf("invalid decimals %x", decimalsData)
+	}
+	decimals := new(big.Int).SetBytes(decimalsData)
+	if !decimals.IsInt64() || decimals.Int64() > 255 { // nolint:gomnd
+		return nil, fmt.Errorf("invalid decimals %s", decimals)
+	}
+
+	return &RosettaTypes.Currency{
+		Symbol:   symbol,
+		Decimals: int32(decimals.Int64()),
+		Metadata: map[string]interface{}{
+			ContractAddressKey: contract.Hex(),
+		},
+	}, nil
+}
+
+// parseTokenSymbol returns the symbol returned by symbol(),
+// which is a string for most tokens and a bytes32 for some
+// early ones.
+func parseTokenSymbol(data []byte) (string, error) {
+	if len(data) == common.HashLength {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+
+	values, err := symbolArguments.Unpack(data)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid symbol %x", err, data)
+	}
+
+	symbol, ok := values[0].(string)
+	if !ok || len(symbol) == 0 {
+		return "", fmt.Errorf("invalid symbol %x", data)
+	}
+
+	return symbol, nil
+}
+
+// tokenTransferOps returns the operations of the transfers of
+// tokens logged in receipt, starting at startIndex. Mints and
+// burns (transfers from and to the zero address) only have the
+// operation of the other account.
+//
+// Logs are matched against the topic of transfers before the
+// tokens are looked up, as most logs of token-heavy blocks are
+// events of other contracts or transfers of other tokens.
+func tokenTransferOps(
+	tokens map[common.Address]*RosettaTypes.Currency,
+	receipt *EthTypes.Receipt,
+	startIndex int,
+) []*RosettaTypes.Operation {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var ops []*RosettaTypes.Operation
+	for _, log := range receipt.Logs {
+		if !isTokenTransfer(log) {
+			continue
+		}
+
+		currency, ok := tokens[log.Address]
+		if !ok {
+			continue
+		}
+
+		from := common.BytesToAddress(log.Topics[1].Bytes())
+		to := common.BytesToAddress(log.Topics[2].Bytes())
+		amount := new(big.Int).SetBytes(log.Data)
+
+		var fromIndex *RosettaTypes.OperationIdentifier
+		if from != (common.Address{}) {
+			fromIndex = &RosettaTypes.OperationIdentifier{
+				Index: int64(startIndex + len(ops)),
+			}
+			ops = append(ops, &RosettaTypes.Operation{
+				OperationIdentifier: fromIndex,
+				Type:                CallOpType,
+				Status:              RosettaTypes.String(SuccessStatus),
+				Account: &RosettaTypes.AccountIdentifier{
+					Address: from.Hex(),
+				},
+				Amount: &RosettaTypes.Amount{
+					Value:    negString(amount),
+					Currency: currency,
+				},
+			})
+		}
+
+		if to != (common.Address{}) {
+			toOp := &RosettaTypes.Operation{
+				OperationIdentifier: &RosettaTypes.OperationIdentifier{
+					Index: int64(startIndex + len(ops)),
+				},
+				Type:   CallOpType,
+				Status: RosettaTypes.String(SuccessStatus),
+				Account: &RosettaTypes.AccountIdentifier{
+					Address: to.Hex(),
+				},
+				Amount: &RosettaTypes.Amount{
+					Value:    amount.String(),
+					Currency: currency,
+				},
+			}
+			if fromIndex != nil {
+				toOp.RelatedOperations = []*RosettaTypes.OperationIdentifier{fromIndex}
+			}
+			ops = append(ops, toOp)
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,276 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/sync/semaphore"
+)
+
+// mockTokenCall mocks the eth_call of method
+// on contract at the latest block.
+func mockTokenCall(
+	ctx context.Context,
+	mockJSONRPC *mocks.JSONRPC,
+	contract common.Address,
+	method []byte,
+	result string,
+) {
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"eth_call",
+		map[string]interface{}{
+			"from": common.Address{},
+			"to":   &contract,
+			"data": hexutil.Bytes(method),
+		},
+		"latest",
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*hexutil.Bytes)
+
+			*r = hexutil.MustDecode(result)
+		},
+	).Once()
+}
+
+func TestLoadTokens(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+	mockGraphQL := &mocks.GraphQL{}
+
+	c := &Client{
+		c:              mockJSONRPC,
+		g:              mockGraphQL,
+		traceSemaphore: semaphore.NewWeighted(100),
+	}
+
+	ctx := context.Background()
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	mkr := common.HexToAddress("0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2")
+	mockTokenCall(
+		ctx,
+		mockJSONRPC,
+		usdc,
+		symbolMethodID,
+		"0x"+
+			"0000000000000000000000000000000000000000000000000000000000000020"+
+			"0000000000000000000000000000000000000000000000000000000000000004"+
+			"5553444300000000000000000000000000000000000000000000000000000000",
+	)
+	mockTokenCall(
+		ctx,
+		mockJSONRPC,
+		usdc,
+		decimalsMethodID,
+		"0x0000000000000000000000000000000000000000000000000000000000000006",
+	)
+	mockTokenCall(
+		ctx,
+		mockJSONRPC,
+		mkr,
+		symbolMethodID,
+		"0x4d4b520000000000000000000000000000000000000000000000000000000000",
+	)
+	mockTokenCall(
+		ctx,
+		mockJSONRPC,
+		mkr,
+		decimalsMethodID,
+		"0x0000000000000000000000000000000000000000000000000000000000000012",
+	)
+
+	currencies, err := c.LoadTokens(ctx, []string{usdc.Hex(), mkr.Hex()})
+	assert.NoError(t, err)
+	usdcCurrency := &RosettaTypes.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: usdc.Hex(),
+		},
+	}
+	mkrCurrency := &RosettaTypes.Currency{
+		Symbol:   "MKR",
+		Decimals: 18,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: mkr.Hex(),
+		},
+	}
+	assert.Equal(t, []*RosettaTypes.Currency{usdcCurrency, mkrCurrency}, currencies)
+	assert.Equal(t, map[common.Address]*RosettaTypes.Currency{
+		usdc: usdcCurrency,
+		mkr:  mkrCurrency,
+	}, c.tokens)
+
+	// Contracts without a symbol are not tokens
+	notToken := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	mockTokenCall(ctx, mockJSONRPC, notToken, symbolMethodID, "0x")
+	_, err = c.LoadTokens(ctx, []string{notToken.Hex()})
+	assert.Error(t, err)
+
+	mockJSONRPC.AssertExpectations(t)
+	mockGraphQL.AssertExpectations(t)
+}
+
+func TestTokenTransferOps(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	currency := &RosettaTypes.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: usdc.Hex(),
+		},
+	}
+	tokens := map[common.Address]*RosettaTypes.Currency{usdc: currency}
+
+	from := common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309")
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	amount := common.BigToHash(big.NewInt(1000000)).Bytes()
+	receipt := &types.Receipt{
+		Logs: []*types.Log{
+			{
+				// Transfer
+				Address: usdc,
+				Topics:  []common.Hash{transferEventTopic, from.Hash(), to.Hash()},
+				Data:    amount,
+			},
+			{
+				// Mint
+				Address: usdc,
+				Topics:  []common.Hash{transferEventTopic, {}, to.Hash()},
+				Data:    amount,
+			},
+			{
+				// Token that is not whitelisted
+				Address: to,
+				Topics:  []common.Hash{transferEventTopic, from.Hash(), to.Hash()},
+				Data:    amount,
+			},
+			{
+				// ERC-721 transfer
+				Address: usdc,
+				Topics:  []common.Hash{transferEventTopic, from.Hash(), to.Hash(), {}},
+			},
+			{
+				// Other event
+				Address: usdc,
+				Topics:  []common.Hash{{}, from.Hash(), to.Hash()},
+				Data:    amount,
+			},
+		},
+	}
+
+	assert.Equal(t, []*RosettaTypes.Operation{
+		{
+			OperationIdentifier: &RosettaTypes.OperationIdentifier{Index: 2},
+			Type:                CallOpType,
+			Status:              RosettaTypes.String(SuccessStatus),
+			Account:             &RosettaTypes.AccountIdentifier{Address: from.Hex()},
+			Amount:              &RosettaTypes.Amount{Value: "-1000000", Currency: currency},
+		},
+		{
+			OperationIdentifier: &RosettaTypes.OperationIdentifier{Index: 3},
+			RelatedOperations:   []*RosettaTypes.OperationIdentifier{{Index: 2}},
+			Type:                CallOpType,
+			Status:              RosettaTypes.String(SuccessStatus),
+			Account:             &RosettaTypes.AccountIdentifier{Address: to.Hex()},
+			Amount:              &RosettaTypes.Amount{Value: "1000000", Currency: currency},
+		},
+		{
+			OperationIdentifier: &RosettaTypes.OperationIdentifier{Index: 4},
+			Type:                CallOpType,
+			Status:              RosettaTypes.String(SuccessStatus),
+			Account:             &RosettaTypes.AccountIdentifier{Address: to.Hex()},
+			Amount:              &RosettaTypes.Amount{Value: "1000000", Currency: currency},
+		},
+	}, tokenTransferOps(tokens, receipt, 2))
+
+	assert.Nil(t, tokenTransferOps(nil, receipt, 2))
+}
+
+// BenchmarkTokenTransferOps decodes a token-heavy receipt, whose
+// logs are mostly transfers of tokens that are not whitelisted
+// and other events.
+func BenchmarkTokenTransferOps(b *testing.B) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	tokens := map[common.Address]*RosettaTypes.Currency{
+		usdc: {
+			Symbol:   "USDC",
+			Decimals: 6,
+			Metadata: map[string]interface{}{
+				ContractAddressKey: usdc.Hex(),
+			},
+		},
+	}
+
+	from := common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309")
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	amount := common.BigToHash(big.NewInt(1000000)).Bytes()
+	receipt := &types.Receipt{}
+	for i := 0; i < 100; i++ {
+		receipt.Logs = append(receipt.Logs,
+			&types.Log{
+				Address: usdc,
+				Topics:  []common.Hash{transferEventTopic, from.Hash(), to.Hash()},
+				Data:    amount,
+			},
+			&types.Log{
+				Address: common.BigToAddress(big.NewInt(int64(i))),
+				Topics:  []common.Hash{transferEventTopic, from.Hash(), to.Hash()},
+				Data:    amount,
+			},
+			&types.Log{
+				Address: usdc,
+				Topics:  []common.Hash{{}, from.Hash()},
+			},
+		)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenTransferOps(tokens, receipt, 0)
+	}
+}
+
+func BenchmarkParseTokenSymbol(b *testing.B) {
+	data := hexutil.MustDecode(
+		"0x0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000004" +
+			"5553444300000000000000000000000000000000000000000000000000000000",
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseTokenSymbol(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
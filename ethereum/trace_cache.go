@@ -0,0 +1,154 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTraceCacheSize is the number of blocks
+	// whose traces are cached when no size is configured.
+	defaultTraceCacheSize = 32
+
+	// traceCacheStatsInterval is how often the
+	// usage of the trace cache is logged.
+	traceCacheStatsInterval = 5 * time.Minute
+)
+
+// TraceCacheStats reports the usage of
+// the block trace cache.
+type TraceCacheStats struct {
+	// Size is the number of blocks currently cached.
+	Size int
+
+	// Hits is the number of block traces
+	// served from the cache.
+	Hits uint64
+
+	// Misses is the number of block traces
+	// requested from geth.
+	Misses uint64
+}
+
+// traceCache is an LRU cache of the raw traces of blocks,
+// keyed by block hash. Traces are cached raw (and decoded
+// on every lookup) because decoded traces are modified
+// while they are converted to operations.
+type traceCache struct {
+	blocks *lru.Cache
+
+	hits   uint64
+	misses uint64
+}
+
+// newTraceCache creates a traceCache holding
+// the traces of up to size blocks.
+func newTraceCache(size int) (*traceCache, error) {
+	if size <= 0 {
+		size = defaultTraceCacheSize
+	}
+
+	blocks, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create trace cache", err)
+	}
+
+	return &traceCache{blocks: blocks}, nil
+}
+
+// get returns the cached traces of the block
+// with hash blockHash, if any.
+func (c *traceCache) get(blockHash common.Hash) (json.RawMessage, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	raw, ok := c.blocks.Get(blockHash)
+	metrics.CacheLookup(traceCacheName, ok)
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	return raw.(json.RawMessage), true
+}
+
+// add caches the traces of the block with hash blockHash.
+func (c *traceCache) add(blockHash common.Hash, raw json.RawMessage) {
+	if c == nil {
+		return
+	}
+
+	c.blocks.Add(blockHash, raw)
+}
+
+// stats returns the usage of the cache.
+func (c *traceCache) stats() TraceCacheStats {
+	if c == nil {
+		return TraceCacheStats{}
+	}
+
+	return TraceCacheStats{
+		Size:   c.blocks.Len(),
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+// TraceCacheStats returns the usage of the block trace
+// cache (all zero when tracing is off).
+func (ec *Client) TraceCacheStats() TraceCacheStats {
+	return ec.traces.stats()
+}
+
+// LogTraceCacheStats logs the usage of the block trace cache
+// every 5 minutes until ctx is done. If tracing is off, it
+// returns immediately.
+func (ec *Client) LogTraceCacheStats(ctx context.Context) error {
+	if ec.traces == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(traceCacheStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			stats := ec.traces.stats()
+			logger.FromContext(ctx).Info(
+				"trace cache",
+				zap.Int("size", stats.Size),
+				zap.Uint64("hits", stats.Hits),
+				zap.Uint64("misses", stats.Misses),
+			)
+		}
+	}
+}
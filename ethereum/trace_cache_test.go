@@ -0,0 +1,142 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/ethereum"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestGetBlockTraces_Cache(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	traces, err := newTraceCache(1)
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		tc:             tc,
+		traceSemaphore: semaphore.NewWeighted(100),
+		traces:         traces,
+	}
+
+	ctx := context.Background()
+	hash := common.HexToHash("0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50")
+	otherHash := common.HexToHash("0x01")
+	file, err := ioutil.ReadFile(
+		"testdata/block_trace_0xb6a2558c2e54bfb11247d0764311143af48d122f29fc408d9519f47d70aa2d50.json",
+	) // nolint
+	assert.NoError(t, err)
+
+	// Each block is traced on the first request
+	// and after being evicted.
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"debug_traceBlockByHash",
+		hash,
+		tc,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+			*r = json.RawMessage(file)
+		},
+	).Twice()
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"debug_traceBlockByHash",
+		otherHash,
+		tc,
+	).Return(
+		nil,
+	).Run(
+		func(args mock.Arguments) {
+			r := args.Get(1).(*json.RawMessage)
+			*r = json.RawMessage("[]")
+		},
+	).Once()
+
+	calls, rawCalls, err := c.getBlockTraces(ctx, hash)
+	assert.NoError(t, err)
+	assert.Len(t, calls, 1)
+	assert.Len(t, rawCalls, 1)
+
+	cachedCalls, cachedRawCalls, err := c.getBlockTraces(ctx, hash)
+	assert.NoError(t, err)
+	assert.Equal(t, calls, cachedCalls)
+	assert.Equal(t, rawCalls, cachedRawCalls)
+	assert.Equal(t, TraceCacheStats{Size: 1, Hits: 1, Misses: 1}, c.TraceCacheStats())
+
+	_, _, err = c.getBlockTraces(ctx, otherHash)
+	assert.NoError(t, err)
+	_, _, err = c.getBlockTraces(ctx, hash)
+	assert.NoError(t, err)
+	assert.Equal(t, TraceCacheStats{Size: 1, Hits: 1, Misses: 3}, c.TraceCacheStats())
+
+	mockJSONRPC.AssertExpectations(t)
+}
+
+func TestGetBlockTraces_CacheError(t *testing.T) {
+	mockJSONRPC := &mocks.JSONRPC{}
+
+	tc, err := testTraceConfig()
+	assert.NoError(t, err)
+	traces, err := newTraceCache(0)
+	assert.NoError(t, err)
+	c := &Client{
+		c:              mockJSONRPC,
+		tc:             tc,
+		traceSemaphore: semaphore.NewWeighted(100),
+		traces:         traces,
+	}
+
+	// Failed traces are not cached.
+	ctx := context.Background()
+	hash := common.HexToHash("0x01")
+	mockJSONRPC.On(
+		"CallContext",
+		ctx,
+		mock.Anything,
+		"debug_traceBlockByHash",
+		hash,
+		tc,
+	).Return(
+		errors.New("execution timeout"),
+	).Twice()
+
+	_, _, err = c.getBlockTraces(ctx, hash)
+	assert.Error(t, err)
+	_, _, err = c.getBlockTraces(ctx, hash)
+	assert.Error(t, err)
+	assert.Equal(t, TraceCacheStats{Misses: 2}, c.TraceCacheStats())
+
+	mockJSONRPC.AssertExpectations(t)
+}
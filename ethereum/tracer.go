@@ -17,29 +17,85 @@ package ethereum
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/ethereum/go-ethereum/eth/tracers"
 )
 
 // convert raw eth data from client to rosetta
 
+// TraceMode determines how transactions are traced.
+type TraceMode string
+
+const (
+	// TraceModeCall traces transactions with geth's
+	// native callTracer.
+	TraceModeCall TraceMode = "call"
+
+	// TraceModeJS traces transactions with the JavaScript
+	// tracer at tracerPath.
+	TraceModeJS TraceMode = "js"
+
+	// TraceModeOff disables tracing. Only the top-level
+	// call of each transaction is reported, so internal
+	// transfers are missing from blocks.
+	TraceModeOff TraceMode = "off"
+)
+
 const (
 	tracerPath = "ethereum/call_tracer.js"
+
+	// nativeTracer is the name of geth's
+	// native call tracer.
+	nativeTracer = "callTracer"
+
+	// traceIncompleteKey is set in the metadata of blocks
+	// and transactions fetched while tracing is off.
+	traceIncompleteKey = "trace_incomplete"
 )
 
 var (
-	tracerTimeout = "120s"
+	tracerTimeout = 120 * time.Second
 )
 
-func loadTraceConfig() (*tracers.TraceConfig, error) {
-	loadedFile, err := ioutil.ReadFile(tracerPath)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not load tracer file", err)
+// ValidateTraceMode returns an error if mode
+// is not a supported TraceMode.
+func ValidateTraceMode(mode TraceMode) error {
+	switch mode {
+	case TraceModeCall, TraceModeJS, TraceModeOff:
+		return nil
+	default:
+		return fmt.Errorf("%s is not a valid trace mode", mode)
+	}
+}
+
+// loadTraceConfig returns the config used to trace
+// transactions with mode (TraceModeJS when empty), or
+// nil when tracing is off.
+func loadTraceConfig(mode TraceMode, timeout time.Duration) (*tracers.TraceConfig, error) {
+	if timeout <= 0 {
+		timeout = tracerTimeout
+	}
+	timeoutValue := timeout.String()
+
+	var tracer string
+	switch mode {
+	case TraceModeOff:
+		return nil, nil
+	case TraceModeCall:
+		tracer = nativeTracer
+	case TraceModeJS, "":
+		loadedFile, err := ioutil.ReadFile(tracerPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not load tracer file", err)
+		}
+		tracer = string(loadedFile)
+	default:
+		return nil, ValidateTraceMode(mode)
 	}
 
-	loadedTracer := string(loadedFile)
 	return &tracers.TraceConfig{
-		Timeout: &tracerTimeout,
-		Tracer:  &loadedTracer,
+		Timeout: &timeoutValue,
+		Tracer:  &tracer,
 	}, nil
 }
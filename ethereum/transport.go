@@ -0,0 +1,167 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+)
+
+const (
+	// defaultMaxIdleConns is the default number of idle
+	// connections kept open to each node. The default of
+	// http.Transport (2) is far below the number of
+	// concurrent requests made while syncing, so most
+	// connections would be closed after a single request.
+	defaultMaxIdleConns = 100
+
+	// reusedConnection and newConnection are the
+	// connection labels of rpcConnections.
+	reusedConnection = "reused"
+	newConnection    = "new"
+)
+
+// LoadCertPool returns the system certificate pool with
+// the PEM-encoded certificates in path appended to it.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, path)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration used to connect
+// to nodes or nil if the defaults should be used.
+func (r *RPCConfig) tlsConfig() (*tls.Config, error) {
+	if len(r.CACert) == 0 && !r.TLSInsecure {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: r.TLSInsecure, // #nosec G402
+	}
+
+	if len(r.CACert) > 0 {
+		pool, err := LoadCertPool(r.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load CA certificates", err)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// httpTransport returns a copy of http.DefaultTransport, which
+// honors the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment
+// variables, using tlsConfig (when not nil) and the connection
+// limits of r.
+func (r *RPCConfig) httpTransport(tlsConfig *tls.Config) *http.Transport {
+	// See this conversation around why `.Clone()` is used here:
+	// https://github.com/golang/go/issues/26013
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	// Idle connections are only limited per node, so
+	// that adding nodes does not starve the others.
+	maxIdleConns := defaultMaxIdleConns
+	if r.MaxIdleConns > 0 {
+		maxIdleConns = r.MaxIdleConns
+	}
+	transport.MaxIdleConns = 0
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	transport.MaxConnsPerHost = r.MaxConnsPerHost
+
+	// Setting TLSClientConfig disables HTTP/2 unless it
+	// is explicitly attempted. Nodes that do not support
+	// it are still served over HTTP/1.1.
+	transport.ForceAttemptHTTP2 = true
+
+	return transport
+}
+
+// connTransport counts the requests sent with base
+// on reused and on new connections.
+type connTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *connTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				rpcConnections.Inc(reusedConnection)
+				return
+			}
+
+			rpcConnections.Inc(newConnection)
+		},
+	}
+
+	return c.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// withConnMetrics returns base wrapped so that the
+// reuse of its connections is counted.
+func withConnMetrics(base http.RoundTripper) http.RoundTripper {
+	return &connTransport{base: base}
+}
+
+// headerTransport adds header to every
+// request sent with base.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+// RoundTrip implements http.RoundTripper.
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range h.header {
+		req.Header[name] = values
+	}
+
+	return h.base.RoundTrip(req)
+}
+
+// withHeader returns base wrapped so that header is added
+// to every request, or base if header is empty.
+func withHeader(base http.RoundTripper, header http.Header) http.RoundTripper {
+	if len(header) == 0 {
+		return base
+	}
+
+	return &headerTransport{base: base, header: header}
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCACert writes the certificate of server
+// to a PEM file and returns its path.
+func writeCACert(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func TestLoadCertPool(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	pool, err := LoadCertPool(writeCACert(t, server))
+	assert.NoError(t, err)
+	assert.NotNil(t, pool)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalid, []byte("not a certificate"), 0600))
+	_, err = LoadCertPool(invalid)
+	assert.Error(t, err)
+
+	_, err = LoadCertPool(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestRPCConfig_TLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var tests = map[string]struct {
+		rpcConfig *RPCConfig
+		err       bool
+	}{
+		"default": {
+			rpcConfig: &RPCConfig{},
+			err:       true,
+		},
+		"ca cert": {
+			rpcConfig: &RPCConfig{CACert: writeCACert(t, server)},
+		},
+		"insecure": {
+			rpcConfig: &RPCConfig{TLSInsecure: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig, err := test.rpcConfig.tlsConfig()
+			assert.NoError(t, err)
+
+			client := &http.Client{Transport: test.rpcConfig.httpTransport(tlsConfig)}
+			resp, err := client.Get(server.URL)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NoError(t, resp.Body.Close())
+		})
+	}
+}
+
+func TestDialNodes_Header(t *testing.T) {
+	received := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path+" "+r.Header.Get("X-Api-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("X-Api-Key", "secret")
+	nodes, err := dialNodes([]string{server.URL}, &RPCConfig{Header: header}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+
+	ctx := context.Background()
+	var result string
+	assert.NoError(t, nodes[0].rpc.CallContext(ctx, &result, "eth_chainId"))
+	assert.Equal(t, "0x1", result)
+
+	_, err = nodes[0].graphql.Query(ctx, "{}")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"/ secret", "/graphql secret"}, received)
+}
+
+func TestRPCConfig_HTTPTransport(t *testing.T) {
+	transport := (&RPCConfig{}).httpTransport(nil)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 0, transport.MaxConnsPerHost)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	transport = (&RPCConfig{MaxIdleConns: 10, MaxConnsPerHost: 20}).httpTransport(nil)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, transport.MaxConnsPerHost)
+}
+
+func TestWithConnMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: withConnMetrics((&RPCConfig{}).httpTransport(nil))}
+	reused := []bool{}
+	for i := 0; i < 2; i++ {
+		// Traces of callers are still called.
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = append(reused, info.Reused)
+			},
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		assert.NoError(t, err)
+
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	assert.Equal(t, []bool{false, true}, reused)
+}
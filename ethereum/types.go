@@ -17,6 +17,7 @@ package ethereum
 import (
 	"context"
 	"fmt"
+	"math/big"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/ethereum/go-ethereum/params"
@@ -46,6 +47,14 @@ const (
 	// in GoerliNetworkNetworkIdentifier.
 	GoerliNetwork string = "Goerli"
 
+	// SepoliaNetwork is the value of the network
+	// in SepoliaNetworkNetworkIdentifier.
+	SepoliaNetwork string = "Sepolia"
+
+	// CustomNetwork is the value of the network
+	// in CustomNetworkNetworkIdentifier.
+	CustomNetwork string = "Custom"
+
 	// DevNetwork is the value of the network
 	// in DevNetworkNetworkIdentifier.
 	DevNetwork string = "Dev"
@@ -66,9 +75,25 @@ const (
 	// an uncle block reward.
 	UncleRewardOpType = "UNCLE_REWARD"
 
+	// GenesisOpType is used to describe
+	// a balance allocated at genesis.
+	GenesisOpType = "GENESIS"
+
 	// FeeOpType is used to represent fee operations.
 	FeeOpType = "FEE"
 
+	// FeeTypeKey is the metadata key of fee operations
+	// that tells the tip apart from the burned base fee.
+	FeeTypeKey = "fee_type"
+
+	// TipFeeType is the FeeTypeKey of the fee
+	// operations moving the tip to the miner.
+	TipFeeType = "tip"
+
+	// BurnFeeType is the FeeTypeKey of the fee
+	// operation burning the base fee (EIP-1559).
+	BurnFeeType = "burn"
+
 	// CallOpType is used to represent CALL trace operations.
 	CallOpType = "CALL"
 
@@ -90,6 +115,11 @@ const (
 	// StaticCallOpType is used to represent STATICCALL trace operations.
 	StaticCallOpType = "STATICCALL"
 
+	// CancelOpType is used in construction to cancel
+	// a pending transaction with a self-transfer
+	// replacing it.
+	CancelOpType = "CANCEL"
+
 	// DestructOpType is a synthetic operation used to represent the
 	// deletion of suicided accounts that still have funds at the end
 	// of a transaction.
@@ -103,6 +133,28 @@ const (
 	// Ethereum operation considered unsuccessful.
 	FailureStatus = "FAILURE"
 
+	// TxStatusPending, TxStatusConfirmed and TxStatusDropped
+	// are the statuses returned by the call method "tx_status":
+	// the transaction is in the mempool, included in a block,
+	// or unknown to geth.
+	TxStatusPending   = "pending"
+	TxStatusConfirmed = "confirmed"
+	TxStatusDropped   = "dropped"
+
+	// SyncStageNotStarted, SyncStageBlocks, SyncStageState and
+	// SyncStageSynced are the stages of the /network/status sync
+	// status: geth has not started syncing, is downloading blocks,
+	// is downloading the state of the head, or is synced.
+	SyncStageNotStarted = "not_started"
+	SyncStageBlocks     = "block_sync"
+	SyncStageState      = "state_sync"
+	SyncStageSynced     = "synced"
+
+	// SyncStageIndexing is the stage of the /network/status sync
+	// status in INDEXER mode when geth is synced but the local
+	// index is not.
+	SyncStageIndexing = "indexing"
+
 	// HistoricalBalanceSupported is whether
 	// historical balance is supported.
 	HistoricalBalanceSupported = true
@@ -123,9 +175,22 @@ const (
 	// of a transfer.
 	TransferGasLimit = int64(21000) //nolint:gomnd
 
+	// TokenTransferGasLimit is the default gas limit of an
+	// ERC-20 token transfer (unused gas is refunded).
+	TokenTransferGasLimit = int64(100000) //nolint:gomnd
+
+	// BatchTransferGasLimitPerRecipient is the gas limit added
+	// to TransferGasLimit for each recipient of a batch transfer
+	// (unused gas is refunded).
+	BatchTransferGasLimitPerRecipient = int64(40000) //nolint:gomnd
+
 	// MainnetGethArguments are the arguments to start a mainnet geth instance.
 	MainnetGethArguments = `--config=/app/ethereum/geth.toml --gcmode=archive --graphql`
 
+	// ContractAddressKey is the key of the currency
+	// metadata holding the address of an ERC-20 token.
+	ContractAddressKey = "contract_address"
+
 	// IncludeMempoolCoins does not apply to rosetta-ethereum as it is not UTXO-based.
 	IncludeMempoolCoins = false
 )
@@ -140,6 +205,9 @@ var (
 	// GoerliGethArguments are the arguments to start a ropsten geth instance.
 	GoerliGethArguments = fmt.Sprintf("%s --goerli", MainnetGethArguments)
 
+	// SepoliaGethArguments are the arguments to start a sepolia geth instance.
+	SepoliaGethArguments = fmt.Sprintf("%s --sepolia", MainnetGethArguments)
+
 	// DevGethArguments are the arguments to start a dev geth instance.
 	DevGethArguments = fmt.Sprintf("%s --dev", MainnetGethArguments)
 
@@ -171,6 +239,46 @@ var (
 		Index: GenesisBlockIndex,
 	}
 
+	// SepoliaGenesisBlockIdentifier is the *types.BlockIdentifier
+	// of the Sepolia genesis block.
+	SepoliaGenesisBlockIdentifier = &types.BlockIdentifier{
+		Hash:  params.SepoliaGenesisHash.Hex(),
+		Index: GenesisBlockIndex,
+	}
+
+	// OfflineFees are the fees /construction/payloads uses on
+	// each network when its metadata has none (i.e. when the
+	// transaction is constructed offline, without calling
+	// /construction/metadata). They are generous so the
+	// transaction is likely to be included without being
+	// replaced, and can be overridden by the configuration.
+	OfflineFees = map[string]*Fees{
+		MainnetNetwork: {
+			GasTipCap: big.NewInt(2 * params.GWei),   //nolint:gomnd
+			GasFeeCap: big.NewInt(100 * params.GWei), //nolint:gomnd
+		},
+		RopstenNetwork: {
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(20 * params.GWei), //nolint:gomnd
+		},
+		RinkebyNetwork: {
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(20 * params.GWei), //nolint:gomnd
+		},
+		GoerliNetwork: {
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(20 * params.GWei), //nolint:gomnd
+		},
+		SepoliaNetwork: {
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(20 * params.GWei), //nolint:gomnd
+		},
+		DevNetwork: {
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(20 * params.GWei), //nolint:gomnd
+		},
+	}
+
 	// Currency is the *types.Currency for all
 	// Ethereum networks.
 	Currency = &types.Currency{
@@ -182,6 +290,7 @@ var (
 	OperationTypes = []string{
 		MinerRewardOpType,
 		UncleRewardOpType,
+		GenesisOpType,
 		FeeOpType,
 		CallOpType,
 		CreateOpType,
@@ -191,6 +300,7 @@ var (
 		DelegateCallOpType,
 		StaticCallOpType,
 		DestructOpType,
+		CancelOpType,
 	}
 
 	// OperationStatuses are all supported operation statuses.
@@ -206,14 +316,18 @@ var (
 	}
 
 	// CallMethods are all supported call methods.
-	CallMethods = []string{
-		"eth_getBlockByNumber",
-		"eth_getTransactionReceipt",
-		"eth_call",
-		"eth_estimateGas",
-	}
+	CallMethods = callMethodNames()
 )
 
+// Fees are the fees per gas of a transaction: a GasPrice for
+// a legacy transaction, or a GasTipCap and GasFeeCap for a
+// dynamic fee (EIP-1559) transaction.
+type Fees struct {
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
 // JSONRPC is the interface for accessing go-ethereum's JSON RPC endpoint.
 type JSONRPC interface {
 	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
@@ -0,0 +1,75 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// declaredOpTypes returns the values of the *OpType
+// constants declared in types.go.
+func declaredOpTypes(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "types.go", nil, 0)
+	assert.NoError(t, err)
+
+	var opTypes []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasSuffix(name.Name, "OpType") {
+					continue
+				}
+
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				assert.True(t, ok, name.Name)
+
+				opType, err := strconv.Unquote(lit.Value)
+				assert.NoError(t, err)
+				opTypes = append(opTypes, opType)
+			}
+		}
+	}
+
+	return opTypes
+}
+
+func TestOperationTypes(t *testing.T) {
+	// Every operation type is advertised exactly once.
+	assert.ElementsMatch(t, declaredOpTypes(t), OperationTypes)
+}
+
+func TestCallMethods(t *testing.T) {
+	seen := map[string]bool{}
+	for _, method := range callMethods {
+		assert.False(t, seen[method.name], method.name)
+		assert.NotNil(t, method.handle, method.name)
+		seen[method.name] = true
+	}
+
+	assert.Equal(t, callMethodNames(), CallMethods)
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// ipcScheme is the URL scheme used to
+	// connect to geth over IPC.
+	ipcScheme = "ipc://"
+
+	// wsBufferSize is the size of the read and
+	// write buffers of WebSocket connections.
+	wsBufferSize = 1024
+)
+
+// ipcPath returns the socket path of rawURL and a
+// boolean indicating if rawURL is an IPC URL.
+func ipcPath(rawURL string) (string, bool) {
+	if !strings.HasPrefix(rawURL, ipcScheme) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(rawURL, ipcScheme), true
+}
+
+// websocketURL derives the WebSocket URL of a node
+// from its HTTP URL by swapping the scheme. IPC URLs
+// are returned as-is because geth serves subscriptions
+// over IPC.
+func websocketURL(httpURL string) (string, error) {
+	if _, ok := ipcPath(httpURL); ok {
+		return httpURL, nil
+	}
+
+	u, err := url.Parse(httpURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unable to derive WebSocket URL from %s", httpURL)
+	}
+
+	return u.String(), nil
+}
+
+// wsConn is a lazily dialed WebSocket (or IPC) connection
+// to geth used for subscriptions. All request/response calls
+// are made by the nodePool instead.
+type wsConn struct {
+	mu     sync.Mutex
+	url    string
+	tls    *tls.Config
+	client *rpc.Client
+
+	// dialed is whether a connection was dialed
+	// before, so that redials are counted.
+	dialed bool
+}
+
+// get returns the current connection, dialing
+// a new one if there is none.
+func (w *wsConn) get(ctx context.Context) (*rpc.Client, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client != nil {
+		return w.client, nil
+	}
+
+	if len(w.url) == 0 {
+		return nil, ErrWebSocketUnavailable
+	}
+
+	var client *rpc.Client
+	var err error
+	if path, ok := ipcPath(w.url); ok {
+		client, err = rpc.DialIPC(ctx, path)
+	} else {
+		client, err = rpc.DialWebsocketWithDialer(ctx, w.url, "", websocket.Dialer{
+			ReadBufferSize:  wsBufferSize,
+			WriteBufferSize: wsBufferSize,
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: w.tls,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to dial %s", err, w.url)
+	}
+
+	if w.dialed {
+		websocketReconnects.Inc()
+	}
+
+	w.client = client
+	w.dialed = true
+	return client, nil
+}
+
+// drop closes client if it is still the current
+// connection so that the next call redials.
+func (w *wsConn) drop(client *rpc.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client != client {
+		return
+	}
+
+	w.client.Close()
+	w.client = nil
+}
+
+// reset closes the current connection and points
+// future connections at url, using tlsConfig.
+func (w *wsConn) reset(url string, tlsConfig *tls.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client != nil {
+		w.client.Close()
+		w.client = nil
+	}
+
+	w.url = url
+	w.tls = tlsConfig
+}
+
+// subscribe creates a subscription in namespace
+// (i.e. `eth`) delivering notifications to channel.
+func (w *wsConn) subscribe(
+	ctx context.Context,
+	namespace string,
+	channel interface{},
+	args ...interface{},
+) (*rpc.ClientSubscription, error) {
+	client, err := w.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := client.Subscribe(ctx, namespace, channel, args...)
+	if isNodeFailure(err) {
+		w.drop(client)
+	}
+
+	return sub, err
+}
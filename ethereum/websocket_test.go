@@ -0,0 +1,97 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebsocketURL(t *testing.T) {
+	tests := map[string]struct {
+		url string
+
+		expected string
+		err      bool
+	}{
+		"http": {
+			url:      "http://localhost:8545",
+			expected: "ws://localhost:8545",
+		},
+		"https with path": {
+			url:      "https://node.example.com/v1/key",
+			expected: "wss://node.example.com/v1/key",
+		},
+		"already websocket": {
+			url:      "ws://localhost:8546",
+			expected: "ws://localhost:8546",
+		},
+		"ipc": {
+			url:      "ipc:///data/geth.ipc",
+			expected: "ipc:///data/geth.ipc",
+		},
+		"unsupported scheme": {
+			url: "ftp://localhost:8545",
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wsURL, err := websocketURL(test.url)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, wsURL)
+		})
+	}
+}
+
+func TestIPCPath(t *testing.T) {
+	path, ok := ipcPath("ipc:///data/geth.ipc")
+	assert.True(t, ok)
+	assert.Equal(t, "/data/geth.ipc", path)
+
+	_, ok = ipcPath("http://localhost:8545")
+	assert.False(t, ok)
+}
+
+func TestRPCConfig_WebSocketURL(t *testing.T) {
+	wsURL, err := (&RPCConfig{
+		WebSocketURL: "ws://other:8546",
+	}).webSocketURL([]string{"http://localhost:8545"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://other:8546", wsURL)
+
+	wsURL, err = (&RPCConfig{}).webSocketURL([]string{"https://localhost:8545"})
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://localhost:8545", wsURL)
+}
+
+func TestSubscribe_Unavailable(t *testing.T) {
+	c := &Client{}
+	_, err := c.Subscribe(context.Background(), "eth", make(chan interface{}), "newHeads")
+	assert.True(t, errors.Is(err, ErrWebSocketUnavailable))
+
+	c = &Client{ws: &wsConn{}}
+	_, err = c.Subscribe(context.Background(), "eth", make(chan interface{}), "newHeads")
+	assert.True(t, errors.Is(err, ErrWebSocketUnavailable))
+}
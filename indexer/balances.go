@@ -0,0 +1,347 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// balancePrefix is the prefix of the keys of the balance
+	// changes of accounts, by address, currency and block.
+	balancePrefix = "balance/"
+
+	// changePrefix is the prefix of the keys of the balances
+	// changed in each block, by block, address and currency.
+	changePrefix = "change/"
+)
+
+// ErrBalancesNotIndexed is returned by Balance
+// when balances are not indexed.
+var ErrBalancesNotIndexed = errors.New("balances are not indexed")
+
+// balanceChange is the change of the balance of an account
+// in a currency in a block, along with the resulting
+// balance. Balances are snapshotted at each change, so the
+// balance at any block is read from the last change before
+// it.
+type balanceChange struct {
+	Delta   string `json:"delta"`
+	Balance string `json:"balance"`
+}
+
+func balanceAccountPrefix(address string, currency string) string {
+	return fmt.Sprintf("%s%s/%s/", balancePrefix, strings.ToLower(address), currency)
+}
+
+func balanceKey(address string, currency string, blockIndex int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d", balanceAccountPrefix(address, currency), keyDigits, blockIndex))
+}
+
+func blockChangesPrefix(blockIndex int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d/", changePrefix, keyDigits, blockIndex))
+}
+
+func changeKey(blockIndex int64, address string, currency string) []byte {
+	return []byte(fmt.Sprintf("%s%s/%s", blockChangesPrefix(blockIndex), strings.ToLower(address), currency))
+}
+
+// balanceDeltas returns the changes of the balances of the
+// successful operations of block, by address and currency
+// (separated by "/").
+func balanceDeltas(block *types.Block) (map[string]*big.Int, error) {
+	deltas := map[string]*big.Int{}
+	for _, transaction := range block.Transactions {
+		for _, op := range transaction.Operations {
+			if op.Account == nil || op.Amount == nil ||
+				op.Status == nil || !successfulStatuses[*op.Status] {
+				continue
+			}
+
+			value, ok := new(big.Int).SetString(op.Amount.Value, 10)
+			if !ok {
+				return nil, fmt.Errorf(
+					"invalid amount %s in transaction %s",
+					op.Amount.Value,
+					transaction.TransactionIdentifier.Hash,
+				)
+			}
+
+			account := strings.ToLower(op.Account.Address) + "/" + currencyValue(op.Amount.Currency)
+			if _, ok := deltas[account]; !ok {
+				deltas[account] = new(big.Int)
+			}
+			deltas[account].Add(deltas[account], value)
+		}
+	}
+
+	return deltas, nil
+}
+
+// balanceAt returns the balance of address in currency at
+// the block at blockIndex (0 if it never changed).
+func balanceAt(
+	ctx context.Context,
+	txn database.Transaction,
+	address string,
+	currency string,
+	blockIndex int64,
+) (*big.Int, error) {
+	balance := new(big.Int)
+	_, err := txn.Scan(
+		ctx,
+		[]byte(balanceAccountPrefix(address, currency)),
+		balanceKey(address, currency, blockIndex),
+		func(key []byte, value []byte) error {
+			var change balanceChange
+			if err := json.Unmarshal(value, &change); err != nil {
+				return err
+			}
+
+			if _, ok := balance.SetString(change.Balance, 10); !ok {
+				return fmt.Errorf("invalid balance %s", change.Balance)
+			}
+
+			return errScanDone
+		},
+		false,
+		true,
+	)
+	if err != nil && !errors.Is(err, errScanDone) {
+		return nil, fmt.Errorf("%w: unable to get balance of %s", err, address)
+	}
+
+	return balance, nil
+}
+
+// addBalances records the balance changes of block in txn.
+func addBalances(
+	ctx context.Context,
+	txn database.Transaction,
+	block *types.Block,
+) error {
+	deltas, err := balanceDeltas(block)
+	if err != nil {
+		return err
+	}
+
+	accounts := make([]string, 0, len(deltas))
+	for account, delta := range deltas {
+		if delta.Sign() != 0 {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+
+	index := block.BlockIdentifier.Index
+	for _, account := range accounts {
+		parts := strings.SplitN(account, "/", 2)
+		address, currency := parts[0], parts[1]
+
+		balance, err := balanceAt(ctx, txn, address, currency, index)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(&balanceChange{
+			Delta:   deltas[account].String(),
+			Balance: balance.Add(balance, deltas[account]).String(),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(ctx, balanceKey(address, currency, index), value, false); err != nil {
+			return fmt.Errorf("%w: unable to store balance of %s", err, address)
+		}
+
+		if err := txn.Set(ctx, changeKey(index, address, currency), []byte{}, false); err != nil {
+			return fmt.Errorf("%w: unable to store balance of %s", err, address)
+		}
+	}
+
+	return nil
+}
+
+// removeBalances removes the balance changes
+// of the block at blockIndex in txn.
+func removeBalances(
+	ctx context.Context,
+	txn database.Transaction,
+	blockIndex int64,
+) error {
+	prefix := blockChangesPrefix(blockIndex)
+	keys := [][]byte{}
+	_, err := txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			parts := strings.SplitN(strings.TrimPrefix(string(key), string(prefix)), "/", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid balance change key %s", key)
+			}
+
+			keys = append(keys, balanceKey(parts[0], parts[1], blockIndex), append([]byte{}, key...))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get balance changes of block %d", err, blockIndex)
+	}
+
+	for _, key := range keys {
+		if err := txn.Delete(ctx, key); err != nil {
+			return fmt.Errorf("%w: unable to remove balance changes of block %d", err, blockIndex)
+		}
+	}
+
+	return nil
+}
+
+// Balance returns the balances of account in currencies (only
+// ETH if there are none) at the block with the provided
+// identifier (the last block in the index if it is nil). It
+// returns ErrBlockNotFound if the block is not in the index.
+func (i *Indexer) Balance(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	identifier *types.PartialBlockIdentifier,
+	currencies []*types.Currency,
+) (*types.AccountBalanceResponse, error) {
+	if !i.config.Balances {
+		return nil, ErrBalancesNotIndexed
+	}
+
+	if len(currencies) == 0 {
+		currencies = []*types.Currency{ethereum.Currency}
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	block, err := i.lookupBlock(ctx, txn, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]*types.Amount, len(currencies))
+	for j, currency := range currencies {
+		balance, err := balanceAt(
+			ctx,
+			txn,
+			account.Address,
+			currencyValue(currency),
+			block.BlockIdentifier.Index,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		balances[j] = &types.Amount{
+			Value:    balance.String(),
+			Currency: currency,
+		}
+	}
+
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: block.BlockIdentifier,
+		Balances:        balances,
+	}, nil
+}
+
+// BalanceChange is the change of the balance of an
+// account in a block, along with the resulting balance.
+type BalanceChange struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Delta           *types.Amount          `json:"delta"`
+	Balance         *types.Amount          `json:"balance"`
+}
+
+// BalanceHistory returns up to limit of the changes of the balance
+// of account in currency, most recent first, starting at offset. It
+// also returns the number of changes.
+func (i *Indexer) BalanceHistory(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	offset int64,
+	limit int64,
+) (int64, []*BalanceChange, error) {
+	if !i.config.Balances {
+		return -1, nil, ErrBalancesNotIndexed
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	prefix := balanceAccountPrefix(account.Address, currencyValue(currency))
+	var count int64
+	changes := []*BalanceChange{}
+	_, err := txn.Scan(
+		ctx,
+		[]byte(prefix),
+		balanceKey(account.Address, currencyValue(currency), 1<<63-1),
+		func(key []byte, value []byte) error {
+			count++
+			if count <= offset || count > offset+limit {
+				return nil
+			}
+
+			var change balanceChange
+			if err := json.Unmarshal(value, &change); err != nil {
+				return err
+			}
+
+			blockIndex, err := strconv.ParseInt(strings.TrimPrefix(string(key), prefix), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid balance key %s", err, key)
+			}
+
+			block, err := header(ctx, txn, blockIndex)
+			if err != nil {
+				return err
+			}
+
+			changes = append(changes, &BalanceChange{
+				BlockIdentifier: block.BlockIdentifier,
+				Delta:           &types.Amount{Value: change.Delta, Currency: currency},
+				Balance:         &types.Amount{Value: change.Balance, Currency: currency},
+			})
+			return nil
+		},
+		false,
+		true,
+	)
+	if err != nil {
+		return -1, nil, fmt.Errorf("%w: unable to get balance history of %s", err, account.Address)
+	}
+
+	return count, changes, nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testReward returns a transaction of hash
+// crediting value ETH to testFrom.
+func testReward(hash string, value string) *types.Transaction {
+	success := ethereum.SuccessStatus
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                ethereum.MinerRewardOpType,
+				Status:              &success,
+				Account:             &types.AccountIdentifier{Address: testFrom},
+				Amount:              &types.Amount{Value: value, Currency: ethereum.Currency},
+			},
+		},
+	}
+}
+
+func TestBalance(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Balances: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		1,
+		"a",
+		testReward("0x1r", "1000000"),
+		testTransaction("0x1a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, testToken),
+		testTransaction("0x2b", ethereum.CallOpType, ethereum.FailureStatus, ethereum.Currency),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(3, "a")))
+
+	index := int64(1)
+	missing := int64(4)
+	hash := "2A"
+	currencies := []*types.Currency{ethereum.Currency, testToken}
+
+	tests := map[string]struct {
+		account    string
+		block      *types.PartialBlockIdentifier
+		currencies []*types.Currency
+
+		expectedBlock    *types.BlockIdentifier
+		expectedBalances []string
+		expectedErr      error
+	}{
+		"head": {
+			account:          testFrom,
+			expectedBlock:    testBlock(3, "a", "a").BlockIdentifier,
+			expectedBalances: []string{"936999"},
+		},
+		"index": {
+			account:          testFrom,
+			block:            &types.PartialBlockIdentifier{Index: &index},
+			currencies:       currencies,
+			expectedBlock:    testBlock(1, "a", "a").BlockIdentifier,
+			expectedBalances: []string{"978999", "0"},
+		},
+		"hash": {
+			account:          testFrom,
+			block:            &types.PartialBlockIdentifier{Hash: &hash},
+			currencies:       currencies,
+			expectedBlock:    testBlock(2, "a", "a").BlockIdentifier,
+			expectedBalances: []string{"936999", "-1"},
+		},
+		"lowercase address": {
+			account:          "0x4cdc6e1a3b6fb9df4c6d7e0e1d3cf5ce4a15e7e4",
+			currencies:       currencies,
+			expectedBlock:    testBlock(3, "a", "a").BlockIdentifier,
+			expectedBalances: []string{"1", "1"},
+		},
+		"unknown account": {
+			account:          "0x0000000000000000000000000000000000000001",
+			expectedBlock:    testBlock(3, "a", "a").BlockIdentifier,
+			expectedBalances: []string{"0"},
+		},
+		"block not indexed": {
+			account:     testFrom,
+			block:       &types.PartialBlockIdentifier{Index: &missing},
+			expectedErr: ErrBlockNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := i.Balance(
+				ctx,
+				&types.AccountIdentifier{Address: test.account},
+				test.block,
+				test.currencies,
+			)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Nil(t, resp)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedBlock, resp.BlockIdentifier)
+
+			balances := make([]string, len(resp.Balances))
+			for j, balance := range resp.Balances {
+				balances[j] = balance.Value
+			}
+			assert.Equal(t, test.expectedBalances, balances)
+		})
+	}
+}
+
+func TestBalance_Reorg(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Balances: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(1, "a", testReward("0x1r", "100"))))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(2, "a", testReward("0x2r", "10"))))
+
+	// Balance changes of removed blocks are no longer indexed.
+	assert.NoError(t, i.removeBlock(ctx, testBlock(2, "a", "a").BlockIdentifier))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(2, "b", testReward("0x2r", "1"))))
+
+	resp, err := i.Balance(ctx, &types.AccountIdentifier{Address: testFrom}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, testBlock(2, "b", "a").BlockIdentifier, resp.BlockIdentifier)
+	assert.Equal(t, "101", resp.Balances[0].Value)
+}
+
+func TestBalance_NotIndexed(t *testing.T) {
+	i := newTestIndexer(t, &mocks.Client{}, &Config{})
+
+	resp, err := i.Balance(context.Background(), &types.AccountIdentifier{Address: testFrom}, nil, nil)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, ErrBalancesNotIndexed)
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	// ErrBlockNotFound is returned when the requested
+	// block is not in the index (yet).
+	ErrBlockNotFound = errors.New("block not indexed")
+
+	// ErrTransactionNotFound is returned when the
+	// requested transaction is not in the index.
+	ErrTransactionNotFound = errors.New("transaction not indexed")
+)
+
+// header returns the header of the block at index, or
+// ErrBlockNotFound if it is not in the index.
+func header(ctx context.Context, txn database.Transaction, index int64) (*types.Block, error) {
+	exists, value, err := txn.Get(ctx, blockKey(index))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotFound, index)
+	}
+
+	var block types.Block
+	if err := json.Unmarshal(value, &block); err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// edgeBlock returns the header of the first (or, if
+// last is set, the last) block in the index, if any.
+func (i *Indexer) edgeBlock(ctx context.Context, last bool) (*types.Block, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	var block *types.Block
+	seekStart := blockKey(0)
+	if last {
+		seekStart = blockKey(1<<63 - 1)
+	}
+
+	_, err := txn.Scan(
+		ctx,
+		[]byte(blockPrefix),
+		seekStart,
+		func(key []byte, value []byte) error {
+			block = &types.Block{}
+			if err := json.Unmarshal(value, block); err != nil {
+				return err
+			}
+
+			return errScanDone
+		},
+		false,
+		last,
+	)
+	if err != nil && !errors.Is(err, errScanDone) {
+		return nil, fmt.Errorf("%w: unable to get blocks", err)
+	}
+
+	return block, nil
+}
+
+// firstBlock returns the identifier of the
+// first block in the index, if any.
+func (i *Indexer) firstBlock(ctx context.Context) (*types.BlockIdentifier, error) {
+	block, err := i.edgeBlock(ctx, false)
+	if err != nil || block == nil {
+		return nil, err
+	}
+
+	return block.BlockIdentifier, nil
+}
+
+// Head returns the header (without transactions) of the last
+// block in the index, or ErrBlockNotFound if it is empty.
+func (i *Indexer) Head(ctx context.Context) (*types.Block, error) {
+	block, err := i.edgeBlock(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("%w: the index is empty", ErrBlockNotFound)
+	}
+
+	return block, nil
+}
+
+// lookupBlock returns the header of the block with the provided
+// identifier (the last block in the index if it is nil), or
+// ErrBlockNotFound if it is not in the index.
+func (i *Indexer) lookupBlock(
+	ctx context.Context,
+	txn database.Transaction,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	if identifier == nil || (identifier.Index == nil && identifier.Hash == nil) {
+		head, err := i.Head(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		identifier = &types.PartialBlockIdentifier{Index: &head.BlockIdentifier.Index}
+	}
+
+	var index int64
+	if identifier.Index != nil {
+		index = *identifier.Index
+	} else {
+		exists, value, err := txn.Get(ctx, hashKey(*identifier.Hash))
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %s", err, *identifier.Hash)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, *identifier.Hash)
+		}
+
+		index, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := header(ctx, txn, index)
+	if err != nil {
+		return nil, err
+	}
+
+	if identifier.Hash != nil && !strings.EqualFold(*identifier.Hash, block.BlockIdentifier.Hash) {
+		return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, *identifier.Hash)
+	}
+
+	return block, nil
+}
+
+// Block returns the block with the provided identifier (the
+// last block in the index if it is nil), along with its
+// transactions if they are indexed. It returns
+// ErrBlockNotFound if the block is not in the index, and
+// ErrTransactionsPruned if its transactions were pruned.
+func (i *Indexer) Block(
+	ctx context.Context,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	block, err := i.lookupBlock(ctx, txn, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	index := block.BlockIdentifier.Index
+	pruned, err := prunedIndex(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if index <= pruned {
+		return nil, fmt.Errorf("%w: block %d", ErrTransactionsPruned, index)
+	}
+
+	block.Transactions = []*types.Transaction{}
+	prefix := blockTransactionsPrefix(index)
+	_, err = txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			var blockTransaction types.BlockTransaction
+			if err := json.Unmarshal(value, &blockTransaction); err != nil {
+				return err
+			}
+
+			block.Transactions = append(block.Transactions, blockTransaction.Transaction)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get transactions of block %d", err, index)
+	}
+
+	return block, nil
+}
+
+// Transaction returns the transaction with the provided identifier
+// in block, or ErrTransactionNotFound if it is not in the index.
+func (i *Indexer) Transaction(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	identifier *types.TransactionIdentifier,
+) (*types.Transaction, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	positions, err := searchPositions(
+		ctx,
+		txn,
+		searchKeyPrefix(searchHash, strings.ToLower(identifier.Hash)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for pos := range positions {
+		exists, value, err := txn.Get(ctx, transactionKey(pos))
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get transaction %s", err, identifier.Hash)
+		}
+		if !exists {
+			continue
+		}
+
+		var blockTransaction types.BlockTransaction
+		if err := json.Unmarshal(value, &blockTransaction); err != nil {
+			return nil, err
+		}
+
+		if blockTransaction.BlockIdentifier.Index == block.Index &&
+			strings.EqualFold(blockTransaction.BlockIdentifier.Hash, block.Hash) {
+			return blockTransaction.Transaction, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, identifier.Hash)
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlock(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Transactions: true})
+
+	_, err := i.Head(ctx)
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+
+	_, err = i.Block(ctx, nil)
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+
+	first := testTransactionBlock(
+		1,
+		"a",
+		testTransaction("0x1a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+		testTransaction("0x1b", ethereum.CallOpType, ethereum.FailureStatus, ethereum.Currency),
+	)
+	first.Timestamp = 1000
+	assert.NoError(t, i.addBlock(ctx, first))
+
+	second := testTransactionBlock(2, "a")
+	second.Timestamp = 2000
+	assert.NoError(t, i.addBlock(ctx, second))
+
+	head, err := i.Head(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, second.BlockIdentifier, head.BlockIdentifier)
+	assert.Equal(t, int64(2000), head.Timestamp)
+
+	// The latest block is returned by default.
+	block, err := i.Block(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.BlockIdentifier, block.BlockIdentifier)
+	assert.Equal(t, []*types.Transaction{}, block.Transactions)
+
+	index := int64(1)
+	block, err = i.Block(ctx, &types.PartialBlockIdentifier{Index: &index})
+	assert.NoError(t, err)
+	assert.Equal(t, first, block)
+
+	hash := "1A"
+	block, err = i.Block(ctx, &types.PartialBlockIdentifier{Hash: &hash})
+	assert.NoError(t, err)
+	assert.Equal(t, first, block)
+
+	wrongHash := "2a"
+	_, err = i.Block(ctx, &types.PartialBlockIdentifier{Index: &index, Hash: &wrongHash})
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+
+	missing := int64(3)
+	_, err = i.Block(ctx, &types.PartialBlockIdentifier{Index: &missing})
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+
+	transaction, err := i.Transaction(
+		ctx,
+		first.BlockIdentifier,
+		&types.TransactionIdentifier{Hash: "0x1b"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Transactions[1], transaction)
+
+	_, err = i.Transaction(
+		ctx,
+		second.BlockIdentifier,
+		&types.TransactionIdentifier{Hash: "0x1b"},
+	)
+	assert.True(t, errors.Is(err, ErrTransactionNotFound))
+
+	// Removed blocks can no longer be found by hash.
+	assert.NoError(t, i.removeBlock(ctx, second.BlockIdentifier))
+	hash = "2a"
+	_, err = i.Block(ctx, &types.PartialBlockIdentifier{Hash: &hash})
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+}
+
+func TestSync_Backfill(t *testing.T) {
+	chain := &testChain{}
+	chain.set(
+		testTransactionBlock(0, "a"),
+		testTransactionBlock(
+			1,
+			"a",
+			testTransaction("0x1a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+		),
+		testTransactionBlock(2, "a"),
+	)
+	mockClient := chain.mock()
+	i := newTestIndexer(t, mockClient, &Config{Transactions: true, Backfill: true})
+
+	// An empty index is synced from genesis.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- i.Sync(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	index := int64(1)
+	block, err := i.Block(context.Background(), &types.PartialBlockIdentifier{Index: &index})
+	assert.NoError(t, err)
+	assert.Len(t, block.Transactions, 1)
+
+	// Transactions are fetched along with blocks.
+	for _, call := range mockClient.Calls {
+		assert.NotEqual(t, "BlockHeader", call.Method)
+	}
+}
+
+func TestSync_BackfillRange(t *testing.T) {
+	chain := &testChain{}
+	blocks := make([]*types.Block, 3*backfillRange)
+	for index := range blocks {
+		blocks[index] = testTransactionBlock(int64(index), "a")
+	}
+	chain.set(blocks...)
+	mockClient := chain.mock()
+	i := newTestIndexer(t, mockClient, &Config{Transactions: true, Backfill: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- i.Sync(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == int64(len(blocks)-1)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	// Blocks more than a range behind the tip are fetched
+	// by range, and the others one at a time.
+	for _, call := range mockClient.Calls {
+		switch call.Method {
+		case "Blocks":
+			assert.Equal(t, int64(0), call.Arguments.Get(1))
+			assert.Equal(t, int64(backfillRange-1), call.Arguments.Get(2))
+		case "Block":
+			identifier := call.Arguments.Get(1).(*types.PartialBlockIdentifier)
+			assert.True(t, *identifier.Index >= backfillRange)
+		}
+	}
+	mockClient.AssertNumberOfCalls(t, "Blocks", 1)
+}
+
+func TestSync_IncompleteIndex(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Backfill: true})
+	assert.NoError(t, i.addBlock(ctx, testBlock(5, "a", "a")))
+
+	err := i.Sync(ctx)
+	assert.True(t, errors.Is(err, ErrIncompleteIndex))
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// blockPrefix is the prefix of the keys of the
+	// headers of the blocks of the canonical chain,
+	// by index.
+	blockPrefix = "block/"
+
+	// hashPrefix is the prefix of the keys of the
+	// indices of the blocks of the canonical chain,
+	// by hash.
+	hashPrefix = "hash/"
+
+	// eventPrefix is the prefix of the keys
+	// of the block events, by sequence.
+	eventPrefix = "event/"
+
+	// sequenceKey holds the sequence
+	// of the next block event.
+	sequenceKey = "sequence"
+
+	// keyDigits is the number of digits indices and
+	// sequences are padded to, so that keys sort in
+	// numerical order.
+	keyDigits = 20
+)
+
+var (
+	// errScanDone stops a scan once
+	// enough entries are read.
+	errScanDone = errors.New("scan done")
+
+	// errParentMismatch is returned when the parent of an
+	// added block is not the last block in the index.
+	errParentMismatch = errors.New("parent is not the last indexed block")
+)
+
+func blockKey(index int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d", blockPrefix, keyDigits, index))
+}
+
+func hashKey(hash string) []byte {
+	return []byte(hashPrefix + strings.ToLower(hash))
+}
+
+func eventKey(sequence int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d", eventPrefix, keyDigits, sequence))
+}
+
+// addBlock records block (and its transactions, balance
+// changes and token transfers) as the head of the canonical
+// chain with a block_added event. It returns
+// errParentMismatch if the block before it in the index is
+// not its parent.
+func (i *Indexer) addBlock(ctx context.Context, block *types.Block) error {
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	parent, err := header(ctx, txn, block.ParentBlockIdentifier.Index)
+	switch {
+	case errors.Is(err, ErrBlockNotFound):
+	case err != nil:
+		return err
+	case block.BlockIdentifier.Index != i.genesis.Index &&
+		types.Hash(parent.BlockIdentifier) != types.Hash(block.ParentBlockIdentifier):
+		return fmt.Errorf(
+			"%w: block %d has parent %s instead of %s",
+			errParentMismatch,
+			block.BlockIdentifier.Index,
+			block.ParentBlockIdentifier.Hash,
+			parent.BlockIdentifier.Hash,
+		)
+	}
+
+	value, err := json.Marshal(&types.Block{
+		BlockIdentifier:       block.BlockIdentifier,
+		ParentBlockIdentifier: block.ParentBlockIdentifier,
+		Timestamp:             block.Timestamp,
+		Metadata:              block.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(ctx, blockKey(block.BlockIdentifier.Index), value, false); err != nil {
+		return fmt.Errorf("%w: unable to store block %d", err, block.BlockIdentifier.Index)
+	}
+
+	index := []byte(strconv.FormatInt(block.BlockIdentifier.Index, 10))
+	if err := txn.Set(ctx, hashKey(block.BlockIdentifier.Hash), index, false); err != nil {
+		return fmt.Errorf("%w: unable to store block %d", err, block.BlockIdentifier.Index)
+	}
+
+	if i.config.Transactions {
+		if err := addTransactions(ctx, txn, block); err != nil {
+			return err
+		}
+	}
+
+	if i.config.Balances {
+		if err := addBalances(ctx, txn, block); err != nil {
+			return err
+		}
+	}
+
+	if i.config.TokenTransfers {
+		if err := addTokenTransfers(ctx, txn, block); err != nil {
+			return err
+		}
+	}
+
+	if err := appendEvent(ctx, txn, block.BlockIdentifier, types.ADDED); err != nil {
+		return err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&indexedHeight, block.BlockIdentifier.Index)
+	return nil
+}
+
+// removeBlock removes block (and its transactions, balance
+// changes and token transfers), the head of the canonical chain,
+// with a block_removed event.
+func (i *Indexer) removeBlock(ctx context.Context, block *types.BlockIdentifier) error {
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	if err := txn.Delete(ctx, blockKey(block.Index)); err != nil {
+		return fmt.Errorf("%w: unable to remove block %d", err, block.Index)
+	}
+
+	if err := txn.Delete(ctx, hashKey(block.Hash)); err != nil {
+		return fmt.Errorf("%w: unable to remove block %d", err, block.Index)
+	}
+
+	if err := removeTransactions(ctx, txn, block.Index); err != nil {
+		return err
+	}
+
+	if err := removeBalances(ctx, txn, block.Index); err != nil {
+		return err
+	}
+
+	if err := removeTokenTransfers(ctx, txn, block.Index); err != nil {
+		return err
+	}
+
+	if err := appendEvent(ctx, txn, block, types.REMOVED); err != nil {
+		return err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&indexedHeight, block.Index-1)
+	return nil
+}
+
+// appendEvent records a block event of eventType
+// for block with the next sequence.
+func appendEvent(
+	ctx context.Context,
+	txn database.Transaction,
+	block *types.BlockIdentifier,
+	eventType types.BlockEventType,
+) error {
+	sequence, err := nextSequence(ctx, txn)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(&types.BlockEvent{
+		Sequence:        sequence,
+		BlockIdentifier: block,
+		Type:            eventType,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(ctx, eventKey(sequence), value, false); err != nil {
+		return fmt.Errorf("%w: unable to store event %d", err, sequence)
+	}
+
+	next := []byte(strconv.FormatInt(sequence+1, 10))
+	if err := txn.Set(ctx, []byte(sequenceKey), next, false); err != nil {
+		return fmt.Errorf("%w: unable to store sequence", err)
+	}
+
+	return nil
+}
+
+// nextSequence returns the sequence of the next block event.
+func nextSequence(ctx context.Context, txn database.Transaction) (int64, error) {
+	exists, value, err := txn.Get(ctx, []byte(sequenceKey))
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to get sequence", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// recentBlocks returns up to limit of the latest
+// blocks of the canonical chain, oldest first.
+func (i *Indexer) recentBlocks(ctx context.Context, limit int) ([]*types.BlockIdentifier, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	var blocks []*types.BlockIdentifier
+	_, err := txn.Scan(
+		ctx,
+		[]byte(blockPrefix),
+		blockKey(1<<63-1),
+		func(key []byte, value []byte) error {
+			var block types.Block
+			if err := json.Unmarshal(value, &block); err != nil {
+				return err
+			}
+
+			blocks = append([]*types.BlockIdentifier{block.BlockIdentifier}, blocks...)
+			if len(blocks) == limit {
+				return errScanDone
+			}
+
+			return nil
+		},
+		false,
+		true,
+	)
+	if err != nil && !errors.Is(err, errScanDone) {
+		return nil, fmt.Errorf("%w: unable to get recent blocks", err)
+	}
+
+	return blocks, nil
+}
+
+// BlockEvents returns up to limit block events starting at sequence
+// offset, along with the largest sequence recorded (-1 if there is
+// none).
+func (i *Indexer) BlockEvents(
+	ctx context.Context,
+	offset int64,
+	limit int64,
+) (int64, []*types.BlockEvent, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	next, err := nextSequence(ctx, txn)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	events := []*types.BlockEvent{}
+	if limit <= 0 {
+		return next - 1, events, nil
+	}
+
+	_, err = txn.Scan(
+		ctx,
+		[]byte(eventPrefix),
+		eventKey(offset),
+		func(key []byte, value []byte) error {
+			var event types.BlockEvent
+			if err := json.Unmarshal(value, &event); err != nil {
+				return err
+			}
+
+			events = append(events, &event)
+			if int64(len(events)) == limit {
+				return errScanDone
+			}
+
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil && !errors.Is(err, errScanDone) {
+		return -1, nil, fmt.Errorf("%w: unable to get block events", err)
+	}
+
+	return next - 1, events, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrIncompleteIndex is returned by Sync when Backfill is
+	// set but the index does not start at genesis.
+	ErrIncompleteIndex = errors.New("index does not start at genesis")
+
+	// errReorg stops the syncer when a reorg is deeper
+	// than its past blocks, so that it is restarted with
+	// the blocks before the removed one.
+	errReorg = errors.New("reorg deeper than past blocks")
+)
+
+const (
+	// syncInitialBackoff is how long to wait before
+	// syncing again after the first failure.
+	syncInitialBackoff = 1 * time.Second
+
+	// syncMaxBackoff is the longest to wait
+	// before syncing again.
+	syncMaxBackoff = 2 * time.Minute
+
+	// defaultSyncConcurrency is the default maximum
+	// number of blocks fetched concurrently.
+	defaultSyncConcurrency = 8
+
+	// backfillRange is the number of blocks fetched at once
+	// (with Client.Blocks) while the indexer is more than a
+	// range behind the tip.
+	backfillRange = 20
+)
+
+// Client is used by the Indexer to follow the chain.
+type Client interface {
+	BlockIdentifier(context.Context, *int64) (*types.BlockIdentifier, error)
+	BlockHeader(context.Context, *types.PartialBlockIdentifier) (*types.Block, error)
+	Block(context.Context, *types.PartialBlockIdentifier) (*types.Block, error)
+	Blocks(ctx context.Context, from int64, to int64) ([]*types.Block, error)
+}
+
+// Config determines what an Indexer records.
+type Config struct {
+	// Transactions is whether the transactions of the
+	// canonical chain are indexed for search.
+	Transactions bool
+
+	// Backfill is whether the chain is followed from
+	// genesis, rather than from the current block, when
+	// the store is empty.
+	Backfill bool
+
+	// Balances is whether the balance changes of accounts
+	// are indexed. Balances are only complete with Backfill.
+	Balances bool
+
+	// TokenTransfers is whether the transfers of the tokens
+	// included in blocks are indexed.
+	TokenTransfers bool
+
+	// EventRetention is how long block events are kept, by
+	// the timestamp of their block (0 to keep all of them).
+	EventRetention time.Duration
+
+	// TransactionRetention is the number of recent blocks
+	// whose transactions and token transfers are kept (0 to
+	// keep all of them).
+	TransactionRetention int64
+
+	// Concurrency is the maximum number of blocks
+	// fetched concurrently (8 when not set).
+	Concurrency int64
+}
+
+// Indexer follows the chain and records, in a local store, each
+// block added to or removed from the canonical chain (when the head
+// advances or reorgs happen) as a sequenced event, along with the
+// header of each block of the canonical chain.
+type Indexer struct {
+	network *types.NetworkIdentifier
+	genesis *types.BlockIdentifier
+	client  Client
+	config  *Config
+	db      database.Database
+
+	// pastBlockLimit is the number of recent blocks
+	// the syncer checks for reorgs.
+	pastBlockLimit int
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New opens (or creates) the store of an Indexer in dir.
+func New(
+	ctx context.Context,
+	dir string,
+	network *types.NetworkIdentifier,
+	genesis *types.BlockIdentifier,
+	client Client,
+	config *Config,
+) (*Indexer, error) {
+	db, err := database.NewBadgerDatabase(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open index %s", err, dir)
+	}
+
+	return &Indexer{
+		network: network,
+		genesis: genesis,
+		client:  client,
+		config:  config,
+		db:      db,
+
+		pastBlockLimit: syncer.DefaultPastBlockLimit,
+	}, nil
+}
+
+// Close closes the store of the Indexer, flushing its writes to
+// disk. It must be called once Sync and Prune have returned, and
+// only the first call closes the store.
+func (i *Indexer) Close(ctx context.Context) error {
+	i.closeOnce.Do(func() {
+		i.closeErr = i.db.Close(ctx)
+	})
+
+	return i.closeErr
+}
+
+// Sync follows the chain until ctx is done. When the store is empty,
+// the chain is followed from genesis if Backfill is set, and from the
+// current block otherwise. Each block is committed on its own, so if
+// syncing fails (for example because geth is unavailable or
+// rosetta-ethereum restarts), it is resumed from the last recorded
+// block, with exponential backoff.
+func (i *Indexer) Sync(ctx context.Context) error {
+	if i.config.Backfill {
+		first, err := i.firstBlock(ctx)
+		if err != nil {
+			return err
+		}
+
+		if first != nil && first.Index != i.genesis.Index {
+			return fmt.Errorf(
+				"%w: the index starts at block %d",
+				ErrIncompleteIndex,
+				first.Index,
+			)
+		}
+	}
+
+	backoff := syncInitialBackoff
+	for {
+		synced, err := i.sync(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, errReorg) {
+			logger.FromContext(ctx).Info("indexer walking back reorg", zap.Error(err))
+			backoff = syncInitialBackoff
+			continue
+		}
+
+		if synced {
+			backoff = syncInitialBackoff
+		}
+
+		logger.FromContext(ctx).Warn(
+			"indexer sync failed",
+			zap.Error(err),
+			zap.Duration("retry_in", backoff),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > syncMaxBackoff {
+			backoff = syncMaxBackoff
+		}
+	}
+}
+
+// sync runs a syncer from the last recorded block until it fails or
+// ctx is done. synced is true if any block was recorded.
+func (i *Indexer) sync(ctx context.Context) (bool, error) {
+	pastBlocks, err := i.recentBlocks(ctx, i.pastBlockLimit)
+	if err != nil {
+		return false, err
+	}
+
+	// The syncer starts at genesis by default.
+	startIndex := int64(-1)
+	switch {
+	case len(pastBlocks) > 0:
+		atomic.StoreInt64(&indexedHeight, pastBlocks[len(pastBlocks)-1].Index)
+		startIndex = pastBlocks[len(pastBlocks)-1].Index + 1
+	case !i.config.Backfill:
+		current, err := i.client.BlockIdentifier(ctx, nil)
+		if err != nil {
+			return false, fmt.Errorf("%w: unable to get current block", err)
+		}
+
+		startIndex = current.Index
+	}
+
+	concurrency := int64(defaultSyncConcurrency)
+	if i.config.Concurrency > 0 {
+		concurrency = i.config.Concurrency
+	}
+
+	handler := &syncHandler{indexer: i}
+	syncCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := syncer.New(
+		i.network,
+		&syncHelper{indexer: i},
+		handler,
+		cancel,
+		syncer.WithPastBlocks(pastBlocks),
+		syncer.WithPastBlockLimit(i.pastBlockLimit),
+		syncer.WithMaxConcurrency(concurrency),
+		syncer.WithCacheSize(syncer.TinyCacheSize),
+	)
+
+	err = s.Sync(syncCtx, startIndex, -1)
+	return handler.added, err
+}
+
+// syncHelper fetches the blocks followed by the syncer.
+type syncHelper struct {
+	indexer *Indexer
+
+	// tip is the index of the current block of
+	// the node, as of the last NetworkStatus.
+	tip int64
+
+	// ranges are the ranges of blocks fetched
+	// while backfilling, by their first index.
+	mu     sync.Mutex
+	ranges map[int64]*blockRange
+}
+
+// blockRange is a range of backfillRange blocks,
+// which is fetched by the first syncer worker
+// asking for one of them.
+type blockRange struct {
+	done   chan struct{}
+	blocks []*types.Block
+	err    error
+
+	// pending is the number of blocks of the
+	// range not yet returned to the syncer.
+	pending int
+}
+
+// NetworkStatus returns the genesis and current block. While at the
+// tip, the syncer only stops once this fails, so it fails as soon as
+// ctx is done.
+func (h *syncHelper) NetworkStatus(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+) (*types.NetworkStatusResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	current, err := h.indexer.client.BlockIdentifier(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreInt64(&nodeHeight, current.Index)
+	atomic.StoreInt64(&h.tip, current.Index)
+
+	return &types.NetworkStatusResponse{
+		CurrentBlockIdentifier: current,
+		GenesisBlockIdentifier: h.indexer.genesis,
+	}, nil
+}
+
+// Block returns the block with the provided identifier, without
+// its transactions unless they are indexed. Blocks more than a
+// range behind the tip are fetched by range, so that backfilling
+// is not limited by the round trip of each block.
+func (h *syncHelper) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	config := h.indexer.config
+	if !config.Transactions && !config.Balances && !config.TokenTransfers {
+		return h.indexer.client.BlockHeader(ctx, identifier)
+	}
+
+	if identifier.Index != nil {
+		start := *identifier.Index - *identifier.Index%backfillRange
+		if start+2*backfillRange <= atomic.LoadInt64(&h.tip) {
+			return h.rangeBlock(ctx, start, *identifier.Index)
+		}
+	}
+
+	return h.indexer.client.Block(ctx, identifier)
+}
+
+// rangeBlock returns the block at index of the range starting
+// at start, fetching the range if no other worker did. Each
+// range is forgotten once all of its blocks were returned (or
+// it could not be fetched), so blocks are never served stale.
+func (h *syncHelper) rangeBlock(ctx context.Context, start int64, index int64) (*types.Block, error) {
+	h.mu.Lock()
+	if h.ranges == nil {
+		h.ranges = map[int64]*blockRange{}
+	}
+
+	r, ok := h.ranges[start]
+	if !ok {
+		r = &blockRange{done: make(chan struct{}), pending: backfillRange}
+		h.ranges[start] = r
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		r.blocks, r.err = h.indexer.client.Blocks(ctx, start, start+backfillRange-1)
+		close(r.done)
+	}
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	h.mu.Lock()
+	r.pending--
+	if (r.err != nil || r.pending == 0) && h.ranges[start] == r {
+		delete(h.ranges, start)
+	}
+	h.mu.Unlock()
+
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: unable to get blocks %d to %d", r.err, start, start+backfillRange-1)
+	}
+
+	return r.blocks[index-start], nil
+}
+
+// syncHandler records the blocks added and
+// removed by the syncer.
+type syncHandler struct {
+	indexer *Indexer
+	added   bool
+}
+
+// BlockSeen is a no-op, as blocks are
+// only recorded once they are sequenced.
+func (h *syncHandler) BlockSeen(context.Context, *types.Block) error {
+	return nil
+}
+
+// BlockAdded records block. The syncer only checks the parents of
+// blocks against its past blocks, so once a reorg removed all of
+// them, the parent of block may still be an orphaned block in the
+// index. The last indexed block is then removed and the syncer is
+// stopped with errReorg, to walk back the reorg further.
+func (h *syncHandler) BlockAdded(ctx context.Context, block *types.Block) error {
+	err := h.indexer.addBlock(ctx, block)
+	if errors.Is(err, errParentMismatch) {
+		head, headErr := h.indexer.Head(ctx)
+		if headErr != nil {
+			return headErr
+		}
+
+		if err := h.indexer.removeBlock(ctx, head.BlockIdentifier); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%w: removed block %d", errReorg, head.BlockIdentifier.Index)
+	}
+	if err != nil {
+		return err
+	}
+
+	h.added = true
+	return nil
+}
+
+// BlockRemoved records the removal of block.
+func (h *syncHandler) BlockRemoved(ctx context.Context, block *types.BlockIdentifier) error {
+	return h.indexer.removeBlock(ctx, block)
+}
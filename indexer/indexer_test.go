@@ -0,0 +1,327 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	testNetwork = &types.NetworkIdentifier{
+		Blockchain: "Ethereum",
+		Network:    "Testnet",
+	}
+
+	testGenesis = &types.BlockIdentifier{
+		Hash:  "genesis",
+		Index: 0,
+	}
+)
+
+// testBlock returns the block at index of branch,
+// whose parent is on parentBranch.
+func testBlock(index int64, branch string, parentBranch string) *types.Block {
+	return &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("%d%s", index, branch),
+			Index: index,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("%d%s", index-1, parentBranch),
+			Index: index - 1,
+		},
+	}
+}
+
+// testChain serves the blocks of a
+// canonical chain that can be replaced.
+type testChain struct {
+	mu     sync.Mutex
+	blocks []*types.Block
+}
+
+func (c *testChain) set(blocks ...*types.Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blocks = blocks
+}
+
+func (c *testChain) head(context.Context, *int64) *types.BlockIdentifier {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.blocks[len(c.blocks)-1].BlockIdentifier
+}
+
+func (c *testChain) block(_ context.Context, identifier *types.PartialBlockIdentifier) *types.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, block := range c.blocks {
+		if block.BlockIdentifier.Index == *identifier.Index {
+			return block
+		}
+	}
+
+	return nil
+}
+
+func (c *testChain) blockRange(_ context.Context, from int64, to int64) []*types.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocks := []*types.Block{}
+	for _, block := range c.blocks {
+		if block.BlockIdentifier.Index >= from && block.BlockIdentifier.Index <= to {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
+}
+
+func (c *testChain) mock() *mocks.Client {
+	mockClient := &mocks.Client{}
+	mockClient.On(
+		"BlockIdentifier",
+		mock.Anything,
+		(*int64)(nil),
+	).Return(
+		c.head,
+		nil,
+	)
+	mockClient.On(
+		"BlockHeader",
+		mock.Anything,
+		mock.Anything,
+	).Return(
+		c.block,
+		nil,
+	).Maybe()
+	mockClient.On(
+		"Block",
+		mock.Anything,
+		mock.Anything,
+	).Return(
+		c.block,
+		nil,
+	).Maybe()
+	mockClient.On(
+		"Blocks",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(
+		c.blockRange,
+		nil,
+	).Maybe()
+
+	return mockClient
+}
+
+func newTestIndexer(t *testing.T, client Client, config *Config) *Indexer {
+	ctx := context.Background()
+	i, err := New(ctx, t.TempDir(), testNetwork, testGenesis, client, config)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, i.Close(ctx))
+	})
+
+	return i
+}
+
+func TestBlockEvents(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{})
+
+	maxSequence, events, err := i.BlockEvents(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), maxSequence)
+	assert.Empty(t, events)
+
+	assert.NoError(t, i.addBlock(ctx, testBlock(1, "a", "")))
+	assert.NoError(t, i.addBlock(ctx, testBlock(2, "a", "a")))
+	assert.NoError(t, i.removeBlock(ctx, testBlock(2, "a", "a").BlockIdentifier))
+	assert.NoError(t, i.addBlock(ctx, testBlock(2, "b", "a")))
+
+	maxSequence, events, err = i.BlockEvents(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), maxSequence)
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 0, BlockIdentifier: testBlock(1, "a", "").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 1, BlockIdentifier: testBlock(2, "a", "a").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 2, BlockIdentifier: testBlock(2, "a", "a").BlockIdentifier, Type: types.REMOVED},
+		{Sequence: 3, BlockIdentifier: testBlock(2, "b", "a").BlockIdentifier, Type: types.ADDED},
+	}, events)
+
+	// Events are paginated by sequence.
+	_, events, err = i.BlockEvents(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].Sequence)
+	assert.Equal(t, int64(2), events[1].Sequence)
+
+	_, events, err = i.BlockEvents(ctx, 4, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Only the canonical chain is kept.
+	blocks, err := i.recentBlocks(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockIdentifier{
+		testBlock(1, "a", "").BlockIdentifier,
+		testBlock(2, "b", "a").BlockIdentifier,
+	}, blocks)
+
+	blocks, err = i.recentBlocks(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockIdentifier{
+		testBlock(2, "b", "a").BlockIdentifier,
+	}, blocks)
+}
+
+func TestSync_Reorg(t *testing.T) {
+	chain := &testChain{}
+	chain.set(testBlock(5, "a", "a"), testBlock(6, "a", "a"))
+	mockClient := chain.mock()
+	i := newTestIndexer(t, mockClient, &Config{})
+
+	// Syncing resumes after the last recorded block.
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, i.addBlock(ctx, testBlock(5, "a", "a")))
+
+	done := make(chan error)
+	go func() {
+		done <- i.Sync(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Block 6 is replaced by a new branch.
+	chain.set(testBlock(5, "a", "a"), testBlock(6, "b", "a"), testBlock(7, "b", "b"))
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == 4
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, events, err := i.BlockEvents(context.Background(), 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 1, BlockIdentifier: testBlock(6, "a", "a").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 2, BlockIdentifier: testBlock(6, "a", "a").BlockIdentifier, Type: types.REMOVED},
+		{Sequence: 3, BlockIdentifier: testBlock(6, "b", "a").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 4, BlockIdentifier: testBlock(7, "b", "b").BlockIdentifier, Type: types.ADDED},
+	}, events)
+}
+
+func TestSync_DeepReorg(t *testing.T) {
+	chain := &testChain{}
+	chain.set(testBlock(4, "a", "a"), testBlock(5, "a", "a"), testBlock(6, "a", "a"))
+	mockClient := chain.mock()
+	i := newTestIndexer(t, mockClient, &Config{})
+
+	// The syncer only checks the parent of block 6
+	// against its past blocks.
+	i.pastBlockLimit = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, block := range chain.blocks {
+		assert.NoError(t, i.addBlock(ctx, block))
+	}
+
+	// Blocks not on top of the last indexed block are rejected.
+	err := i.addBlock(ctx, testBlock(7, "b", "b"))
+	assert.ErrorIs(t, err, errParentMismatch)
+
+	// Blocks 5 and 6 are replaced by a new branch.
+	chain.set(
+		testBlock(4, "a", "a"),
+		testBlock(5, "b", "a"),
+		testBlock(6, "b", "b"),
+		testBlock(7, "b", "b"),
+	)
+
+	done := make(chan error)
+	go func() {
+		done <- i.Sync(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == 7
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, events, err := i.BlockEvents(context.Background(), 3, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 3, BlockIdentifier: testBlock(6, "a", "a").BlockIdentifier, Type: types.REMOVED},
+		{Sequence: 4, BlockIdentifier: testBlock(5, "a", "a").BlockIdentifier, Type: types.REMOVED},
+		{Sequence: 5, BlockIdentifier: testBlock(5, "b", "a").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 6, BlockIdentifier: testBlock(6, "b", "b").BlockIdentifier, Type: types.ADDED},
+		{Sequence: 7, BlockIdentifier: testBlock(7, "b", "b").BlockIdentifier, Type: types.ADDED},
+	}, events)
+
+	head, err := i.Head(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, testBlock(7, "b", "b").BlockIdentifier, head.BlockIdentifier)
+}
+
+func TestSync_Empty(t *testing.T) {
+	chain := &testChain{}
+	chain.set(testBlock(5, "a", "a"), testBlock(6, "a", "a"))
+	i := newTestIndexer(t, chain.mock(), &Config{})
+
+	// An empty index follows the chain from the current block.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- i.Sync(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		maxSequence, _, err := i.BlockEvents(ctx, 0, 0)
+		return err == nil && maxSequence == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, events, err := i.BlockEvents(context.Background(), 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 0, BlockIdentifier: testBlock(6, "a", "a").BlockIdentifier, Type: types.ADDED},
+	}, events)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"sync/atomic"
+
+	"github.com/coinbase/rosetta-ethereum/metrics"
+)
+
+var (
+	// indexedHeight and nodeHeight are the indices of the
+	// last indexed block and of the current block of the
+	// node (-1 until they are known).
+	indexedHeight int64 = -1
+	nodeHeight    int64 = -1
+
+	_ = metrics.DefaultRegistry.NewGaugeFunc(
+		"rosetta_indexer_height",
+		"Index of the last block in the index.",
+		func() (float64, bool) {
+			height := atomic.LoadInt64(&indexedHeight)
+			return float64(height), height >= 0
+		},
+	)
+
+	_ = metrics.DefaultRegistry.NewGaugeFunc(
+		"rosetta_node_height",
+		"Index of the current block of geth, as last seen by the indexer.",
+		func() (float64, bool) {
+			height := atomic.LoadInt64(&nodeHeight)
+			return float64(height), height >= 0
+		},
+	)
+
+	_ = metrics.DefaultRegistry.NewGaugeFunc(
+		"rosetta_indexer_sync_lag_blocks",
+		"Number of blocks the index is behind the current block of geth.",
+		func() (float64, bool) {
+			indexed := atomic.LoadInt64(&indexedHeight)
+			node := atomic.LoadInt64(&nodeHeight)
+			return float64(node - indexed), indexed >= 0 && node >= 0
+		},
+	)
+)
@@ -0,0 +1,253 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// pruneInterval is how often expired
+	// entries are removed from the index.
+	pruneInterval = 10 * time.Minute
+
+	// pruneBatchSize is the largest number of block
+	// events removed in one database transaction.
+	pruneBatchSize = 1000
+
+	// prunedKey holds the index of the last
+	// block whose transactions were pruned.
+	prunedKey = "pruned"
+)
+
+// ErrTransactionsPruned is returned by Block when the
+// transactions of the requested block were pruned.
+var ErrTransactionsPruned = errors.New("transactions pruned")
+
+// prunedIndex returns the index of the last block whose
+// transactions were pruned (-1 if there is none).
+func prunedIndex(ctx context.Context, txn database.Transaction) (int64, error) {
+	exists, value, err := txn.Get(ctx, []byte(prunedKey))
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to get pruned block", err)
+	}
+	if !exists {
+		return -1, nil
+	}
+
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// Prune removes, every pruneInterval until ctx is done, the
+// block events older than EventRetention and the transactions
+// (and token transfers) of the blocks more than
+// TransactionRetention blocks behind the last indexed block.
+// Balance changes are never pruned. The space of removed
+// entries is reclaimed by the garbage collection of the store.
+func (i *Indexer) Prune(ctx context.Context) error {
+	if i.config.EventRetention == 0 && i.config.TransactionRetention == 0 {
+		return nil
+	}
+
+	for {
+		if err := i.prune(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).Warn("indexer pruning failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pruneInterval):
+		}
+	}
+}
+
+// prune removes the block events and
+// transactions that are expired at now.
+func (i *Indexer) prune(ctx context.Context, now time.Time) error {
+	head, err := i.Head(ctx)
+	if errors.Is(err, ErrBlockNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var blocks int64
+	if i.config.TransactionRetention > 0 {
+		blocks, err = i.pruneTransactions(ctx, head.BlockIdentifier.Index-i.config.TransactionRetention)
+		if err != nil {
+			return err
+		}
+	}
+
+	var events int64
+	if i.config.EventRetention > 0 {
+		events, err = i.pruneEvents(ctx, now.Add(-i.config.EventRetention))
+		if err != nil {
+			return err
+		}
+	}
+
+	if blocks > 0 || events > 0 {
+		logger.FromContext(ctx).Info(
+			"indexer pruned",
+			zap.Int64("blocks", blocks),
+			zap.Int64("events", events),
+		)
+	}
+
+	return nil
+}
+
+// pruneTransactions removes the transactions of the blocks up
+// to last, one block at a time, and returns the number of
+// blocks pruned.
+func (i *Indexer) pruneTransactions(ctx context.Context, last int64) (int64, error) {
+	txn := i.db.ReadTransaction(ctx)
+	start, err := prunedIndex(ctx, txn)
+	txn.Discard(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	start++
+	if start == 0 {
+		first, err := i.firstBlock(ctx)
+		if err != nil || first == nil {
+			return 0, err
+		}
+
+		start = first.Index
+	}
+
+	var pruned int64
+	for index := start; index <= last && ctx.Err() == nil; index++ {
+		if err := i.pruneBlockTransactions(ctx, index); err != nil {
+			return pruned, err
+		}
+
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// pruneBlockTransactions removes the transactions and token
+// transfers of the block at index and records it as the last pruned block.
+func (i *Indexer) pruneBlockTransactions(ctx context.Context, index int64) error {
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	if err := removeTransactions(ctx, txn, index); err != nil {
+		return err
+	}
+
+	if err := removeTokenTransfers(ctx, txn, index); err != nil {
+		return err
+	}
+
+	if err := txn.Set(ctx, []byte(prunedKey), []byte(strconv.FormatInt(index, 10)), false); err != nil {
+		return fmt.Errorf("%w: unable to store pruned block", err)
+	}
+
+	return txn.Commit(ctx)
+}
+
+// pruneEvents removes the oldest block events, up to the first
+// one whose block (or the block at its index, for removed
+// blocks) is not older than before, and returns the number of
+// events pruned.
+func (i *Indexer) pruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	timestamp := before.UnixNano() / int64(time.Millisecond)
+
+	var pruned int64
+	for ctx.Err() == nil {
+		count, err := i.pruneEventBatch(ctx, timestamp)
+		pruned += count
+		if err != nil || count < pruneBatchSize {
+			return pruned, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// pruneEventBatch removes up to pruneBatchSize of the
+// oldest block events of blocks older than timestamp.
+func (i *Indexer) pruneEventBatch(ctx context.Context, timestamp int64) (int64, error) {
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	keys := [][]byte{}
+	_, err := txn.Scan(
+		ctx,
+		[]byte(eventPrefix),
+		eventKey(0),
+		func(key []byte, value []byte) error {
+			var event types.BlockEvent
+			if err := json.Unmarshal(value, &event); err != nil {
+				return err
+			}
+
+			block, err := header(ctx, txn, event.BlockIdentifier.Index)
+			if errors.Is(err, ErrBlockNotFound) {
+				return errScanDone
+			}
+			if err != nil {
+				return err
+			}
+
+			if block.Timestamp >= timestamp {
+				return errScanDone
+			}
+
+			keys = append(keys, append([]byte{}, key...))
+			if len(keys) == pruneBatchSize {
+				return errScanDone
+			}
+
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil && !errors.Is(err, errScanDone) {
+		return 0, fmt.Errorf("%w: unable to get events", err)
+	}
+
+	for _, key := range keys {
+		if err := txn.Delete(ctx, key); err != nil {
+			return 0, fmt.Errorf("%w: unable to remove event", err)
+		}
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return int64(len(keys)), nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{
+		Transactions:         true,
+		Balances:             true,
+		EventRetention:       time.Hour,
+		TransactionRetention: 1,
+	})
+
+	now := time.Now()
+	timedBlock := func(block *types.Block, parentHash string, age time.Duration) *types.Block {
+		block.ParentBlockIdentifier.Hash = parentHash
+		block.Timestamp = now.Add(-age).UnixNano() / int64(time.Millisecond)
+		return block
+	}
+
+	assert.NoError(t, i.addBlock(ctx, timedBlock(testTransactionBlock(
+		1,
+		"a",
+		testReward("0x1r", "100"),
+	), "0a", 3*time.Hour)))
+	assert.NoError(t, i.addBlock(ctx, timedBlock(testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	), "1a", 2*time.Hour)))
+	assert.NoError(t, i.removeBlock(ctx, testBlock(2, "a", "a").BlockIdentifier))
+	assert.NoError(t, i.addBlock(ctx, timedBlock(testTransactionBlock(
+		2,
+		"b",
+		testTransaction("0x2b", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	), "1a", 2*time.Hour)))
+	assert.NoError(t, i.addBlock(ctx, timedBlock(testTransactionBlock(
+		3,
+		"b",
+		testTransaction("0x3b", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	), "2b", 0)))
+
+	// Events of blocks older than an hour and transactions of
+	// blocks more than one block behind are pruned.
+	assert.NoError(t, i.prune(ctx, now))
+
+	maxSequence, events, err := i.BlockEvents(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), maxSequence)
+	assert.Equal(t, []*types.BlockEvent{
+		{Sequence: 4, BlockIdentifier: testBlock(3, "b", "b").BlockIdentifier, Type: types.ADDED},
+	}, events)
+
+	block, err := i.Block(ctx, &types.PartialBlockIdentifier{Index: types.Int64(2)})
+	assert.Nil(t, block)
+	assert.ErrorIs(t, err, ErrTransactionsPruned)
+
+	block, err = i.Block(ctx, &types.PartialBlockIdentifier{Index: types.Int64(3)})
+	assert.NoError(t, err)
+	assert.Len(t, block.Transactions, 1)
+
+	count, transactions, err := i.SearchTransactions(ctx, &types.SearchTransactionsRequest{}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, []string{"0x3b"}, hashes(transactions))
+
+	// Balance changes are kept.
+	resp, err := i.Balance(
+		ctx,
+		&types.AccountIdentifier{Address: testFrom},
+		&types.PartialBlockIdentifier{Index: types.Int64(1)},
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "100", resp.Balances[0].Value)
+
+	// Pruning resumes after the last pruned block.
+	assert.NoError(t, i.addBlock(ctx, timedBlock(testTransactionBlock(4, "b"), "3b", 0)))
+	pruned, err := i.pruneTransactions(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	_, err = i.Block(ctx, &types.PartialBlockIdentifier{Index: types.Int64(3)})
+	assert.ErrorIs(t, err, ErrTransactionsPruned)
+}
+
+func TestPrune_Disabled(t *testing.T) {
+	i := newTestIndexer(t, &mocks.Client{}, &Config{})
+
+	// Prune returns at once when nothing is pruned.
+	assert.NoError(t, i.Prune(context.Background()))
+}
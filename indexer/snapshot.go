@@ -0,0 +1,314 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// importBatchSize is the largest number of entries
+// written in one database transaction on import.
+const importBatchSize = 10000
+
+var (
+	// ErrIndexNotEmpty is returned by Import when
+	// the index already holds entries.
+	ErrIndexNotEmpty = errors.New("index is not empty")
+
+	// ErrInvalidSnapshot is returned by Import when the
+	// snapshot is corrupted or does not match the index.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// Manifest describes the content of a snapshot
+// and is used to verify it on import.
+type Manifest struct {
+	Network *types.NetworkIdentifier `json:"network"`
+	Genesis *types.BlockIdentifier   `json:"genesis"`
+
+	// Tip is the last block in the
+	// snapshot (nil if there is none).
+	Tip *types.BlockIdentifier `json:"tip,omitempty"`
+
+	// Counts are the numbers of entries
+	// in the snapshot, by key prefix.
+	Counts map[string]int64 `json:"counts"`
+}
+
+// keyCategory returns the prefix of key
+// its entry is counted under in a Manifest.
+func keyCategory(key []byte) string {
+	category := string(key)
+	if i := strings.Index(category, "/"); i >= 0 {
+		return category[:i+1]
+	}
+
+	return category
+}
+
+// writeRecord writes key and value,
+// each prefixed by its length, to w.
+func writeRecord(w io.Writer, key []byte, value []byte) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, data := range [][]byte{key, value} {
+		n := binary.PutUvarint(buf, uint64(len(data)))
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readRecord reads a key and a value
+// written by writeRecord from r.
+func readRecord(r *bufio.Reader) ([]byte, []byte, error) {
+	record := make([][]byte, 2) // nolint:gomnd
+	for j := range record {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		record[j] = make([]byte, length)
+		if _, err := io.ReadFull(r, record[j]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return record[0], record[1], nil
+}
+
+// Export writes a gzip-compressed snapshot of every entry of the
+// index to w, followed by its Manifest (after an empty key), and
+// returns the Manifest.
+func (i *Indexer) Export(ctx context.Context, w io.Writer) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	manifest := &Manifest{
+		Network: i.network,
+		Genesis: i.genesis,
+		Counts:  map[string]int64{},
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	_, err := txn.Scan(
+		ctx,
+		[]byte{},
+		[]byte{},
+		func(key []byte, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// Blocks are scanned by index, so
+			// the tip is the last of them.
+			if strings.HasPrefix(string(key), blockPrefix) {
+				var block types.Block
+				if err := json.Unmarshal(value, &block); err != nil {
+					return err
+				}
+
+				manifest.Tip = block.BlockIdentifier
+			}
+
+			manifest.Counts[keyCategory(key)]++
+			return writeRecord(gz, key, value)
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to export index", err)
+	}
+
+	value, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeRecord(gz, []byte{}, value); err != nil {
+		return nil, fmt.Errorf("%w: unable to write manifest", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("%w: unable to write snapshot", err)
+	}
+
+	return manifest, nil
+}
+
+// Import reads a snapshot written by Export from r into the index,
+// which must be empty, and returns its Manifest. It returns
+// ErrInvalidSnapshot if the snapshot is not of the network and
+// genesis of the index, or if its entries or tip do not match
+// its Manifest. The index must then be discarded.
+func (i *Indexer) Import(ctx context.Context, r io.Reader) (*Manifest, error) {
+	if err := i.checkEmpty(ctx); err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	reader := bufio.NewReader(gz)
+
+	counts := map[string]int64{}
+	var manifest *Manifest
+	for manifest == nil {
+		batch := [][2][]byte{}
+		for len(batch) < importBatchSize {
+			key, value, err := readRecord(reader)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+
+			if len(key) == 0 {
+				manifest = &Manifest{}
+				if err := json.Unmarshal(value, manifest); err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+				}
+
+				break
+			}
+
+			counts[keyCategory(key)]++
+			batch = append(batch, [2][]byte{key, value})
+		}
+
+		if err := i.importBatch(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	// The checksum of the snapshot is
+	// only verified at its end.
+	if _, err := reader.ReadByte(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: unexpected data after manifest (%v)", ErrInvalidSnapshot, err)
+	}
+
+	if err := i.verify(ctx, manifest, counts); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// checkEmpty returns ErrIndexNotEmpty if
+// the index holds any entry.
+func (i *Indexer) checkEmpty(ctx context.Context) error {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	_, err := txn.Scan(
+		ctx,
+		[]byte{},
+		[]byte{},
+		func(key []byte, value []byte) error {
+			return errScanDone
+		},
+		false,
+		false,
+	)
+	if errors.Is(err, errScanDone) {
+		return ErrIndexNotEmpty
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to read index", err)
+	}
+
+	return nil
+}
+
+// importBatch writes the entries of batch in one transaction.
+func (i *Indexer) importBatch(ctx context.Context, batch [][2][]byte) error {
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	for _, entry := range batch {
+		if err := txn.Set(ctx, entry[0], entry[1], false); err != nil {
+			return fmt.Errorf("%w: unable to import %s", err, entry[0])
+		}
+	}
+
+	return txn.Commit(ctx)
+}
+
+// verify returns ErrInvalidSnapshot if manifest is not of the
+// network and genesis of the index, or if counts (of the
+// imported entries) or the tip of the index do not match it.
+func (i *Indexer) verify(ctx context.Context, manifest *Manifest, counts map[string]int64) error {
+	if types.Hash(manifest.Network) != types.Hash(i.network) ||
+		types.Hash(manifest.Genesis) != types.Hash(i.genesis) {
+		return fmt.Errorf(
+			"%w: snapshot of %s (genesis %s) does not match %s (genesis %s)",
+			ErrInvalidSnapshot,
+			types.PrintStruct(manifest.Network),
+			types.PrintStruct(manifest.Genesis),
+			types.PrintStruct(i.network),
+			types.PrintStruct(i.genesis),
+		)
+	}
+
+	if len(manifest.Counts) == 0 {
+		manifest.Counts = map[string]int64{}
+	}
+	if !reflect.DeepEqual(manifest.Counts, counts) {
+		return fmt.Errorf(
+			"%w: imported entries %s do not match %s",
+			ErrInvalidSnapshot,
+			types.PrintStruct(counts),
+			types.PrintStruct(manifest.Counts),
+		)
+	}
+
+	var tip *types.BlockIdentifier
+	head, err := i.Head(ctx)
+	switch {
+	case errors.Is(err, ErrBlockNotFound):
+	case err != nil:
+		return err
+	default:
+		tip = head.BlockIdentifier
+	}
+
+	if types.Hash(tip) != types.Hash(manifest.Tip) {
+		return fmt.Errorf(
+			"%w: imported tip %s does not match %s",
+			ErrInvalidSnapshot,
+			types.PrintStruct(tip),
+			types.PrintStruct(manifest.Tip),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{Transactions: true, Balances: true}
+	i := newTestIndexer(t, &mocks.Client{}, config)
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(1, "a", testReward("0x1r", "100"))))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+
+	var snapshot bytes.Buffer
+	manifest, err := i.Export(ctx, &snapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, testBlock(2, "a", "a").BlockIdentifier, manifest.Tip)
+	assert.Equal(t, int64(2), manifest.Counts[blockPrefix])
+	assert.Equal(t, int64(2), manifest.Counts[eventPrefix])
+	assert.Equal(t, int64(2), manifest.Counts[transactionPrefix])
+	assert.Equal(t, int64(1), manifest.Counts[sequenceKey])
+
+	t.Run("import", func(t *testing.T) {
+		imported := newTestIndexer(t, &mocks.Client{}, config)
+		importedManifest, err := imported.Import(ctx, bytes.NewReader(snapshot.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, manifest, importedManifest)
+
+		expected, err := i.Block(ctx, nil)
+		assert.NoError(t, err)
+		block, err := imported.Block(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, block)
+
+		maxSequence, events, err := imported.BlockEvents(ctx, 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), maxSequence)
+		assert.Len(t, events, 2)
+
+		balance, err := imported.Balance(ctx, &types.AccountIdentifier{Address: testFrom}, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "-20901", balance.Balances[0].Value)
+
+		// Snapshots are only imported into empty indexes.
+		_, err = imported.Import(ctx, bytes.NewReader(snapshot.Bytes()))
+		assert.ErrorIs(t, err, ErrIndexNotEmpty)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		imported := newTestIndexer(t, &mocks.Client{}, config)
+		_, err := imported.Import(ctx, bytes.NewReader(snapshot.Bytes()[:snapshot.Len()-10]))
+		assert.ErrorIs(t, err, ErrInvalidSnapshot)
+	})
+
+	t.Run("other network", func(t *testing.T) {
+		network := &types.NetworkIdentifier{Blockchain: "Ethereum", Network: "Mainnet"}
+		imported, err := New(ctx, t.TempDir(), network, testGenesis, &mocks.Client{}, config)
+		assert.NoError(t, err)
+		defer imported.Close(ctx) // nolint:errcheck
+
+		_, err = imported.Import(ctx, bytes.NewReader(snapshot.Bytes()))
+		assert.ErrorIs(t, err, ErrInvalidSnapshot)
+	})
+}
+
+func TestSnapshot_Empty(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{})
+
+	var snapshot bytes.Buffer
+	manifest, err := i.Export(ctx, &snapshot)
+	assert.NoError(t, err)
+	assert.Nil(t, manifest.Tip)
+	assert.Empty(t, manifest.Counts)
+
+	imported := newTestIndexer(t, &mocks.Client{}, &Config{})
+	_, err = imported.Import(ctx, &snapshot)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,369 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// transferPrefix is the prefix of the keys of the token
+	// transfers of the canonical chain, by position.
+	transferPrefix = "transfer/"
+
+	// transferSearchPrefix is the prefix of the keys of the
+	// token transfer indexes, by condition and position.
+	transferSearchPrefix = "transfersearch/"
+)
+
+// Conditions token transfers are indexed by.
+const (
+	transferToken   = "token"
+	transferFrom    = "from"
+	transferTo      = "to"
+	transferAddress = "address"
+)
+
+// ErrTokenTransfersNotIndexed is returned by
+// TokenTransfers when token transfers are not indexed.
+var ErrTokenTransfersNotIndexed = errors.New("token transfers are not indexed")
+
+// TokenTransfer is a transfer of an ERC-20 token. From is
+// empty for mints, and To for burns.
+type TokenTransfer struct {
+	BlockIdentifier       *types.BlockIdentifier       `json:"block_identifier"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	Currency              *types.Currency              `json:"currency"`
+	From                  string                       `json:"from,omitempty"`
+	To                    string                       `json:"to,omitempty"`
+	Amount                string                       `json:"amount"`
+}
+
+// TokenTransferFilter selects the token transfers matching all of
+// its conditions. Conditions that are not set match any transfer.
+type TokenTransferFilter struct {
+	// Token is the contract address of the token.
+	Token string
+
+	From string
+	To   string
+
+	// Address matches transfers from or to it.
+	Address string
+
+	MaxBlock *int64
+}
+
+// transferPosition returns the position of the transfer at
+// transferIndex of a transaction, which sorts in the order of
+// the chain.
+func transferPosition(blockIndex int64, txIndex int, transferIndex int) string {
+	return fmt.Sprintf("%s/%0*d", position(blockIndex, txIndex), positionDigits, transferIndex)
+}
+
+func transferKey(pos string) []byte {
+	return []byte(transferPrefix + pos)
+}
+
+func blockTransfersPrefix(blockIndex int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d/", transferPrefix, keyDigits, blockIndex))
+}
+
+func transferSearchKeyPrefix(condition string, value string) string {
+	return fmt.Sprintf("%s%s/%s/", transferSearchPrefix, condition, strings.ToLower(value))
+}
+
+// tokenContract returns the contract address of
+// currency, if it is an ERC-20 token.
+func tokenContract(currency *types.Currency) (string, bool) {
+	if currency == nil {
+		return "", false
+	}
+
+	contract, ok := currency.Metadata[ethereum.ContractAddressKey].(string)
+	return contract, ok
+}
+
+// transferSearchKeyPrefixes returns the prefixes
+// of the index keys of transfer.
+func transferSearchKeyPrefixes(transfer *TokenTransfer) []string {
+	contract, _ := tokenContract(transfer.Currency)
+	prefixes := []string{transferSearchKeyPrefix(transferToken, contract)}
+	if len(transfer.From) > 0 {
+		prefixes = append(
+			prefixes,
+			transferSearchKeyPrefix(transferFrom, transfer.From),
+			transferSearchKeyPrefix(transferAddress, transfer.From),
+		)
+	}
+
+	if len(transfer.To) > 0 {
+		prefixes = append(prefixes, transferSearchKeyPrefix(transferTo, transfer.To))
+		if !strings.EqualFold(transfer.From, transfer.To) {
+			prefixes = append(prefixes, transferSearchKeyPrefix(transferAddress, transfer.To))
+		}
+	}
+
+	return prefixes
+}
+
+// tokenTransfers returns the token transfers of the successful
+// operations of transaction. The operation crediting the recipient
+// of a transfer is related to the one debiting its sender, while
+// mints and burns only have one of them (see tokenTransferOps).
+func tokenTransfers(transaction *types.Transaction) ([]*TokenTransfer, error) {
+	ops := map[int64]*types.Operation{}
+	related := map[int64]bool{}
+	for _, op := range transaction.Operations {
+		if op.Account == nil || op.Amount == nil ||
+			op.Status == nil || !successfulStatuses[*op.Status] {
+			continue
+		}
+
+		if _, ok := tokenContract(op.Amount.Currency); !ok {
+			continue
+		}
+
+		ops[op.OperationIdentifier.Index] = op
+		for _, identifier := range op.RelatedOperations {
+			related[identifier.Index] = true
+		}
+	}
+
+	transfers := []*TokenTransfer{}
+	for _, op := range transaction.Operations {
+		if _, ok := ops[op.OperationIdentifier.Index]; !ok || related[op.OperationIdentifier.Index] {
+			continue
+		}
+
+		amount, ok := new(big.Int).SetString(op.Amount.Value, 10)
+		if !ok {
+			return nil, fmt.Errorf(
+				"invalid amount %s in transaction %s",
+				op.Amount.Value,
+				transaction.TransactionIdentifier.Hash,
+			)
+		}
+
+		transfer := &TokenTransfer{
+			TransactionIdentifier: transaction.TransactionIdentifier,
+			Currency:              op.Amount.Currency,
+			To:                    op.Account.Address,
+			Amount:                amount.String(),
+		}
+
+		switch {
+		case len(op.RelatedOperations) > 0:
+			if from, ok := ops[op.RelatedOperations[0].Index]; ok {
+				transfer.From = from.Account.Address
+			}
+		case amount.Sign() < 0:
+			transfer.From = op.Account.Address
+			transfer.To = ""
+			transfer.Amount = amount.Neg(amount).String()
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+// addTokenTransfers records the token transfers of
+// block and their index keys in txn.
+func addTokenTransfers(
+	ctx context.Context,
+	txn database.Transaction,
+	block *types.Block,
+) error {
+	for i, transaction := range block.Transactions {
+		transfers, err := tokenTransfers(transaction)
+		if err != nil {
+			return err
+		}
+
+		for j, transfer := range transfers {
+			transfer.BlockIdentifier = block.BlockIdentifier
+			pos := transferPosition(block.BlockIdentifier.Index, i, j)
+			value, err := json.Marshal(transfer)
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set(ctx, transferKey(pos), value, false); err != nil {
+				return fmt.Errorf("%w: unable to store transfer of %s", err, transaction.TransactionIdentifier.Hash)
+			}
+
+			for _, prefix := range transferSearchKeyPrefixes(transfer) {
+				if err := txn.Set(ctx, []byte(prefix+pos), []byte{}, false); err != nil {
+					return fmt.Errorf("%w: unable to index transfer of %s", err, transaction.TransactionIdentifier.Hash)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeTokenTransfers removes the token transfers of the
+// block at blockIndex and their index keys in txn.
+func removeTokenTransfers(
+	ctx context.Context,
+	txn database.Transaction,
+	blockIndex int64,
+) error {
+	prefix := blockTransfersPrefix(blockIndex)
+	keys := [][]byte{}
+	_, err := txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			var transfer TokenTransfer
+			if err := json.Unmarshal(value, &transfer); err != nil {
+				return err
+			}
+
+			pos := strings.TrimPrefix(string(key), transferPrefix)
+			for _, searchPrefix := range transferSearchKeyPrefixes(&transfer) {
+				keys = append(keys, []byte(searchPrefix+pos))
+			}
+
+			keys = append(keys, append([]byte{}, key...))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get transfers of block %d", err, blockIndex)
+	}
+
+	for _, key := range keys {
+		if err := txn.Delete(ctx, key); err != nil {
+			return fmt.Errorf("%w: unable to remove transfers of block %d", err, blockIndex)
+		}
+	}
+
+	return nil
+}
+
+// TokenTransfers returns up to limit of the token transfers
+// matching filter in blocks up to its max block, most recent
+// first, starting at offset. It also returns the number of
+// matching transfers.
+func (i *Indexer) TokenTransfers(
+	ctx context.Context,
+	filter *TokenTransferFilter,
+	offset int64,
+	limit int64,
+) (int64, []*TokenTransfer, error) {
+	if !i.config.TokenTransfers {
+		return -1, nil, ErrTokenTransfersNotIndexed
+	}
+
+	conditions := []string{}
+	for _, condition := range []struct {
+		name  string
+		value string
+	}{
+		{transferToken, filter.Token},
+		{transferFrom, filter.From},
+		{transferTo, filter.To},
+		{transferAddress, filter.Address},
+	} {
+		if len(condition.value) > 0 {
+			conditions = append(conditions, transferSearchKeyPrefix(condition.name, condition.value))
+		}
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	var matches map[string]struct{}
+	if len(conditions) == 0 {
+		var err error
+		matches, err = searchPositions(ctx, txn, transferPrefix)
+		if err != nil {
+			return -1, nil, err
+		}
+	}
+
+	for _, condition := range conditions {
+		positions, err := searchPositions(ctx, txn, condition)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		if matches == nil {
+			matches = positions
+			continue
+		}
+
+		for pos := range matches {
+			if _, ok := positions[pos]; !ok {
+				delete(matches, pos)
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(matches))
+	for pos := range matches {
+		if filter.MaxBlock != nil {
+			blockIndex, err := positionBlockIndex(pos)
+			if err != nil {
+				return -1, nil, fmt.Errorf("%w: unable to parse position %s", err, pos)
+			}
+
+			if blockIndex > *filter.MaxBlock {
+				continue
+			}
+		}
+
+		sorted = append(sorted, pos)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	transfers := []*TokenTransfer{}
+	for j := offset; j < int64(len(sorted)) && j < offset+limit; j++ {
+		exists, value, err := txn.Get(ctx, transferKey(sorted[j]))
+		if err != nil {
+			return -1, nil, fmt.Errorf("%w: unable to get transfer %s", err, sorted[j])
+		}
+		if !exists {
+			return -1, nil, fmt.Errorf("transfer %s is not indexed", sorted[j])
+		}
+
+		var transfer TokenTransfer
+		if err := json.Unmarshal(value, &transfer); err != nil {
+			return -1, nil, err
+		}
+
+		transfers = append(transfers, &transfer)
+	}
+
+	return int64(len(sorted)), transfers, nil
+}
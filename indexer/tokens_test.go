@@ -0,0 +1,194 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMinter = "0x0000000000000000000000000000000000000001"
+
+// testTokenTransaction returns a transaction of hash with a
+// fee paid by testFrom, a transfer of value testToken from
+// testFrom to testTo (as included in blocks by the client)
+// and a mint of value testToken to testMinter.
+func testTokenTransaction(hash string, value string) *types.Transaction {
+	transaction := testTransaction(hash, ethereum.CallOpType, ethereum.SuccessStatus, testToken)
+	transaction.Operations[1].Amount.Value = "-" + value
+	transaction.Operations[2].Amount.Value = value
+	transaction.Operations[2].RelatedOperations = []*types.OperationIdentifier{{Index: 1}}
+	transaction.Operations = append(transaction.Operations, &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 3},
+		Type:                ethereum.CallOpType,
+		Status:              types.String(ethereum.SuccessStatus),
+		Account:             &types.AccountIdentifier{Address: testMinter},
+		Amount:              &types.Amount{Value: value, Currency: testToken},
+	})
+
+	return transaction
+}
+
+func TestTokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{TokenTransfers: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		1,
+		"a",
+		testTokenTransaction("0x1a", "10"),
+		testTransaction("0x1b", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.FailureStatus, testToken),
+		testTokenTransaction("0x2b", "20"),
+	)))
+
+	maxBlock := int64(1)
+	tests := map[string]struct {
+		filter *TokenTransferFilter
+		offset int64
+		limit  int64
+
+		expectedCount   int64
+		expectedAmounts []string
+	}{
+		"all": {
+			filter:          &TokenTransferFilter{},
+			limit:           10,
+			expectedCount:   4,
+			expectedAmounts: []string{"20", "20", "10", "10"},
+		},
+		"token": {
+			filter:          &TokenTransferFilter{Token: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"},
+			limit:           10,
+			expectedCount:   4,
+			expectedAmounts: []string{"20", "20", "10", "10"},
+		},
+		"other token": {
+			filter:          &TokenTransferFilter{Token: testMinter},
+			limit:           10,
+			expectedAmounts: []string{},
+		},
+		"from": {
+			filter:          &TokenTransferFilter{From: testFrom},
+			limit:           10,
+			expectedCount:   2,
+			expectedAmounts: []string{"20", "10"},
+		},
+		"to minter": {
+			filter:          &TokenTransferFilter{To: testMinter, MaxBlock: &maxBlock},
+			limit:           10,
+			expectedCount:   1,
+			expectedAmounts: []string{"10"},
+		},
+		"from and to": {
+			filter:          &TokenTransferFilter{From: testTo, To: testFrom},
+			limit:           10,
+			expectedAmounts: []string{},
+		},
+		"address": {
+			filter:          &TokenTransferFilter{Address: testTo},
+			offset:          1,
+			limit:           1,
+			expectedCount:   2,
+			expectedAmounts: []string{"10"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			count, transfers, err := i.TokenTransfers(ctx, test.filter, test.offset, test.limit)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCount, count)
+
+			amounts := make([]string, len(transfers))
+			for j, transfer := range transfers {
+				amounts[j] = transfer.Amount
+			}
+			assert.Equal(t, test.expectedAmounts, amounts)
+		})
+	}
+
+	_, transfers, err := i.TokenTransfers(ctx, &TokenTransferFilter{}, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &TokenTransfer{
+		BlockIdentifier:       testBlock(2, "a", "a").BlockIdentifier,
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "0x2b"},
+		Currency:              testToken,
+		To:                    testMinter,
+		Amount:                "20",
+	}, transfers[0])
+	assert.Equal(t, testFrom, transfers[1].From)
+	assert.Equal(t, testTo, transfers[1].To)
+
+	// Transfers of removed blocks are no longer indexed.
+	assert.NoError(t, i.removeBlock(ctx, testBlock(2, "a", "a").BlockIdentifier))
+	count, _, err := i.TokenTransfers(ctx, &TokenTransferFilter{Address: testFrom}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTokenTransfers_NotIndexed(t *testing.T) {
+	i := newTestIndexer(t, &mocks.Client{}, &Config{})
+
+	_, transfers, err := i.TokenTransfers(context.Background(), &TokenTransferFilter{}, 0, 10)
+	assert.Nil(t, transfers)
+	assert.ErrorIs(t, err, ErrTokenTransfersNotIndexed)
+}
+
+func TestBalanceHistory(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Balances: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(1, "a", testTokenTransaction("0x1a", "10"))))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(2, "a", testReward("0x2r", "100"))))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(3, "a", testTokenTransaction("0x3a", "5"))))
+
+	account := &types.AccountIdentifier{Address: testTo}
+	count, changes, err := i.BalanceHistory(ctx, account, testToken, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, []*BalanceChange{
+		{
+			BlockIdentifier: testBlock(3, "a", "a").BlockIdentifier,
+			Delta:           &types.Amount{Value: "5", Currency: testToken},
+			Balance:         &types.Amount{Value: "15", Currency: testToken},
+		},
+		{
+			BlockIdentifier: testBlock(1, "a", "a").BlockIdentifier,
+			Delta:           &types.Amount{Value: "10", Currency: testToken},
+			Balance:         &types.Amount{Value: "10", Currency: testToken},
+		},
+	}, changes)
+
+	count, changes, err = i.BalanceHistory(ctx, &types.AccountIdentifier{Address: testFrom}, ethereum.Currency, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "100", changes[0].Delta.Value)
+	assert.Equal(t, "-20900", changes[0].Balance.Value)
+
+	_, _, err = newTestIndexer(t, &mocks.Client{}, &Config{}).BalanceHistory(ctx, account, testToken, 0, 10)
+	assert.ErrorIs(t, err, ErrBalancesNotIndexed)
+}
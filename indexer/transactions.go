@@ -0,0 +1,363 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// transactionPrefix is the prefix of the keys of the
+	// transactions of the canonical chain, by position.
+	transactionPrefix = "tx/"
+
+	// searchPrefix is the prefix of the keys of the
+	// search indexes, by condition and position.
+	searchPrefix = "search/"
+
+	// positionDigits is the number of digits the index of a
+	// transaction in its block is padded to.
+	positionDigits = 6
+)
+
+// Search conditions transactions are indexed by.
+const (
+	searchAll      = "all"
+	searchHash     = "hash"
+	searchAddress  = "address"
+	searchType     = "type"
+	searchStatus   = "status"
+	searchCurrency = "currency"
+	searchSuccess  = "success"
+)
+
+// successfulStatuses are the operation statuses
+// of successful operations.
+var successfulStatuses = func() map[string]bool {
+	statuses := map[string]bool{}
+	for _, status := range ethereum.OperationStatuses {
+		statuses[status.Status] = status.Successful
+	}
+
+	return statuses
+}()
+
+// position returns the position of a transaction, which
+// sorts in the order of the chain.
+func position(blockIndex int64, txIndex int) string {
+	return fmt.Sprintf("%0*d/%0*d", keyDigits, blockIndex, positionDigits, txIndex)
+}
+
+// positionBlockIndex returns the index of the
+// block of the transaction at pos.
+func positionBlockIndex(pos string) (int64, error) {
+	return strconv.ParseInt(strings.SplitN(pos, "/", 2)[0], 10, 64)
+}
+
+func transactionKey(pos string) []byte {
+	return []byte(transactionPrefix + pos)
+}
+
+func blockTransactionsPrefix(blockIndex int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d/", transactionPrefix, keyDigits, blockIndex))
+}
+
+func searchKeyPrefix(condition string, value string) string {
+	return fmt.Sprintf("%s%s/%s/", searchPrefix, condition, value)
+}
+
+// currencyValue returns the search value of currency.
+func currencyValue(currency *types.Currency) string {
+	return types.Hash(currency)
+}
+
+// searchKeyPrefixes returns the prefixes of the
+// search index keys of transaction.
+func searchKeyPrefixes(transaction *types.Transaction) []string {
+	prefixes := map[string]struct{}{
+		searchKeyPrefix(searchAll, ""): {},
+		searchKeyPrefix(searchHash, strings.ToLower(transaction.TransactionIdentifier.Hash)): {},
+	}
+
+	success := true
+	for _, op := range transaction.Operations {
+		prefixes[searchKeyPrefix(searchType, op.Type)] = struct{}{}
+
+		if op.Account != nil {
+			prefixes[searchKeyPrefix(searchAddress, strings.ToLower(op.Account.Address))] = struct{}{}
+		}
+
+		if op.Amount != nil {
+			prefixes[searchKeyPrefix(searchCurrency, currencyValue(op.Amount.Currency))] = struct{}{}
+		}
+
+		if op.Status != nil {
+			prefixes[searchKeyPrefix(searchStatus, *op.Status)] = struct{}{}
+			success = success && successfulStatuses[*op.Status]
+		}
+	}
+	prefixes[searchKeyPrefix(searchSuccess, strconv.FormatBool(success))] = struct{}{}
+
+	sorted := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		sorted = append(sorted, prefix)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// addTransactions records the transactions of block
+// and their search index keys in txn.
+func addTransactions(
+	ctx context.Context,
+	txn database.Transaction,
+	block *types.Block,
+) error {
+	for i, transaction := range block.Transactions {
+		pos := position(block.BlockIdentifier.Index, i)
+		value, err := json.Marshal(&types.BlockTransaction{
+			BlockIdentifier: block.BlockIdentifier,
+			Transaction:     transaction,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(ctx, transactionKey(pos), value, false); err != nil {
+			return fmt.Errorf("%w: unable to store transaction %s", err, transaction.TransactionIdentifier.Hash)
+		}
+
+		for _, prefix := range searchKeyPrefixes(transaction) {
+			if err := txn.Set(ctx, []byte(prefix+pos), []byte{}, false); err != nil {
+				return fmt.Errorf("%w: unable to index transaction %s", err, transaction.TransactionIdentifier.Hash)
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeTransactions removes the transactions of the block
+// at blockIndex and their search index keys in txn.
+func removeTransactions(
+	ctx context.Context,
+	txn database.Transaction,
+	blockIndex int64,
+) error {
+	prefix := blockTransactionsPrefix(blockIndex)
+	keys := [][]byte{}
+	_, err := txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			var blockTransaction types.BlockTransaction
+			if err := json.Unmarshal(value, &blockTransaction); err != nil {
+				return err
+			}
+
+			pos := strings.TrimPrefix(string(key), transactionPrefix)
+			for _, searchPrefix := range searchKeyPrefixes(blockTransaction.Transaction) {
+				keys = append(keys, []byte(searchPrefix+pos))
+			}
+
+			keys = append(keys, append([]byte{}, key...))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get transactions of block %d", err, blockIndex)
+	}
+
+	for _, key := range keys {
+		if err := txn.Delete(ctx, key); err != nil {
+			return fmt.Errorf("%w: unable to remove transactions of block %d", err, blockIndex)
+		}
+	}
+
+	return nil
+}
+
+// searchPositions returns the positions of the
+// transactions indexed under prefix.
+func searchPositions(
+	ctx context.Context,
+	txn database.Transaction,
+	prefix string,
+) (map[string]struct{}, error) {
+	positions := map[string]struct{}{}
+	_, err := txn.Scan(
+		ctx,
+		[]byte(prefix),
+		[]byte(prefix),
+		func(key []byte, value []byte) error {
+			positions[strings.TrimPrefix(string(key), prefix)] = struct{}{}
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to search transactions", err)
+	}
+
+	return positions, nil
+}
+
+// searchConditions returns the search index key prefixes of the
+// conditions of request. An empty prefix matches no transaction.
+func searchConditions(request *types.SearchTransactionsRequest) []string {
+	conditions := []string{}
+	if request.TransactionIdentifier != nil {
+		conditions = append(
+			conditions,
+			searchKeyPrefix(searchHash, strings.ToLower(request.TransactionIdentifier.Hash)),
+		)
+	}
+
+	// Ethereum accounts have no sub-accounts.
+	if request.AccountIdentifier != nil {
+		if request.AccountIdentifier.SubAccount != nil {
+			conditions = append(conditions, "")
+		} else {
+			conditions = append(
+				conditions,
+				searchKeyPrefix(searchAddress, strings.ToLower(request.AccountIdentifier.Address)),
+			)
+		}
+	}
+
+	// Ethereum has no coins.
+	if request.CoinIdentifier != nil {
+		conditions = append(conditions, "")
+	}
+
+	if request.Currency != nil {
+		conditions = append(conditions, searchKeyPrefix(searchCurrency, currencyValue(request.Currency)))
+	}
+
+	if request.Status != nil {
+		conditions = append(conditions, searchKeyPrefix(searchStatus, *request.Status))
+	}
+
+	if request.Type != nil {
+		conditions = append(conditions, searchKeyPrefix(searchType, *request.Type))
+	}
+
+	if request.Address != nil {
+		conditions = append(conditions, searchKeyPrefix(searchAddress, strings.ToLower(*request.Address)))
+	}
+
+	if request.Success != nil {
+		conditions = append(conditions, searchKeyPrefix(searchSuccess, strconv.FormatBool(*request.Success)))
+	}
+
+	if len(conditions) == 0 {
+		conditions = append(conditions, searchKeyPrefix(searchAll, ""))
+	}
+
+	return conditions
+}
+
+// SearchTransactions returns up to limit of the transactions matching
+// the conditions of request (all of them, unless its operator is
+// "or") in blocks up to its max block, most recent first, starting at
+// offset. It also returns the number of matching transactions.
+func (i *Indexer) SearchTransactions(
+	ctx context.Context,
+	request *types.SearchTransactionsRequest,
+	offset int64,
+	limit int64,
+) (int64, []*types.BlockTransaction, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	var matches map[string]struct{}
+	for _, condition := range searchConditions(request) {
+		positions := map[string]struct{}{}
+		if len(condition) > 0 {
+			var err error
+			positions, err = searchPositions(ctx, txn, condition)
+			if err != nil {
+				return -1, nil, err
+			}
+		}
+
+		switch {
+		case matches == nil:
+			matches = positions
+		case request.Operator != nil && *request.Operator == types.OR:
+			for pos := range positions {
+				matches[pos] = struct{}{}
+			}
+		default:
+			for pos := range matches {
+				if _, ok := positions[pos]; !ok {
+					delete(matches, pos)
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(matches))
+	for pos := range matches {
+		if request.MaxBlock != nil {
+			blockIndex, err := positionBlockIndex(pos)
+			if err != nil {
+				return -1, nil, fmt.Errorf("%w: unable to parse position %s", err, pos)
+			}
+
+			if blockIndex > *request.MaxBlock {
+				continue
+			}
+		}
+
+		sorted = append(sorted, pos)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	transactions := []*types.BlockTransaction{}
+	for j := offset; j < int64(len(sorted)) && j < offset+limit; j++ {
+		exists, value, err := txn.Get(ctx, transactionKey(sorted[j]))
+		if err != nil {
+			return -1, nil, fmt.Errorf("%w: unable to get transaction %s", err, sorted[j])
+		}
+		if !exists {
+			return -1, nil, fmt.Errorf("transaction %s is not indexed", sorted[j])
+		}
+
+		var transaction types.BlockTransaction
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return -1, nil, err
+		}
+
+		transactions = append(transactions, &transaction)
+	}
+
+	return int64(len(sorted)), transactions, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFrom = "0x5aCB42b3cfCD734a57AFF800C57F4F5d3A11a9f1"
+	testTo   = "0x4Cdc6E1A3B6fB9dF4C6d7e0E1D3cF5ce4A15E7E4"
+)
+
+var testToken = &types.Currency{
+	Symbol:   "USDC",
+	Decimals: 6,
+	Metadata: map[string]interface{}{
+		"contract_address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+	},
+}
+
+// testTransaction returns a transaction of hash with a
+// fee paid by testFrom and an operation of opType from
+// testFrom to testTo in currency with status.
+func testTransaction(
+	hash string,
+	opType string,
+	status string,
+	currency *types.Currency,
+) *types.Transaction {
+	success := ethereum.SuccessStatus
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                ethereum.FeeOpType,
+				Status:              &success,
+				Account:             &types.AccountIdentifier{Address: testFrom},
+				Amount:              &types.Amount{Value: "-21000", Currency: ethereum.Currency},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				Type:                opType,
+				Status:              &status,
+				Account:             &types.AccountIdentifier{Address: testFrom},
+				Amount:              &types.Amount{Value: "-1", Currency: currency},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 2},
+				Type:                opType,
+				Status:              &status,
+				Account:             &types.AccountIdentifier{Address: testTo},
+				Amount:              &types.Amount{Value: "1", Currency: currency},
+			},
+		},
+	}
+}
+
+func testTransactionBlock(index int64, branch string, transactions ...*types.Transaction) *types.Block {
+	block := testBlock(index, branch, "a")
+	block.Transactions = transactions
+	return block
+}
+
+func hashes(transactions []*types.BlockTransaction) []string {
+	hashes := make([]string, len(transactions))
+	for i, transaction := range transactions {
+		hashes[i] = transaction.Transaction.TransactionIdentifier.Hash
+	}
+
+	return hashes
+}
+
+func TestSearchTransactions(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Transactions: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		1,
+		"a",
+		testTransaction("0x1a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+		testTransaction("0x1b", ethereum.CallOpType, ethereum.FailureStatus, ethereum.Currency),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, testToken),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		3,
+		"a",
+		testTransaction("0x3a", ethereum.CreateOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+
+	or := types.OR
+	maxBlock := int64(2)
+	callType := ethereum.CallOpType
+	createType := ethereum.CreateOpType
+	failure := ethereum.FailureStatus
+	address := "0x4cdc6e1a3b6fb9df4c6d7e0e1d3cf5ce4a15e7e4"
+	success := false
+
+	tests := map[string]struct {
+		request *types.SearchTransactionsRequest
+		offset  int64
+		limit   int64
+
+		expectedCount  int64
+		expectedHashes []string
+	}{
+		"all": {
+			request:        &types.SearchTransactionsRequest{},
+			limit:          10,
+			expectedCount:  4,
+			expectedHashes: []string{"0x3a", "0x2a", "0x1b", "0x1a"},
+		},
+		"page": {
+			request:        &types.SearchTransactionsRequest{},
+			offset:         1,
+			limit:          2,
+			expectedCount:  4,
+			expectedHashes: []string{"0x2a", "0x1b"},
+		},
+		"max block": {
+			request:        &types.SearchTransactionsRequest{MaxBlock: &maxBlock},
+			limit:          10,
+			expectedCount:  3,
+			expectedHashes: []string{"0x2a", "0x1b", "0x1a"},
+		},
+		"hash": {
+			request: &types.SearchTransactionsRequest{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "0x1B"},
+			},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x1b"},
+		},
+		"address": {
+			request:        &types.SearchTransactionsRequest{Address: &address},
+			limit:          10,
+			expectedCount:  4,
+			expectedHashes: []string{"0x3a", "0x2a", "0x1b", "0x1a"},
+		},
+		"account": {
+			request: &types.SearchTransactionsRequest{
+				AccountIdentifier: &types.AccountIdentifier{Address: testFrom},
+				Type:              &createType,
+			},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x3a"},
+		},
+		"sub-account": {
+			request: &types.SearchTransactionsRequest{
+				AccountIdentifier: &types.AccountIdentifier{
+					Address:    testFrom,
+					SubAccount: &types.SubAccountIdentifier{Address: "stake"},
+				},
+			},
+			limit:          10,
+			expectedCount:  0,
+			expectedHashes: []string{},
+		},
+		"coin": {
+			request: &types.SearchTransactionsRequest{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: "0x1a:0"},
+			},
+			limit:          10,
+			expectedCount:  0,
+			expectedHashes: []string{},
+		},
+		"currency": {
+			request: &types.SearchTransactionsRequest{
+				Currency: &types.Currency{
+					Symbol:   "USDC",
+					Decimals: 6,
+					Metadata: map[string]interface{}{
+						"contract_address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+					},
+				},
+			},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x2a"},
+		},
+		"status": {
+			request:        &types.SearchTransactionsRequest{Status: &failure},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x1b"},
+		},
+		"success": {
+			request:        &types.SearchTransactionsRequest{Success: &success},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x1b"},
+		},
+		"and": {
+			request: &types.SearchTransactionsRequest{
+				Type:    &callType,
+				Success: &success,
+			},
+			limit:          10,
+			expectedCount:  1,
+			expectedHashes: []string{"0x1b"},
+		},
+		"or": {
+			request: &types.SearchTransactionsRequest{
+				Operator: &or,
+				Type:     &createType,
+				Success:  &success,
+			},
+			limit:          10,
+			expectedCount:  2,
+			expectedHashes: []string{"0x3a", "0x1b"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			count, transactions, err := i.SearchTransactions(ctx, test.request, test.offset, test.limit)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCount, count)
+			assert.Equal(t, test.expectedHashes, hashes(transactions))
+		})
+	}
+
+	// Transactions are returned with their block.
+	_, transactions, err := i.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "0x2a"},
+	}, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.BlockTransaction{
+		{
+			BlockIdentifier: testBlock(2, "a", "a").BlockIdentifier,
+			Transaction:     testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, testToken),
+		},
+	}, transactions)
+}
+
+func TestSearchTransactions_Reorg(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndexer(t, &mocks.Client{}, &Config{Transactions: true})
+
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		1,
+		"a",
+		testTransaction("0x1a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"a",
+		testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, testToken),
+	)))
+
+	// Transactions of removed blocks are no longer indexed.
+	assert.NoError(t, i.removeBlock(ctx, testBlock(2, "a", "a").BlockIdentifier))
+	assert.NoError(t, i.addBlock(ctx, testTransactionBlock(
+		2,
+		"b",
+		testTransaction("0x2b", ethereum.CreateOpType, ethereum.SuccessStatus, ethereum.Currency),
+	)))
+
+	count, transactions, err := i.SearchTransactions(ctx, &types.SearchTransactionsRequest{}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, []string{"0x2b", "0x1a"}, hashes(transactions))
+
+	count, _, err = i.SearchTransactions(ctx, &types.SearchTransactionsRequest{Currency: testToken}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, _, err = i.SearchTransactions(ctx, &types.SearchTransactionsRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "0x2a"},
+	}, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
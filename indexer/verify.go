@@ -0,0 +1,472 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// Kinds of discrepancies between the index and the node.
+const (
+	// DiscrepancyBlock is an indexed block that is not the
+	// block of the node at its index (a missed reorg).
+	DiscrepancyBlock = "block"
+
+	// DiscrepancyTransactions is a block whose indexed
+	// transactions are not those of the node.
+	DiscrepancyTransactions = "transactions"
+
+	// DiscrepancyBalances is a block whose indexed balance
+	// changes are not derived from its operations, or do
+	// not add up to the balances snapshotted with them.
+	DiscrepancyBalances = "balances"
+
+	// DiscrepancyTokenTransfers is a block whose indexed
+	// token transfers are not derived from its operations.
+	DiscrepancyTokenTransfers = "token_transfers"
+)
+
+// VerifyOptions determines which blocks Verify checks.
+type VerifyOptions struct {
+	// StartIndex and EndIndex are the range of blocks to
+	// check (by default, all the blocks in the index).
+	StartIndex *int64
+	EndIndex   *int64
+
+	// Sample is the number of blocks picked at random in
+	// the range to check (0 to check all of them).
+	Sample int64
+
+	// Repair is whether the blocks from the first
+	// discrepancy onwards are removed, so they are synced
+	// again.
+	Repair bool
+}
+
+// Discrepancy is a difference between the index and the node.
+type Discrepancy struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Kind            string                 `json:"kind"`
+	Details         string                 `json:"details"`
+}
+
+// VerifyReport is the summary of a run of Verify.
+type VerifyReport struct {
+	StartIndex    int64          `json:"start_index"`
+	EndIndex      int64          `json:"end_index"`
+	CheckedBlocks int64          `json:"checked_blocks"`
+	Discrepancies []*Discrepancy `json:"discrepancies"`
+
+	// RepairedFrom is the index of the first block removed
+	// by the repair, if any. The blocks from it onwards are
+	// synced again the next time the indexer runs.
+	RepairedFrom *int64 `json:"repaired_from,omitempty"`
+}
+
+// Verify re-derives the blocks of the index selected by options
+// from the node, and checks that their headers, transactions,
+// balance changes and token transfers (those that are indexed)
+// match. Blocks whose transactions were pruned are only checked
+// for their headers and balance changes. With Repair, the blocks
+// from the first discrepancy onwards (from where the index forked
+// off the node, for missed reorgs) are removed with block_removed
+// events, like in a reorg, so they are synced again. The indexer
+// must not be syncing.
+func (i *Indexer) Verify(ctx context.Context, options *VerifyOptions) (*VerifyReport, error) {
+	first, err := i.firstBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, fmt.Errorf("%w: the index is empty", ErrBlockNotFound)
+	}
+
+	head, err := i.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		StartIndex:    first.Index,
+		EndIndex:      head.BlockIdentifier.Index,
+		Discrepancies: []*Discrepancy{},
+	}
+	if options.StartIndex != nil && *options.StartIndex > report.StartIndex {
+		report.StartIndex = *options.StartIndex
+	}
+	if options.EndIndex != nil && *options.EndIndex < report.EndIndex {
+		report.EndIndex = *options.EndIndex
+	}
+	if report.StartIndex > report.EndIndex {
+		return nil, fmt.Errorf("invalid range %d to %d", report.StartIndex, report.EndIndex)
+	}
+
+	for _, index := range verifyIndices(report.StartIndex, report.EndIndex, options.Sample) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		discrepancies, err := i.verifyBlock(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+
+		report.CheckedBlocks++
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+
+	if !options.Repair || len(report.Discrepancies) == 0 {
+		return report, nil
+	}
+
+	// Discrepancies are found in the order of the chain.
+	from := report.Discrepancies[0].BlockIdentifier.Index
+	if report.Discrepancies[0].Kind == DiscrepancyBlock {
+		from, err = i.forkIndex(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.rewind(ctx, from); err != nil {
+		return nil, err
+	}
+	report.RepairedFrom = &from
+
+	return report, nil
+}
+
+// verifyIndices returns the indices of the blocks to check
+// from start to end: sample of them picked at random (all of
+// them if sample is 0), in ascending order.
+func verifyIndices(start int64, end int64, sample int64) []int64 {
+	count := end - start + 1
+	if sample <= 0 || sample >= count {
+		indices := make([]int64, 0, count)
+		for index := start; index <= end; index++ {
+			indices = append(indices, index)
+		}
+
+		return indices
+	}
+
+	picked := map[int64]struct{}{}
+	for int64(len(picked)) < sample {
+		picked[start+rand.Int63n(count)] = struct{}{} // #nosec G404
+	}
+
+	indices := make([]int64, 0, sample)
+	for index := range picked {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(a, b int) bool { return indices[a] < indices[b] })
+
+	return indices
+}
+
+// verifyBlock returns the discrepancies between the
+// block at index in the index and in the node.
+func (i *Indexer) verifyBlock(ctx context.Context, index int64) ([]*Discrepancy, error) {
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	indexed, err := header(ctx, txn, index)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := (&syncHelper{indexer: i}).Block(
+		ctx,
+		i.network,
+		&types.PartialBlockIdentifier{Index: &index},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get block %d from the node", err, index)
+	}
+
+	if types.Hash(indexed.BlockIdentifier) != types.Hash(block.BlockIdentifier) {
+		return []*Discrepancy{{
+			BlockIdentifier: indexed.BlockIdentifier,
+			Kind:            DiscrepancyBlock,
+			Details:         fmt.Sprintf("the node has block %s", block.BlockIdentifier.Hash),
+		}}, nil
+	}
+
+	discrepancies := []*Discrepancy{}
+	discrepancy := func(kind string, format string, args ...interface{}) {
+		discrepancies = append(discrepancies, &Discrepancy{
+			BlockIdentifier: indexed.BlockIdentifier,
+			Kind:            kind,
+			Details:         fmt.Sprintf(format, args...),
+		})
+	}
+
+	pruned, err := prunedIndex(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.config.Transactions && index > pruned {
+		transactions, err := i.Block(ctx, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return nil, err
+		}
+
+		if types.Hash(transactions.Transactions) != types.Hash(block.Transactions) {
+			discrepancy(
+				DiscrepancyTransactions,
+				"%d transactions are indexed instead of %d",
+				len(transactions.Transactions),
+				len(block.Transactions),
+			)
+		}
+	}
+
+	if i.config.Balances {
+		details, err := i.verifyBalances(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(details) > 0 {
+			discrepancy(DiscrepancyBalances, "%s", details)
+		}
+	}
+
+	if i.config.TokenTransfers && index > pruned {
+		details, err := i.verifyTokenTransfers(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(details) > 0 {
+			discrepancy(DiscrepancyTokenTransfers, "%s", details)
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// verifyBalances returns what is wrong with the indexed
+// balance changes of block (nothing if they are right).
+func (i *Indexer) verifyBalances(ctx context.Context, block *types.Block) (string, error) {
+	deltas, err := balanceDeltas(block)
+	if err != nil {
+		return "", err
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	index := block.BlockIdentifier.Index
+	prefix := blockChangesPrefix(index)
+	accounts := []string{}
+	_, err = txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			accounts = append(accounts, strings.TrimPrefix(string(key), string(prefix)))
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to get balance changes of block %d", err, index)
+	}
+
+	expected := 0
+	for _, delta := range deltas {
+		if delta.Sign() != 0 {
+			expected++
+		}
+	}
+	if len(accounts) != expected {
+		return fmt.Sprintf("%d balance changes are indexed instead of %d", len(accounts), expected), nil
+	}
+
+	for _, account := range accounts {
+		parts := strings.SplitN(account, "/", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("invalid balance change key %s", account)
+		}
+		address, currency := parts[0], parts[1]
+
+		exists, value, err := txn.Get(ctx, balanceKey(address, currency, index))
+		if err != nil {
+			return "", fmt.Errorf("%w: unable to get balance of %s", err, address)
+		}
+		if !exists {
+			return fmt.Sprintf("the balance change of %s is missing", address), nil
+		}
+
+		var change balanceChange
+		if err := json.Unmarshal(value, &change); err != nil {
+			return "", err
+		}
+
+		delta, ok := deltas[account]
+		if !ok {
+			return fmt.Sprintf("the balance of %s does not change", address), nil
+		}
+		if delta.String() != change.Delta {
+			return fmt.Sprintf("the balance of %s changes by %s instead of %s", address, change.Delta, delta), nil
+		}
+
+		previous, err := balanceAt(ctx, txn, address, currency, index-1)
+		if err != nil {
+			return "", err
+		}
+
+		if balance := previous.Add(previous, delta); balance.String() != change.Balance {
+			return fmt.Sprintf("the balance of %s is %s instead of %s", address, change.Balance, balance), nil
+		}
+	}
+
+	return "", nil
+}
+
+// verifyTokenTransfers returns what is wrong with the indexed
+// token transfers of block (nothing if they are right).
+func (i *Indexer) verifyTokenTransfers(ctx context.Context, block *types.Block) (string, error) {
+	expected := []*TokenTransfer{}
+	for _, transaction := range block.Transactions {
+		transfers, err := tokenTransfers(transaction)
+		if err != nil {
+			return "", err
+		}
+
+		for _, transfer := range transfers {
+			transfer.BlockIdentifier = block.BlockIdentifier
+			expected = append(expected, transfer)
+		}
+	}
+
+	txn := i.db.ReadTransaction(ctx)
+	defer txn.Discard(ctx)
+
+	index := block.BlockIdentifier.Index
+	prefix := blockTransfersPrefix(index)
+	indexed := []*TokenTransfer{}
+	_, err := txn.Scan(
+		ctx,
+		prefix,
+		prefix,
+		func(key []byte, value []byte) error {
+			var transfer TokenTransfer
+			if err := json.Unmarshal(value, &transfer); err != nil {
+				return err
+			}
+
+			indexed = append(indexed, &transfer)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to get transfers of block %d", err, index)
+	}
+
+	if types.Hash(indexed) != types.Hash(expected) {
+		return fmt.Sprintf("%d token transfers are indexed instead of %d", len(indexed), len(expected)), nil
+	}
+
+	return "", nil
+}
+
+// forkIndex returns the index of the first block the index
+// has in common with the node, walking back from the block at
+// index that the node does not have.
+func (i *Indexer) forkIndex(ctx context.Context, index int64) (int64, error) {
+	for ; index > 0; index-- {
+		txn := i.db.ReadTransaction(ctx)
+		parent, err := header(ctx, txn, index-1)
+		txn.Discard(ctx)
+		if errors.Is(err, ErrBlockNotFound) {
+			return index, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+
+		parentIndex := index - 1
+		block, err := i.client.BlockHeader(ctx, &types.PartialBlockIdentifier{Index: &parentIndex})
+		if err != nil {
+			return -1, fmt.Errorf("%w: unable to get block %d from the node", err, parentIndex)
+		}
+
+		if types.Hash(parent.BlockIdentifier) == types.Hash(block.BlockIdentifier) {
+			return index, nil
+		}
+	}
+
+	return index, nil
+}
+
+// rewind removes the blocks from the head of the index down
+// to the block at index, so they are synced again. If their
+// transactions were pruned, the last pruned block is moved
+// before them, so the synced transactions are pruned again.
+func (i *Indexer) rewind(ctx context.Context, index int64) error {
+	for {
+		head, err := i.Head(ctx)
+		if errors.Is(err, ErrBlockNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if head.BlockIdentifier.Index < index {
+			break
+		}
+
+		if err := i.removeBlock(ctx, head.BlockIdentifier); err != nil {
+			return err
+		}
+	}
+
+	txn := i.db.Transaction(ctx)
+	defer txn.Discard(ctx)
+
+	pruned, err := prunedIndex(ctx, txn)
+	if err != nil {
+		return err
+	}
+	if pruned < index {
+		return nil
+	}
+
+	if index == 0 {
+		if err := txn.Delete(ctx, []byte(prunedKey)); err != nil {
+			return fmt.Errorf("%w: unable to store pruned block", err)
+		}
+	} else if err := txn.Set(ctx, []byte(prunedKey), []byte(strconv.FormatInt(index-1, 10)), false); err != nil {
+		return fmt.Errorf("%w: unable to store pruned block", err)
+	}
+
+	return txn.Commit(ctx)
+}
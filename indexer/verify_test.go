@@ -0,0 +1,112 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	blocks := []*types.Block{
+		testTransactionBlock(
+			1,
+			"a",
+			testReward("0x1r", "1000000"),
+			testTokenTransaction("0x1a", "10"),
+		),
+		testTransactionBlock(
+			2,
+			"a",
+			testTransaction("0x2a", ethereum.CallOpType, ethereum.SuccessStatus, ethereum.Currency),
+		),
+		testTransactionBlock(3, "a", testReward("0x3r", "100")),
+	}
+
+	chain := &testChain{}
+	chain.set(blocks...)
+	i := newTestIndexer(t, chain.mock(), &Config{
+		Transactions:   true,
+		Balances:       true,
+		TokenTransfers: true,
+	})
+	for _, block := range blocks {
+		assert.NoError(t, i.addBlock(ctx, block))
+	}
+
+	report, err := i.Verify(ctx, &VerifyOptions{Repair: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &VerifyReport{
+		StartIndex:    1,
+		EndIndex:      3,
+		CheckedBlocks: 3,
+		Discrepancies: []*Discrepancy{},
+	}, report)
+
+	report, err = i.Verify(ctx, &VerifyOptions{Sample: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), report.CheckedBlocks)
+	assert.Empty(t, report.Discrepancies)
+
+	// The operations of block 2 were indexed wrongly.
+	chain.set(
+		blocks[0],
+		testTransactionBlock(
+			2,
+			"a",
+			testTransaction("0x2a", ethereum.CallOpType, ethereum.FailureStatus, ethereum.Currency),
+		),
+		blocks[2],
+	)
+	start := int64(2)
+	report, err = i.Verify(ctx, &VerifyOptions{StartIndex: &start})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), report.CheckedBlocks)
+	assert.Len(t, report.Discrepancies, 2)
+	assert.Equal(t, DiscrepancyTransactions, report.Discrepancies[0].Kind)
+	assert.Equal(t, DiscrepancyBalances, report.Discrepancies[1].Kind)
+	assert.Equal(t, int64(2), report.Discrepancies[0].BlockIdentifier.Index)
+	assert.Nil(t, report.RepairedFrom)
+
+	// Blocks 2 and 3 were reorged out without the index
+	// following, and the blocks from 2 are removed.
+	chain.set(blocks[0], testBlock(2, "b", "a"), testBlock(3, "b", "b"))
+	end := int64(2)
+	report, err = i.Verify(ctx, &VerifyOptions{EndIndex: &end, Repair: true})
+	assert.NoError(t, err)
+	assert.Len(t, report.Discrepancies, 1)
+	assert.Equal(t, &Discrepancy{
+		BlockIdentifier: blocks[1].BlockIdentifier,
+		Kind:            DiscrepancyBlock,
+		Details:         "the node has block 2b",
+	}, report.Discrepancies[0])
+	assert.Equal(t, int64(2), *report.RepairedFrom)
+
+	head, err := i.Head(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[0].BlockIdentifier, head.BlockIdentifier)
+
+	maxSequence, events, err := i.BlockEvents(ctx, 3, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), maxSequence)
+	assert.Equal(t, types.REMOVED, events[0].Type)
+	assert.Equal(t, blocks[2].BlockIdentifier, events[0].BlockIdentifier)
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// RequestIDHeader is the header carrying the request ID,
+	// in both requests (to reuse the ID of a proxy) and
+	// responses.
+	RequestIDHeader = "X-Request-ID"
+
+	// RequestIDKey is the key of the request ID in the logs
+	// and in the details of the errors returned.
+	RequestIDKey = "request_id"
+
+	// maxRequestIDLength is the longest request
+	// ID accepted in RequestIDHeader.
+	maxRequestIDLength = 64
+
+	// maxLoggedRequestSize is the largest request
+	// body whose fields are logged.
+	maxLoggedRequestSize = 1 << 20
+)
+
+// slowRequestThreshold is the duration (in nanoseconds)
+// above which requests are logged as slow (never if 0).
+var slowRequestThreshold int64
+
+// SetSlowRequestThreshold logs the requests served by
+// Middleware that take longer than threshold as slow, and
+// counts them in metrics (0 to stop).
+func SetSlowRequestThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&slowRequestThreshold, int64(threshold))
+}
+
+// annotationsKey is the context key of
+// the annotations of a request.
+type annotationsKey struct{}
+
+// annotations are the fields added to the
+// access log of a request while serving it.
+type annotations struct {
+	mu     sync.Mutex
+	fields []zap.Field
+}
+
+// Annotate adds fields to the access log of the request served
+// with ctx (i.e. the size of the traces fetched to serve it),
+// replacing any earlier field with the same key. It does nothing
+// if ctx is not the context of a request served by Middleware.
+func Annotate(ctx context.Context, fields ...zap.Field) {
+	a, ok := ctx.Value(annotationsKey{}).(*annotations)
+	if !ok {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, field := range fields {
+		replaced := false
+		for i := range a.fields {
+			if a.fields[i].Key == field.Key {
+				a.fields[i] = field
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			a.fields = append(a.fields, field)
+		}
+	}
+}
+
+// requestID returns the request ID of r: the ID in its
+// RequestIDHeader if it is valid, or a new random ID.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return newRequestID()
+	}
+
+	// Only printable ASCII without spaces or quotes is accepted,
+	// so that IDs cannot forge log lines or break error details.
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '"' || id[i] == '\\' {
+			return newRequestID()
+		}
+	}
+
+	return id
+}
+
+// rosettaRequest holds the fields of Rosetta
+// requests recorded in access logs.
+type rosettaRequest struct {
+	NetworkIdentifier *struct {
+		Network string `json:"network"`
+	} `json:"network_identifier"`
+
+	BlockIdentifier *struct {
+		Index *int64  `json:"index"`
+		Hash  *string `json:"hash"`
+	} `json:"block_identifier"`
+}
+
+// requestFields returns the network and block identifier
+// of body, if it is a Rosetta request.
+func requestFields(body []byte) []zap.Field {
+	var request rosettaRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return []zap.Field{zap.String("network", "")}
+	}
+
+	network := ""
+	if request.NetworkIdentifier != nil {
+		network = request.NetworkIdentifier.Network
+	}
+
+	fields := []zap.Field{zap.String("network", network)}
+
+	if block := request.BlockIdentifier; block != nil {
+		if block.Index != nil {
+			fields = append(fields, zap.Int64("block_index", *block.Index))
+		}
+		if block.Hash != nil {
+			fields = append(fields, zap.String("block_hash", *block.Hash))
+		}
+	}
+
+	return fields
+}
+
+// readCloser reads from a Reader
+// and closes with a Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// accessRecorder records the status code written by a
+// http.Handler, and buffers the body of error responses
+// so that the request ID can be added to it.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+
+	// errorBody is the body of an error response
+	// (nil for other responses).
+	errorBody *bytes.Buffer
+}
+
+func (r *accessRecorder) WriteHeader(status int) {
+	r.status = status
+	if status >= http.StatusBadRequest {
+		r.errorBody = &bytes.Buffer{}
+		return
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	if r.errorBody != nil {
+		return r.errorBody.Write(b)
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// annotateError returns body with id added to its details and
+// the code of the error, if body is a Rosetta error. Otherwise,
+// body is returned as-is.
+func annotateError(body []byte, id string) ([]byte, *int64) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var rosettaErr map[string]interface{}
+	if err := decoder.Decode(&rosettaErr); err != nil {
+		return body, nil
+	}
+
+	number, ok := rosettaErr["code"].(json.Number)
+	if !ok {
+		return body, nil
+	}
+
+	code, err := number.Int64()
+	if err != nil {
+		return body, nil
+	}
+
+	details, ok := rosettaErr["details"].(map[string]interface{})
+	if !ok {
+		details = map[string]interface{}{}
+	}
+	details[RequestIDKey] = id
+	rosettaErr["details"] = details
+
+	annotated, err := json.Marshal(rosettaErr)
+	if err != nil {
+		return body, &code
+	}
+
+	return append(annotated, '\n'), &code
+}
+
+// Middleware logs each request with its request ID, method,
+// path (the Rosetta endpoint), network, block identifier (if
+// any and the request is at most 1 MiB), status, Rosetta error code (if any), duration and any
+// annotations. The request context carries a logger with the
+// request ID so that logs written while serving the request can
+// be correlated. The request ID is returned in RequestIDHeader
+// and added to the details of Rosetta errors, so that users can
+// quote it when reporting issues. Requests slower than the
+// threshold set with SetSlowRequestThreshold are also logged as
+// warnings.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r)
+		logger := zap.L().With(zap.String(RequestIDKey, id))
+		w.Header().Set(RequestIDHeader, id)
+
+		requestLog := []zap.Field{zap.String("network", "")}
+		if r.Body != nil && r.Method == http.MethodPost {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLoggedRequestSize+1))
+			if err != nil {
+				r.Body.Close() // nolint:errcheck
+				logger.Warn("unable to read request", zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if len(body) > maxLoggedRequestSize {
+				// Larger requests are passed on as they are
+				// read, so that next can limit their size.
+				r.Body = &readCloser{
+					Reader: io.MultiReader(bytes.NewReader(body), r.Body),
+					Closer: r.Body,
+				}
+			} else {
+				r.Body.Close() // nolint:errcheck
+				requestLog = requestFields(body)
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		a := &annotations{}
+		ctx := context.WithValue(WithContext(r.Context(), logger), annotationsKey{}, a)
+		recorder := &accessRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		fields := append([]zap.Field{
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		}, requestLog...)
+		fields = append(fields, zap.Int("status", recorder.status))
+
+		if recorder.errorBody != nil {
+			body, code := annotateError(recorder.errorBody.Bytes(), id)
+			if code != nil {
+				fields = append(fields, zap.Int64("error_code", *code))
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(recorder.status)
+			if _, err := w.Write(body); err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+		}
+
+		duration := time.Since(start)
+		fields = append(fields, zap.Duration("duration", duration))
+
+		a.mu.Lock()
+		fields = append(fields, a.fields...)
+		a.mu.Unlock()
+
+		logger.Info("request", fields...)
+
+		threshold := time.Duration(atomic.LoadInt64(&slowRequestThreshold))
+		if threshold > 0 && duration > threshold {
+			metrics.SlowRequest(r.URL.Path, recorder.status)
+			logger.Warn("slow request", append(fields, zap.Duration("threshold", threshold))...)
+		}
+	})
+}
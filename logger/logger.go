@@ -0,0 +1,139 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// JSONFormat writes one JSON object per log entry.
+	JSONFormat = "json"
+
+	// ConsoleFormat writes human-readable log entries.
+	ConsoleFormat = "console"
+
+	// DefaultLevel is used when no level is provided.
+	DefaultLevel = "info"
+
+	// DefaultFormat is used when no format is provided.
+	DefaultFormat = ConsoleFormat
+
+	// requestIDBytes is the number of random
+	// bytes in each request ID.
+	requestIDBytes = 8
+)
+
+type contextKey struct{}
+
+// level is shared by all loggers created with Init
+// so that it can be changed while running.
+var level = zap.NewAtomicLevel()
+
+// ParseLevel returns an error if text is not
+// a valid log level (i.e. `debug`, `info`).
+func ParseLevel(text string) (zapcore.Level, error) {
+	if len(text) == 0 {
+		text = DefaultLevel
+	}
+
+	return zapcore.ParseLevel(text)
+}
+
+// ValidateFormat returns an error if format
+// is not JSONFormat or ConsoleFormat.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", JSONFormat, ConsoleFormat:
+		return nil
+	default:
+		return fmt.Errorf("%s is not a valid log format", format)
+	}
+}
+
+// Init replaces the global zap logger with one writing
+// to stderr at levelText in format. Empty values fall
+// back to DefaultLevel and DefaultFormat.
+func Init(levelText string, format string) error {
+	if err := SetLevel(levelText); err != nil {
+		return err
+	}
+
+	if err := ValidateFormat(format); err != nil {
+		return err
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = level
+	cfg.Sampling = nil
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if format != JSONFormat {
+		cfg.Encoding = ConsoleFormat
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+
+	logger, err := cfg.Build()
+	if err != nil {
+		return fmt.Errorf("%w: unable to build logger", err)
+	}
+
+	zap.ReplaceGlobals(logger)
+	return nil
+}
+
+// SetLevel changes the level of all loggers
+// created with Init.
+func SetLevel(levelText string) error {
+	l, err := ParseLevel(levelText)
+	if err != nil {
+		return err
+	}
+
+	level.SetLevel(l)
+	return nil
+}
+
+// WithContext returns a copy of ctx carrying logger.
+func WithContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx
+// or, if there is none, the global logger.
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+			return logger
+		}
+	}
+
+	return zap.L()
+}
+
+// newRequestID returns a random hex-encoded request ID.
+func newRequestID() string {
+	b := make([]byte, requestIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseLevel(t *testing.T) {
+	var tests = map[string]struct {
+		text  string
+		level zapcore.Level
+		err   bool
+	}{
+		"empty": {
+			level: zapcore.InfoLevel,
+		},
+		"debug": {
+			text:  "debug",
+			level: zapcore.DebugLevel,
+		},
+		"error": {
+			text:  "error",
+			level: zapcore.ErrorLevel,
+		},
+		"invalid": {
+			text: "loud",
+			err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			level, err := ParseLevel(test.text)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.level, level)
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, ValidateFormat(""))
+	assert.NoError(t, ValidateFormat(JSONFormat))
+	assert.NoError(t, ValidateFormat(ConsoleFormat))
+	assert.Error(t, ValidateFormat("xml"))
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, zap.L(), FromContext(context.Background()))
+
+	logger := zap.NewNop()
+	ctx := WithContext(context.Background(), logger)
+	assert.Equal(t, logger, FromContext(ctx))
+}
+
+func TestMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	var requestLogger *zap.Logger
+	var requestBody []byte
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestLogger = FromContext(r.Context())
+		requestBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	body := `{"network_identifier":{"blockchain":"Ethereum","network":"Mainnet"}}`
+	req := httptest.NewRequest(http.MethodPost, "/block", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.NotEqual(t, zap.L(), requestLogger)
+	assert.Equal(t, body, string(requestBody))
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "request", entries[0].Message)
+	assert.Equal(t, http.MethodPost, fields["method"])
+	assert.Equal(t, "/block", fields["path"])
+	assert.Equal(t, "Mainnet", fields["network"])
+	assert.Equal(t, int64(http.StatusTeapot), fields["status"])
+	assert.Len(t, fields[RequestIDKey], 2*requestIDBytes)
+	assert.Equal(t, fields[RequestIDKey], recorder.Header().Get(RequestIDHeader))
+	assert.NotContains(t, fields, "error_code")
+}
+
+func TestMiddleware_LargeRequest(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	var requestBody []byte
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ = ioutil.ReadAll(r.Body)
+	}))
+
+	body := `{"network_identifier":{"network":"Mainnet"},"data":"` +
+		strings.Repeat("x", maxLoggedRequestSize) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/call", strings.NewReader(body))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, body, string(requestBody))
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "", entries[0].ContextMap()["network"])
+}
+
+func TestMiddleware_Errors(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/block":
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":2,"message":"geth error","retriable":false,"details":{"context":"timeout","gas":12345678901234567890}}` + "\n")) // nolint:errcheck
+		case "/network/list":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":1,"message":"Endpoint unavailable offline","retriable":false}`)) // nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	// Rosetta errors carry the request ID in their details.
+	req := httptest.NewRequest(http.MethodPost, "/block", strings.NewReader("{}"))
+	req.Header.Set(RequestIDHeader, "proxy-id-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "proxy-id-1", recorder.Header().Get(RequestIDHeader))
+	assert.JSONEq(t, `{
+		"code": 2,
+		"message": "geth error",
+		"retriable": false,
+		"details": {"context": "timeout", "gas": 12345678901234567890, "request_id": "proxy-id-1"}
+	}`, recorder.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/network/list", strings.NewReader("{}"))
+	req.Header.Set(RequestIDHeader, "not a valid id")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	id := recorder.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 2*requestIDBytes)
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"code": 1,
+		"message": "Endpoint unavailable offline",
+		"retriable": false,
+		"details": {"request_id": "%s"}
+	}`, id), recorder.Body.String())
+
+	// Other errors are returned as-is.
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "404 page not found\n", recorder.Body.String())
+
+	entries := logs.All()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "proxy-id-1", entries[0].ContextMap()[RequestIDKey])
+	assert.Equal(t, int64(2), entries[0].ContextMap()["error_code"])
+	assert.Equal(t, int64(1), entries[1].ContextMap()["error_code"])
+	assert.Equal(t, int64(http.StatusNotFound), entries[2].ContextMap()["status"])
+	assert.NotContains(t, entries[2].ContextMap(), "error_code")
+}
+
+func TestMiddleware_Slow(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	SetSlowRequestThreshold(10 * time.Millisecond)
+	defer SetSlowRequestThreshold(0)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Annotate(r.Context(), zap.Int("trace_bytes", 1), zap.Bool("trace_cached", false))
+		Annotate(r.Context(), zap.Int("trace_bytes", 2048))
+		if r.URL.Path == "/block" {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+
+	body := `{"network_identifier":{"network":"Mainnet"},"block_identifier":{"index":100,"hash":"0xabc"}}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/block", strings.NewReader(body)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/network/list", strings.NewReader("{}")))
+
+	// Annotating a context not served by Middleware does nothing.
+	Annotate(context.Background(), zap.Int("trace_bytes", 1))
+
+	entries := logs.All()
+	assert.Len(t, entries, 3)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "request", entries[0].Message)
+	assert.Equal(t, int64(100), fields["block_index"])
+	assert.Equal(t, "0xabc", fields["block_hash"])
+	assert.Equal(t, int64(2048), fields["trace_bytes"])
+	assert.Equal(t, false, fields["trace_cached"])
+
+	assert.Equal(t, "slow request", entries[1].Message)
+	assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	assert.Equal(t, int64(2048), entries[1].ContextMap()["trace_bytes"])
+	assert.Equal(t, 10*time.Millisecond, entries[1].ContextMap()["threshold"])
+
+	assert.Equal(t, "/network/list", entries[2].ContextMap()["path"])
+	assert.NotContains(t, entries[2].ContextMap(), "block_index")
+}
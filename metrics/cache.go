@@ -0,0 +1,32 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+var cacheLookups = DefaultRegistry.NewCounter(
+	"rosetta_cache_lookups_total",
+	"Number of cache lookups, by cache and result (hit or miss).",
+	"cache",
+	"result",
+)
+
+// CacheLookup counts a lookup in cache.
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheLookups.Inc(cache, result)
+}
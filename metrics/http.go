@@ -0,0 +1,102 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unknownEndpoint is the endpoint label of requests
+// to paths that are not served, so that arbitrary
+// paths do not create new series.
+const unknownEndpoint = "unknown"
+
+var (
+	requests = DefaultRegistry.NewCounter(
+		"rosetta_requests_total",
+		"Number of Rosetta requests served, by endpoint and status code.",
+		"endpoint",
+		"status",
+	)
+
+	requestDuration = DefaultRegistry.NewHistogram(
+		"rosetta_request_duration_seconds",
+		"Time taken to serve Rosetta requests, by endpoint.",
+		DefaultBuckets,
+		"endpoint",
+	)
+
+	slowRequests = DefaultRegistry.NewCounter(
+		"rosetta_slow_requests_total",
+		"Number of Rosetta requests slower than SLOW_REQUEST_THRESHOLD, by endpoint.",
+		"endpoint",
+	)
+
+	panics = DefaultRegistry.NewCounter(
+		"rosetta_panics_total",
+		"Number of Rosetta requests that panicked, by endpoint.",
+		"endpoint",
+	)
+)
+
+// endpointLabel returns the endpoint label
+// of a request to path answered with status.
+func endpointLabel(path string, status int) string {
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		return unknownEndpoint
+	}
+
+	return path
+}
+
+// SlowRequest counts a slow request to
+// path answered with status.
+func SlowRequest(path string, status int) {
+	slowRequests.Inc(endpointLabel(path, status))
+}
+
+// Panic counts a request to path that panicked.
+func Panic(path string) {
+	panics.Inc(endpointLabel(path, http.StatusInternalServerError))
+}
+
+// statusRecorder records the status code
+// written by a http.Handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware records the number and duration of
+// the requests served by next, by endpoint.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		endpoint := endpointLabel(r.URL.Path, recorder.status)
+		requests.Inc(endpoint, strconv.Itoa(recorder.status))
+		requestDuration.ObserveSince(start, endpoint)
+	})
+}
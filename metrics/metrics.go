@@ -0,0 +1,398 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics records counters, gauges and histograms,
+// and exports them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// contentType is the content type of
+	// the Prometheus text exposition format.
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// labelSeparator separates the label values
+	// of a series in the key of its series.
+	labelSeparator = "\xff"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the
+// buckets of latency histograms.
+var DefaultBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+}
+
+// DefaultRegistry is the registry the metrics of
+// rosetta-ethereum are recorded in.
+var DefaultRegistry = NewRegistry()
+
+// metric is a family of series sharing a name.
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metrics and exports them over HTTP.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// register adds m to the registry. It panics if a metric
+// with the same name is already registered, as metrics are
+// registered when packages are initialized.
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metric %s is already registered", m.name()))
+	}
+
+	r.metrics[m.name()] = m
+}
+
+// ServeHTTP writes all metrics, sorted by name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	metrics := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	w.Header().Set("Content-Type", contentType)
+	buffered := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buffered)
+	}
+	buffered.Flush() // nolint:errcheck
+}
+
+// family holds the series of a metric, by label values.
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]interface{}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// get returns the series with values, creating it
+// with create if it does not exist.
+func (f *family) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.metricName, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, labelSeparator)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// each calls fn with the label values of each
+// series (sorted) and the series.
+func (f *family) each(fn func(values []string, series interface{})) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	f.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		f.mu.Lock()
+		series := f.series[key]
+		f.mu.Unlock()
+
+		values := []string{}
+		if len(f.labels) > 0 {
+			values = strings.Split(key, labelSeparator)
+		}
+
+		fn(values, series)
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of f.
+func (f *family) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escape(f.help, false))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.kind)
+}
+
+func newFamily(name string, help string, kind string, labels []string) *family {
+	return &family{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		series:     map[string]interface{}{},
+	}
+}
+
+// escape escapes s for a HELP line or,
+// if quoted is set, a label value.
+func escape(s string, quoted bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quoted {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+
+	return s
+}
+
+// labelPairs returns the label pairs of a series (with
+// extra pairs appended), as written after its name.
+func labelPairs(labels []string, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(labels)+len(extra)/2)
+	for i, label := range labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escape(values[i], true)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escape(extra[i+1], true)))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// value is a float64 that can be updated concurrently.
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(delta float64) {
+	v.mu.Lock()
+	v.v += delta
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.v
+}
+
+// Counter is a metric that only increases, with
+// one series per combination of label values.
+type Counter struct {
+	*family
+}
+
+// NewCounter registers a Counter in r.
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Add adds delta (which must not be negative) to
+// the series of c with the provided label values.
+func (c *Counter) Add(delta float64, values ...string) {
+	c.get(values, func() interface{} { return &value{} }).(*value).add(delta)
+}
+
+// Inc adds 1 to the series of c
+// with the provided label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w)
+	c.each(func(values []string, series interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labelPairs(c.labels, values), formatFloat(series.(*value).get()))
+	})
+}
+
+// Gauge is a metric that can go up and down, with
+// one series per combination of label values.
+type Gauge struct {
+	*family
+}
+
+// NewGauge registers a Gauge in r.
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	g := &Gauge{newFamily(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the series of g with the
+// provided label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.get(values, func() interface{} { return &value{} }).(*value).set(v)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeHeader(w)
+	g.each(func(values []string, series interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labelPairs(g.labels, values), formatFloat(series.(*value).get()))
+	})
+}
+
+// GaugeFunc is a gauge without labels whose value
+// is computed each time metrics are exported.
+type GaugeFunc struct {
+	*family
+	fn func() (float64, bool)
+}
+
+// NewGaugeFunc registers a GaugeFunc in r. fn returns the
+// value of the gauge, and whether it is known (the gauge
+// is not exported until it is).
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() (float64, bool)) *GaugeFunc {
+	g := &GaugeFunc{family: newFamily(name, help, "gauge", nil), fn: fn}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	v, ok := g.fn()
+	if !ok {
+		return
+	}
+
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(v))
+}
+
+// histogramSeries holds the observations of a series of a Histogram.
+type histogramSeries struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations in buckets, with one
+// series per combination of label values.
+type Histogram struct {
+	*family
+	buckets []float64
+}
+
+// NewHistogram registers a Histogram in r, with buckets
+// of observations up to each of buckets (sorted).
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, "histogram", labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// Observe records v in the series of h
+// with the provided label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.get(values, func() interface{} {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}).(*histogramSeries)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// ObserveSince records the number of seconds since
+// start in the series of h with the provided label
+// values.
+func (h *Histogram) ObserveSince(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w)
+	h.each(func(values []string, series interface{}) {
+		s := series.(*histogramSeries)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(
+				w,
+				"%s_bucket%s %d\n",
+				h.metricName,
+				labelPairs(h.labels, values, "le", formatFloat(bound)),
+				s.counts[i],
+			)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelPairs(h.labels, values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labelPairs(h.labels, values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labelPairs(h.labels, values), s.count)
+	})
+}
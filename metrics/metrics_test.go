@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the metrics exported by r.
+func scrape(t *testing.T, r http.Handler) string {
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+
+	return recorder.Body.String()
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounter("test_total", "A counter.", "method")
+	gauge := r.NewGauge("test_gauge", "A gauge\nwith two lines.")
+	histogram := r.NewHistogram("test_seconds", "A histogram.", []float64{0.1, 1}, "method")
+
+	known := false
+	r.NewGaugeFunc("test_func", "A gauge func.", func() (float64, bool) {
+		return 42, known
+	})
+
+	counter.Inc("eth_call")
+	counter.Add(2, "eth_call")
+	counter.Inc(`say "hi"`)
+	gauge.Set(-1.5)
+	histogram.Observe(0.05, "eth_call")
+	histogram.Observe(0.5, "eth_call")
+
+	assert.Equal(t, `# HELP test_gauge A gauge\nwith two lines.
+# TYPE test_gauge gauge
+test_gauge -1.5
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="eth_call",le="0.1"} 1
+test_seconds_bucket{method="eth_call",le="1"} 2
+test_seconds_bucket{method="eth_call",le="+Inf"} 2
+test_seconds_sum{method="eth_call"} 0.55
+test_seconds_count{method="eth_call"} 2
+# HELP test_total A counter.
+# TYPE test_total counter
+test_total{method="eth_call"} 3
+test_total{method="say \"hi\""} 1
+`, scrape(t, r))
+
+	known = true
+	assert.Contains(t, scrape(t, r), "# TYPE test_func gauge\ntest_func 42\n")
+
+	assert.Panics(t, func() { r.NewCounter("test_total", "Again.") })
+	assert.Panics(t, func() { counter.Inc() })
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/block" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/block", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin", nil))
+
+	body := scrape(t, DefaultRegistry)
+	assert.Contains(t, body, `rosetta_requests_total{endpoint="/block",status="200"} 1`)
+	assert.Contains(t, body, `rosetta_requests_total{endpoint="unknown",status="404"} 1`)
+	assert.Contains(t, body, `rosetta_request_duration_seconds_count{endpoint="/block"} 1`)
+	assert.NotContains(t, body, "/admin")
+
+	SlowRequest("/block", http.StatusOK)
+	SlowRequest("/admin", http.StatusNotFound)
+
+	body = scrape(t, DefaultRegistry)
+	assert.Contains(t, body, `rosetta_slow_requests_total{endpoint="/block"} 1`)
+	assert.Contains(t, body, `rosetta_slow_requests_total{endpoint="unknown"} 1`)
+
+	Panic("/block")
+	body = scrape(t, DefaultRegistry)
+	assert.Contains(t, body, `rosetta_panics_total{endpoint="/block"} 1`)
+}
@@ -8,8 +8,12 @@ import (
 
 	common "github.com/ethereum/go-ethereum/common"
 
+	goethereum "github.com/ethereum/go-ethereum"
+
 	coretypes "github.com/ethereum/go-ethereum/core/types"
 
+	ethereum "github.com/coinbase/rosetta-ethereum/ethereum"
+
 	mock "github.com/stretchr/testify/mock"
 
 	types "github.com/coinbase/rosetta-sdk-go/types"
@@ -20,13 +24,13 @@ type Client struct {
 	mock.Mock
 }
 
-// Balance provides a mock function with given fields: _a0, _a1, _a2
-func (_m *Client) Balance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 *types.PartialBlockIdentifier) (*types.AccountBalanceResponse, error) {
-	ret := _m.Called(_a0, _a1, _a2)
+// Balance provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *Client) Balance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 *types.PartialBlockIdentifier, _a3 []*types.Currency) (*types.AccountBalanceResponse, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
 
 	var r0 *types.AccountBalanceResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, *types.PartialBlockIdentifier) *types.AccountBalanceResponse); ok {
-		r0 = rf(_a0, _a1, _a2)
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, *types.PartialBlockIdentifier, []*types.Currency) *types.AccountBalanceResponse); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*types.AccountBalanceResponse)
@@ -34,8 +38,8 @@ func (_m *Client) Balance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, *types.PartialBlockIdentifier) error); ok {
-		r1 = rf(_a0, _a1, _a2)
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, *types.PartialBlockIdentifier, []*types.Currency) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -66,6 +70,66 @@ func (_m *Client) Block(_a0 context.Context, _a1 *types.PartialBlockIdentifier)
 	return r0, r1
 }
 
+// BlockHeader provides a mock function with given fields: _a0, _a1
+func (_m *Client) BlockHeader(_a0 context.Context, _a1 *types.PartialBlockIdentifier) (*types.Block, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.Block
+	if rf, ok := ret.Get(0).(func(context.Context, *types.PartialBlockIdentifier) *types.Block); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Block)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.PartialBlockIdentifier) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BlockIdentifier provides a mock function with given fields: _a0, _a1
+func (_m *Client) BlockIdentifier(_a0 context.Context, _a1 *int64) (*types.BlockIdentifier, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.BlockIdentifier
+	if rf, ok := ret.Get(0).(func(context.Context, *int64) *types.BlockIdentifier); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.BlockIdentifier)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *int64) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BreakerState provides a mock function with given fields:
+func (_m *Client) BreakerState() ethereum.BreakerState {
+	ret := _m.Called()
+
+	var r0 ethereum.BreakerState
+	if rf, ok := ret.Get(0).(func() ethereum.BreakerState); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(ethereum.BreakerState)
+	}
+
+	return r0
+}
+
 // Call provides a mock function with given fields: ctx, request
 func (_m *Client) Call(ctx context.Context, request *types.CallRequest) (*types.CallResponse, error) {
 	ret := _m.Called(ctx, request)
@@ -89,6 +153,73 @@ func (_m *Client) Call(ctx context.Context, request *types.CallRequest) (*types.
 	return r0, r1
 }
 
+// CallContract provides a mock function with given fields: ctx, msg, blockNumber
+func (_m *Client) CallContract(ctx context.Context, msg goethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	ret := _m.Called(ctx, msg, blockNumber)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(context.Context, goethereum.CallMsg, *big.Int) []byte); ok {
+		r0 = rf(ctx, msg, blockNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, goethereum.CallMsg, *big.Int) error); ok {
+		r1 = rf(ctx, msg, blockNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EstimateGas provides a mock function with given fields: ctx, msg
+func (_m *Client) EstimateGas(ctx context.Context, msg goethereum.CallMsg) (uint64, error) {
+	ret := _m.Called(ctx, msg)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, goethereum.CallMsg) uint64); ok {
+		r0 = rf(ctx, msg)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, goethereum.CallMsg) error); ok {
+		r1 = rf(ctx, msg)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FeeHistory provides a mock function with given fields: ctx, blockCount, rewardPercentiles
+func (_m *Client) FeeHistory(ctx context.Context, blockCount uint64, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	ret := _m.Called(ctx, blockCount, rewardPercentiles)
+
+	var r0 *ethereum.FeeHistory
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, []float64) *ethereum.FeeHistory); ok {
+		r0 = rf(ctx, blockCount, rewardPercentiles)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ethereum.FeeHistory)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, []float64) error); ok {
+		r1 = rf(ctx, blockCount, rewardPercentiles)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetMempool provides a mock function with given fields: ctx
 func (_m *Client) GetMempool(ctx context.Context) (*types.MempoolResponse, error) {
 	ret := _m.Called(ctx)
@@ -112,6 +243,29 @@ func (_m *Client) GetMempool(ctx context.Context) (*types.MempoolResponse, error
 	return r0, r1
 }
 
+// MempoolTransaction provides a mock function with given fields: ctx, hash
+func (_m *Client) MempoolTransaction(ctx context.Context, hash string) (*types.Transaction, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 *types.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string) *types.Transaction); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PendingNonceAt provides a mock function with given fields: _a0, _a1
 func (_m *Client) PendingNonceAt(_a0 context.Context, _a1 common.Address) (uint64, error) {
 	ret := _m.Called(_a0, _a1)
@@ -218,6 +372,29 @@ func (_m *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
 	return r0, r1
 }
 
+// SuggestGasTipCap provides a mock function with given fields: ctx
+func (_m *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *big.Int
+	if rf, ok := ret.Get(0).(func(context.Context) *big.Int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*big.Int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Transaction provides a mock function with given fields: _a0, _a1, _a2
 func (_m *Client) Transaction(_a0 context.Context, _a1 *types.BlockIdentifier, _a2 *types.TransactionIdentifier) (*types.Transaction, error) {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -240,3 +417,47 @@ func (_m *Client) Transaction(_a0 context.Context, _a1 *types.BlockIdentifier, _
 
 	return r0, r1
 }
+
+// TransactionByHash provides a mock function with given fields: ctx, hash
+func (_m *Client) TransactionByHash(ctx context.Context, hash common.Hash) (*coretypes.Transaction, bool, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 *coretypes.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash) *coretypes.Transaction); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*coretypes.Transaction)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash) bool); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, common.Hash) error); ok {
+		r2 = rf(ctx, hash)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ValidateNetwork provides a mock function with given fields: ctx, genesis
+func (_m *Client) ValidateNetwork(ctx context.Context, genesis *types.BlockIdentifier) error {
+	ret := _m.Called(ctx, genesis)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *types.BlockIdentifier) error); ok {
+		r0 = rf(ctx, genesis)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
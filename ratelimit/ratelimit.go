@@ -0,0 +1,289 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits the rate of the requests served,
+// globally, per client and per endpoint of each client, with
+// token buckets.
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// RetryAfterKey is the key of the number of seconds
+	// to wait before retrying in the details of the
+	// errors of rejected requests.
+	RetryAfterKey = "retry_after_seconds"
+
+	// idleTimeout is how long the buckets of a client
+	// are kept after its last request.
+	idleTimeout = 10 * time.Minute
+
+	// unixClient is the client of requests
+	// received on a Unix domain socket.
+	unixClient = "unix"
+)
+
+// Scopes of the limits, as reported in metrics.
+const (
+	scopeGlobal   = "global"
+	scopeClient   = "client"
+	scopeEndpoint = "endpoint"
+)
+
+var limited = metrics.DefaultRegistry.NewCounter(
+	"rosetta_rate_limited_requests_total",
+	"Number of requests rejected by rate limits, by scope (global, client or endpoint).",
+	"scope",
+)
+
+// Config is the configuration of a Limiter.
+// Rates are in requests per second, and a rate
+// of 0 is not limited.
+type Config struct {
+	// Global limits the requests of all clients.
+	Global float64
+
+	// PerClient limits the requests of each client.
+	PerClient float64
+
+	// Endpoints limits the requests of each
+	// client to each endpoint (by path).
+	Endpoints map[string]float64
+
+	// KeyHeader is the header identifying clients (i.e.
+	// an API key set by an authenticating proxy). When
+	// empty, or missing from a request, clients are
+	// identified by their IP address.
+	KeyHeader string
+}
+
+// Enabled returns whether c limits any request.
+func (c *Config) Enabled() bool {
+	return c.Global > 0 || c.PerClient > 0 || len(c.Endpoints) > 0
+}
+
+// bucket is a token bucket filled at rate tokens per
+// second up to burst tokens. Each request takes a token.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, now time.Time) *bucket {
+	// A client may use a second of requests at once.
+	burst := math.Max(1, math.Ceil(rate))
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// refill adds the tokens accrued since the last refill.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long until the bucket has a token.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// limit is a bucket checked
+// for a request, with its scope.
+type limit struct {
+	scope  string
+	bucket *bucket
+}
+
+// Limiter limits the rate of requests according to its
+// Config. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	config    *Config
+	global    *bucket
+	clients   map[string]*bucket
+	endpoints map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a Limiter limiting requests according
+// to config (if nil, no request is limited).
+func New(config *Config) *Limiter {
+	l := &Limiter{}
+	l.SetConfig(config)
+
+	return l
+}
+
+// SetConfig replaces the configuration of l (if nil, no
+// request is limited), resetting the buckets of all
+// clients.
+func (l *Limiter) SetConfig(config *Config) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.config = config
+	l.global = nil
+	l.clients = map[string]*bucket{}
+	l.endpoints = map[string]*bucket{}
+	l.lastSweep = now
+
+	if config.Global > 0 {
+		l.global = newBucket(config.Global, now)
+	}
+}
+
+// Allow returns whether a request of client to endpoint can
+// be served now or, if not, how long until it can be. A
+// rejected request takes no token from any bucket.
+func (l *Limiter) Allow(client string, endpoint string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	limits := make([]limit, 0, 3) // nolint:gomnd
+	if l.global != nil {
+		limits = append(limits, limit{scopeGlobal, l.global})
+	}
+
+	if l.config.PerClient > 0 {
+		b, ok := l.clients[client]
+		if !ok {
+			b = newBucket(l.config.PerClient, now)
+			l.clients[client] = b
+		}
+		limits = append(limits, limit{scopeClient, b})
+	}
+
+	if rate := l.config.Endpoints[endpoint]; rate > 0 {
+		key := client + " " + endpoint
+		b, ok := l.endpoints[key]
+		if !ok {
+			b = newBucket(rate, now)
+			l.endpoints[key] = b
+		}
+		limits = append(limits, limit{scopeEndpoint, b})
+	}
+
+	for _, lim := range limits {
+		lim.bucket.refill(now)
+		if wait := lim.bucket.wait(); wait > 0 {
+			limited.Inc(lim.scope)
+			return false, wait
+		}
+	}
+
+	for _, lim := range limits {
+		lim.bucket.tokens--
+	}
+
+	return true, 0
+}
+
+// sweep removes the buckets of clients idle for
+// idleTimeout, at most once per idleTimeout.
+// l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for _, buckets := range []map[string]*bucket{l.clients, l.endpoints} {
+		for key, b := range buckets {
+			if now.Sub(b.last) > idleTimeout {
+				delete(buckets, key)
+			}
+		}
+	}
+}
+
+// Client returns the client of r: the value of its KeyHeader
+// if it has one, or its source IP address.
+func (l *Limiter) Client(r *http.Request) string {
+	l.mu.Lock()
+	keyHeader := l.config.KeyHeader
+	l.mu.Unlock()
+
+	if len(keyHeader) > 0 {
+		if key := r.Header.Get(keyHeader); len(key) > 0 {
+			return "key:" + key
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Requests received on a Unix domain
+		// socket have no remote address.
+		return unixClient
+	}
+
+	return host
+}
+
+// Middleware rejects the Rosetta requests (POST) to next that
+// exceed the limits of l with 429 Too Many Requests and the
+// Rosetta error rejection, whose details carry the number of
+// seconds to wait before retrying (also in the Retry-After
+// header). Other requests (i.e. health checks) are not limited.
+func (l *Limiter) Middleware(rejection *types.Error, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wait := l.Allow(l.Client(r), r.URL.Path)
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		retryAfter := int64(math.Ceil(wait.Seconds()))
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(&types.Error{ // nolint:errcheck
+			Code:      rejection.Code,
+			Message:   rejection.Message,
+			Retriable: rejection.Retriable,
+			Details: map[string]interface{}{
+				RetryAfterKey: retryAfter,
+			},
+		})
+	})
+}
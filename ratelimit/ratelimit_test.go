@@ -0,0 +1,194 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	tests := map[string]struct {
+		config *Config
+
+		// requests are the requests made in order,
+		// as client and endpoint.
+		requests [][2]string
+		allowed  []bool
+	}{
+		"global": {
+			config: &Config{Global: 2},
+			requests: [][2]string{
+				{"a", "/block"}, {"b", "/block"}, {"c", "/account/balance"},
+			},
+			allowed: []bool{true, true, false},
+		},
+		"per client": {
+			config: &Config{PerClient: 1},
+			requests: [][2]string{
+				{"a", "/block"}, {"a", "/block"}, {"b", "/block"},
+			},
+			allowed: []bool{true, false, true},
+		},
+		"per endpoint": {
+			config: &Config{Endpoints: map[string]float64{"/block": 1}},
+			requests: [][2]string{
+				{"a", "/block"}, {"a", "/block"}, {"a", "/account/balance"}, {"b", "/block"},
+			},
+			allowed: []bool{true, false, true, true},
+		},
+		"rejections take no tokens": {
+			config: &Config{Global: 2, Endpoints: map[string]float64{"/block": 1}},
+			requests: [][2]string{
+				{"a", "/block"}, {"a", "/block"}, {"a", "/block"}, {"b", "/block"}, {"c", "/block"},
+			},
+			allowed: []bool{true, false, false, true, false},
+		},
+		"fractional rate": {
+			config: &Config{PerClient: 0.5},
+			requests: [][2]string{
+				{"a", "/block"}, {"a", "/block"},
+			},
+			allowed: []bool{true, false},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := New(test.config)
+			for i, request := range test.requests {
+				allowed, wait := l.Allow(request[0], request[1])
+				assert.Equal(t, test.allowed[i], allowed, "request %d", i)
+				assert.Equal(t, allowed, wait == 0, "request %d", i)
+			}
+		})
+	}
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	l := New(&Config{PerClient: 20})
+	for i := 0; i < 20; i++ {
+		allowed, _ := l.Allow("a", "/block")
+		assert.True(t, allowed)
+	}
+
+	allowed, wait := l.Allow("a", "/block")
+	assert.False(t, allowed)
+	assert.True(t, wait > 0 && wait <= 50*time.Millisecond)
+
+	time.Sleep(wait + 10*time.Millisecond)
+	allowed, _ = l.Allow("a", "/block")
+	assert.True(t, allowed)
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	l := New(&Config{PerClient: 1, Endpoints: map[string]float64{"/block": 1}})
+	l.Allow("a", "/block")
+	l.Allow("b", "/block")
+	assert.Len(t, l.clients, 2)
+	assert.Len(t, l.endpoints, 2)
+
+	l.clients["a"].last = time.Now().Add(-2 * idleTimeout)
+	l.endpoints["a /block"].last = time.Now().Add(-2 * idleTimeout)
+	l.lastSweep = time.Now().Add(-2 * idleTimeout)
+
+	l.Allow("b", "/account/balance")
+	assert.Len(t, l.clients, 1)
+	assert.Len(t, l.endpoints, 1)
+
+	allowed, _ := l.Allow("a", "/block")
+	assert.True(t, allowed)
+}
+
+func TestLimiter_SetConfig(t *testing.T) {
+	l := New(nil)
+	for i := 0; i < 5; i++ {
+		allowed, _ := l.Allow("a", "/block")
+		assert.True(t, allowed)
+	}
+
+	l.SetConfig(&Config{PerClient: 1})
+	allowed, _ := l.Allow("a", "/block")
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("a", "/block")
+	assert.False(t, allowed)
+
+	l.SetConfig(nil)
+	allowed, _ = l.Allow("a", "/block")
+	assert.True(t, allowed)
+}
+
+func TestLimiter_Client(t *testing.T) {
+	l := New(&Config{PerClient: 1, KeyHeader: "X-Api-Key"})
+
+	r := httptest.NewRequest(http.MethodPost, "/block", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	assert.Equal(t, "10.0.0.1", l.Client(r))
+
+	r.Header.Set("X-Api-Key", "secret")
+	assert.Equal(t, "key:secret", l.Client(r))
+
+	r = httptest.NewRequest(http.MethodPost, "/block", nil)
+	r.RemoteAddr = "@"
+	assert.Equal(t, unixClient, l.Client(r))
+
+	l = New(&Config{PerClient: 1})
+	r = httptest.NewRequest(http.MethodPost, "/block", nil)
+	r.RemoteAddr = "[::1]:5000"
+	r.Header.Set("X-Api-Key", "secret")
+	assert.Equal(t, "::1", l.Client(r))
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	rejection := &types.Error{Code: 30, Message: "rate limited", Retriable: true}
+	l := New(&Config{PerClient: 1})
+	handler := l.Middleware(rejection, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method string, remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/block", nil)
+		r.RemoteAddr = remote
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "10.0.0.1:5000").Code)
+
+	recorder := serve(http.MethodPost, "10.0.0.1:5001")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+	var rosettaErr types.Error
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rosettaErr))
+	assert.Equal(t, types.Error{
+		Code:      30,
+		Message:   "rate limited",
+		Retriable: true,
+		Details:   map[string]interface{}{RetryAfterKey: float64(1)},
+	}, rosettaErr)
+	assert.Nil(t, rejection.Details)
+
+	// Health checks and other clients are not limited.
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "10.0.0.2:5000").Code)
+}
@@ -0,0 +1,269 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package requestlimit bounds the size and shape of the
+// JSON bodies of the requests served, so that a client
+// cannot exhaust the memory of the server.
+package requestlimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// DefaultMaxSize is the largest request body
+	// (in bytes) when no size is configured.
+	DefaultMaxSize = 1 << 20
+
+	// DefaultMaxItems is the longest array of the
+	// construction requests when no length is
+	// configured.
+	DefaultMaxItems = 1000
+
+	// maxDepth is the deepest nesting of arrays
+	// and objects in a request body.
+	maxDepth = 32
+
+	// constructionPrefix is the path prefix of the
+	// construction endpoints, whose arrays are bounded.
+	constructionPrefix = "/construction/"
+
+	// ReasonKey is the key of the reason a
+	// request is invalid in the details of
+	// its error.
+	ReasonKey = "reason"
+
+	// MaxSizeKey is the key of the largest request
+	// body in the details of the error of requests
+	// that are too large.
+	MaxSizeKey = "max_size"
+)
+
+var rejected = metrics.DefaultRegistry.NewCounter(
+	"rosetta_rejected_requests_total",
+	"Number of requests rejected for their body, by reason (size, syntax, depth, duplicate_key or array_length).",
+	"reason",
+)
+
+// invalid is why a request body is invalid: the reason
+// it is counted as in metrics, and a message for clients.
+type invalid struct {
+	reason  string
+	message string
+}
+
+// Config is the configuration of the limits of requests.
+type Config struct {
+	// MaxSize is the largest request body, in
+	// bytes (DefaultMaxSize when not positive).
+	MaxSize int64
+
+	// MaxItems is the longest array of the construction
+	// requests (i.e. their operations or signatures),
+	// DefaultMaxItems when not positive.
+	MaxItems int
+}
+
+func (c *Config) maxSize() int64 {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+
+	return DefaultMaxSize
+}
+
+func (c *Config) maxItems() int {
+	if c.MaxItems > 0 {
+		return c.MaxItems
+	}
+
+	return DefaultMaxItems
+}
+
+// Errors are the Rosetta errors of rejected requests.
+type Errors struct {
+	// TooLarge is the error of requests
+	// larger than the MaxSize.
+	TooLarge *types.Error
+
+	// Invalid is the error of requests that are not
+	// valid JSON, are nested too deeply, have duplicate
+	// keys or arrays longer than MaxItems.
+	Invalid *types.Error
+}
+
+// frame is an array or object being
+// checked, with its keys or length.
+type frame struct {
+	object    bool
+	expectKey bool
+	keys      map[string]struct{}
+	length    int
+}
+
+// check returns why body is invalid, or nil if it is not: when
+// it is not a single JSON value, nests arrays and objects deeper
+// than maxDepth, has an object with duplicate keys, or has an
+// array longer than maxItems (unbounded when not positive).
+func check(body []byte, maxItems int) *invalid {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	stack := []*frame{}
+	values := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return &invalid{"syntax", fmt.Sprintf("malformed JSON: %s", err.Error())}
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		switch {
+		case top == nil:
+			values++
+			if values > 1 {
+				return &invalid{"syntax", "malformed JSON: multiple values"}
+			}
+		case top.object && top.expectKey:
+			key, _ := token.(string)
+			if _, ok := top.keys[key]; ok {
+				return &invalid{"duplicate_key", fmt.Sprintf("duplicate key %q", key)}
+			}
+			top.keys[key] = struct{}{}
+			top.expectKey = false
+			continue
+		case top.object:
+			top.expectKey = true
+		default:
+			top.length++
+			if maxItems > 0 && top.length > maxItems {
+				return &invalid{"array_length", fmt.Sprintf("array longer than %d items", maxItems)}
+			}
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			if len(stack) == maxDepth {
+				return &invalid{"depth", fmt.Sprintf("nested deeper than %d levels", maxDepth)}
+			}
+
+			f := &frame{object: delim == '{'}
+			if f.object {
+				f.expectKey = true
+				f.keys = map[string]struct{}{}
+			}
+			stack = append(stack, f)
+		}
+	}
+
+	return nil
+}
+
+// reject writes rosettaErr with status, with details
+// (if any), and counts the request as rejected for reason.
+func reject(
+	w http.ResponseWriter,
+	status int,
+	rosettaErr *types.Error,
+	reason string,
+	details map[string]interface{},
+) {
+	rejected.Inc(reason)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&types.Error{ // nolint:errcheck
+		Code:      rosettaErr.Code,
+		Message:   rosettaErr.Message,
+		Retriable: rosettaErr.Retriable,
+		Details:   details,
+	})
+}
+
+// Middleware rejects the requests (POST) to next whose body is
+// larger than the MaxSize of config with 413 Request Entity Too
+// Large and errs.TooLarge, and the requests whose body is not a
+// single JSON value, nests arrays and objects too deeply, has
+// duplicate keys or (for the construction endpoints) an array
+// longer than the MaxItems of config with 400 Bad Request and
+// errs.Invalid, whose details carry the reason. The body of
+// the requests passed on to next is buffered.
+func Middleware(config *Config, errs *Errors, next http.Handler) http.Handler {
+	maxSize := config.maxSize()
+	maxItems := config.maxItems()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tooLarge := map[string]interface{}{MaxSizeKey: maxSize}
+		if r.ContentLength > maxSize {
+			reject(w, http.StatusRequestEntityTooLarge, errs.TooLarge, "size", tooLarge)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+		r.Body.Close() // nolint:errcheck
+		if err != nil {
+			reject(w, http.StatusBadRequest, errs.Invalid, "syntax", map[string]interface{}{
+				ReasonKey: fmt.Sprintf("unable to read request: %s", err.Error()),
+			})
+			return
+		}
+
+		if int64(len(body)) > maxSize {
+			reject(w, http.StatusRequestEntityTooLarge, errs.TooLarge, "size", tooLarge)
+			return
+		}
+
+		items := 0
+		if strings.HasPrefix(r.URL.Path, constructionPrefix) {
+			items = maxItems
+		}
+
+		if i := check(body, items); i != nil {
+			reject(w, http.StatusBadRequest, errs.Invalid, i.reason, map[string]interface{}{
+				ReasonKey: i.message,
+			})
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestlimit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		body     string
+		maxItems int
+		reason   string
+	}{
+		"request": {
+			body: `{"network_identifier":{"network":"Mainnet"},"block_identifier":{"index":1}}`,
+		},
+		"empty": {
+			body: ``,
+		},
+		"nested arrays": {
+			body:     `{"operations":[{"a":[1,2]},{"a":[]}],"b":[[1],[2]]}`,
+			maxItems: 2,
+		},
+		"same keys in different objects": {
+			body: `{"a":{"b":1},"c":{"b":1},"d":[{"b":1},{"b":2}]}`,
+		},
+		"malformed": {
+			body:   `{"a":1,}`,
+			reason: "syntax",
+		},
+		"multiple values": {
+			body:   `{"a":1} {"a":1}`,
+			reason: "syntax",
+		},
+		"duplicate key": {
+			body:   `{"a":{"b":1,"c":{},"b":2}}`,
+			reason: "duplicate_key",
+		},
+		"too deep": {
+			body:   strings.Repeat("[", maxDepth+1) + strings.Repeat("]", maxDepth+1),
+			reason: "depth",
+		},
+		"deepest": {
+			body: strings.Repeat("[", maxDepth) + strings.Repeat("]", maxDepth),
+		},
+		"array too long": {
+			body:     `{"a":[1,2],"operations":[{},{},{}]}`,
+			maxItems: 2,
+			reason:   "array_length",
+		},
+		"unbounded arrays": {
+			body: `[1,2,3,4,5]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			i := check([]byte(test.body), test.maxItems)
+			if len(test.reason) == 0 {
+				assert.Nil(t, i)
+				return
+			}
+
+			assert.Equal(t, test.reason, i.reason)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	errs := &Errors{
+		TooLarge: &types.Error{Code: 32, Message: "Request too large"},
+		Invalid:  &types.Error{Code: 33, Message: "Invalid request"},
+	}
+	handler := Middleware(&Config{MaxSize: 64, MaxItems: 2}, errs, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			w.Write(body) // nolint:errcheck
+		},
+	))
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	rosettaErr := func(recorder *httptest.ResponseRecorder) *types.Error {
+		var rosettaErr types.Error
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rosettaErr))
+		return &rosettaErr
+	}
+
+	recorder := serve(http.MethodPost, "/construction/payloads", `{"operations":[{},{}]}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `{"operations":[{},{}]}`, recorder.Body.String())
+
+	recorder = serve(http.MethodPost, "/block", `{"a":"`+strings.Repeat("x", 64)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Equal(t, &types.Error{
+		Code:    32,
+		Message: "Request too large",
+		Details: map[string]interface{}{MaxSizeKey: float64(64)},
+	}, rosettaErr(recorder))
+
+	recorder = serve(http.MethodPost, "/construction/payloads", `{"operations":[{},{},{}]}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, &types.Error{
+		Code:    33,
+		Message: "Invalid request",
+		Details: map[string]interface{}{ReasonKey: "array longer than 2 items"},
+	}, rosettaErr(recorder))
+
+	// Arrays are only bounded in construction requests.
+	recorder = serve(http.MethodPost, "/call", `{"parameters":[1,2,3]}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve(http.MethodPost, "/block", `{"a":1,"a":2}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, `duplicate key "a"`, rosettaErr(recorder).Details[ReasonKey])
+
+	// Health checks are not limited.
+	recorder = serve(http.MethodGet, "/healthz", strings.Repeat("x", 128))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -24,18 +24,22 @@ import (
 
 // AccountAPIService implements the server.AccountAPIServicer interface.
 type AccountAPIService struct {
-	config *configuration.Configuration
-	client Client
+	config  *configuration.Configuration
+	client  Client
+	indexer Indexer
 }
 
 // NewAccountAPIService returns a new *AccountAPIService.
+// In INDEXER mode, balances are served from indexer.
 func NewAccountAPIService(
 	cfg *configuration.Configuration,
 	client Client,
+	indexer Indexer,
 ) *AccountAPIService {
 	return &AccountAPIService{
-		config: cfg,
-		client: client,
+		config:  cfg,
+		client:  client,
+		indexer: indexer,
 	}
 }
 
@@ -44,17 +48,42 @@ func (s *AccountAPIService) AccountBalance(
 	ctx context.Context,
 	request *types.AccountBalanceRequest,
 ) (*types.AccountBalanceResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
+	for _, currency := range request.Currencies {
+		if _, _, err := tokenContract(currency); err != nil {
+			return nil, wrapErr(ErrInvalidInput, err)
+		}
+	}
+
+	if s.config.Mode == configuration.Indexer {
+		balanceResponse, err := s.indexer.Balance(
+			ctx,
+			request.AccountIdentifier,
+			request.BlockIdentifier,
+			request.Currencies,
+		)
+		if err != nil {
+			return nil, indexerErr(err)
+		}
+
+		return balanceResponse, nil
+	}
+
+	if err := checkPruned(ctx, s.config, s.client, request.BlockIdentifier); err != nil {
+		return nil, err
+	}
+
 	balanceResponse, err := s.client.Balance(
 		ctx,
 		request.AccountIdentifier,
 		request.BlockIdentifier,
+		request.Currencies,
 	)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, gethErr(err)
 	}
 
 	return balanceResponse, nil
@@ -16,10 +16,12 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -31,7 +33,7 @@ func TestAccountBalance_Offline(t *testing.T) {
 		Mode: configuration.Offline,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewAccountAPIService(cfg, mockClient)
+	servicer := NewAccountAPIService(cfg, mockClient, nil)
 	ctx := context.Background()
 
 	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{})
@@ -51,7 +53,7 @@ func TestAccountBalance_Online(t *testing.T) {
 		Mode: configuration.Online,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewAccountAPIService(cfg, mockClient)
+	servicer := NewAccountAPIService(cfg, mockClient, nil)
 
 	ctx := context.Background()
 
@@ -79,6 +81,7 @@ func TestAccountBalance_Online(t *testing.T) {
 		ctx,
 		account,
 		types.ConstructPartialBlockIdentifier(block),
+		[]*types.Currency(nil),
 	).Return(resp, nil).Once()
 
 	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
@@ -95,3 +98,236 @@ func TestAccountBalance_Online(t *testing.T) {
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestAccountBalance_Pruned(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:       configuration.Online,
+		PruneDepth: 128,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewAccountAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	mockClient.On(
+		"BlockIdentifier",
+		ctx,
+		(*int64)(nil),
+	).Return(
+		&types.BlockIdentifier{
+			Index: 1000,
+			Hash:  "block 1000",
+		},
+		nil,
+	).Twice()
+
+	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{
+			Address: "hello",
+		},
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: types.Int64(10),
+		},
+	})
+	assert.Nil(t, bal)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+	assert.Equal(t, ErrBlockPruned.Message, err.Message)
+
+	// Blocks requested by hash are resolved to their index.
+	mockClient.On(
+		"BlockHeader",
+		ctx,
+		&types.PartialBlockIdentifier{
+			Hash: types.String("block 10"),
+		},
+	).Return(
+		&types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 10,
+				Hash:  "block 10",
+			},
+		},
+		nil,
+	).Once()
+
+	bal, err = servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{
+			Address: "hello",
+		},
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Hash: types.String("block 10"),
+		},
+	})
+	assert.Nil(t, bal)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestAccountBalance_Currencies(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewAccountAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "0x2f93B2f047E05cdf602820Ac4B3178efc2b43D55",
+	}
+	usdc := &types.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			"contract_address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+	}
+	resp := &types.AccountBalanceResponse{
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: 1000,
+			Hash:  "block 1000",
+		},
+		Balances: []*types.Amount{
+			{
+				Value:    "1000000",
+				Currency: usdc,
+			},
+		},
+	}
+
+	mockClient.On(
+		"Balance",
+		ctx,
+		account,
+		(*types.PartialBlockIdentifier)(nil),
+		[]*types.Currency{usdc},
+	).Return(resp, nil).Once()
+
+	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+		Currencies:        []*types.Currency{usdc},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, resp, bal)
+
+	// Tokens must have a valid contract address
+	bal, err = servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+		Currencies: []*types.Currency{
+			{
+				Symbol:   "USDC",
+				Decimals: 6,
+				Metadata: map[string]interface{}{
+					"contract_address": "0x1234",
+				},
+			},
+		},
+	})
+	assert.Nil(t, bal)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestAccountBalance_StateUnavailable(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewAccountAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "hello",
+	}
+	block := &types.PartialBlockIdentifier{
+		Index: types.Int64(10),
+	}
+	mockClient.On(
+		"Balance",
+		ctx,
+		account,
+		block,
+		[]*types.Currency(nil),
+	).Return(
+		nil,
+		fmt.Errorf("%w: missing trie node", ethereum.ErrStateUnavailable),
+	).Once()
+
+	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+		BlockIdentifier:   block,
+	})
+	assert.Nil(t, bal)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestAccountBalance_Indexer(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:       configuration.Indexer,
+		PruneDepth: 128,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "0x5aCB42b3cfCD734a57AFF800C57F4F5d3A11a9f1",
+	}
+
+	t.Run("historical balance", func(t *testing.T) {
+		block := &types.PartialBlockIdentifier{Index: types.Int64(10)}
+		resp := &types.AccountBalanceResponse{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 10,
+				Hash:  "0x3c5a2a1dd3c1a6bd2caa0e3dd2e2d0e8fa6e34db4f4d3e8a2bf0e0a6e0c51d31",
+			},
+			Balances: []*types.Amount{
+				{
+					Value:    "25000000000000000000",
+					Currency: ethereum.Currency,
+				},
+			},
+		}
+		mockIndexer.On(
+			"Balance",
+			ctx,
+			account,
+			block,
+			([]*types.Currency)(nil),
+		).Return(resp, nil).Once()
+
+		// The balance is not checked against the prune depth.
+		bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+			AccountIdentifier: account,
+			BlockIdentifier:   block,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, resp, bal)
+	})
+
+	t.Run("block not indexed", func(t *testing.T) {
+		block := &types.PartialBlockIdentifier{Index: types.Int64(11)}
+		mockIndexer.On(
+			"Balance",
+			ctx,
+			account,
+			block,
+			([]*types.Currency)(nil),
+		).Return(nil, fmt.Errorf("%w: 11", indexer.ErrBlockNotFound)).Once()
+
+		bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+			AccountIdentifier: account,
+			BlockIdentifier:   block,
+		})
+		assert.Nil(t, bal)
+		assert.Equal(t, ErrBlockNotIndexed.Code, err.Code)
+		assert.True(t, err.Retriable)
+	})
+
+	// Balances are not fetched from geth.
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// batchMethod is the method of the batch contract
+	// (set with BATCH_CONTRACT) sending each of amounts,
+	// out of the value of the call, to the recipient at
+	// the same index:
+	//
+	//	function multisend(address[] recipients, uint256[] amounts) payable
+	batchMethod = "multisend"
+
+	batchABIJSON = `[{
+		"name": "multisend",
+		"type": "function",
+		"stateMutability": "payable",
+		"inputs": [
+			{"name": "recipients", "type": "address[]"},
+			{"name": "amounts", "type": "uint256[]"}
+		],
+		"outputs": []
+	}]`
+)
+
+var (
+	// batchABI is the ABI of the batch contract.
+	batchABI = mustParseABI(batchABIJSON)
+
+	// batchTransferMethod is the multisend method of
+	// batchABI, looked up once instead of on every decode.
+	batchTransferMethod = batchABI.Methods[batchMethod]
+)
+
+func mustParseABI(raw string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(raw))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+// batchTransfer is a transfer of Amount ETH
+// to To made by the batch contract.
+type batchTransfer struct {
+	To     string
+	Amount *big.Int
+}
+
+// batchTotal returns the ETH moved by transfers,
+// which is the value of the batch transaction.
+func batchTotal(transfers []*batchTransfer) *big.Int {
+	total := new(big.Int)
+	for _, transfer := range transfers {
+		total.Add(total, transfer.Amount)
+	}
+
+	return total
+}
+
+// batchTransferData returns the calldata
+// of the batch contract making transfers.
+func batchTransferData(transfers []*batchTransfer) ([]byte, error) {
+	recipients := make([]common.Address, len(transfers))
+	amounts := make([]*big.Int, len(transfers))
+	for i, transfer := range transfers {
+		recipients[i] = common.HexToAddress(transfer.To)
+		amounts[i] = transfer.Amount
+	}
+
+	return batchABI.Pack(batchMethod, recipients, amounts)
+}
+
+// parseBatchTransferData returns the transfers
+// made by the batch contract calldata data.
+func parseBatchTransferData(data []byte) ([]*batchTransfer, error) {
+	method := batchTransferMethod
+	if len(data) < len(method.ID) || !bytes.Equal(data[:len(method.ID)], method.ID) {
+		return nil, fmt.Errorf("%x is not a batch transfer", data)
+	}
+
+	args, err := method.Inputs.Unpack(data[len(method.ID):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to unpack batch transfer", err)
+	}
+
+	recipients, ok := args[0].([]common.Address)
+	if !ok {
+		return nil, fmt.Errorf("unexpected batch transfer recipients %v", args[0])
+	}
+	amounts, ok := args[1].([]*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected batch transfer amounts %v", args[1])
+	}
+	if len(recipients) == 0 || len(recipients) != len(amounts) {
+		return nil, fmt.Errorf(
+			"batch transfer has %d recipients and %d amounts",
+			len(recipients),
+			len(amounts),
+		)
+	}
+
+	transfers := make([]*batchTransfer, len(recipients))
+	for i, recipient := range recipients {
+		transfers[i] = &batchTransfer{
+			To:     recipient.Hex(),
+			Amount: amounts[i],
+		}
+	}
+
+	return transfers, nil
+}
+
+// batchOperations returns the operations of
+// the batch transfers made by from.
+func batchOperations(from string, transfers []*batchTransfer) []*types.Operation {
+	ops := []*types.Operation{
+		{
+			Type: ethereum.CallOpType,
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Account: &types.AccountIdentifier{
+				Address: from,
+			},
+			Amount: &types.Amount{
+				Value:    new(big.Int).Neg(batchTotal(transfers)).String(),
+				Currency: ethereum.Currency,
+			},
+		},
+	}
+
+	for i, transfer := range transfers {
+		ops = append(ops, &types.Operation{
+			Type: ethereum.CallOpType,
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(i + 1),
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 0,
+				},
+			},
+			Account: &types.AccountIdentifier{
+				Address: transfer.To,
+			},
+			Amount: &types.Amount{
+				Value:    transfer.Amount.String(),
+				Currency: ethereum.Currency,
+			},
+		})
+	}
+
+	return ops
+}
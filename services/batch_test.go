@@ -0,0 +1,59 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchTransferData(t *testing.T) {
+	transfers := []*batchTransfer{
+		{
+			To:     "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d",
+			Amount: big.NewInt(1000),
+		},
+		{
+			To:     "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309",
+			Amount: big.NewInt(0),
+		},
+	}
+
+	data, err := batchTransferData(transfers)
+	assert.NoError(t, err)
+	assert.Equal(t, hexutil.MustDecode("0xaad41a41"), data[:4]) // multisend(address[],uint256[])
+	assert.Equal(t, big.NewInt(1000), batchTotal(transfers))
+
+	parsed, err := parseBatchTransferData(data)
+	assert.NoError(t, err)
+	assert.Len(t, parsed, len(transfers))
+	for i, transfer := range transfers {
+		assert.Equal(t, transfer.To, parsed[i].To)
+		assert.Equal(t, transfer.Amount.String(), parsed[i].Amount.String())
+	}
+
+	// Other calls are not batch transfers
+	_, err = parseBatchTransferData(data[:4])
+	assert.Error(t, err)
+	_, err = parseBatchTransferData(erc20TransferData(transfers[0].To, transfers[0].Amount))
+	assert.Error(t, err)
+	empty, err := batchTransferData([]*batchTransfer{})
+	assert.NoError(t, err)
+	_, err = parseBatchTransferData(empty)
+	assert.Error(t, err)
+}
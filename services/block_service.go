@@ -18,66 +18,155 @@ import (
 	"context"
 	"errors"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
 	"github.com/coinbase/rosetta-ethereum/configuration"
-	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/tracing"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
 )
 
 // BlockAPIService implements the server.BlockAPIServicer interface.
 type BlockAPIService struct {
-	config *configuration.Configuration
-	client Client
+	config    *configuration.Configuration
+	client    Client
+	indexer   Indexer
+	responses *responseCache
 }
 
 // NewBlockAPIService creates a new instance of a BlockAPIService.
+// In INDEXER mode, blocks are served from indexer. Otherwise,
+// the responses of immutable blocks are cached in responses,
+// if not nil.
 func NewBlockAPIService(
 	cfg *configuration.Configuration,
 	client Client,
+	indexer Indexer,
+	responses cache.Store,
 ) *BlockAPIService {
 	return &BlockAPIService{
-		config: cfg,
-		client: client,
+		config:    cfg,
+		client:    client,
+		indexer:   indexer,
+		responses: newResponseCache(cfg, responses),
 	}
 }
 
+// endSpan records the error, if any, of the
+// request traced by span and ends it.
+func endSpan(span *tracing.Span, err *types.Error) {
+	if err != nil {
+		span.SetAttribute("rosetta.error_code", int64(err.Code))
+		span.SetError(errors.New(err.Message))
+	}
+
+	span.End()
+}
+
 // Block implements the /block endpoint.
 func (s *BlockAPIService) Block(
 	ctx context.Context,
 	request *types.BlockRequest,
-) (*types.BlockResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+) (response *types.BlockResponse, rosettaErr *types.Error) {
+	ctx, span := tracing.Start(ctx, "BlockAPIService.Block", tracing.KindInternal)
+	defer func() { endSpan(span, rosettaErr) }()
+
+	if request.BlockIdentifier != nil && request.BlockIdentifier.Index != nil {
+		span.SetAttribute("block.index", *request.BlockIdentifier.Index)
+	}
+	if request.BlockIdentifier != nil && request.BlockIdentifier.Hash != nil {
+		span.SetAttribute("block.hash", *request.BlockIdentifier.Hash)
+	}
+
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
-	block, err := s.client.Block(ctx, request.BlockIdentifier)
-	if errors.Is(err, ethereum.ErrBlockOrphaned) {
-		return nil, wrapErr(ErrBlockOrphaned, err)
+	if s.config.Mode == configuration.Indexer {
+		block, err := s.indexer.Block(ctx, request.BlockIdentifier)
+		if err != nil {
+			return nil, indexerErr(err)
+		}
+
+		return &types.BlockResponse{
+			Block: block,
+		}, nil
+	}
+
+	if err := checkPruned(ctx, s.config, s.client, request.BlockIdentifier); err != nil {
+		return nil, err
+	}
+
+	if cached := s.responses.block(ctx, request.BlockIdentifier); cached != nil {
+		return cached, nil
 	}
+
+	block, err := s.client.Block(ctx, request.BlockIdentifier)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, gethErr(err)
 	}
 
-	return &types.BlockResponse{
+	response = &types.BlockResponse{
 		Block: block,
-	}, nil
+	}
+	s.responses.addBlock(ctx, s.client, request.BlockIdentifier, response)
+
+	return response, nil
 }
 
 // BlockTransaction implements the /block/transaction endpoint.
 func (s *BlockAPIService) BlockTransaction(
 	ctx context.Context,
 	request *types.BlockTransactionRequest,
-) (*types.BlockTransactionResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+) (response *types.BlockTransactionResponse, rosettaErr *types.Error) {
+	ctx, span := tracing.Start(ctx, "BlockAPIService.BlockTransaction", tracing.KindInternal)
+	defer func() { endSpan(span, rosettaErr) }()
+
+	if request.BlockIdentifier != nil {
+		span.SetAttribute("block.index", request.BlockIdentifier.Index)
+	}
+	if request.TransactionIdentifier != nil {
+		span.SetAttribute("transaction.hash", request.TransactionIdentifier.Hash)
+	}
+
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
+	if s.config.Mode == configuration.Indexer {
+		tx, err := s.indexer.Transaction(ctx, request.BlockIdentifier, request.TransactionIdentifier)
+		if err != nil {
+			return nil, indexerErr(err)
+		}
+
+		return &types.BlockTransactionResponse{
+			Transaction: tx,
+		}, nil
+	}
+
+	cached := s.responses.transaction(ctx, request.BlockIdentifier, request.TransactionIdentifier)
+	if cached != nil {
+		return cached, nil
+	}
+
 	tx, err := s.client.Transaction(ctx, request.BlockIdentifier, request.TransactionIdentifier)
+	if errors.Is(err, geth.NotFound) {
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	}
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, gethErr(err)
 	}
 
-	return &types.BlockTransactionResponse{
+	response = &types.BlockTransactionResponse{
 		Transaction: tx,
-	}, nil
+	}
+	s.responses.addTransaction(
+		ctx,
+		s.client,
+		request.BlockIdentifier,
+		request.TransactionIdentifier,
+		response,
+	)
+
+	return response, nil
 }
@@ -17,10 +17,13 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -32,7 +35,7 @@ func TestBlockService_Offline(t *testing.T) {
 		Mode: configuration.Offline,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewBlockAPIService(cfg, mockClient)
+	servicer := NewBlockAPIService(cfg, mockClient, nil, nil)
 	ctx := context.Background()
 
 	block, err := servicer.Block(ctx, &types.BlockRequest{})
@@ -53,7 +56,7 @@ func TestBlockService_Online(t *testing.T) {
 		Mode: configuration.Online,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewBlockAPIService(cfg, mockClient)
+	servicer := NewBlockAPIService(cfg, mockClient, nil, nil)
 	ctx := context.Background()
 
 	block := &types.Block{
@@ -112,7 +115,7 @@ func TestBlockTransactionService_Offline(t *testing.T) {
 		Mode: configuration.Online,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewBlockAPIService(cfg, mockClient)
+	servicer := NewBlockAPIService(cfg, mockClient, nil, nil)
 	ctx := context.Background()
 
 	blockIdentifier := &types.BlockIdentifier{Hash: "xyz"}
@@ -164,3 +167,317 @@ func TestBlockTransactionService_Offline(t *testing.T) {
 		assert.Equal(t, blockTransactionResponse, b)
 	})
 }
+
+func TestBlockService_Pruned(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:       configuration.Online,
+		PruneDepth: 128,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewBlockAPIService(cfg, mockClient, nil, nil)
+	ctx := context.Background()
+
+	mockClient.On(
+		"BlockIdentifier",
+		ctx,
+		(*int64)(nil),
+	).Return(
+		&types.BlockIdentifier{
+			Index: 1000,
+			Hash:  "block 1000",
+		},
+		nil,
+	).Times(3)
+
+	b, err := servicer.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: types.Int64(872),
+		},
+	})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+	assert.Equal(t, ErrBlockPruned.Message, err.Message)
+
+	// Blocks requested by hash are resolved to their index.
+	mockClient.On(
+		"BlockHeader",
+		ctx,
+		&types.PartialBlockIdentifier{
+			Hash: types.String("block 872"),
+		},
+	).Return(
+		&types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: 872,
+				Hash:  "block 872",
+			},
+		},
+		nil,
+	).Once()
+
+	b, err = servicer.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Hash: types.String("block 872"),
+		},
+	})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+	assert.Equal(t, ErrBlockPruned.Message, err.Message)
+
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: 873,
+			Hash:  "block 873",
+		},
+	}
+	mockClient.On(
+		"Block",
+		ctx,
+		&types.PartialBlockIdentifier{
+			Index: types.Int64(873),
+		},
+	).Return(
+		block,
+		nil,
+	).Once()
+
+	b, err = servicer.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: types.Int64(873),
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, block, b.Block)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestBlockService_Indexer(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Indexer,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewBlockAPIService(cfg, mockClient, mockIndexer, nil)
+	ctx := context.Background()
+
+	blockIdentifier := &types.BlockIdentifier{
+		Index: 10,
+		Hash:  "0x3c5a2a1dd3c1a6bd2caa0e3dd2e2d0e8fa6e34db4f4d3e8a2bf0e0a6e0c51d31",
+	}
+	transaction := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: "0xb89dbf00e5c1a6ec89a4d42879969e8ea843a6814a783fb5c2bbf712ea1ef071",
+		},
+		Operations: []*types.Operation{},
+	}
+	block := &types.Block{
+		BlockIdentifier: blockIdentifier,
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Index: 9,
+			Hash:  "0x4f1a5d6f1c0a1b7e0c8e0bbef5f64f10ca8b0a5d96f20b2be4a8dbfe3ee22fa0",
+		},
+		Timestamp:    1000,
+		Transactions: []*types.Transaction{transaction},
+	}
+
+	t.Run("block", func(t *testing.T) {
+		partial := &types.PartialBlockIdentifier{Index: &blockIdentifier.Index}
+		mockIndexer.On("Block", ctx, partial).Return(block, nil).Once()
+
+		resp, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: partial})
+		assert.Nil(t, err)
+		assert.Equal(t, block, resp.Block)
+	})
+
+	t.Run("block not indexed", func(t *testing.T) {
+		index := int64(11)
+		partial := &types.PartialBlockIdentifier{Index: &index}
+		mockIndexer.On("Block", ctx, partial).Return(nil, fmt.Errorf("%w: 11", indexer.ErrBlockNotFound)).Once()
+
+		resp, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: partial})
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrBlockNotIndexed.Code, err.Code)
+		assert.True(t, err.Retriable)
+	})
+
+	t.Run("transactions pruned", func(t *testing.T) {
+		index := int64(3)
+		partial := &types.PartialBlockIdentifier{Index: &index}
+		mockIndexer.On("Block", ctx, partial).Return(nil, fmt.Errorf("%w: block 3", indexer.ErrTransactionsPruned)).Once()
+
+		resp, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: partial})
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrBlockPruned.Code, err.Code)
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		mockIndexer.On(
+			"Transaction",
+			ctx,
+			blockIdentifier,
+			transaction.TransactionIdentifier,
+		).Return(transaction, nil).Once()
+
+		resp, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			BlockIdentifier:       blockIdentifier,
+			TransactionIdentifier: transaction.TransactionIdentifier,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, transaction, resp.Transaction)
+	})
+
+	t.Run("transaction not indexed", func(t *testing.T) {
+		missing := &types.TransactionIdentifier{Hash: "0x00"}
+		mockIndexer.On(
+			"Transaction",
+			ctx,
+			blockIdentifier,
+			missing,
+		).Return(nil, fmt.Errorf("%w: 0x00", indexer.ErrTransactionNotFound)).Once()
+
+		resp, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+			BlockIdentifier:       blockIdentifier,
+			TransactionIdentifier: missing,
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrTransactionNotFound.Code, err.Code)
+	})
+
+	// Blocks are not fetched from geth.
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestBlockService_ResponseCache(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:               configuration.Online,
+		Network:            &types.NetworkIdentifier{Network: "Mainnet"},
+		ResponseCacheDepth: 10,
+	}
+	responses, err := cache.NewMemory(16)
+	assert.NoError(t, err)
+	mockClient := &mocks.Client{}
+	servicer := NewBlockAPIService(cfg, mockClient, nil, responses)
+	ctx := context.Background()
+
+	mockClient.On(
+		"BlockIdentifier",
+		ctx,
+		(*int64)(nil),
+	).Return(
+		&types.BlockIdentifier{Index: 100, Hash: "0x64"},
+		nil,
+	)
+
+	deep := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 90, Hash: "0x5A"},
+		ParentBlockIdentifier: &types.BlockIdentifier{Index: 89, Hash: "0x59"},
+		Timestamp:             1000,
+	}
+	recent := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 91, Hash: "0x5b"},
+		ParentBlockIdentifier: deep.BlockIdentifier,
+		Timestamp:             1012,
+	}
+
+	t.Run("immutable block", func(t *testing.T) {
+		byIndex := &types.PartialBlockIdentifier{Index: types.Int64(90)}
+		mockClient.On("Block", ctx, byIndex).Return(deep, nil).Once()
+
+		// The block is fetched once, and then served
+		// from the cache by index and by hash.
+		for i := 0; i < 2; i++ {
+			resp, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: byIndex})
+			assert.Nil(t, err)
+			assert.Equal(t, deep, resp.Block)
+		}
+
+		resp, err := servicer.Block(ctx, &types.BlockRequest{
+			BlockIdentifier: &types.PartialBlockIdentifier{Hash: types.String("0x5a")},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, deep, resp.Block)
+	})
+
+	t.Run("recent block", func(t *testing.T) {
+		byIndex := &types.PartialBlockIdentifier{Index: types.Int64(91)}
+		mockClient.On("Block", ctx, byIndex).Return(recent, nil).Twice()
+
+		for i := 0; i < 2; i++ {
+			resp, err := servicer.Block(ctx, &types.BlockRequest{BlockIdentifier: byIndex})
+			assert.Nil(t, err)
+			assert.Equal(t, recent, resp.Block)
+		}
+	})
+
+	t.Run("current block", func(t *testing.T) {
+		mockClient.On(
+			"Block",
+			ctx,
+			(*types.PartialBlockIdentifier)(nil),
+		).Return(recent, nil).Once()
+
+		resp, err := servicer.Block(ctx, &types.BlockRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, recent, resp.Block)
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		transaction := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: "0xab"},
+			Operations:            []*types.Operation{},
+		}
+		request := &types.BlockTransactionRequest{
+			BlockIdentifier:       deep.BlockIdentifier,
+			TransactionIdentifier: transaction.TransactionIdentifier,
+		}
+		mockClient.On(
+			"Transaction",
+			ctx,
+			deep.BlockIdentifier,
+			transaction.TransactionIdentifier,
+		).Return(transaction, nil).Once()
+		mockClient.On(
+			"BlockIdentifier",
+			ctx,
+			types.Int64(90),
+		).Return(deep.BlockIdentifier, nil).Once()
+
+		for i := 0; i < 2; i++ {
+			resp, err := servicer.BlockTransaction(ctx, request)
+			assert.Nil(t, err)
+			assert.Equal(t, transaction, resp.Transaction)
+		}
+	})
+
+	t.Run("transaction of a wrong index", func(t *testing.T) {
+		transaction := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: "0xcd"},
+			Operations:            []*types.Operation{},
+		}
+		request := &types.BlockTransactionRequest{
+			BlockIdentifier:       &types.BlockIdentifier{Index: 1, Hash: recent.BlockIdentifier.Hash},
+			TransactionIdentifier: transaction.TransactionIdentifier,
+		}
+		mockClient.On(
+			"Transaction",
+			ctx,
+			request.BlockIdentifier,
+			transaction.TransactionIdentifier,
+		).Return(transaction, nil).Twice()
+		mockClient.On(
+			"BlockIdentifier",
+			ctx,
+			types.Int64(1),
+		).Return(&types.BlockIdentifier{Index: 1, Hash: "0x01"}, nil).Twice()
+
+		for i := 0; i < 2; i++ {
+			resp, err := servicer.BlockTransaction(ctx, request)
+			assert.Nil(t, err)
+			assert.Equal(t, transaction, resp.Transaction)
+		}
+	})
+
+	mockClient.AssertExpectations(t)
+}
@@ -17,25 +17,69 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
+const (
+	// tokenTransfersMethod is the call method returning
+	// the token transfers in the index.
+	tokenTransfersMethod = "token_transfers"
+
+	// balanceHistoryMethod is the call method returning the
+	// changes of the balance of an account in the index.
+	balanceHistoryMethod = "balance_history"
+)
+
 // CallAPIService implements the server.CallAPIServicer interface.
 type CallAPIService struct {
-	config *configuration.Configuration
-	client Client
+	config  *configuration.Configuration
+	client  Client
+	indexer Indexer
 }
 
 // NewCallAPIService creates a new instance of a CallAPIService.
-func NewCallAPIService(cfg *configuration.Configuration, client Client) *CallAPIService {
+// indexer is nil when neither BLOCK_EVENTS nor TRANSACTION_INDEX is
+// enabled.
+func NewCallAPIService(
+	cfg *configuration.Configuration,
+	client Client,
+	indexer Indexer,
+) *CallAPIService {
 	return &CallAPIService{
-		config: cfg,
-		client: client,
+		config:  cfg,
+		client:  client,
+		indexer: indexer,
+	}
+}
+
+// indexerCallMethods returns the call methods served from
+// the index with config: token transfers are indexed along
+// with transactions when TOKEN_WHITELIST is set, and
+// balances in INDEXER mode.
+func indexerCallMethods(config *configuration.Configuration) []string {
+	methods := []string{}
+	if config.TransactionIndex && len(config.TokenWhitelist) > 0 {
+		methods = append(methods, tokenTransfersMethod)
+	}
+
+	if config.Mode == configuration.Indexer {
+		methods = append(methods, balanceHistoryMethod)
 	}
+
+	return methods
+}
+
+// callMethods returns the call methods
+// advertised in /network/options.
+func callMethods(config *configuration.Configuration) []string {
+	methods := append([]string{}, ethereum.CallMethods...)
+	return append(methods, indexerCallMethods(config)...)
 }
 
 // Call implements the /call endpoint.
@@ -43,23 +87,200 @@ func (s *CallAPIService) Call(
 	ctx context.Context,
 	request *types.CallRequest,
 ) (*types.CallResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
+	switch request.Method {
+	case tokenTransfersMethod:
+		return s.callTokenTransfers(ctx, request.Parameters)
+	case balanceHistoryMethod:
+		return s.callBalanceHistory(ctx, request.Parameters)
+	}
+
 	response, err := s.client.Call(ctx, request)
-	if errors.Is(err, ethereum.ErrCallParametersInvalid) {
-		return nil, wrapErr(ErrCallParametersInvalid, err)
+	if err != nil {
+		return nil, gethErr(err)
+	}
+
+	return response, nil
+}
+
+// TokenTransfersInput is the input to the call method
+// "token_transfers". Conditions that are not set match
+// any transfer.
+type TokenTransfersInput struct {
+	Token    string `json:"token,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Address  string `json:"address,omitempty"`
+	MaxBlock *int64 `json:"max_block,omitempty"`
+	Offset   int64  `json:"offset,omitempty"`
+	Limit    *int64 `json:"limit,omitempty"`
+}
+
+// TokenTransfersOutput is the output of
+// the call method "token_transfers".
+type TokenTransfersOutput struct {
+	Transfers  []*indexer.TokenTransfer `json:"transfers"`
+	TotalCount int64                    `json:"total_count"`
+	NextOffset *int64                   `json:"next_offset,omitempty"`
+}
+
+// BalanceHistoryInput is the input to the call method
+// "balance_history". Currency defaults to ETH.
+type BalanceHistoryInput struct {
+	Address  string          `json:"address"`
+	Currency *types.Currency `json:"currency,omitempty"`
+	Offset   int64           `json:"offset,omitempty"`
+	Limit    *int64          `json:"limit,omitempty"`
+}
+
+// BalanceHistoryOutput is the output of
+// the call method "balance_history".
+type BalanceHistoryOutput struct {
+	Changes    []*indexer.BalanceChange `json:"changes"`
+	TotalCount int64                    `json:"total_count"`
+	NextOffset *int64                   `json:"next_offset,omitempty"`
+}
+
+// indexerCallAvailable returns ErrUnimplemented if
+// method is not served from the index.
+func (s *CallAPIService) indexerCallAvailable(method string) *types.Error {
+	if s.indexer != nil {
+		for _, available := range indexerCallMethods(s.config) {
+			if available == method {
+				return nil
+			}
+		}
+	}
+
+	return wrapErr(ErrUnimplemented, fmt.Errorf("%s is not served from the index", method))
+}
+
+// callPage returns the offset and limit of a page of
+// results, and checks them.
+func callPage(offset int64, limit *int64) (int64, int64, error) {
+	pageLimit := int64(defaultSearchLimit)
+	if limit != nil {
+		pageLimit = *limit
+	}
+	if pageLimit > maxSearchLimit {
+		pageLimit = maxSearchLimit
+	}
+
+	if offset < 0 || pageLimit < 0 {
+		return -1, -1, fmt.Errorf("invalid offset %d or limit %d", offset, pageLimit)
+	}
+
+	return offset, pageLimit, nil
+}
+
+// nextOffset returns the offset of the page after count
+// results at offset, or nil if there is none.
+func nextOffset(offset int64, count int, totalCount int64) *int64 {
+	if next := offset + int64(count); next < totalCount {
+		return &next
 	}
-	if errors.Is(err, ethereum.ErrCallOutputMarshal) {
+
+	return nil
+}
+
+// callResponse returns the response of a call with output.
+func callResponse(output interface{}) (*types.CallResponse, *types.Error) {
+	result, err := marshalJSONMap(output)
+	if err != nil {
 		return nil, wrapErr(ErrCallOutputMarshal, err)
 	}
-	if errors.Is(err, ethereum.ErrCallMethodInvalid) {
-		return nil, wrapErr(ErrCallMethodInvalid, err)
+
+	return &types.CallResponse{
+		Result: result,
+	}, nil
+}
+
+// callTokenTransfers handles the call method "token_transfers".
+func (s *CallAPIService) callTokenTransfers(
+	ctx context.Context,
+	params map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	if err := s.indexerCallAvailable(tokenTransfersMethod); err != nil {
+		return nil, err
 	}
+
+	var input TokenTransfersInput
+	if err := unmarshalJSONMap(params, &input); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	offset, limit, err := callPage(input.Offset, input.Limit)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, wrapErr(ErrCallParametersInvalid, err)
 	}
 
-	return response, nil
+	totalCount, transfers, err := s.indexer.TokenTransfers(
+		ctx,
+		&indexer.TokenTransferFilter{
+			Token:    input.Token,
+			From:     input.From,
+			To:       input.To,
+			Address:  input.Address,
+			MaxBlock: input.MaxBlock,
+		},
+		offset,
+		limit,
+	)
+	if err != nil {
+		return nil, indexerErr(err)
+	}
+
+	return callResponse(&TokenTransfersOutput{
+		Transfers:  transfers,
+		TotalCount: totalCount,
+		NextOffset: nextOffset(offset, len(transfers), totalCount),
+	})
+}
+
+// callBalanceHistory handles the call method "balance_history".
+func (s *CallAPIService) callBalanceHistory(
+	ctx context.Context,
+	params map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	if err := s.indexerCallAvailable(balanceHistoryMethod); err != nil {
+		return nil, err
+	}
+
+	var input BalanceHistoryInput
+	if err := unmarshalJSONMap(params, &input); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	if len(input.Address) == 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, errors.New("address missing from params"))
+	}
+
+	if input.Currency == nil {
+		input.Currency = ethereum.Currency
+	}
+
+	offset, limit, err := callPage(input.Offset, input.Limit)
+	if err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	totalCount, changes, err := s.indexer.BalanceHistory(
+		ctx,
+		&types.AccountIdentifier{Address: input.Address},
+		input.Currency,
+		offset,
+		limit,
+	)
+	if err != nil {
+		return nil, indexerErr(err)
+	}
+
+	return callResponse(&BalanceHistoryOutput{
+		Changes:    changes,
+		TotalCount: totalCount,
+		NextOffset: nextOffset(offset, len(changes), totalCount),
+	})
 }
@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -30,7 +32,7 @@ func TestCall_Offline(t *testing.T) {
 		Mode: configuration.Offline,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewCallAPIService(cfg, mockClient)
+	servicer := NewCallAPIService(cfg, mockClient, nil)
 	ctx := context.Background()
 
 	resp, err := servicer.Call(ctx, &types.CallRequest{})
@@ -45,7 +47,7 @@ func TestCall_Online(t *testing.T) {
 		Mode: configuration.Online,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewCallAPIService(cfg, mockClient)
+	servicer := NewCallAPIService(cfg, mockClient, nil)
 	ctx := context.Background()
 
 	request := &types.CallRequest{
@@ -65,3 +67,112 @@ func TestCall_Online(t *testing.T) {
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestCall_TokenTransfers(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:             configuration.Online,
+		TransactionIndex: true,
+		TokenWhitelist:   []string{"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"},
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	transfer := &indexer.TokenTransfer{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 10, Hash: "block 10"},
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx"},
+		Currency:              &types.Currency{Symbol: "USDC", Decimals: 6},
+		From:                  "0x5aCB42b3cfCD734a57AFF800C57F4F5d3A11a9f1",
+		Amount:                "100",
+	}
+	mockIndexer.On(
+		"TokenTransfers",
+		ctx,
+		&indexer.TokenTransferFilter{From: transfer.From},
+		int64(1),
+		int64(defaultSearchLimit),
+	).Return(
+		int64(3),
+		[]*indexer.TokenTransfer{transfer},
+		nil,
+	).Once()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: "token_transfers",
+		Parameters: map[string]interface{}{
+			"from":   transfer.From,
+			"offset": 1,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), resp.Result["total_count"])
+	assert.Equal(t, float64(2), resp.Result["next_offset"])
+	assert.Len(t, resp.Result["transfers"], 1)
+
+	// Balances are only indexed in INDEXER mode.
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     "balance_history",
+		Parameters: map[string]interface{}{"address": transfer.From},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnimplemented.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCall_BalanceHistory(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Indexer,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{Address: "0x5aCB42b3cfCD734a57AFF800C57F4F5d3A11a9f1"}
+	change := &indexer.BalanceChange{
+		BlockIdentifier: &types.BlockIdentifier{Index: 10, Hash: "block 10"},
+		Delta:           &types.Amount{Value: "-1", Currency: ethereum.Currency},
+		Balance:         &types.Amount{Value: "99", Currency: ethereum.Currency},
+	}
+	mockIndexer.On(
+		"BalanceHistory",
+		ctx,
+		account,
+		ethereum.Currency,
+		int64(0),
+		int64(2),
+	).Return(
+		int64(1),
+		[]*indexer.BalanceChange{change},
+		nil,
+	).Once()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: "balance_history",
+		Parameters: map[string]interface{}{
+			"address": account.Address,
+			"limit":   2,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), resp.Result["total_count"])
+	assert.NotContains(t, resp.Result, "next_offset")
+
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     "balance_history",
+		Parameters: map[string]interface{}{},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallParametersInvalid.Code, err.Code)
+
+	// Token transfers are only indexed with TOKEN_WHITELIST.
+	resp, err = servicer.Call(ctx, &types.CallRequest{Method: "token_transfers"})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnimplemented.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
@@ -17,16 +17,23 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
-	"strconv"
+	"sort"
+	"strings"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
 
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
 
 	"github.com/coinbase/rosetta-sdk-go/parser"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -54,6 +61,13 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	ctx context.Context,
 	request *types.ConstructionDeriveRequest,
 ) (*types.ConstructionDeriveResponse, *types.Error) {
+	if request.PublicKey.CurveType != types.Secp256k1 {
+		return nil, wrapErr(
+			ErrUnableToDecompressPubkey,
+			fmt.Errorf("curve type %s is not supported", request.PublicKey.CurveType),
+		)
+	}
+
 	pubkey, err := crypto.DecompressPubkey(request.PublicKey.Bytes)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToDecompressPubkey, err)
@@ -73,58 +87,79 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 	ctx context.Context,
 	request *types.ConstructionPreprocessRequest,
 ) (*types.ConstructionPreprocessResponse, *types.Error) {
-	descriptions := &parser.Descriptions{
-		OperationDescriptions: []*parser.OperationDescription{
-			{
-				Type: ethereum.CallOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.NegativeAmountSign,
-					Currency: ethereum.Currency,
-				},
-			},
-			{
-				Type: ethereum.CallOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.PositiveAmountSign,
-					Currency: ethereum.Currency,
-				},
-			},
-		},
-		ErrUnmatched: true,
+	intent, intentErr := parseIntent(request.Operations)
+	if intentErr != nil {
+		return nil, intentErr
 	}
 
-	matches, err := parser.MatchOperations(descriptions, request.Operations)
+	preprocessOutput, err := parseOptions(intent.From, request)
 	if err != nil {
-		return nil, wrapErr(ErrUnclearIntent, err)
+		return nil, wrapErr(ErrInvalidInput, err)
 	}
 
-	fromOp, _ := matches[0].First()
-	fromAdd := fromOp.Account.Address
-	toOp, _ := matches[1].First()
-	toAdd := toOp.Account.Address
-
-	// Ensure valid from address
-	checkFrom, ok := ethereum.ChecksumAddress(fromAdd)
-	if !ok {
-		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", fromAdd))
-	}
-
-	// Ensure valid to address
-	_, ok = ethereum.ChecksumAddress(toAdd)
-	if !ok {
-		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", toAdd))
+	switch {
+	case intent.Cancel:
+		if len(preprocessOutput.ReplaceTxHash) == 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				fmt.Errorf("%s is required to cancel a transaction", replaceTxHashKey),
+			)
+		}
+		if len(preprocessOutput.Data) > 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				fmt.Errorf("%s cannot be provided to cancel a transaction", dataKey),
+			)
+		}
+	case len(intent.Contract) > 0:
+		if len(preprocessOutput.Data) > 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				fmt.Errorf("%s cannot be provided for a token transfer", dataKey),
+			)
+		}
+		if preprocessOutput.GasLimit == nil && !preprocessOutput.Simulate {
+			gasLimit := defaultGasLimit(intent)
+			preprocessOutput.GasLimit = &gasLimit
+		}
+	case len(intent.Batch) > 0:
+		if len(s.config.BatchContract) == 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				errors.New("batch transfers require a batch contract"),
+			)
+		}
+		if len(preprocessOutput.Data) > 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				fmt.Errorf("%s cannot be provided for a batch transfer", dataKey),
+			)
+		}
+		if preprocessOutput.GasLimit == nil && !preprocessOutput.Simulate {
+			gasLimit := defaultGasLimit(intent)
+			preprocessOutput.GasLimit = &gasLimit
+		}
 	}
 
-	preprocessOutput := &options{
-		From: checkFrom,
+	// The simulation runs the transaction /construction/payloads
+	// builds, which calls the token contract for a token transfer.
+	if preprocessOutput.Simulate {
+		preprocessOutput.To = intent.To
+		preprocessOutput.Value = intent.Amount
+		preprocessOutput.CallData = preprocessOutput.Data
+		if len(intent.Contract) > 0 {
+			preprocessOutput.To = intent.Contract
+			preprocessOutput.Value = big.NewInt(0)
+			preprocessOutput.CallData = erc20TransferData(intent.To, intent.Amount)
+		}
+		if len(intent.Batch) > 0 {
+			callData, err := batchTransferData(intent.Batch)
+			if err != nil {
+				return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+			}
+			preprocessOutput.To = s.config.BatchContract
+			preprocessOutput.CallData = callData
+		}
 	}
 
 	marshaled, err := marshalJSONMap(preprocessOutput)
@@ -142,7 +177,7 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 	ctx context.Context,
 	request *types.ConstructionMetadataRequest,
 ) (*types.ConstructionMetadataResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
@@ -151,18 +186,74 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	nonce, err := s.client.PendingNonceAt(ctx, common.HexToAddress(input.From))
-	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+	// A replacement reuses the nonce of the replaced
+	// transaction and pays at least the fee bump geth
+	// requires over it.
+	var replaced *ethTypes.Transaction
+	var nonce uint64
+	if len(input.ReplaceTxHash) > 0 {
+		var replacedErr *types.Error
+		replaced, replacedErr = s.replacedTransaction(ctx, &input)
+		if replacedErr != nil {
+			return nil, replacedErr
+		}
+		nonce = replaced.Nonce()
+	} else {
+		pendingNonce, err := s.client.PendingNonceAt(ctx, common.HexToAddress(input.From))
+		if err != nil {
+			return nil, gethErr(err)
+		}
+		nonce = pendingNonce
+
+		// An explicit nonce may be below the pending nonce (to
+		// replace a pending transaction) but not above it.
+		if input.Nonce != nil {
+			if *input.Nonce > nonce {
+				return nil, wrapErr(
+					ErrNonceGap,
+					fmt.Errorf(
+						"nonce %d is above the pending nonce %d of %s",
+						*input.Nonce,
+						nonce,
+						input.From,
+					),
+				)
+			}
+			nonce = *input.Nonce
+		}
 	}
-	gasPrice, err := s.client.SuggestGasPrice(ctx)
-	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+
+	gasPrice, gasTipCap, gasFeeCap, fetchErr := s.fees(ctx, &input)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	if replaced != nil {
+		gasPrice, gasTipCap, gasFeeCap = replacementFees(replaced, gasPrice, gasTipCap, gasFeeCap)
+	}
+
+	gasLimit := uint64(ethereum.TransferGasLimit)
+	if input.GasLimit != nil {
+		gasLimit = *input.GasLimit
+	}
+	if input.Simulate {
+		simulatedGasLimit, simulateErr := s.simulate(ctx, &input)
+		if simulateErr != nil {
+			return nil, simulateErr
+		}
+		gasLimit = simulatedGasLimit
+	}
+	if limitErr := s.checkFeeLimits(gasLimit, gasPrice, gasFeeCap); limitErr != nil {
+		return nil, limitErr
 	}
 
 	metadata := &metadata{
-		Nonce:    nonce,
-		GasPrice: gasPrice,
+		Nonce:     nonce,
+		GasPrice:  gasPrice,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		GasLimit:  gasLimit,
+		ChainID:   s.config.Params.ChainID,
+		Data:      input.Data,
 	}
 
 	metadataMap, err := marshalJSONMap(metadata)
@@ -170,14 +261,19 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	// Find suggested gas usage
-	suggestedFee := metadata.GasPrice.Int64() * ethereum.TransferGasLimit
+	// Find suggested gas usage (at most the fee cap
+	// is paid per gas for dynamic fee transactions)
+	feePerGas := metadata.GasPrice
+	if metadata.GasFeeCap != nil {
+		feePerGas = metadata.GasFeeCap
+	}
+	suggestedFee := new(big.Int).Mul(feePerGas, new(big.Int).SetUint64(metadata.GasLimit))
 
 	return &types.ConstructionMetadataResponse{
 		Metadata: metadataMap,
 		SuggestedFee: []*types.Amount{
 			{
-				Value:    strconv.FormatInt(suggestedFee, 10),
+				Value:    suggestedFee.String(),
 				Currency: ethereum.Currency,
 			},
 		},
@@ -189,36 +285,9 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	ctx context.Context,
 	request *types.ConstructionPayloadsRequest,
 ) (*types.ConstructionPayloadsResponse, *types.Error) {
-	descriptions := &parser.Descriptions{
-		OperationDescriptions: []*parser.OperationDescription{
-			{
-				Type: ethereum.CallOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.NegativeAmountSign,
-					Currency: ethereum.Currency,
-				},
-			},
-			{
-				Type: ethereum.CallOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.PositiveAmountSign,
-					Currency: ethereum.Currency,
-				},
-			},
-		},
-		ErrUnmatched: true,
-	}
-	matches, err := parser.MatchOperations(descriptions, request.Operations)
-	if err != nil {
-		return nil, wrapErr(ErrUnclearIntent, err)
+	intent, intentErr := parseIntent(request.Operations)
+	if intentErr != nil {
+		return nil, intentErr
 	}
 
 	// Convert map to Metadata struct
@@ -227,66 +296,103 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	// Required Fields for constructing a real Ethereum transaction
-	toOp, amount := matches[1].First()
-	toAdd := toOp.Account.Address
-	nonce := metadata.Nonce
-	gasPrice := metadata.GasPrice
 	chainID := s.config.Params.ChainID
-	transferGasLimit := uint64(ethereum.TransferGasLimit)
-	transferData := []byte{}
-
-	// Additional Fields for constructing custom Ethereum tx struct
-	fromOp, _ := matches[0].First()
-	fromAdd := fromOp.Account.Address
+	transferGasLimit := defaultGasLimit(intent)
+	if metadata.GasLimit > 0 {
+		transferGasLimit = metadata.GasLimit
+	}
+	// Without /construction/metadata (i.e. when constructing
+	// offline) the transaction pays the offline fees.
+	if metadata.GasPrice == nil && metadata.GasTipCap == nil && metadata.GasFeeCap == nil {
+		if fees := s.offlineFees(); fees != nil {
+			metadata.GasPrice = fees.GasPrice
+			metadata.GasTipCap = fees.GasTipCap
+			metadata.GasFeeCap = fees.GasFeeCap
+		}
+	}
+	if metadata.GasPrice == nil && (metadata.GasTipCap == nil || metadata.GasFeeCap == nil) {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf(
+				"metadata must contain %s or both %s and %s",
+				gasPriceKey,
+				maxFeePerGasKey,
+				maxPriorityFeePerGasKey,
+			),
+		)
+	}
+	if metadata.ChainID != nil && metadata.ChainID.Cmp(chainID) != 0 {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf("metadata chain ID %s does not match network chain ID %s", metadata.ChainID, chainID),
+		)
+	}
+	if len(metadata.Data) > 0 && (intent.Cancel || len(intent.Contract) > 0 || len(intent.Batch) > 0) {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf("%s cannot be provided for a token transfer, a batch transfer or a cancellation", dataKey),
+		)
+	}
+	if limitErr := s.checkFeeLimits(transferGasLimit, metadata.GasPrice, metadata.GasFeeCap); limitErr != nil {
+		return nil, limitErr
+	}
 
-	// Ensure valid from address
-	checkFrom, ok := ethereum.ChecksumAddress(fromAdd)
-	if !ok {
-		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", fromAdd))
+	unsignedTx := &transaction{
+		From:      intent.From,
+		To:        intent.To,
+		Value:     intent.Amount,
+		Data:      metadata.Data,
+		Nonce:     metadata.Nonce,
+		GasPrice:  metadata.GasPrice,
+		GasTipCap: metadata.GasTipCap,
+		GasFeeCap: metadata.GasFeeCap,
+		GasLimit:  transferGasLimit,
+		ChainID:   chainID,
 	}
 
-	// Ensure valid to address
-	checkTo, ok := ethereum.ChecksumAddress(toAdd)
-	if !ok {
-		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", toAdd))
+	// Token transfers call the token contract
+	// instead of sending ETH to the recipient.
+	if len(intent.Contract) > 0 {
+		unsignedTx.To = intent.Contract
+		unsignedTx.Value = big.NewInt(0)
+		unsignedTx.Data = erc20TransferData(intent.To, intent.Amount)
+		unsignedTx.Currency = intent.Currency
 	}
 
-	tx := ethTypes.NewTransaction(
-		nonce,
-		common.HexToAddress(checkTo),
-		amount,
-		transferGasLimit,
-		gasPrice,
-		transferData,
-	)
+	// Batch transfers call the batch contract
+	// with the total value of the transfers.
+	if len(intent.Batch) > 0 {
+		if len(s.config.BatchContract) == 0 {
+			return nil, wrapErr(
+				ErrInvalidInput,
+				errors.New("batch transfers require a batch contract"),
+			)
+		}
 
-	unsignedTx := &transaction{
-		From:     checkFrom,
-		To:       checkTo,
-		Value:    amount,
-		Data:     tx.Data(),
-		Nonce:    tx.Nonce(),
-		GasPrice: gasPrice,
-		GasLimit: tx.Gas(),
-		ChainID:  chainID,
+		data, err := batchTransferData(intent.Batch)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+		unsignedTx.To = s.config.BatchContract
+		unsignedTx.Data = data
 	}
+	tx := newEthTransaction(unsignedTx)
 
 	// Construct SigningPayload
-	signer := ethTypes.NewEIP155Signer(chainID)
+	signer := ethTypes.LatestSignerForChainID(chainID)
 	payload := &types.SigningPayload{
-		AccountIdentifier: &types.AccountIdentifier{Address: checkFrom},
+		AccountIdentifier: &types.AccountIdentifier{Address: intent.From},
 		Bytes:             signer.Hash(tx).Bytes(),
 		SignatureType:     types.EcdsaRecovery,
 	}
 
-	unsignedTxJSON, err := json.Marshal(unsignedTx)
+	unsignedTxJSON, err := encodeUnsignedTransaction(unsignedTx)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
 	return &types.ConstructionPayloadsResponse{
-		UnsignedTransaction: string(unsignedTxJSON),
+		UnsignedTransaction: unsignedTxJSON,
 		Payloads:            []*types.SigningPayload{payload},
 	}, nil
 }
@@ -297,21 +403,18 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	ctx context.Context,
 	request *types.ConstructionCombineRequest,
 ) (*types.ConstructionCombineResponse, *types.Error) {
-	var unsignedTx transaction
-	if err := json.Unmarshal([]byte(request.UnsignedTransaction), &unsignedTx); err != nil {
+	unsignedTx, err := decodeUnsignedTransaction(request.UnsignedTransaction)
+	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	ethTransaction := ethTypes.NewTransaction(
-		unsignedTx.Nonce,
-		common.HexToAddress(unsignedTx.To),
-		unsignedTx.Value,
-		unsignedTx.GasLimit,
-		unsignedTx.GasPrice,
-		unsignedTx.Data,
-	)
+	if err := validateSignatures(request.Signatures); err != nil {
+		return nil, wrapErr(ErrSignatureInvalid, err)
+	}
+
+	ethTransaction := newEthTransaction(unsignedTx)
 
-	signer := ethTypes.NewEIP155Signer(unsignedTx.ChainID)
+	signer := ethTypes.LatestSignerForChainID(unsignedTx.ChainID)
 	signedTx, err := ethTransaction.WithSignature(signer, request.Signatures[0].Bytes)
 	if err != nil {
 		return nil, wrapErr(ErrSignatureInvalid, err)
@@ -322,6 +425,13 @@ func (s *ConstructionAPIService) ConstructionCombine(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	if unsignedTx.Currency != nil {
+		signedTxJSON, err = withTokenCurrency(signedTxJSON, unsignedTx.Currency)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+	}
+
 	return &types.ConstructionCombineResponse{
 		SignedTransaction: string(signedTxJSON),
 	}, nil
@@ -352,32 +462,56 @@ func (s *ConstructionAPIService) ConstructionParse(
 	request *types.ConstructionParseRequest,
 ) (*types.ConstructionParseResponse, *types.Error) {
 	var tx transaction
+	opType := ethereum.CallOpType
 	if !request.Signed {
-		err := json.Unmarshal([]byte(request.Transaction), &tx)
+		unsignedTx, err := decodeUnsignedTransaction(request.Transaction)
 		if err != nil {
 			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 		}
+		tx = *unsignedTx
 	} else {
-		t := new(ethTypes.Transaction)
-		err := t.UnmarshalJSON([]byte(request.Transaction))
+		t, err := decodeSignedTransaction(request.Transaction)
 		if err != nil {
 			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 		}
 
-		tx.To = t.To().String()
 		tx.Value = t.Value()
 		tx.Data = t.Data()
 		tx.Nonce = t.Nonce()
-		tx.GasPrice = t.GasPrice()
 		tx.GasLimit = t.Gas()
 		tx.ChainID = t.ChainId()
+		if t.Type() == ethTypes.DynamicFeeTxType {
+			tx.GasTipCap = t.GasTipCap()
+			tx.GasFeeCap = t.GasFeeCap()
+		} else {
+			tx.GasPrice = t.GasPrice()
+		}
 
-		msg, err := t.AsMessage(ethTypes.NewEIP155Signer(t.ChainId()), nil)
+		from, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(t.ChainId()), t)
 		if err != nil {
 			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 		}
 
-		tx.From = msg.From().Hex()
+		tx.From = from.Hex()
+
+		// A contract creation moves its value
+		// to the address of the new contract.
+		if t.To() != nil {
+			tx.To = t.To().String()
+		} else {
+			tx.To = crypto.CreateAddress(from, t.Nonce()).Hex()
+			opType = ethereum.CreateOpType
+		}
+
+		// Only transactions from /construction/combine
+		// are JSON (and may be token transfers).
+		if !isRawTransaction(request.Transaction) {
+			var token tokenCurrency
+			if err := json.Unmarshal([]byte(request.Transaction), &token); err != nil {
+				return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+			}
+			tx.Currency = token.Currency
+		}
 	}
 
 	// Ensure valid from address
@@ -392,9 +526,24 @@ func (s *ConstructionAPIService) ConstructionParse(
 		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", tx.To))
 	}
 
+	// Token transfers move the tokens in
+	// the calldata to the recipient in it.
+	value := tx.Value
+	currency := ethereum.Currency
+	if tx.Currency != nil {
+		recipient, amount, err := parseTokenTransfer(&tx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+
+		checkTo = recipient
+		value = amount
+		currency = tx.Currency
+	}
+
 	ops := []*types.Operation{
 		{
-			Type: ethereum.CallOpType,
+			Type: opType,
 			OperationIdentifier: &types.OperationIdentifier{
 				Index: 0,
 			},
@@ -402,12 +551,12 @@ func (s *ConstructionAPIService) ConstructionParse(
 				Address: checkFrom,
 			},
 			Amount: &types.Amount{
-				Value:    new(big.Int).Neg(tx.Value).String(),
-				Currency: ethereum.Currency,
+				Value:    new(big.Int).Neg(value).String(),
+				Currency: currency,
 			},
 		},
 		{
-			Type: ethereum.CallOpType,
+			Type: opType,
 			OperationIdentifier: &types.OperationIdentifier{
 				Index: 1,
 			},
@@ -420,16 +569,57 @@ func (s *ConstructionAPIService) ConstructionParse(
 				Address: checkTo,
 			},
 			Amount: &types.Amount{
-				Value:    tx.Value.String(),
-				Currency: ethereum.Currency,
+				Value:    value.String(),
+				Currency: currency,
 			},
 		},
 	}
 
+	// An empty self-transfer is a cancellation.
+	if checkFrom == checkTo && value.Sign() == 0 && len(tx.Data) == 0 && tx.Currency == nil {
+		ops = []*types.Operation{
+			{
+				Type: ethereum.CancelOpType,
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 0,
+				},
+				Account: &types.AccountIdentifier{
+					Address: checkFrom,
+				},
+			},
+		}
+	}
+
+	// Calls of the batch contract are batch transfers
+	// (unless their calldata does not transfer exactly
+	// their value, which is then only sent to the contract).
+	if len(s.config.BatchContract) > 0 &&
+		opType == ethereum.CallOpType &&
+		tx.Currency == nil &&
+		common.HexToAddress(tx.To) == common.HexToAddress(s.config.BatchContract) {
+		transfers, err := parseBatchTransferData(tx.Data)
+		if err == nil && batchTotal(transfers).Cmp(value) == 0 {
+			ops = batchOperations(checkFrom, transfers)
+		}
+	}
+
+	// The suggested fee is the most the transaction can pay
+	// (the fee cap of a dynamic fee transaction is paid per
+	// gas at most).
 	metadata := &parseMetadata{
-		Nonce:    tx.Nonce,
-		GasPrice: tx.GasPrice,
-		ChainID:  tx.ChainID,
+		Nonce:     tx.Nonce,
+		GasPrice:  tx.GasPrice,
+		GasTipCap: tx.GasTipCap,
+		GasFeeCap: tx.GasFeeCap,
+		ChainID:   tx.ChainID,
+		Data:      tx.Data,
+	}
+	feePerGas := tx.GasPrice
+	if tx.GasFeeCap != nil {
+		feePerGas = tx.GasFeeCap
+	}
+	if feePerGas != nil {
+		metadata.SuggestedFee = new(big.Int).Mul(feePerGas, new(big.Int).SetUint64(tx.GasLimit))
 	}
 	metaMap, err := marshalJSONMap(metadata)
 	if err != nil {
@@ -462,7 +652,7 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	ctx context.Context,
 	request *types.ConstructionSubmitRequest,
 ) (*types.TransactionIdentifierResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
@@ -472,7 +662,7 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	}
 
 	if err := s.client.SendTransaction(ctx, &signedTx); err != nil {
-		return nil, wrapErr(ErrBroadcastFailed, err)
+		return nil, nodeErr(ErrBroadcastFailed, err)
 	}
 
 	txIdentifier := &types.TransactionIdentifier{
@@ -482,3 +672,906 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 		TransactionIdentifier: txIdentifier,
 	}, nil
 }
+
+const (
+	// gasPriceKey, maxFeePerGasKey, maxPriorityFeePerGasKey,
+	// gasLimitKey and nonceKey are the keys of the
+	// /construction/preprocess metadata overriding the values
+	// otherwise fetched by /construction/metadata.
+	gasPriceKey             = "gas_price"
+	maxFeePerGasKey         = "max_fee_per_gas"
+	maxPriorityFeePerGasKey = "max_priority_fee_per_gas"
+	gasLimitKey             = "gas_limit"
+	nonceKey                = "nonce"
+
+	// dataKey is the key of the /construction/preprocess
+	// metadata holding the hex calldata of a contract call.
+	dataKey = "data"
+
+	// replaceTxHashKey is the key of the /construction/preprocess
+	// metadata holding the hash of a pending transaction to
+	// replace (to speed it up or, with a CANCEL operation,
+	// to cancel it).
+	replaceTxHashKey = "replace_transaction_hash"
+
+	// simulateKey is the key of the /construction/preprocess
+	// metadata requesting /construction/metadata to simulate
+	// the transaction (and to estimate its gas limit unless
+	// gas_limit is provided).
+	simulateKey = "simulate"
+
+	// revertReasonKey is the key of the ErrSimulationFailed
+	// details holding the revert reason of the transaction.
+	revertReasonKey = "revert_reason"
+
+	// replacementFeeBump is the minimum fee increase (in
+	// percent) geth requires to replace a pending transaction
+	// (the default of --txpool.pricebump).
+	replacementFeeBump = 10
+
+	// feeHistoryBlocks is the number of recent blocks
+	// whose tips are used to suggest a tip cap.
+	feeHistoryBlocks = 20
+
+	// feeHistoryPercentile is the percentile of the
+	// tips in each block used to suggest a tip cap.
+	feeHistoryPercentile = 50
+
+	// baseFeeMultiplier is how many times the next base
+	// fee the suggested fee cap covers, so a transaction
+	// stays valid while the base fee rises for a few blocks.
+	baseFeeMultiplier = 2
+)
+
+// parseOptions creates the options of a transaction sent from
+// from, applying the suggested fee multiplier and any overrides
+// in the metadata of request.
+func parseOptions(
+	from string,
+	request *types.ConstructionPreprocessRequest,
+) (*options, error) {
+	opts := &options{
+		From:                   from,
+		SuggestedFeeMultiplier: request.SuggestedFeeMultiplier,
+	}
+	if opts.SuggestedFeeMultiplier != nil && *opts.SuggestedFeeMultiplier <= 0 {
+		return nil, fmt.Errorf("suggested fee multiplier %f must be positive", *opts.SuggestedFeeMultiplier)
+	}
+
+	gasPrice, err := parseOverride(request.Metadata, gasPriceKey)
+	if err != nil {
+		return nil, err
+	}
+	opts.GasPrice = gasPrice
+
+	gasFeeCap, err := parseOverride(request.Metadata, maxFeePerGasKey)
+	if err != nil {
+		return nil, err
+	}
+	opts.GasFeeCap = gasFeeCap
+
+	gasTipCap, err := parseOverride(request.Metadata, maxPriorityFeePerGasKey)
+	if err != nil {
+		return nil, err
+	}
+	opts.GasTipCap = gasTipCap
+
+	if gasPrice != nil && (gasFeeCap != nil || gasTipCap != nil) {
+		return nil, fmt.Errorf(
+			"%s cannot be combined with %s or %s",
+			gasPriceKey,
+			maxFeePerGasKey,
+			maxPriorityFeePerGasKey,
+		)
+	}
+	if gasFeeCap != nil && gasTipCap != nil && gasTipCap.Cmp(gasFeeCap) > 0 {
+		return nil, fmt.Errorf(
+			"%s %s exceeds %s %s",
+			maxPriorityFeePerGasKey,
+			gasTipCap,
+			maxFeePerGasKey,
+			gasFeeCap,
+		)
+	}
+
+	gasLimit, err := parseOverride(request.Metadata, gasLimitKey)
+	if err != nil {
+		return nil, err
+	}
+	if gasLimit != nil {
+		if !gasLimit.IsUint64() || gasLimit.Sign() == 0 {
+			return nil, fmt.Errorf("%s %s is out of range", gasLimitKey, gasLimit)
+		}
+		limit := gasLimit.Uint64()
+		opts.GasLimit = &limit
+	}
+
+	nonce, err := parseOverride(request.Metadata, nonceKey)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != nil {
+		if !nonce.IsUint64() {
+			return nil, fmt.Errorf("%s %s is out of range", nonceKey, nonce)
+		}
+		n := nonce.Uint64()
+		opts.Nonce = &n
+	}
+
+	if raw, ok := request.Metadata[simulateKey]; ok {
+		simulate, ok := raw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a boolean", simulateKey)
+		}
+		opts.Simulate = simulate
+	}
+
+	if raw, ok := request.Metadata[dataKey]; ok {
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", dataKey)
+		}
+
+		data, err := hexutil.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s is not a valid %s", err, s, dataKey)
+		}
+
+		// Contract calls use more gas than a transfer
+		// (depending on the contract), so the caller
+		// must provide the gas limit unless it is
+		// estimated by the simulation.
+		if len(data) > 0 && opts.GasLimit == nil && !opts.Simulate {
+			return nil, fmt.Errorf(
+				"%s is required with %s unless %s is set",
+				gasLimitKey,
+				dataKey,
+				simulateKey,
+			)
+		}
+		opts.Data = data
+	}
+
+	if raw, ok := request.Metadata[replaceTxHashKey]; ok {
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", replaceTxHashKey)
+		}
+
+		hash, err := hexutil.Decode(s)
+		if err != nil || len(hash) != common.HashLength {
+			return nil, fmt.Errorf("%s is not a valid %s", s, replaceTxHashKey)
+		}
+
+		// The replacement has the nonce of the replaced transaction.
+		if opts.Nonce != nil {
+			return nil, fmt.Errorf("%s cannot be combined with %s", nonceKey, replaceTxHashKey)
+		}
+		opts.ReplaceTxHash = common.BytesToHash(hash).Hex()
+	}
+
+	return opts, nil
+}
+
+// parseOverride parses the non-negative integer at key in
+// metadata, given as a decimal or 0x-prefixed hex string.
+// It returns nil if key is not set.
+func parseOverride(metadata map[string]interface{}, key string) (*big.Int, error) {
+	raw, ok := metadata[key]
+	if !ok {
+		return nil, nil
+	}
+
+	s, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string", key)
+	}
+
+	v, ok := new(big.Int).SetString(s, 0)
+	if !ok || v.Sign() < 0 {
+		return nil, fmt.Errorf("%s is not a valid %s", s, key)
+	}
+
+	return v, nil
+}
+
+// multiplyGasPrice scales gasPrice by multiplier (if any),
+// rounding down.
+func multiplyGasPrice(gasPrice *big.Int, multiplier *float64) *big.Int {
+	if multiplier == nil {
+		return gasPrice
+	}
+
+	scaled := new(big.Float).Mul(new(big.Float).SetInt(gasPrice), big.NewFloat(*multiplier))
+	result, _ := scaled.Int(nil)
+	return result
+}
+
+// fees returns the gas price of a legacy transaction or the tip
+// and fee caps of a dynamic fee (EIP-1559) transaction sent with
+// input. A dynamic fee transaction is built if either cap is
+// overridden or if the chain has a base fee. Suggested values
+// are scaled by the suggested fee multiplier, overrides are not.
+func (s *ConstructionAPIService) fees(
+	ctx context.Context,
+	input *options,
+) (*big.Int, *big.Int, *big.Int, *types.Error) {
+	if input.GasPrice != nil {
+		return input.GasPrice, nil, nil, nil
+	}
+
+	gasTipCap, gasFeeCap := input.GasTipCap, input.GasFeeCap
+	if gasTipCap == nil || gasFeeCap == nil {
+		suggestedTipCap, suggestedFeeCap, err := s.suggestDynamicFee(ctx)
+		if err != nil {
+			return nil, nil, nil, gethErr(err)
+		}
+
+		if suggestedFeeCap == nil {
+			if gasTipCap != nil || gasFeeCap != nil {
+				return nil, nil, nil, wrapErr(
+					ErrInvalidInput,
+					fmt.Errorf(
+						"both %s and %s must be provided on a chain without a base fee",
+						maxFeePerGasKey,
+						maxPriorityFeePerGasKey,
+					),
+				)
+			}
+
+			gasPrice, err := s.client.SuggestGasPrice(ctx)
+			if err != nil {
+				return nil, nil, nil, gethErr(err)
+			}
+
+			return multiplyGasPrice(gasPrice, input.SuggestedFeeMultiplier), nil, nil, nil
+		}
+
+		if gasTipCap == nil {
+			gasTipCap = multiplyGasPrice(suggestedTipCap, input.SuggestedFeeMultiplier)
+		}
+		if gasFeeCap == nil {
+			gasFeeCap = multiplyGasPrice(suggestedFeeCap, input.SuggestedFeeMultiplier)
+		}
+	}
+
+	// A tip cap above the fee cap is rejected by geth, so a
+	// suggested cap is adjusted to the overridden one (both
+	// overrides are checked by /construction/preprocess).
+	if gasTipCap.Cmp(gasFeeCap) > 0 {
+		if input.GasFeeCap == nil {
+			gasFeeCap = gasTipCap
+		} else {
+			gasTipCap = gasFeeCap
+		}
+	}
+
+	return nil, gasTipCap, gasFeeCap, nil
+}
+
+// suggestDynamicFee suggests the tip and fee caps of a dynamic
+// fee transaction from the fee history of recent blocks: the
+// median of their tips (or the tip cap suggested by geth if they
+// include no transactions) and twice the next base fee plus the
+// tip. It returns nil caps if the chain has no base fee.
+func (s *ConstructionAPIService) suggestDynamicFee(
+	ctx context.Context,
+) (*big.Int, *big.Int, error) {
+	history, err := s.client.FeeHistory(
+		ctx,
+		feeHistoryBlocks,
+		[]float64{feeHistoryPercentile},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(history.BaseFee) == 0 {
+		return nil, nil, nil
+	}
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+	if baseFee == nil || baseFee.Sign() == 0 {
+		return nil, nil, nil
+	}
+
+	tips := []*big.Int{}
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			tips = append(tips, reward[0])
+		}
+	}
+
+	var gasTipCap *big.Int
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool {
+			return tips[i].Cmp(tips[j]) < 0
+		})
+		gasTipCap = tips[len(tips)/2]
+	} else {
+		gasTipCap, err = s.client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gasFeeCap := new(big.Int).Mul(baseFee, big.NewInt(baseFeeMultiplier))
+	gasFeeCap.Add(gasFeeCap, gasTipCap)
+
+	return gasTipCap, gasFeeCap, nil
+}
+
+// offlineFees returns the fees /construction/payloads uses when
+// its metadata has none: the configured offline fees or else
+// those of the network (nil if it has none).
+func (s *ConstructionAPIService) offlineFees() *ethereum.Fees {
+	if s.config.OfflineFees != nil {
+		return s.config.OfflineFees
+	}
+
+	return ethereum.OfflineFees[s.config.Network.Network]
+}
+
+// checkFeeLimits ensures a transaction with gasLimit, paying at
+// most gasPrice (or gasFeeCap for dynamic fee transactions) per
+// gas, is within the configured MAX_GAS_LIMIT and MAX_FEE_CAP.
+func (s *ConstructionAPIService) checkFeeLimits(
+	gasLimit uint64,
+	gasPrice *big.Int,
+	gasFeeCap *big.Int,
+) *types.Error {
+	if s.config.MaxGasLimit > 0 && gasLimit > s.config.MaxGasLimit {
+		return wrapErr(
+			ErrFeeLimitExceeded,
+			fmt.Errorf("gas limit %d exceeds maximum %d", gasLimit, s.config.MaxGasLimit),
+		)
+	}
+
+	feePerGas := gasPrice
+	if gasFeeCap != nil {
+		feePerGas = gasFeeCap
+	}
+	if s.config.MaxFeeCap != nil && feePerGas != nil && feePerGas.Cmp(s.config.MaxFeeCap) > 0 {
+		return wrapErr(
+			ErrFeeLimitExceeded,
+			fmt.Errorf("fee per gas %s exceeds maximum %s", feePerGas, s.config.MaxFeeCap),
+		)
+	}
+
+	return nil
+}
+
+// simulate runs the transaction described by input on top
+// of the pending block and returns its gas limit: the
+// explicit gas limit, or the gas geth estimates it uses
+// with the configured safety margin (GAS_LIMIT_MULTIPLIER).
+func (s *ConstructionAPIService) simulate(
+	ctx context.Context,
+	input *options,
+) (uint64, *types.Error) {
+	to := common.HexToAddress(input.To)
+	msg := geth.CallMsg{
+		From:  common.HexToAddress(input.From),
+		To:    &to,
+		Value: input.Value,
+		Data:  input.CallData,
+	}
+	if input.GasLimit != nil {
+		msg.Gas = *input.GasLimit
+	}
+
+	if _, err := s.client.CallContract(ctx, msg, big.NewInt(-1)); err != nil {
+		return 0, simulationErr(err)
+	}
+	if input.GasLimit != nil {
+		return *input.GasLimit, nil
+	}
+
+	gasLimit, err := s.client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, simulationErr(err)
+	}
+	if s.config.GasLimitMultiplier > 1 {
+		gasLimit = uint64(math.Ceil(float64(gasLimit) * s.config.GasLimitMultiplier))
+	}
+
+	return gasLimit, nil
+}
+
+// simulationErr returns the error of a simulation that failed
+// with err. geth reports execution failures as JSON-RPC errors,
+// with the revert data (if any) as their data. Failures known
+// to nodeErr (i.e. insufficient funds) are returned as such.
+func simulationErr(err error) *types.Error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return gethErr(err)
+	}
+
+	simulationErr := nodeErr(ErrSimulationFailed, err)
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return simulationErr
+	}
+
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return simulationErr
+	}
+
+	revertData, decodeErr := hexutil.Decode(data)
+	if decodeErr != nil {
+		return simulationErr
+	}
+
+	reason, unpackErr := abi.UnpackRevert(revertData)
+	if unpackErr != nil {
+		return simulationErr
+	}
+	simulationErr.Details[revertReasonKey] = reason
+
+	return simulationErr
+}
+
+// encodeUnsignedTransaction returns the versioned
+// envelope of the unsigned transaction tx.
+func encodeUnsignedTransaction(tx *transaction) (string, error) {
+	envelope, err := json.Marshal(&unsignedTransaction{
+		Version:     unsignedTransactionVersion,
+		Type:        hexutil.Uint64(transactionType(tx)),
+		ChainID:     (*hexutil.Big)(tx.ChainID),
+		Transaction: tx,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(envelope), nil
+}
+
+// decodeUnsignedTransaction decodes the unsigned transaction
+// unsignedTx created by /construction/payloads, either in the
+// versioned envelope or (if created by an older release)
+// as a bare transaction.
+func decodeUnsignedTransaction(unsignedTx string) (*transaction, error) {
+	var version struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(unsignedTx), &version); err != nil {
+		return nil, err
+	}
+
+	if version.Version == nil {
+		var tx transaction
+		if err := json.Unmarshal([]byte(unsignedTx), &tx); err != nil {
+			return nil, err
+		}
+
+		return &tx, nil
+	}
+
+	if *version.Version != unsignedTransactionVersion {
+		return nil, fmt.Errorf("unsigned transaction version %d is not supported", *version.Version)
+	}
+
+	var envelope unsignedTransaction
+	if err := json.Unmarshal([]byte(unsignedTx), &envelope); err != nil {
+		return nil, err
+	}
+
+	tx := envelope.Transaction
+	if tx == nil {
+		return nil, errors.New("unsigned transaction is empty")
+	}
+
+	txType := transactionType(tx)
+	if uint64(envelope.Type) != txType {
+		return nil, fmt.Errorf(
+			"unsigned transaction type %d does not match its fees (type %d)",
+			envelope.Type,
+			txType,
+		)
+	}
+
+	if envelope.ChainID == nil || tx.ChainID == nil ||
+		(*big.Int)(envelope.ChainID).Cmp(tx.ChainID) != 0 {
+		return nil, errors.New("unsigned transaction chain ID does not match its envelope")
+	}
+
+	return tx, nil
+}
+
+// isRawTransaction returns true if signedTx is the 0x-prefixed
+// hex of the binary encoding of a transaction (as sent with
+// eth_sendRawTransaction) rather than JSON.
+func isRawTransaction(signedTx string) bool {
+	return strings.HasPrefix(strings.TrimSpace(signedTx), "0x")
+}
+
+// decodeSignedTransaction decodes signedTx, which is either the
+// JSON returned by /construction/combine or a raw transaction
+// created by other tools.
+func decodeSignedTransaction(signedTx string) (*ethTypes.Transaction, error) {
+	t := new(ethTypes.Transaction)
+	if !isRawTransaction(signedTx) {
+		if err := t.UnmarshalJSON([]byte(signedTx)); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	raw, err := hexutil.Decode(strings.TrimSpace(signedTx))
+	if err != nil {
+		return nil, err
+	}
+	if err := t.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// defaultGasLimit returns the gas limit of the
+// transaction of intent when none is provided.
+func defaultGasLimit(intent *intent) uint64 {
+	switch {
+	case len(intent.Contract) > 0:
+		return uint64(ethereum.TokenTransferGasLimit)
+	case len(intent.Batch) > 0:
+		return uint64(ethereum.TransferGasLimit +
+			ethereum.BatchTransferGasLimitPerRecipient*int64(len(intent.Batch)))
+	default:
+		return uint64(ethereum.TransferGasLimit)
+	}
+}
+
+// parseBatchIntent returns the intent of ops sending
+// ETH from one account to several recipients.
+func parseBatchIntent(ops []*types.Operation) (*intent, *types.Error) {
+	descriptions := &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: ethereum.CallOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.NegativeAmountSign,
+					Currency: ethereum.Currency,
+				},
+			},
+			{
+				Type: ethereum.CallOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.PositiveOrZeroAmountSign,
+					Currency: ethereum.Currency,
+				},
+				AllowRepeats: true,
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	matches, err := parser.MatchOperations(descriptions, ops)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
+	fromOp, fromAmount := matches[0].First()
+	checkFrom, ok := ethereum.ChecksumAddress(fromOp.Account.Address)
+	if !ok {
+		return nil, wrapErr(
+			ErrInvalidAddress,
+			fmt.Errorf("%s is not a valid address", fromOp.Account.Address),
+		)
+	}
+
+	transfers := make([]*batchTransfer, len(matches[1].Operations))
+	for i, toOp := range matches[1].Operations {
+		checkTo, ok := ethereum.ChecksumAddress(toOp.Account.Address)
+		if !ok {
+			return nil, wrapErr(
+				ErrInvalidAddress,
+				fmt.Errorf("%s is not a valid address", toOp.Account.Address),
+			)
+		}
+
+		transfers[i] = &batchTransfer{
+			To:     checkTo,
+			Amount: matches[1].Amounts[i],
+		}
+	}
+
+	// The batch contract sends exactly
+	// the value of the transaction.
+	total := batchTotal(transfers)
+	if new(big.Int).Neg(fromAmount).Cmp(total) != 0 {
+		return nil, wrapErr(
+			ErrUnclearIntent,
+			fmt.Errorf("%s sends %s but recipients receive %s", checkFrom, new(big.Int).Neg(fromAmount), total),
+		)
+	}
+
+	return &intent{
+		From:     checkFrom,
+		Amount:   total,
+		Currency: ethereum.Currency,
+		Batch:    transfers,
+	}, nil
+}
+
+// transactionType returns the EIP-2718
+// type of the unsigned transaction t.
+func transactionType(t *transaction) uint64 {
+	if t.GasFeeCap != nil {
+		return ethTypes.DynamicFeeTxType
+	}
+
+	return ethTypes.LegacyTxType
+}
+
+// newEthTransaction creates the go-ethereum transaction of t.
+func newEthTransaction(t *transaction) *ethTypes.Transaction {
+	to := common.HexToAddress(t.To)
+	if t.GasFeeCap != nil {
+		return ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+			ChainID:   t.ChainID,
+			Nonce:     t.Nonce,
+			GasTipCap: t.GasTipCap,
+			GasFeeCap: t.GasFeeCap,
+			Gas:       t.GasLimit,
+			To:        &to,
+			Value:     t.Value,
+			Data:      t.Data,
+		})
+	}
+
+	return ethTypes.NewTransaction(
+		t.Nonce,
+		to,
+		t.Value,
+		t.GasLimit,
+		t.GasPrice,
+		t.Data,
+	)
+}
+
+// intent is what the operations of a construction request
+// ask for: a transfer of ETH (or of the ERC-20 token at
+// Contract) from From to To, a Batch of ETH transfers from
+// From (moving Amount in total), or the cancellation of a
+// pending transaction of From.
+type intent struct {
+	From     string
+	To       string
+	Amount   *big.Int
+	Currency *types.Currency
+	Contract string
+	Batch    []*batchTransfer
+	Cancel   bool
+}
+
+// parseIntent returns the intent of ops.
+func parseIntent(ops []*types.Operation) (*intent, *types.Error) {
+	if len(ops) == 1 && ops[0].Type == ethereum.CancelOpType {
+		descriptions := &parser.Descriptions{
+			OperationDescriptions: []*parser.OperationDescription{
+				{
+					Type: ethereum.CancelOpType,
+					Account: &parser.AccountDescription{
+						Exists: true,
+					},
+				},
+			},
+			ErrUnmatched: true,
+		}
+
+		matches, err := parser.MatchOperations(descriptions, ops)
+		if err != nil {
+			return nil, wrapErr(ErrUnclearIntent, err)
+		}
+
+		cancelOp, _ := matches[0].First()
+		checkFrom, ok := ethereum.ChecksumAddress(cancelOp.Account.Address)
+		if !ok {
+			return nil, wrapErr(
+				ErrInvalidAddress,
+				fmt.Errorf("%s is not a valid address", cancelOp.Account.Address),
+			)
+		}
+
+		// A pending transaction is cancelled by
+		// replacing it with an empty self-transfer.
+		return &intent{
+			From:     checkFrom,
+			To:       checkFrom,
+			Amount:   big.NewInt(0),
+			Currency: ethereum.Currency,
+			Cancel:   true,
+		}, nil
+	}
+
+	if len(ops) > 2 { // nolint:gomnd
+		return parseBatchIntent(ops)
+	}
+
+	descriptions := &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: ethereum.CallOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.NegativeOrZeroAmountSign,
+					Currency: nil, // ETH or an ERC-20 token
+				},
+			},
+			{
+				Type: ethereum.CallOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.PositiveOrZeroAmountSign,
+					Currency: nil, // ETH or an ERC-20 token
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	matches, err := parser.MatchOperations(descriptions, ops)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
+	fromOp, _ := matches[0].First()
+	fromAdd := fromOp.Account.Address
+	toOp, amount := matches[1].First()
+	toAdd := toOp.Account.Address
+
+	// Ensure valid from address
+	checkFrom, ok := ethereum.ChecksumAddress(fromAdd)
+	if !ok {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", fromAdd))
+	}
+
+	// Ensure valid to address
+	checkTo, ok := ethereum.ChecksumAddress(toAdd)
+	if !ok {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", toAdd))
+	}
+
+	contract, _, tokenErr := transferToken(fromOp, toOp)
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	return &intent{
+		From:     checkFrom,
+		To:       checkTo,
+		Amount:   amount,
+		Currency: toOp.Amount.Currency,
+		Contract: contract,
+	}, nil
+}
+
+// replacedTransaction returns the pending transaction
+// of input.From with hash input.ReplaceTxHash.
+func (s *ConstructionAPIService) replacedTransaction(
+	ctx context.Context,
+	input *options,
+) (*ethTypes.Transaction, *types.Error) {
+	tx, pending, err := s.client.TransactionByHash(ctx, common.HexToHash(input.ReplaceTxHash))
+	if errors.Is(err, geth.NotFound) {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf("transaction %s not found", input.ReplaceTxHash),
+		)
+	}
+	if err != nil {
+		return nil, gethErr(err)
+	}
+
+	if !pending {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf("transaction %s is already confirmed", input.ReplaceTxHash),
+		)
+	}
+
+	from, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	if from != common.HexToAddress(input.From) {
+		return nil, wrapErr(
+			ErrInvalidInput,
+			fmt.Errorf("transaction %s was not sent by %s", input.ReplaceTxHash, input.From),
+		)
+	}
+
+	return tx, nil
+}
+
+// replacementFees raises the gas price (of a legacy
+// transaction) or the tip and fee caps (of a dynamic fee
+// transaction) to the lowest fees geth accepts to replace
+// the transaction replaced.
+func replacementFees(
+	replaced *ethTypes.Transaction,
+	gasPrice *big.Int,
+	gasTipCap *big.Int,
+	gasFeeCap *big.Int,
+) (*big.Int, *big.Int, *big.Int) {
+	// The tip and fee caps of a legacy
+	// transaction are its gas price.
+	minTipCap := bumpedFee(replaced.GasTipCap())
+	minFeeCap := bumpedFee(replaced.GasFeeCap())
+
+	if gasPrice != nil {
+		return maxBig(gasPrice, minFeeCap), nil, nil
+	}
+
+	gasTipCap = maxBig(gasTipCap, minTipCap)
+	gasFeeCap = maxBig(maxBig(gasFeeCap, minFeeCap), gasTipCap)
+
+	return nil, gasTipCap, gasFeeCap
+}
+
+// bumpedFee returns fee increased by
+// replacementFeeBump percent, rounding up.
+func bumpedFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+replacementFeeBump)) // nolint:gomnd
+	bumped.Add(bumped, big.NewInt(99))                                  // nolint:gomnd
+	return bumped.Div(bumped, big.NewInt(100))                          // nolint:gomnd
+}
+
+// maxBig returns the larger of a and b.
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+
+	return b
+}
+
+// validateSignatures ensures signatures holds the single
+// ecdsa_recovery signature of a transaction: 64 bytes of
+// R and S followed by a recovery id of 0 or 1.
+func validateSignatures(signatures []*types.Signature) error {
+	if len(signatures) != 1 {
+		return fmt.Errorf("expected 1 signature but got %d", len(signatures))
+	}
+
+	signature := signatures[0]
+	if signature.SignatureType != types.EcdsaRecovery {
+		return fmt.Errorf(
+			"signature type %s is not supported (expected %s)",
+			signature.SignatureType,
+			types.EcdsaRecovery,
+		)
+	}
+
+	if len(signature.Bytes) != crypto.SignatureLength {
+		return fmt.Errorf(
+			"signature must be %d bytes but got %d",
+			crypto.SignatureLength,
+			len(signature.Bytes),
+		)
+	}
+
+	if v := signature.Bytes[crypto.RecoveryIDOffset]; v > 1 {
+		return fmt.Errorf("recovery id %d must be 0 or 1", v)
+	}
+
+	return nil
+}
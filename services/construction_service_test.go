@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -26,7 +27,11 @@ import (
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -85,6 +90,15 @@ func TestConstructionService(t *testing.T) {
 		},
 	}, deriveResponse)
 
+	_, err = servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: networkIdentifier,
+		PublicKey: &types.PublicKey{
+			Bytes:     publicKey.Bytes,
+			CurveType: types.Edwards25519,
+		},
+	})
+	assert.Equal(t, ErrUnableToDecompressPubkey.Code, err.Code)
+
 	// Test Preprocess
 	intent := `[{"operation_identifier":{"index":0},"type":"CALL","account":{"address":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"},"amount":{"value":"-42894881044106498","currency":{"symbol":"ETH","decimals":18}}},{"operation_identifier":{"index":1},"type":"CALL","account":{"address":"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"},"amount":{"value":"42894881044106498","currency":{"symbol":"ETH","decimals":18}}}]` // nolint
 	var ops []*types.Operation
@@ -108,8 +122,19 @@ func TestConstructionService(t *testing.T) {
 	metadata := &metadata{
 		GasPrice: big.NewInt(1000000000),
 		Nonce:    0,
+		GasLimit: 21000,
+		ChainID:  big.NewInt(3),
 	}
 
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Once()
 	mockClient.On(
 		"SuggestGasPrice",
 		ctx,
@@ -141,7 +166,7 @@ func TestConstructionService(t *testing.T) {
 	}, metadataResponse)
 
 	// Test Payloads
-	unsignedRaw := `{"from":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309","to":"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d","value":"0x9864aac3510d02","data":"0x","nonce":"0x0","gas_price":"0x3b9aca00","gas":"0x5208","chain_id":"0x3"}` // nolint
+	unsignedRaw := `{"version":1,"type":"0x0","chain_id":"0x3","transaction":` + `{"from":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309","to":"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d","value":"0x9864aac3510d02","data":"0x","nonce":"0x0","gas_price":"0x3b9aca00","gas":"0x5208","chain_id":"0x3"}` + `}` // nolint
 	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
 		NetworkIdentifier: networkIdentifier,
 		Operations:        ops,
@@ -167,9 +192,10 @@ func TestConstructionService(t *testing.T) {
 	})
 	assert.Nil(t, err)
 	parseMetadata := &parseMetadata{
-		Nonce:    metadata.Nonce,
-		GasPrice: metadata.GasPrice,
-		ChainID:  big.NewInt(3),
+		Nonce:        metadata.Nonce,
+		GasPrice:     metadata.GasPrice,
+		ChainID:      big.NewInt(3),
+		SuggestedFee: big.NewInt(21000000000000),
 	}
 	assert.Equal(t, &types.ConstructionParseResponse{
 		Operations:               parseOps,
@@ -239,3 +265,2038 @@ func TestConstructionService(t *testing.T) {
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestConstructionService_FeeOverrides(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	intent := `[{"operation_identifier":{"index":0},"type":"CALL","account":{"address":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"},"amount":{"value":"-42894881044106498","currency":{"symbol":"ETH","decimals":18}}},{"operation_identifier":{"index":1},"type":"CALL","account":{"address":"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"},"amount":{"value":"42894881044106498","currency":{"symbol":"ETH","decimals":18}}}]` // nolint
+	var ops []*types.Operation
+	assert.NoError(t, json.Unmarshal([]byte(intent), &ops))
+
+	// Suggested gas price is scaled by the multiplier
+	multiplier := 1.5
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier:      networkIdentifier,
+			Operations:             ops,
+			SuggestedFeeMultiplier: &multiplier,
+			Metadata: map[string]interface{}{
+				"gas_limit": "30000",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":                     "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309",
+		"suggested_fee_multiplier": 1.5,
+		"gas_limit":                "0x7530",
+	}, preprocessResponse.Options)
+
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Once()
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Once()
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"),
+	).Return(
+		uint64(7),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionMetadataResponse{
+		Metadata: forceMarshalMap(t, &metadata{
+			GasPrice: big.NewInt(1500000000),
+			Nonce:    7,
+			GasLimit: 30000,
+			ChainID:  big.NewInt(3),
+		}),
+		SuggestedFee: []*types.Amount{
+			{
+				Value:    "45000000000000",
+				Currency: ethereum.Currency,
+			},
+		},
+	}, metadataResponse)
+
+	// Explicit gas price is not fetched from geth and an
+	// explicit nonce may be below the pending nonce
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier:      networkIdentifier,
+			Operations:             ops,
+			SuggestedFeeMultiplier: &multiplier,
+			Metadata: map[string]interface{}{
+				"gas_price": "0x77359400",
+				"nonce":     "5",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress("0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"),
+	).Return(
+		uint64(7),
+		nil,
+	).Twice()
+	metadataResponse, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionMetadataResponse{
+		Metadata: forceMarshalMap(t, &metadata{
+			GasPrice: big.NewInt(2000000000),
+			Nonce:    5,
+			GasLimit: 21000,
+			ChainID:  big.NewInt(3),
+		}),
+		SuggestedFee: []*types.Amount{
+			{
+				Value:    "42000000000000",
+				Currency: ethereum.Currency,
+			},
+		},
+	}, metadataResponse)
+
+	// An explicit nonce above the pending nonce leaves a gap
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"gas_price": "0x77359400",
+				"nonce":     "9",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Equal(t, ErrNonceGap.Code, err.Code)
+	assert.True(t, err.Retriable)
+
+	// Payloads uses the gas limit in the metadata
+	// and rejects metadata for another network
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: forceMarshalMap(t, &metadata{
+			GasPrice: big.NewInt(1000000000),
+			GasLimit: 30000,
+			ChainID:  big.NewInt(3),
+		}),
+	})
+	assert.Nil(t, err)
+	unsignedTx, decodeErr := decodeUnsignedTransaction(payloadsResponse.UnsignedTransaction)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, uint64(30000), unsignedTx.GasLimit)
+
+	_, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: forceMarshalMap(t, &metadata{
+			GasPrice: big.NewInt(1000000000),
+			ChainID:  big.NewInt(1),
+		}),
+	})
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	// Invalid overrides are rejected
+	for _, m := range []map[string]interface{}{
+		{"gas_price": "-1"},
+		{"gas_limit": "0"},
+		{"nonce": "abc"},
+		{"nonce": 12},
+	} {
+		_, err = servicer.ConstructionPreprocess(
+			ctx,
+			&types.ConstructionPreprocessRequest{
+				NetworkIdentifier: networkIdentifier,
+				Operations:        ops,
+				Metadata:          m,
+			},
+		)
+		assert.Equal(t, ErrInvalidInput.Code, err.Code)
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_DynamicFee(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	key, keyErr := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	assert.NoError(t, keyErr)
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	to := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}
+
+	// Test Preprocess
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+		},
+	)
+	assert.Nil(t, err)
+
+	// Test Metadata: the tip cap is the median of the recent
+	// tips and the fee cap twice the next base fee plus the tip
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{
+			OldestBlock: big.NewInt(100),
+			Reward: [][]*big.Int{
+				{big.NewInt(3000000000)},
+				{big.NewInt(1000000000)},
+				{big.NewInt(2000000000)},
+			},
+			BaseFee: []*big.Int{
+				big.NewInt(9000000000),
+				big.NewInt(9500000000),
+				big.NewInt(9800000000),
+				big.NewInt(10000000000),
+			},
+		},
+		nil,
+	).Once()
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(5),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionMetadataResponse{
+		Metadata: map[string]interface{}{
+			"nonce":                    "0x5",
+			"max_priority_fee_per_gas": "0x77359400",
+			"max_fee_per_gas":          "0x51f4d5c00",
+			"gas_limit":                "0x5208",
+			"chain_id":                 "0x3",
+		},
+		SuggestedFee: []*types.Amount{
+			{
+				Value:    "462000000000000",
+				Currency: ethereum.Currency,
+			},
+		},
+	}, metadataResponse)
+
+	// Test Payloads
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata:          metadataResponse.Metadata,
+	})
+	assert.Nil(t, err)
+	unsignedRaw := `{"version":1,"type":"0x2","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + to + `","value":"0x3e8","data":"0x","nonce":"0x5","max_priority_fee_per_gas":"0x77359400","max_fee_per_gas":"0x51f4d5c00","gas":"0x5208","chain_id":"0x3"}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	// Test Parse Unsigned
+	parseMetadata := map[string]interface{}{
+		"nonce":                    "0x5",
+		"max_priority_fee_per_gas": "0x77359400",
+		"max_fee_per_gas":          "0x51f4d5c00",
+		"chain_id":                 "0x3",
+		"suggested_fee":            "0x1a42fc1e2e000",
+	}
+	parseUnsignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       unsignedRaw,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations:               ops,
+		AccountIdentifierSigners: []*types.AccountIdentifier{},
+		Metadata:                 parseMetadata,
+	}, parseUnsignedResponse)
+
+	// Test Combine
+	signature, signErr := crypto.Sign(payloadsResponse.Payloads[0].Bytes, key)
+	assert.NoError(t, signErr)
+	combineResponse, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: unsignedRaw,
+		Signatures: []*types.Signature{
+			{
+				SigningPayload: payloadsResponse.Payloads[0],
+				SignatureType:  types.EcdsaRecovery,
+				Bytes:          signature,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	var signedTx ethTypes.Transaction
+	assert.NoError(t, signedTx.UnmarshalJSON([]byte(combineResponse.SignedTransaction)))
+	assert.Equal(t, uint8(ethTypes.DynamicFeeTxType), signedTx.Type())
+
+	// Test Parse Signed
+	parseSignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations: ops,
+		AccountIdentifierSigners: []*types.AccountIdentifier{
+			{Address: from},
+		},
+		Metadata: parseMetadata,
+	}, parseSignedResponse)
+
+	// Test Hash
+	hashResponse, err := servicer.ConstructionHash(ctx, &types.ConstructionHashRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, signedTx.Hash().Hex(), hashResponse.TransactionIdentifier.Hash)
+
+	// Test Submit
+	mockClient.On(
+		"SendTransaction",
+		ctx,
+		mock.Anything,
+	).Return(
+		nil,
+	).Once()
+	submitResponse, err := servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, hashResponse, submitResponse)
+
+	// Explicit caps are not scaled and a lone fee cap
+	// lowers the suggested tip cap
+	multiplier := 2.0
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier:      networkIdentifier,
+			Operations:             ops,
+			SuggestedFeeMultiplier: &multiplier,
+			Metadata: map[string]interface{}{
+				"max_fee_per_gas": "3000000000",
+				"nonce":           "6",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(6),
+		nil,
+	).Once()
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{
+			BaseFee: []*big.Int{big.NewInt(1000000000), big.NewInt(1000000000)},
+			Reward:  [][]*big.Int{{}},
+		},
+		nil,
+	).Once()
+	mockClient.On(
+		"SuggestGasTipCap",
+		ctx,
+	).Return(
+		big.NewInt(2000000000),
+		nil,
+	).Once()
+	metadataResponse, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":                    "0x6",
+		"max_priority_fee_per_gas": "0xb2d05e00",
+		"max_fee_per_gas":          "0xb2d05e00",
+		"gas_limit":                "0x5208",
+		"chain_id":                 "0x3",
+	}, metadataResponse.Metadata)
+
+	// Conflicting fee overrides are rejected
+	for _, m := range []map[string]interface{}{
+		{"gas_price": "1", "max_fee_per_gas": "2"},
+		{"max_fee_per_gas": "1", "max_priority_fee_per_gas": "2"},
+	} {
+		_, err = servicer.ConstructionPreprocess(
+			ctx,
+			&types.ConstructionPreprocessRequest{
+				NetworkIdentifier: networkIdentifier,
+				Operations:        ops,
+				Metadata:          m,
+			},
+		)
+		assert.Equal(t, ErrInvalidInput.Code, err.Code)
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_ContractCall(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	from := "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"
+	contract := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	data := "0x3ccfd60b" // withdraw()
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "0", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: contract},
+			Amount:              &types.Amount{Value: "0", Currency: ethereum.Currency},
+		},
+	}
+
+	// Calldata requires a gas limit
+	_, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"data": data,
+			},
+		},
+	)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"data":      data,
+				"gas_limit": "100000",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":      from,
+		"gas_limit": "0x186a0",
+		"data":      data,
+	}, preprocessResponse.Options)
+
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Once()
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Once()
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(1),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":     "0x1",
+		"gas_price": "0x3b9aca00",
+		"gas_limit": "0x186a0",
+		"chain_id":  "0x3",
+		"data":      data,
+	}, metadataResponse.Metadata)
+
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata:          metadataResponse.Metadata,
+	})
+	assert.Nil(t, err)
+	unsignedRaw := `{"version":1,"type":"0x0","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + contract + `","value":"0x0","data":"` + data + `","nonce":"0x1","gas_price":"0x3b9aca00","gas":"0x186a0","chain_id":"0x3"}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	// Parse round-trips the calldata
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       unsignedRaw,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations:               ops,
+		AccountIdentifierSigners: []*types.AccountIdentifier{},
+		Metadata: map[string]interface{}{
+			"nonce":         "0x1",
+			"gas_price":     "0x3b9aca00",
+			"chain_id":      "0x3",
+			"data":          data,
+			"suggested_fee": "0x5af3107a4000",
+		},
+	}, parseResponse)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_TokenTransfer(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	key, keyErr := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	assert.NoError(t, keyErr)
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	to := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	contract := "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	currency := &types.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			"contract_address": contract,
+		},
+	}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000000", Currency: currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000000", Currency: currency},
+		},
+	}
+
+	// Test Preprocess: token transfers have a larger default gas limit
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":      from,
+		"gas_limit": "0x186a0",
+	}, preprocessResponse.Options)
+
+	// Test Metadata
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Once()
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Once()
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(0),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+
+	// Test Payloads: the token contract is called with no value
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata:          metadataResponse.Metadata,
+	})
+	assert.Nil(t, err)
+	data := "0xa9059cbb00000000000000000000000057b414a0332b5cab885a451c2a28a07d1e9b8a8d00000000000000000000000000000000000000000000000000000000000f4240" // nolint
+
+	unsignedRaw := `{"version":1,"type":"0x0","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + contract + `","value":"0x0","data":"` + data + `","nonce":"0x0","gas_price":"0x3b9aca00","gas":"0x186a0","chain_id":"0x3","currency":{"symbol":"USDC","decimals":6,"metadata":{"contract_address":"` + contract + `"}}}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	// Test Parse Unsigned
+	parseMetadata := map[string]interface{}{
+		"nonce":         "0x0",
+		"gas_price":     "0x3b9aca00",
+		"chain_id":      "0x3",
+		"data":          data,
+		"suggested_fee": "0x5af3107a4000",
+	}
+	parseUnsignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       unsignedRaw,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations:               ops,
+		AccountIdentifierSigners: []*types.AccountIdentifier{},
+		Metadata:                 parseMetadata,
+	}, parseUnsignedResponse)
+
+	// Test Combine
+	signature, signErr := crypto.Sign(payloadsResponse.Payloads[0].Bytes, key)
+	assert.NoError(t, signErr)
+	combineResponse, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: unsignedRaw,
+		Signatures: []*types.Signature{
+			{
+				SigningPayload: payloadsResponse.Payloads[0],
+				SignatureType:  types.EcdsaRecovery,
+				Bytes:          signature,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	// Test Parse Signed
+	parseSignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations: ops,
+		AccountIdentifierSigners: []*types.AccountIdentifier{
+			{Address: from},
+		},
+		Metadata: parseMetadata,
+	}, parseSignedResponse)
+
+	// Test Hash
+	var signedTx ethTypes.Transaction
+	assert.NoError(t, signedTx.UnmarshalJSON([]byte(combineResponse.SignedTransaction)))
+	assert.Equal(t, common.HexToAddress(contract), *signedTx.To())
+	hashResponse, err := servicer.ConstructionHash(ctx, &types.ConstructionHashRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, signedTx.Hash().Hex(), hashResponse.TransactionIdentifier.Hash)
+
+	// Mixed currencies, tokens without a contract
+	// and calldata are rejected
+	mixedOps := []*types.Operation{ops[0], {
+		OperationIdentifier: ops[1].OperationIdentifier,
+		RelatedOperations:   ops[1].RelatedOperations,
+		Type:                ethereum.CallOpType,
+		Account:             ops[1].Account,
+		Amount:              &types.Amount{Value: "1000000", Currency: ethereum.Currency},
+	}}
+	_, err = servicer.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        mixedOps,
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+
+	noContract := &types.Currency{Symbol: "USDC", Decimals: 6}
+	_, err = servicer.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: ops[0].OperationIdentifier,
+				Type:                ethereum.CallOpType,
+				Account:             ops[0].Account,
+				Amount:              &types.Amount{Value: "-1", Currency: noContract},
+			},
+			{
+				OperationIdentifier: ops[1].OperationIdentifier,
+				Type:                ethereum.CallOpType,
+				Account:             ops[1].Account,
+				Amount:              &types.Amount{Value: "1", Currency: noContract},
+			},
+		},
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+
+	_, err = servicer.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"data":      "0x3ccfd60b",
+			"gas_limit": "100000",
+		},
+	})
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionSubmit_Errors(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	signedRaw := `{"type":"0x0","nonce":"0x0","gasPrice":"0x3b9aca00","maxPriorityFeePerGas":null,"maxFeePerGas":null,"gas":"0x5208","value":"0x9864aac3510d02","input":"0x","v":"0x2a","r":"0x8c712c64bc65c4a88707fa93ecd090144dffb1bf133805a10a51d354c2f9f2b2","s":"0x5a63cea6989f4c58372c41f31164036a6b25dce1d5c05e1d31c16c0590c176e8","to":"0x57b414a0332b5cab885a451c2a28a07d1e9b8a8d","hash":"0x424969b1a98757bcd748c60bad2a7de9745cfb26bfefb4550e780a098feada42"}` // nolint
+
+	var tests = map[string]struct {
+		sendErr error
+
+		expectedErr *types.Error
+	}{
+		"nonce too low": {
+			sendErr:     errors.New("nonce too low"),
+			expectedErr: ErrNonceTooLow,
+		},
+		"replacement underpriced": {
+			sendErr:     errors.New("replacement transaction underpriced"),
+			expectedErr: ErrReplacementUnderpriced,
+		},
+		"insufficient funds": {
+			sendErr:     errors.New("insufficient funds for gas * price + value"),
+			expectedErr: ErrInsufficientFunds,
+		},
+		"intrinsic gas too low": {
+			sendErr:     errors.New("intrinsic gas too low"),
+			expectedErr: ErrIntrinsicGasTooLow,
+		},
+		"underpriced": {
+			sendErr:     errors.New("transaction underpriced"),
+			expectedErr: ErrTransactionUnderpriced,
+		},
+		"already known": {
+			sendErr:     errors.New("already known"),
+			expectedErr: ErrTransactionAlreadyKnown,
+		},
+		"other": {
+			sendErr:     errors.New("invalid sender"),
+			expectedErr: ErrBroadcastFailed,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockClient := &mocks.Client{}
+			servicer := NewConstructionAPIService(cfg, mockClient)
+			ctx := context.Background()
+
+			mockClient.On(
+				"SendTransaction",
+				ctx,
+				mock.Anything,
+			).Return(
+				test.sendErr,
+			).Once()
+			resp, err := servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+				NetworkIdentifier: networkIdentifier,
+				SignedTransaction: signedRaw,
+			})
+			assert.Nil(t, resp)
+			assert.Equal(t, wrapErr(test.expectedErr, test.sendErr), err)
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConstructionService_Replacement(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	key, keyErr := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	assert.NoError(t, keyErr)
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	to := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	signer := ethTypes.LatestSignerForChainID(big.NewInt(3))
+
+	// Speed up a pending legacy transfer
+	legacyTx, signErr := ethTypes.SignNewTx(key, signer, &ethTypes.LegacyTx{
+		Nonce:    3,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      21000,
+		To:       &common.Address{},
+		Value:    big.NewInt(1000),
+	})
+	assert.NoError(t, signErr)
+
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"replace_transaction_hash": legacyTx.Hash().Hex(),
+			},
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":                     from,
+		"replace_transaction_hash": legacyTx.Hash().Hex(),
+	}, preprocessResponse.Options)
+
+	mockClient.On(
+		"TransactionByHash",
+		ctx,
+		legacyTx.Hash(),
+	).Return(
+		legacyTx,
+		true,
+		nil,
+	).Once()
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Once()
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":     "0x3",
+		"gas_price": "0x4190ab00",
+		"gas_limit": "0x5208",
+		"chain_id":  "0x3",
+	}, metadataResponse.Metadata)
+
+	// Cancel a pending dynamic fee transaction
+	dynamicTx, signErr := ethTypes.SignNewTx(key, signer, &ethTypes.DynamicFeeTx{
+		ChainID:   big.NewInt(3),
+		Nonce:     4,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(30000000000),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(1000),
+	})
+	assert.NoError(t, signErr)
+
+	cancelOps := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CancelOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+		},
+	}
+	_, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        cancelOps,
+		},
+	)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        cancelOps,
+			Metadata: map[string]interface{}{
+				"replace_transaction_hash": dynamicTx.Hash().Hex(),
+			},
+		},
+	)
+	assert.Nil(t, err)
+
+	mockClient.On(
+		"TransactionByHash",
+		ctx,
+		dynamicTx.Hash(),
+	).Return(
+		dynamicTx,
+		true,
+		nil,
+	).Once()
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{
+			Reward:  [][]*big.Int{{big.NewInt(1000000000)}},
+			BaseFee: []*big.Int{big.NewInt(10000000000), big.NewInt(10000000000)},
+		},
+		nil,
+	).Once()
+	metadataResponse, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":                    "0x4",
+		"max_priority_fee_per_gas": "0x83215600",
+		"max_fee_per_gas":          "0x7aef40a00",
+		"gas_limit":                "0x5208",
+		"chain_id":                 "0x3",
+	}, metadataResponse.Metadata)
+
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        cancelOps,
+		Metadata:          metadataResponse.Metadata,
+	})
+	assert.Nil(t, err)
+	unsignedRaw := `{"version":1,"type":"0x2","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + from + `","value":"0x0","data":"0x","nonce":"0x4","max_priority_fee_per_gas":"0x83215600","max_fee_per_gas":"0x7aef40a00","gas":"0x5208","chain_id":"0x3"}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       unsignedRaw,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cancelOps, parseResponse.Operations)
+
+	// Confirmed, unknown and foreign transactions
+	// cannot be replaced
+	otherKey, keyErr := crypto.GenerateKey()
+	assert.NoError(t, keyErr)
+	otherTx, signErr := ethTypes.SignNewTx(otherKey, signer, &ethTypes.LegacyTx{
+		Nonce:    3,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      21000,
+		To:       &common.Address{},
+	})
+	assert.NoError(t, signErr)
+	mockClient.On(
+		"TransactionByHash",
+		ctx,
+		otherTx.Hash(),
+	).Return(
+		otherTx,
+		true,
+		nil,
+	).Once()
+	mockClient.On(
+		"TransactionByHash",
+		ctx,
+		legacyTx.Hash(),
+	).Return(
+		legacyTx,
+		false,
+		nil,
+	).Once()
+	mockClient.On(
+		"TransactionByHash",
+		ctx,
+		common.HexToHash("0x01"),
+	).Return(
+		nil,
+		false,
+		geth.NotFound,
+	).Once()
+	for _, hash := range []common.Hash{
+		otherTx.Hash(),
+		legacyTx.Hash(),
+		common.HexToHash("0x01"),
+	} {
+		_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options: map[string]interface{}{
+				"from":                     from,
+				"replace_transaction_hash": hash.Hex(),
+			},
+		})
+		assert.Equal(t, ErrInvalidInput.Code, err.Code)
+	}
+
+	// A replacement has the nonce of the replaced transaction
+	_, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"replace_transaction_hash": legacyTx.Hash().Hex(),
+				"nonce":                    "3",
+			},
+		},
+	)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestValidateSignatures(t *testing.T) {
+	valid := make([]byte, 65)
+	valid[64] = 1
+
+	badRecoveryID := make([]byte, 65)
+	badRecoveryID[64] = 27
+
+	var tests = map[string]struct {
+		signatures []*types.Signature
+
+		err bool
+	}{
+		"valid": {
+			signatures: []*types.Signature{
+				{SignatureType: types.EcdsaRecovery, Bytes: valid},
+			},
+		},
+		"no signatures": {
+			err: true,
+		},
+		"multiple signatures": {
+			signatures: []*types.Signature{
+				{SignatureType: types.EcdsaRecovery, Bytes: valid},
+				{SignatureType: types.EcdsaRecovery, Bytes: valid},
+			},
+			err: true,
+		},
+		"schnorr": {
+			signatures: []*types.Signature{
+				{SignatureType: types.Schnorr1, Bytes: valid[:64]},
+			},
+			err: true,
+		},
+		"short": {
+			signatures: []*types.Signature{
+				{SignatureType: types.EcdsaRecovery, Bytes: valid[:64]},
+			},
+			err: true,
+		},
+		"invalid recovery id": {
+			signatures: []*types.Signature{
+				{SignatureType: types.EcdsaRecovery, Bytes: badRecoveryID},
+			},
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateSignatures(test.signatures)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// rpcError is a JSON-RPC error returned by geth.
+type rpcError struct {
+	message string
+	data    interface{}
+}
+
+func (e *rpcError) Error() string          { return e.message }
+func (e *rpcError) ErrorCode() int         { return 3 } // nolint:gomnd
+func (e *rpcError) ErrorData() interface{} { return e.data }
+
+func TestConstructionService_Simulation(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	from := "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"
+	contract := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	data := "0x3ccfd60b" // withdraw()
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: contract},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}
+
+	_, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"simulate": "true",
+			},
+		},
+	)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	// The gas limit of a simulated contract
+	// call is estimated
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+			Metadata: map[string]interface{}{
+				"data":     data,
+				"simulate": true,
+			},
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":      from,
+		"data":      data,
+		"simulate":  true,
+		"to":        contract,
+		"value":     "0x3e8",
+		"call_data": data,
+	}, preprocessResponse.Options)
+
+	contractAddress := common.HexToAddress(contract)
+	msg := geth.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    &contractAddress,
+		Value: big.NewInt(1000),
+		Data:  hexutil.MustDecode(data),
+	}
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(0),
+		nil,
+	).Times(3)
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Times(3)
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Times(3)
+	mockClient.On(
+		"CallContract",
+		ctx,
+		msg,
+		big.NewInt(-1),
+	).Return(
+		[]byte{},
+		nil,
+	).Once()
+	mockClient.On(
+		"EstimateGas",
+		ctx,
+		msg,
+	).Return(
+		uint64(45000),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionMetadataResponse{
+		Metadata: map[string]interface{}{
+			"nonce":     "0x0",
+			"gas_price": "0x3b9aca00",
+			"gas_limit": "0xafc8",
+			"chain_id":  "0x3",
+			"data":      data,
+		},
+		SuggestedFee: []*types.Amount{
+			{
+				Value:    "45000000000000",
+				Currency: ethereum.Currency,
+			},
+		},
+	}, metadataResponse)
+
+	// Reverts are returned with their reason
+	revertData := append(
+		hexutil.MustDecode("0x08c379a0"), // Error(string)
+		common.LeftPadBytes([]byte{0x20}, 32)...,
+	)
+	revertData = append(revertData, common.LeftPadBytes([]byte{byte(len("paused"))}, 32)...)
+	revertData = append(revertData, common.RightPadBytes([]byte("paused"), 32)...)
+	mockClient.On(
+		"CallContract",
+		ctx,
+		msg,
+		big.NewInt(-1),
+	).Return(
+		nil,
+		&rpcError{
+			message: "execution reverted: paused",
+			data:    hexutil.Encode(revertData),
+		},
+	).Once()
+	_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Equal(t, &types.Error{
+		Code:    ErrSimulationFailed.Code,
+		Message: ErrSimulationFailed.Message,
+		Details: map[string]interface{}{
+			"context":         "execution reverted: paused",
+			"revert_reason":   "paused",
+			"node_error_code": 3,
+			"node_error_data": hexutil.Encode(revertData),
+		},
+	}, err)
+
+	// Other errors are geth errors
+	mockClient.On(
+		"CallContract",
+		ctx,
+		msg,
+		big.NewInt(-1),
+	).Return(
+		nil,
+		errors.New("connection refused"),
+	).Once()
+	_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           preprocessResponse.Options,
+	})
+	assert.Equal(t, ErrGeth.Code, err.Code)
+
+	// Token transfers simulate the call to the token contract
+	token := &types.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			"contract_address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+	}
+	tokenOps := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000000", Currency: token},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: contract},
+			Amount:              &types.Amount{Value: "1000000", Currency: token},
+		},
+	}
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        tokenOps,
+			Metadata: map[string]interface{}{
+				"simulate": true,
+			},
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":      from,
+		"simulate":  true,
+		"to":        "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		"value":     "0x0",
+		"call_data": hexutil.Encode(erc20TransferData(contract, big.NewInt(1000000))),
+	}, preprocessResponse.Options)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_OfflineFees(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Offline,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	from := "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"
+	to := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}
+
+	// Without fees in the metadata, the
+	// network's offline fees are used
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce": "0x2",
+		},
+	})
+	assert.Nil(t, err)
+	unsignedRaw := `{"version":1,"type":"0x2","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + to + `","value":"0x3e8","data":"0x","nonce":"0x2","max_priority_fee_per_gas":"0x3b9aca00","max_fee_per_gas":"0x4a817c800","gas":"0x5208","chain_id":"0x3"}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       unsignedRaw,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"nonce":                    "0x2",
+		"max_priority_fee_per_gas": "0x3b9aca00",
+		"max_fee_per_gas":          "0x4a817c800",
+		"chain_id":                 "0x3",
+		"suggested_fee":            "0x17dfcdece4000",
+	}, parseResponse.Metadata)
+
+	// Configured offline fees replace those of the network
+	cfg.OfflineFees = &ethereum.Fees{GasPrice: big.NewInt(3000000000)}
+	payloadsResponse, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce": "0x2",
+		},
+	})
+	assert.Nil(t, err)
+	unsignedRaw = `{"version":1,"type":"0x0","chain_id":"0x3","transaction":` + `{"from":"` + from + `","to":"` + to + `","value":"0x3e8","data":"0x","nonce":"0x2","gas_price":"0xb2d05e00","gas":"0x5208","chain_id":"0x3"}` + `}` // nolint
+	assert.Equal(t, unsignedRaw, payloadsResponse.UnsignedTransaction)
+
+	// Networks without offline fees require
+	// fees in the metadata
+	cfg.OfflineFees = nil
+	cfg.Network = &types.NetworkIdentifier{
+		Network:    ethereum.CustomNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+	_, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce": "0x2",
+		},
+	})
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_FeeLimits(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:               configuration.Online,
+		Network:            networkIdentifier,
+		Params:             params.RopstenChainConfig,
+		GasLimitMultiplier: 1.5,
+		MaxGasLimit:        60000,
+		MaxFeeCap:          big.NewInt(2000000000),
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	from := "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"
+	contract := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	data := "0x3ccfd60b" // withdraw()
+	options := map[string]interface{}{
+		"from":      from,
+		"data":      data,
+		"simulate":  true,
+		"to":        contract,
+		"value":     "0x3e8",
+		"call_data": data,
+	}
+
+	contractAddress := common.HexToAddress(contract)
+	msg := geth.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    &contractAddress,
+		Value: big.NewInt(1000),
+		Data:  hexutil.MustDecode(data),
+	}
+	mockClient.On(
+		"PendingNonceAt",
+		ctx,
+		common.HexToAddress(from),
+	).Return(
+		uint64(0),
+		nil,
+	).Times(3)
+	mockClient.On(
+		"FeeHistory",
+		ctx,
+		uint64(20),
+		[]float64{50},
+	).Return(
+		&ethereum.FeeHistory{},
+		nil,
+	).Times(3)
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(1000000000),
+		nil,
+	).Twice()
+	mockClient.On(
+		"CallContract",
+		ctx,
+		msg,
+		big.NewInt(-1),
+	).Return(
+		[]byte{},
+		nil,
+	).Twice()
+
+	// Estimated gas limits have the safety margin applied
+	mockClient.On(
+		"EstimateGas",
+		ctx,
+		msg,
+	).Return(
+		uint64(30000),
+		nil,
+	).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           options,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "0xafc8", metadataResponse.Metadata["gas_limit"])
+
+	// Estimates exceeding the maximum gas limit are rejected
+	mockClient.On(
+		"EstimateGas",
+		ctx,
+		msg,
+	).Return(
+		uint64(50000),
+		nil,
+	).Once()
+	_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options:           options,
+	})
+	assert.Equal(t, &types.Error{
+		Code:    ErrFeeLimitExceeded.Code,
+		Message: ErrFeeLimitExceeded.Message,
+		Details: map[string]interface{}{
+			"context": "gas limit 75000 exceeds maximum 60000",
+		},
+	}, err)
+
+	// Gas prices exceeding the maximum fee cap are rejected
+	mockClient.On(
+		"SuggestGasPrice",
+		ctx,
+	).Return(
+		big.NewInt(3000000000),
+		nil,
+	).Once()
+	_, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options: map[string]interface{}{
+			"from": from,
+		},
+	})
+	assert.Equal(t, &types.Error{
+		Code:    ErrFeeLimitExceeded.Code,
+		Message: ErrFeeLimitExceeded.Message,
+		Details: map[string]interface{}{
+			"context": "fee per gas 3000000000 exceeds maximum 2000000000",
+		},
+	}, err)
+
+	// Payloads enforce the limits on the metadata provided
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: contract},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}
+	_, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce":     "0x0",
+			"gas_price": "0x3b9aca00",
+			"gas_limit": "0xafc8",
+		},
+	})
+	assert.Nil(t, err)
+
+	_, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce":     "0x0",
+			"gas_price": "0x3b9aca00",
+			"gas_limit": "0xf4240",
+		},
+	})
+	assert.Equal(t, ErrFeeLimitExceeded.Code, err.Code)
+
+	_, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce":                    "0x0",
+			"max_priority_fee_per_gas": "0x3b9aca00",
+			"max_fee_per_gas":          "0xba43b7400",
+		},
+	})
+	assert.Equal(t, ErrFeeLimitExceeded.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_ParseRawTransaction(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Offline,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	key, keyErr := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	assert.NoError(t, keyErr)
+	fromAddress := crypto.PubkeyToAddress(key.PublicKey)
+	from := fromAddress.Hex()
+
+	// A contract creation moves its value to the new contract
+	createTx, signErr := ethTypes.SignNewTx(
+		key,
+		ethTypes.LatestSignerForChainID(big.NewInt(3)),
+		&ethTypes.DynamicFeeTx{
+			ChainID:   big.NewInt(3),
+			Nonce:     7,
+			GasTipCap: big.NewInt(1000000000),
+			GasFeeCap: big.NewInt(2000000000),
+			Gas:       100000,
+			Value:     big.NewInt(5),
+			Data:      []byte{0x60, 0x00},
+		},
+	)
+	assert.NoError(t, signErr)
+	rawCreate, marshalErr := createTx.MarshalBinary()
+	assert.NoError(t, marshalErr)
+
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       hexutil.Encode(rawCreate),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ConstructionParseResponse{
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                ethereum.CreateOpType,
+				Account:             &types.AccountIdentifier{Address: from},
+				Amount:              &types.Amount{Value: "-5", Currency: ethereum.Currency},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+				Type:                ethereum.CreateOpType,
+				Account: &types.AccountIdentifier{
+					Address: crypto.CreateAddress(fromAddress, 7).Hex(),
+				},
+				Amount: &types.Amount{Value: "5", Currency: ethereum.Currency},
+			},
+		},
+		AccountIdentifierSigners: []*types.AccountIdentifier{{Address: from}},
+		Metadata: map[string]interface{}{
+			"nonce":                    "0x7",
+			"max_priority_fee_per_gas": "0x3b9aca00",
+			"max_fee_per_gas":          "0x77359400",
+			"chain_id":                 "0x3",
+			"data":                     "0x6000",
+			"suggested_fee":            "0xb5e620f48000",
+		},
+	}, parseResponse)
+
+	// Transactions without replay protection
+	// have no chain ID
+	to := common.HexToAddress("0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d")
+	legacyTx, signErr := ethTypes.SignNewTx(key, ethTypes.HomesteadSigner{}, &ethTypes.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(1000),
+	})
+	assert.NoError(t, signErr)
+	rawLegacy, marshalErr := legacyTx.MarshalBinary()
+	assert.NoError(t, marshalErr)
+
+	parseResponse, err = servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       hexutil.Encode(rawLegacy),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: ethereum.Currency},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: to.Hex()},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		},
+	}, parseResponse.Operations)
+	assert.Equal(t, []*types.AccountIdentifier{{Address: from}}, parseResponse.AccountIdentifierSigners)
+	assert.Equal(t, "0x0", parseResponse.Metadata["chain_id"])
+
+	_, err = servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       "0x1234",
+	})
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_BatchTransfer(t *testing.T) {
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Offline,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient)
+	ctx := context.Background()
+
+	from := "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"
+	batchContract := "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	recipients := []string{
+		"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d",
+		"0x0000000000000000000000000000000000000001",
+		"0x0000000000000000000000000000000000000002",
+	}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-3000", Currency: ethereum.Currency},
+		},
+	}
+	for i, recipient := range recipients {
+		ops = append(ops, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i + 1)},
+			RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+			Type:                ethereum.CallOpType,
+			Account:             &types.AccountIdentifier{Address: recipient},
+			Amount:              &types.Amount{Value: "1000", Currency: ethereum.Currency},
+		})
+	}
+
+	// Batch transfers require a batch contract
+	_, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+		},
+	)
+	assert.Equal(t, ErrInvalidInput.Code, err.Code)
+
+	cfg.BatchContract = batchContract
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        ops,
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":      from,
+		"gas_limit": "0x226c8",
+	}, preprocessResponse.Options)
+
+	// The sender must send what the recipients receive
+	unbalancedOps := append([]*types.Operation{}, ops...)
+	unbalancedOps[0] = &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                ethereum.CallOpType,
+		Account:             &types.AccountIdentifier{Address: from},
+		Amount:              &types.Amount{Value: "-2000", Currency: ethereum.Currency},
+	}
+	_, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        unbalancedOps,
+		},
+	)
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+
+	// The batch contract is called with the total value
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata: map[string]interface{}{
+			"nonce":     "0x1",
+			"gas_price": "0x3b9aca00",
+			"gas_limit": "0x226c8",
+			"chain_id":  "0x3",
+		},
+	})
+	assert.Nil(t, err)
+
+	unsignedTx, decodeErr := decodeUnsignedTransaction(payloadsResponse.UnsignedTransaction)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, batchContract, unsignedTx.To)
+	assert.Equal(t, big.NewInt(3000), unsignedTx.Value)
+	assert.Equal(t, uint64(141000), unsignedTx.GasLimit)
+	transfers, parseErr := parseBatchTransferData(unsignedTx.Data)
+	assert.NoError(t, parseErr)
+	assert.Len(t, transfers, len(recipients))
+
+	// Parse returns the individual transfers
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       payloadsResponse.UnsignedTransaction,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, ops, parseResponse.Operations)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestUnsignedTransactionVersions(t *testing.T) {
+	// Unsigned transactions created by /construction/payloads
+	// before the envelope was introduced are still combined.
+	networkIdentifier = &types.NetworkIdentifier{
+		Network:    ethereum.RopstenNetwork,
+		Blockchain: ethereum.Blockchain,
+	}
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Offline,
+		Network: networkIdentifier,
+		Params:  params.RopstenChainConfig,
+	}
+	servicer := NewConstructionAPIService(cfg, &mocks.Client{})
+	ctx := context.Background()
+
+	bareRaw := `{"from":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309","to":"0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d","value":"0x9864aac3510d02","data":"0x","nonce":"0x0","gas_price":"0x3b9aca00","gas":"0x5208","chain_id":"0x3"}` // nolint
+
+	signaturesRaw := `[{"hex_bytes":"8c712c64bc65c4a88707fa93ecd090144dffb1bf133805a10a51d354c2f9f2b25a63cea6989f4c58372c41f31164036a6b25dce1d5c05e1d31c16c0590c176e801","signing_payload":{"address":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309","hex_bytes":"b682f3e39c512ff57471f482eab264551487320cbd3b34485f4779a89e5612d1","account_identifier":{"address":"0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309"},"signature_type":"ecdsa_recovery"},"public_key":{"hex_bytes":"03d3d3358e7f69cbe45bde38d7d6f24660c7eeeaee5c5590cfab985c8839b21fd5","curve_type":"secp256k1"},"signature_type":"ecdsa_recovery"}]` // nolint
+	var signatures []*types.Signature
+	assert.NoError(t, json.Unmarshal([]byte(signaturesRaw), &signatures))
+
+	bareCombine, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: bareRaw,
+		Signatures:          signatures,
+	})
+	assert.Nil(t, err)
+
+	bareTx, decodeErr := decodeUnsignedTransaction(bareRaw)
+	assert.NoError(t, decodeErr)
+	envelopeRaw, encodeErr := encodeUnsignedTransaction(bareTx)
+	assert.NoError(t, encodeErr)
+	assert.Equal(t, `{"version":1,"type":"0x0","chain_id":"0x3","transaction":`+bareRaw+`}`, envelopeRaw)
+
+	envelopeCombine, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: envelopeRaw,
+		Signatures:          signatures,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, bareCombine, envelopeCombine)
+
+	// Both versions round-trip
+	dynamicTx := &transaction{
+		From:      "0xe3a5B4d7f79d64088C8d4ef153A7DDe2B2d47309",
+		To:        "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d",
+		Value:     big.NewInt(1000),
+		Data:      []byte{},
+		Nonce:     5,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(22000000000),
+		GasLimit:  21000,
+		ChainID:   big.NewInt(3),
+	}
+	dynamicRaw, encodeErr := encodeUnsignedTransaction(dynamicTx)
+	assert.NoError(t, encodeErr)
+	assert.Contains(t, dynamicRaw, `"type":"0x2"`)
+	decodedTx, decodeErr := decodeUnsignedTransaction(dynamicRaw)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, dynamicTx, decodedTx)
+
+	bareDynamicRaw, marshalErr := json.Marshal(dynamicTx)
+	assert.NoError(t, marshalErr)
+	decodedTx, decodeErr = decodeUnsignedTransaction(string(bareDynamicRaw))
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, dynamicTx, decodedTx)
+
+	// Unknown versions and inconsistent envelopes are rejected
+	var tests = map[string]string{
+		"unknown version": `{"version":2,"type":"0x0","chain_id":"0x3","transaction":` + bareRaw + `}`,
+		"wrong type":      `{"version":1,"type":"0x2","chain_id":"0x3","transaction":` + bareRaw + `}`,
+		"wrong chain ID":  `{"version":1,"type":"0x0","chain_id":"0x1","transaction":` + bareRaw + `}`,
+		"no transaction":  `{"version":1,"type":"0x0","chain_id":"0x3"}`,
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeUnsignedTransaction(raw)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// contractAddressKey is the key of the currency
+	// metadata holding the address of an ERC-20 token.
+	contractAddressKey = ethereum.ContractAddressKey
+
+	// erc20TransferDataLength is the length of the calldata
+	// of transfer(address,uint256): the method ID and two
+	// 32-byte arguments.
+	erc20TransferDataLength = 4 + 32 + 32
+)
+
+// erc20TransferMethodID is the method ID
+// of transfer(address,uint256).
+var erc20TransferMethodID = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+
+// tokenContract returns the checksummed contract address of
+// currency if it is an ERC-20 token (and false if it is ETH).
+func tokenContract(currency *types.Currency) (string, bool, error) {
+	if types.Hash(currency) == types.Hash(ethereum.Currency) {
+		return "", false, nil
+	}
+
+	raw, ok := currency.Metadata[contractAddressKey]
+	if !ok {
+		return "", false, fmt.Errorf(
+			"currency %s has no %s metadata",
+			currency.Symbol,
+			contractAddressKey,
+		)
+	}
+
+	contract, ok := raw.(string)
+	if !ok {
+		return "", false, fmt.Errorf("%s must be a string", contractAddressKey)
+	}
+
+	checkContract, ok := ethereum.ChecksumAddress(contract)
+	if !ok {
+		return "", false, fmt.Errorf("%s is not a valid address", contract)
+	}
+
+	return checkContract, true, nil
+}
+
+// erc20TransferData returns the calldata transferring
+// amount tokens to the address to.
+func erc20TransferData(to string, amount *big.Int) []byte {
+	data := make([]byte, 0, erc20TransferDataLength)
+	data = append(data, erc20TransferMethodID...)
+	data = append(data, common.LeftPadBytes(common.HexToAddress(to).Bytes(), 32)...)
+	data = append(data, math.U256Bytes(new(big.Int).Set(amount))...)
+
+	return data
+}
+
+// parseERC20TransferData returns the recipient and amount
+// of the transfer(address,uint256) calldata data.
+func parseERC20TransferData(data []byte) (string, *big.Int, error) {
+	if len(data) != erc20TransferDataLength ||
+		!bytes.Equal(data[:4], erc20TransferMethodID) {
+		return "", nil, fmt.Errorf("%x is not an ERC-20 transfer", data)
+	}
+
+	// The address is the last 20 bytes
+	// of the first argument.
+	if !bytes.Equal(data[4:16], make([]byte, 12)) {
+		return "", nil, fmt.Errorf("%x is not a valid recipient", data[4:36])
+	}
+
+	to := common.BytesToAddress(data[16:36])
+	amount := new(big.Int).SetBytes(data[36:])
+
+	return to.Hex(), amount, nil
+}
+
+// transferToken returns the contract address of the ERC-20
+// token moved by fromOp and toOp, or false if they move ETH.
+func transferToken(fromOp, toOp *types.Operation) (string, bool, *types.Error) {
+	if types.Hash(fromOp.Amount.Currency) != types.Hash(toOp.Amount.Currency) {
+		return "", false, wrapErr(
+			ErrUnclearIntent,
+			errors.New("operations must have the same currency"),
+		)
+	}
+
+	contract, isToken, err := tokenContract(toOp.Amount.Currency)
+	if err != nil {
+		return "", false, wrapErr(ErrUnclearIntent, err)
+	}
+
+	return contract, isToken, nil
+}
+
+// parseTokenTransfer returns the recipient and amount of
+// tokens of the token transfer tx, which must call the
+// contract of tx.Currency.
+func parseTokenTransfer(tx *transaction) (string, *big.Int, error) {
+	contract, isToken, err := tokenContract(tx.Currency)
+	if err != nil {
+		return "", nil, err
+	}
+	if !isToken {
+		return "", nil, fmt.Errorf("currency %s is not a token", tx.Currency.Symbol)
+	}
+
+	if common.HexToAddress(tx.To) != common.HexToAddress(contract) {
+		return "", nil, fmt.Errorf(
+			"transaction to %s does not call the contract %s of %s",
+			tx.To,
+			contract,
+			tx.Currency.Symbol,
+		)
+	}
+
+	return parseERC20TransferData(tx.Data)
+}
+
+// tokenCurrency is the field /construction/combine adds to
+// the signed transaction of a token transfer, so that
+// /construction/parse knows its currency (go-ethereum
+// ignores it when decoding the transaction).
+type tokenCurrency struct {
+	Currency *types.Currency `json:"currency,omitempty"`
+}
+
+// withTokenCurrency adds currency to the JSON
+// signed transaction signedTxJSON.
+func withTokenCurrency(signedTxJSON []byte, currency *types.Currency) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(signedTxJSON, &fields); err != nil {
+		return nil, err
+	}
+
+	rawCurrency, err := json.Marshal(currency)
+	if err != nil {
+		return nil, err
+	}
+	fields["currency"] = rawCurrency
+
+	return json.Marshal(fields)
+}
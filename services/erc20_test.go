@@ -0,0 +1,112 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestERC20TransferData(t *testing.T) {
+	to := "0x57B414a0332B5CaB885a451c2a28a07d1e9b8a8d"
+	amount := big.NewInt(1000000)
+
+	data := erc20TransferData(to, amount)
+	assert.Equal(
+		t,
+		"0xa9059cbb"+
+			"00000000000000000000000057b414a0332b5cab885a451c2a28a07d1e9b8a8d"+
+			"00000000000000000000000000000000000000000000000000000000000f4240",
+		hexutil.Encode(data),
+	)
+
+	parsedTo, parsedAmount, err := parseERC20TransferData(data)
+	assert.NoError(t, err)
+	assert.Equal(t, to, parsedTo)
+	assert.Equal(t, amount, parsedAmount)
+
+	// Other calls are not transfers
+	_, _, err = parseERC20TransferData(data[:36])
+	assert.Error(t, err)
+	approve := append(hexutil.MustDecode("0x095ea7b3"), data[4:]...)
+	_, _, err = parseERC20TransferData(approve)
+	assert.Error(t, err)
+	dirtyAddress := append([]byte{}, data...)
+	dirtyAddress[4] = 1
+	_, _, err = parseERC20TransferData(dirtyAddress)
+	assert.Error(t, err)
+}
+
+func TestTokenContract(t *testing.T) {
+	var tests = map[string]struct {
+		currency *types.Currency
+
+		contract string
+		isToken  bool
+		err      bool
+	}{
+		"eth": {
+			currency: ethereum.Currency,
+		},
+		"token": {
+			currency: &types.Currency{
+				Symbol:   "USDC",
+				Decimals: 6,
+				Metadata: map[string]interface{}{
+					"contract_address": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+				},
+			},
+			contract: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+			isToken:  true,
+		},
+		"no contract": {
+			currency: &types.Currency{
+				Symbol:   "USDC",
+				Decimals: 6,
+			},
+			err: true,
+		},
+		"invalid contract": {
+			currency: &types.Currency{
+				Symbol:   "USDC",
+				Decimals: 6,
+				Metadata: map[string]interface{}{
+					"contract_address": "0x1234",
+				},
+			},
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			contract, isToken, err := tokenContract(test.currency)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.contract, contract)
+			assert.Equal(t, test.isToken, isToken)
+		})
+	}
+}
@@ -15,7 +15,29 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// contextKey is the key of the message of the
+	// underlying error in the details of errors.
+	contextKey = "context"
+
+	// nodeErrorCodeKey and nodeErrorDataKey are the keys
+	// of the JSON-RPC error code and data returned by geth
+	// in the details of errors.
+	nodeErrorCodeKey = "node_error_code"
+	nodeErrorDataKey = "node_error_data"
 )
 
 var (
@@ -37,6 +59,25 @@ var (
 		ErrInvalidAddress,
 		ErrGethNotReady,
 		ErrInvalidInput,
+		ErrBlockPruned,
+		ErrNonceTooLow,
+		ErrReplacementUnderpriced,
+		ErrNonceGap,
+		ErrSimulationFailed,
+		ErrFeeLimitExceeded,
+		ErrTransactionNotFound,
+		ErrIndexer,
+		ErrBlockNotIndexed,
+		ErrGethUnavailable,
+		ErrBlockNotFound,
+		ErrIntrinsicGasTooLow,
+		ErrInsufficientFunds,
+		ErrTransactionUnderpriced,
+		ErrTransactionAlreadyKnown,
+		ErrRateLimited,
+		ErrInternal,
+		ErrRequestTooLarge,
+		ErrInvalidRequest,
 	}
 
 	// ErrUnimplemented is returned when an endpoint
@@ -152,8 +193,199 @@ var (
 		Code:    14, //nolint
 		Message: "invalid input",
 	}
+
+	// ErrBlockPruned is returned when the state of
+	// a requested block has been pruned by geth, or
+	// its transactions have been pruned from the index.
+	ErrBlockPruned = &types.Error{
+		Code:    15, //nolint
+		Message: "Block pruned",
+	}
+
+	// ErrNonceTooLow is returned when a transaction is
+	// rejected by geth because its nonce has already been
+	// used. It can be constructed again with a new nonce.
+	ErrNonceTooLow = &types.Error{
+		Code:      16, //nolint
+		Message:   "Nonce too low",
+		Retriable: true,
+	}
+
+	// ErrReplacementUnderpriced is returned when a transaction
+	// replacing a pending one is rejected by geth because its
+	// fee is not high enough. It can be constructed again with
+	// a higher fee.
+	ErrReplacementUnderpriced = &types.Error{
+		Code:      17, //nolint
+		Message:   "Replacement transaction underpriced",
+		Retriable: true,
+	}
+
+	// ErrNonceGap is returned when the nonce provided
+	// in /construction/preprocess is above the pending
+	// nonce of the account (or geth rejects a transaction
+	// whose nonce is too high), so the transaction would
+	// not be executed until the missing nonces are used.
+	ErrNonceGap = &types.Error{
+		Code:      18, //nolint
+		Message:   "Nonce gap",
+		Retriable: true,
+	}
+
+	// ErrSimulationFailed is returned when geth fails to
+	// execute the transaction simulated in /construction/metadata
+	// (for example because it reverts or runs out of gas). Its
+	// details contain the revert reason, if the contract
+	// provided one.
+	ErrSimulationFailed = &types.Error{
+		Code:    19, //nolint
+		Message: "Transaction simulation failed",
+	}
+
+	// ErrFeeLimitExceeded is returned when the gas limit
+	// or fee per gas of a transaction exceeds the
+	// MAX_GAS_LIMIT or MAX_FEE_CAP configuration.
+	ErrFeeLimitExceeded = &types.Error{
+		Code:    20, //nolint
+		Message: "Fee limit exceeded",
+	}
+
+	// ErrTransactionNotFound is returned when the
+	// transaction requested from /mempool/transaction
+	// is not in the mempool (it may have been included
+	// in a block or dropped), or when the transaction
+	// requested from /block/transaction is unknown to
+	// geth (or, in INDEXER mode, to the local index).
+	ErrTransactionNotFound = &types.Error{
+		Code:    21, //nolint
+		Message: "Transaction not found",
+	}
+
+	// ErrIndexer is returned when the local
+	// index errors on a request.
+	ErrIndexer = &types.Error{
+		Code:    22, //nolint
+		Message: "Indexer error",
+	}
+
+	// ErrBlockNotIndexed is returned in INDEXER mode
+	// when the requested block is not in the local
+	// index (it may not be indexed yet).
+	ErrBlockNotIndexed = &types.Error{
+		Code:      23, //nolint
+		Message:   "Block not indexed",
+		Retriable: true,
+	}
+
+	// ErrGethUnavailable is returned when geth
+	// cannot be reached or does not answer a
+	// request in time, or while requests to geth
+	// fail fast because it keeps failing.
+	ErrGethUnavailable = &types.Error{
+		Code:      24, //nolint
+		Message:   "geth unavailable",
+		Retriable: true,
+	}
+
+	// ErrBlockNotFound is returned when the requested
+	// block is unknown to geth (it may not be mined
+	// yet).
+	ErrBlockNotFound = &types.Error{
+		Code:      25, //nolint
+		Message:   "Block not found",
+		Retriable: true,
+	}
+
+	// ErrIntrinsicGasTooLow is returned when a transaction
+	// is rejected by geth because its gas limit is below
+	// the gas it uses before executing any code.
+	ErrIntrinsicGasTooLow = &types.Error{
+		Code:    26, //nolint
+		Message: "Intrinsic gas too low",
+	}
+
+	// ErrInsufficientFunds is returned when a transaction
+	// is rejected by geth because its sender cannot pay
+	// for its value and gas.
+	ErrInsufficientFunds = &types.Error{
+		Code:    27, //nolint
+		Message: "Insufficient funds",
+	}
+
+	// ErrTransactionUnderpriced is returned when a
+	// transaction is rejected by geth because its fee
+	// is below the minimum of the mempool (or the base
+	// fee of the next block). It can be constructed
+	// again with a higher fee.
+	ErrTransactionUnderpriced = &types.Error{
+		Code:      28, //nolint
+		Message:   "Transaction underpriced",
+		Retriable: true,
+	}
+
+	// ErrTransactionAlreadyKnown is returned when a
+	// transaction submitted is already in the mempool
+	// of geth.
+	ErrTransactionAlreadyKnown = &types.Error{
+		Code:    29, //nolint
+		Message: "Transaction already known",
+	}
+
+	// ErrRateLimited is returned when a request exceeds
+	// the rate limits of the server. The number of seconds
+	// to wait before retrying is in its details.
+	ErrRateLimited = &types.Error{
+		Code:      30, //nolint
+		Message:   "Rate limit exceeded",
+		Retriable: true,
+	}
+
+	// ErrInternal is returned when serving a request
+	// fails unexpectedly (i.e. on a block of an unknown
+	// shape). The failure is logged with the request ID
+	// in the details of the error.
+	ErrInternal = &types.Error{
+		Code:    31, //nolint
+		Message: "Internal error",
+	}
+
+	// ErrRequestTooLarge is returned when the body of a
+	// request is larger than the maximum request size.
+	ErrRequestTooLarge = &types.Error{
+		Code:    32, //nolint
+		Message: "Request too large",
+	}
+
+	// ErrInvalidRequest is returned when the body of a
+	// request is not valid JSON, is nested too deeply,
+	// has duplicate keys or (in construction requests)
+	// arrays that are too long. The reason is in its
+	// details.
+	ErrInvalidRequest = &types.Error{
+		Code:    33, //nolint
+		Message: "Invalid request",
+	}
 )
 
+// nodeErrors maps the messages of the errors returned by geth
+// to the errors they are returned as. Messages are matched in
+// order, so a message must come before any message it contains.
+var nodeErrors = []struct {
+	message string
+	err     *types.Error
+}{
+	{core.ErrNonceTooLow.Error(), ErrNonceTooLow},
+	{core.ErrNonceTooHigh.Error(), ErrNonceGap},
+	{core.ErrReplaceUnderpriced.Error(), ErrReplacementUnderpriced},
+	{core.ErrUnderpriced.Error(), ErrTransactionUnderpriced},
+	{core.ErrFeeCapTooLow.Error(), ErrTransactionUnderpriced},
+	{core.ErrIntrinsicGas.Error(), ErrIntrinsicGasTooLow},
+	{core.ErrInsufficientFunds.Error(), ErrInsufficientFunds},
+	{core.ErrAlreadyKnown.Error(), ErrTransactionAlreadyKnown},
+	{core.ErrGasLimit.Error(), ErrFeeLimitExceeded},
+	{"exceeds the configured cap", ErrFeeLimitExceeded},
+}
+
 // wrapErr adds details to the types.Error provided. We use a function
 // to do this so that we don't accidentially overrwrite the standard
 // errors.
@@ -165,9 +397,79 @@ func wrapErr(rErr *types.Error, err error) *types.Error {
 	}
 	if err != nil {
 		newErr.Details = map[string]interface{}{
-			"context": err.Error(),
+			contextKey: err.Error(),
 		}
 	}
 
 	return newErr
 }
+
+// nodeErr returns the error err returned by geth (or by the
+// client while making a request to geth) is returned as, or
+// fallback if it is not a known error. The details of the
+// error carry the message of err and, if geth returned one,
+// its JSON-RPC error code and data.
+func nodeErr(fallback *types.Error, err error) *types.Error {
+	rErr := wrapErr(classifyNodeErr(err, fallback), err)
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		rErr.Details[nodeErrorCodeKey] = rpcErr.ErrorCode()
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		rErr.Details[nodeErrorDataKey] = dataErr.ErrorData()
+	}
+
+	return rErr
+}
+
+// gethErr returns the error err returned by geth
+// is returned as (ErrGeth if it is not known).
+func gethErr(err error) *types.Error {
+	return nodeErr(ErrGeth, err)
+}
+
+// classifyNodeErr returns the error err is returned
+// as, or fallback if it is not a known error.
+func classifyNodeErr(err error, fallback *types.Error) *types.Error {
+	switch {
+	case errors.Is(err, ethereum.ErrCallParametersInvalid):
+		return ErrCallParametersInvalid
+	case errors.Is(err, ethereum.ErrCallOutputMarshal):
+		return ErrCallOutputMarshal
+	case errors.Is(err, ethereum.ErrCallMethodInvalid):
+		return ErrCallMethodInvalid
+	case errors.Is(err, ethereum.ErrBlockOrphaned):
+		return ErrBlockOrphaned
+	case errors.Is(err, ethereum.ErrStateUnavailable):
+		return ErrBlockPruned
+	case errors.Is(err, geth.NotFound):
+		return ErrBlockNotFound
+	case errors.Is(err, ethereum.ErrCircuitOpen):
+		return ErrGethUnavailable
+	}
+
+	message := err.Error()
+	if ethereum.StateUnavailable(message) {
+		return ErrBlockPruned
+	}
+
+	for _, nodeError := range nodeErrors {
+		if strings.Contains(message, nodeError.message) {
+			return nodeError.err
+		}
+	}
+
+	// geth could not be reached or did not answer in
+	// time, as opposed to rejecting the request.
+	var rpcErr rpc.Error
+	var netErr net.Error
+	if !errors.As(err, &rpcErr) &&
+		(errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)) {
+		return ErrGethUnavailable
+	}
+
+	return fallback
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	assert.NoError(t, err)
+
+	// Every error declared in errors.go is advertised.
+	declared := 0
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if strings.HasPrefix(name.Name, "Err") && name.Name != "Errors" {
+					declared++
+				}
+			}
+		}
+	}
+	assert.Len(t, Errors, declared)
+
+	// Codes are unique and sequential, so that each
+	// new error takes the next code.
+	for i, rErr := range Errors {
+		assert.Equal(t, int32(i), rErr.Code, rErr.Message)
+		assert.NotEmpty(t, rErr.Message)
+	}
+}
+
+type testNodeError struct {
+	message string
+	code    int
+}
+
+func (e *testNodeError) Error() string  { return e.message }
+func (e *testNodeError) ErrorCode() int { return e.code }
+
+func TestGethErr(t *testing.T) {
+	var tests = map[string]struct {
+		err error
+
+		expectedErr *types.Error
+		details     map[string]interface{}
+	}{
+		"unknown": {
+			err:         errors.New("unable to decode block"),
+			expectedErr: ErrGeth,
+		},
+		"client error": {
+			err:         fmt.Errorf("%w: unknown method", ethereum.ErrCallMethodInvalid),
+			expectedErr: ErrCallMethodInvalid,
+		},
+		"orphaned": {
+			err:         fmt.Errorf("%w: receipt not found", ethereum.ErrBlockOrphaned),
+			expectedErr: ErrBlockOrphaned,
+		},
+		"state unavailable": {
+			err:         fmt.Errorf("%w: missing trie node", ethereum.ErrStateUnavailable),
+			expectedErr: ErrBlockPruned,
+		},
+		"not found": {
+			err:         geth.NotFound,
+			expectedErr: ErrBlockNotFound,
+		},
+		"pruned state": {
+			err:         &testNodeError{message: "missing trie node 1a2b (path )", code: -32000},
+			expectedErr: ErrBlockPruned,
+			details:     map[string]interface{}{"node_error_code": -32000},
+		},
+		"nonce too low": {
+			err: fmt.Errorf(
+				"%w: transaction fetch failed",
+				&testNodeError{message: "nonce too low", code: -32000},
+			),
+			expectedErr: ErrNonceTooLow,
+			details:     map[string]interface{}{"node_error_code": -32000},
+		},
+		"replacement underpriced": {
+			err:         &testNodeError{message: "replacement transaction underpriced", code: -32000},
+			expectedErr: ErrReplacementUnderpriced,
+			details:     map[string]interface{}{"node_error_code": -32000},
+		},
+		"fee cap": {
+			err:         &testNodeError{message: "tx fee (1.20 ether) exceeds the configured cap (1.00 ether)"},
+			expectedErr: ErrFeeLimitExceeded,
+			details:     map[string]interface{}{"node_error_code": 0},
+		},
+		"rejected": {
+			err:         &testNodeError{message: "method not found", code: -32601},
+			expectedErr: ErrGeth,
+			details:     map[string]interface{}{"node_error_code": -32601},
+		},
+		"timeout": {
+			err:         fmt.Errorf("%w: block fetch failed", context.DeadlineExceeded),
+			expectedErr: ErrGethUnavailable,
+		},
+		"circuit open": {
+			err:         ethereum.ErrCircuitOpen,
+			expectedErr: ErrGethUnavailable,
+		},
+		"unreachable": {
+			err:         &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectedErr: ErrGethUnavailable,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expected := wrapErr(test.expectedErr, test.err)
+			for key, value := range test.details {
+				expected.Details[key] = value
+			}
+
+			assert.Equal(t, expected, gethErr(test.err))
+		})
+	}
+}
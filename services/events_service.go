@@ -0,0 +1,105 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// defaultEventsLimit is the number of block events
+	// returned when no limit is requested.
+	defaultEventsLimit = 100
+
+	// maxEventsLimit is the largest number of block
+	// events returned in one response.
+	maxEventsLimit = 1000
+)
+
+// EventsAPIService implements the server.EventsAPIServicer interface.
+type EventsAPIService struct {
+	config  *configuration.Configuration
+	indexer Indexer
+}
+
+// NewEventsAPIService creates a new instance of an EventsAPIService.
+// indexer is nil when neither BLOCK_EVENTS nor TRANSACTION_INDEX is
+// enabled.
+func NewEventsAPIService(
+	config *configuration.Configuration,
+	indexer Indexer,
+) server.EventsAPIServicer {
+	return &EventsAPIService{
+		config:  config,
+		indexer: indexer,
+	}
+}
+
+// EventsBlocks implements the /events/blocks endpoint.
+func (s *EventsAPIService) EventsBlocks(
+	ctx context.Context,
+	request *types.EventsBlocksRequest,
+) (*types.EventsBlocksResponse, *types.Error) {
+	if !s.config.Mode.IsOnline() {
+		return nil, ErrUnavailableOffline
+	}
+
+	if s.indexer == nil {
+		return nil, wrapErr(ErrUnimplemented, errors.New("BLOCK_EVENTS is not enabled"))
+	}
+
+	limit := int64(defaultEventsLimit)
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	if limit > maxEventsLimit {
+		limit = maxEventsLimit
+	}
+
+	// Without an offset, the latest events are returned.
+	var offset int64
+	if request.Offset != nil {
+		offset = *request.Offset
+	} else {
+		maxSequence, _, err := s.indexer.BlockEvents(ctx, 0, 0)
+		if err != nil {
+			return nil, wrapErr(ErrIndexer, err)
+		}
+
+		if offset = maxSequence - limit + 1; offset < 0 {
+			offset = 0
+		}
+	}
+
+	maxSequence, events, err := s.indexer.BlockEvents(ctx, offset, limit)
+	if err != nil {
+		return nil, wrapErr(ErrIndexer, err)
+	}
+
+	// No event has been recorded yet.
+	if maxSequence < 0 {
+		maxSequence = 0
+	}
+
+	return &types.EventsBlocksResponse{
+		MaxSequence: maxSequence,
+		Events:      events,
+	}, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsService_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewEventsAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestEventsService_Disabled(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	servicer := NewEventsAPIService(cfg, nil)
+	ctx := context.Background()
+
+	resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnimplemented.Code, err.Code)
+	assert.Equal(t, "BLOCK_EVENTS is not enabled", err.Details["context"])
+}
+
+func TestEventsService_Online(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewEventsAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	events := []*types.BlockEvent{
+		{
+			Sequence: 3,
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  "0x3c5a2a1dd3c1a6bd2caa0e3dd2e2d0e8fa6e34db4f4d3e8a2bf0e0a6e0c51d31",
+				Index: 10,
+			},
+			Type: types.ADDED,
+		},
+		{
+			Sequence: 4,
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  "0x3c5a2a1dd3c1a6bd2caa0e3dd2e2d0e8fa6e34db4f4d3e8a2bf0e0a6e0c51d31",
+				Index: 10,
+			},
+			Type: types.REMOVED,
+		},
+	}
+
+	t.Run("offset", func(t *testing.T) {
+		offset := int64(3)
+		limit := int64(2)
+		mockIndexer.On("BlockEvents", ctx, offset, limit).Return(int64(9), events, nil).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+			Offset: &offset,
+			Limit:  &limit,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, &types.EventsBlocksResponse{
+			MaxSequence: 9,
+			Events:      events,
+		}, resp)
+		assert.NoError(t, asserter.EventsBlocksResponse(resp))
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		// Without an offset, the last limit events are returned.
+		limit := int64(2)
+		mockIndexer.On("BlockEvents", ctx, int64(0), int64(0)).Return(int64(4), []*types.BlockEvent{}, nil).Once()
+		mockIndexer.On("BlockEvents", ctx, int64(3), limit).Return(int64(4), events, nil).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+			Limit: &limit,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), resp.MaxSequence)
+		assert.Equal(t, events, resp.Events)
+	})
+
+	t.Run("default limit", func(t *testing.T) {
+		offset := int64(0)
+		mockIndexer.On("BlockEvents", ctx, offset, int64(defaultEventsLimit)).Return(int64(4), events, nil).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+			Offset: &offset,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, events, resp.Events)
+	})
+
+	t.Run("max limit", func(t *testing.T) {
+		offset := int64(0)
+		limit := int64(maxEventsLimit + 1)
+		mockIndexer.On("BlockEvents", ctx, offset, int64(maxEventsLimit)).Return(int64(4), events, nil).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+			Offset: &offset,
+			Limit:  &limit,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, events, resp.Events)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mockIndexer.On("BlockEvents", ctx, int64(0), int64(0)).Return(int64(-1), []*types.BlockEvent{}, nil).Once()
+		mockIndexer.On("BlockEvents", ctx, int64(0), int64(defaultEventsLimit)).Return(int64(-1), []*types.BlockEvent{}, nil).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, &types.EventsBlocksResponse{
+			MaxSequence: 0,
+			Events:      []*types.BlockEvent{},
+		}, resp)
+		assert.NoError(t, asserter.EventsBlocksResponse(resp))
+	})
+
+	t.Run("indexer error", func(t *testing.T) {
+		offset := int64(0)
+		mockIndexer.On("BlockEvents", ctx, offset, int64(defaultEventsLimit)).Return(int64(-1), nil, errors.New("closed")).Once()
+
+		resp, err := servicer.EventsBlocks(ctx, &types.EventsBlocksRequest{
+			Offset: &offset,
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrIndexer.Code, err.Code)
+		assert.Equal(t, "closed", err.Details["context"])
+	})
+
+	mockIndexer.AssertExpectations(t)
+}
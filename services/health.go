@@ -0,0 +1,246 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// HealthPath is the path of the liveness endpoint.
+	HealthPath = "/healthz"
+
+	// ReadyPath is the path of the readiness endpoint.
+	ReadyPath = "/readyz"
+
+	// defaultReadyMaxLag is the number of blocks geth and
+	// the index may be behind the tip of the chain while
+	// ready, when READY_MAX_LAG is not set.
+	defaultReadyMaxLag = 10
+
+	// readyTimeout is the longest the checks
+	// of a readiness probe may take.
+	readyTimeout = 5 * time.Second
+
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// Names of the readiness checks.
+const (
+	checkNode    = "node"
+	checkChainID = "chain_id"
+	checkSync    = "sync"
+	checkIndexer = "indexer"
+	checkBreaker = "circuit_breaker"
+)
+
+// healthCheck is the outcome of a readiness check.
+type healthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// healthResponse is the body of /healthz and /readyz.
+type healthResponse struct {
+	Status string         `json:"status"`
+	Checks []*healthCheck `json:"checks,omitempty"`
+}
+
+// HealthService serves the liveness and readiness
+// endpoints used by orchestration (i.e. Kubernetes
+// probes and load balancer health checks).
+type HealthService struct {
+	config  *configuration.Configuration
+	client  Client
+	indexer Indexer
+}
+
+// NewHealthService creates a new instance of a HealthService.
+func NewHealthService(
+	cfg *configuration.Configuration,
+	client Client,
+	indexer Indexer,
+) *HealthService {
+	return &HealthService{
+		config:  cfg,
+		client:  client,
+		indexer: indexer,
+	}
+}
+
+// writeHealth writes response with status code.
+func writeHealth(w http.ResponseWriter, code int, response *healthResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response) // nolint:errcheck
+}
+
+// Health implements the /healthz endpoint: it
+// succeeds as long as the process serves requests.
+func (s *HealthService) Health(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, &healthResponse{Status: statusOK})
+}
+
+// Ready implements the /readyz endpoint: it succeeds
+// only if every readiness check passes, and returns
+// 503 Service Unavailable otherwise.
+func (s *HealthService) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := s.readiness(ctx)
+	for _, check := range checks {
+		if !check.OK {
+			writeHealth(w, http.StatusServiceUnavailable, &healthResponse{
+				Status: statusUnavailable,
+				Checks: checks,
+			})
+			return
+		}
+	}
+
+	writeHealth(w, http.StatusOK, &healthResponse{Status: statusOK, Checks: checks})
+}
+
+// maxLag returns the number of blocks geth and the index
+// may be behind the tip of the chain while ready.
+func (s *HealthService) maxLag() int64 {
+	if s.config.ReadyMaxLag > 0 {
+		return s.config.ReadyMaxLag
+	}
+
+	return defaultReadyMaxLag
+}
+
+// readiness checks that geth is reachable, serves the
+// configured chain and is synced within maxLag blocks of the
+// tip, that the index (if any) is within maxLag blocks of
+// geth, and that the circuit breaker of requests to geth is
+// closed. There is nothing to check in offline mode.
+func (s *HealthService) readiness(ctx context.Context) []*healthCheck {
+	if !s.config.Mode.IsOnline() {
+		return nil
+	}
+
+	// While the breaker is half-open, this request
+	// probes geth and closes it if geth recovered.
+	currentBlock, _, syncStatus, _, err := s.client.Status(ctx)
+	if err != nil {
+		// The other checks need geth.
+		return []*healthCheck{failedCheck(checkNode, err), s.breakerCheck()}
+	}
+
+	checks := []*healthCheck{{Name: checkNode, OK: true}}
+
+	if err := s.client.ValidateNetwork(ctx, nil); err != nil {
+		checks = append(checks, failedCheck(checkChainID, err))
+	} else {
+		checks = append(checks, &healthCheck{Name: checkChainID, OK: true})
+	}
+
+	if err := s.checkSync(syncStatus); err != nil {
+		checks = append(checks, failedCheck(checkSync, err))
+	} else {
+		checks = append(checks, &healthCheck{Name: checkSync, OK: true})
+	}
+
+	if s.indexer != nil {
+		if err := s.checkIndexer(ctx, currentBlock.Index); err != nil {
+			checks = append(checks, failedCheck(checkIndexer, err))
+		} else {
+			checks = append(checks, &healthCheck{Name: checkIndexer, OK: true})
+		}
+	}
+
+	return append(checks, s.breakerCheck())
+}
+
+// checkSync returns an error if geth has not synced
+// the state of the tip or is more than maxLag blocks
+// behind it.
+func (s *HealthService) checkSync(syncStatus *types.SyncStatus) error {
+	if syncStatus == nil || syncStatus.Stage == nil {
+		return nil
+	}
+
+	switch *syncStatus.Stage {
+	case ethereum.SyncStageNotStarted:
+		return errors.New("geth has not started syncing")
+	case ethereum.SyncStageState:
+		return errors.New("geth is syncing the state of the tip")
+	}
+
+	if syncStatus.CurrentIndex == nil || syncStatus.TargetIndex == nil {
+		return nil
+	}
+
+	if lag := *syncStatus.TargetIndex - *syncStatus.CurrentIndex; lag > s.maxLag() {
+		return fmt.Errorf("geth is %d blocks behind the tip", lag)
+	}
+
+	return nil
+}
+
+// checkIndexer returns an error if the index is more
+// than maxLag blocks behind the block at currentIndex.
+func (s *HealthService) checkIndexer(ctx context.Context, currentIndex int64) error {
+	head, err := s.indexer.Head(ctx)
+	if err != nil {
+		return err
+	}
+
+	if lag := currentIndex - head.BlockIdentifier.Index; lag > s.maxLag() {
+		return fmt.Errorf("the index is %d blocks behind geth", lag)
+	}
+
+	return nil
+}
+
+// breakerCheck returns the outcome of checkBreaker.
+func (s *HealthService) breakerCheck() *healthCheck {
+	if err := s.checkBreaker(); err != nil {
+		return failedCheck(checkBreaker, err)
+	}
+
+	return &healthCheck{Name: checkBreaker, OK: true}
+}
+
+// checkBreaker returns an error if the circuit
+// breaker of requests to geth is not closed.
+func (s *HealthService) checkBreaker() error {
+	if state := s.client.BreakerState(); state != ethereum.BreakerClosed {
+		return fmt.Errorf("the circuit breaker of requests to geth is %s", state)
+	}
+
+	return nil
+}
+
+// failedCheck returns the check name that failed with err.
+func failedCheck(name string, err error) *healthCheck {
+	return &healthCheck{Name: name, Error: err.Error()}
+}
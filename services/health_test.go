@@ -0,0 +1,268 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// probe returns the status code and body of a
+// request to path served by s.
+func probe(t *testing.T, s *HealthService, path string) (int, *healthResponse) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, s.Health)
+	mux.HandleFunc(ReadyPath, s.Ready)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response healthResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	return recorder.Code, &response
+}
+
+func testSyncStatus(stage string, current int64, target int64) *types.SyncStatus {
+	return &types.SyncStatus{
+		CurrentIndex: types.Int64(current),
+		TargetIndex:  types.Int64(target),
+		Stage:        types.String(stage),
+		Synced:       types.Bool(stage == ethereum.SyncStageSynced),
+	}
+}
+
+func TestHealth_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{Mode: configuration.Offline}
+	mockClient := &mocks.Client{}
+	s := NewHealthService(cfg, mockClient, nil)
+
+	code, response := probe(t, s, HealthPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &healthResponse{Status: statusOK}, response)
+
+	// There is no node to check.
+	code, response = probe(t, s, ReadyPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &healthResponse{Status: statusOK}, response)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestReady(t *testing.T) {
+	head := &types.BlockIdentifier{Index: 1000, Hash: "block 1000"}
+
+	tests := map[string]struct {
+		statusErr   error
+		syncStatus  *types.SyncStatus
+		networkErr  error
+		indexed     *int64
+		indexerErr  error
+		readyMaxLag int64
+		breaker     ethereum.BreakerState
+
+		code   int
+		checks []*healthCheck
+	}{
+		"ready": {
+			syncStatus: testSyncStatus(ethereum.SyncStageSynced, 1000, 1000),
+			code:       http.StatusOK,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"node unreachable": {
+			statusErr: errors.New("connection refused"),
+			code:      http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, Error: "connection refused"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"circuit open": {
+			statusErr: ethereum.ErrCircuitOpen,
+			breaker:   ethereum.BreakerOpen,
+			code:      http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, Error: "circuit breaker open"},
+				{Name: checkBreaker, Error: "the circuit breaker of requests to geth is open"},
+			},
+		},
+		"wrong chain": {
+			syncStatus: testSyncStatus(ethereum.SyncStageSynced, 1000, 1000),
+			networkErr: fmt.Errorf("%w: expected chain ID 1 but node has 5", ethereum.ErrNetworkMismatch),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, Error: "network mismatch: expected chain ID 1 but node has 5"},
+				{Name: checkSync, OK: true},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"syncing within max lag": {
+			syncStatus: testSyncStatus(ethereum.SyncStageBlocks, 995, 1005),
+			code:       http.StatusOK,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"syncing behind": {
+			syncStatus: testSyncStatus(ethereum.SyncStageBlocks, 900, 1005),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, Error: "geth is 105 blocks behind the tip"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"syncing behind with larger max lag": {
+			syncStatus:  testSyncStatus(ethereum.SyncStageBlocks, 900, 1005),
+			readyMaxLag: 128,
+			code:        http.StatusOK,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"syncing state": {
+			syncStatus: testSyncStatus(ethereum.SyncStageState, 1005, 1005),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, Error: "geth is syncing the state of the tip"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"not started": {
+			syncStatus: testSyncStatus(ethereum.SyncStageNotStarted, 0, 0),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, Error: "geth has not started syncing"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"indexer caught up": {
+			syncStatus: testSyncStatus(ethereum.SyncStageSynced, 1000, 1000),
+			indexed:    types.Int64(995),
+			code:       http.StatusOK,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkIndexer, OK: true},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"indexer behind": {
+			syncStatus: testSyncStatus(ethereum.SyncStageSynced, 1000, 1000),
+			indexed:    types.Int64(10),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkIndexer, Error: "the index is 990 blocks behind geth"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+		"indexer empty": {
+			syncStatus: testSyncStatus(ethereum.SyncStageSynced, 1000, 1000),
+			indexerErr: errors.New("the index is empty"),
+			code:       http.StatusServiceUnavailable,
+			checks: []*healthCheck{
+				{Name: checkNode, OK: true},
+				{Name: checkChainID, OK: true},
+				{Name: checkSync, OK: true},
+				{Name: checkIndexer, Error: "the index is empty"},
+				{Name: checkBreaker, OK: true},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:        configuration.Online,
+				ReadyMaxLag: test.readyMaxLag,
+			}
+			mockClient := &mocks.Client{}
+			mockIndexer := &mocks.Indexer{}
+
+			var blockIndexer Indexer
+			if test.indexed != nil || test.indexerErr != nil {
+				blockIndexer = mockIndexer
+			}
+			s := NewHealthService(cfg, mockClient, blockIndexer)
+
+			breaker := ethereum.BreakerClosed
+			if len(test.breaker) > 0 {
+				breaker = test.breaker
+			}
+			mockClient.On("BreakerState").Return(breaker).Once()
+
+			if test.statusErr != nil {
+				mockClient.On("Status", mock.Anything).Return(nil, int64(-1), nil, nil, test.statusErr).Once()
+			} else {
+				mockClient.On("Status", mock.Anything).Return(head, int64(0), test.syncStatus, nil, nil).Once()
+				mockClient.On("ValidateNetwork", mock.Anything, (*types.BlockIdentifier)(nil)).Return(test.networkErr).Once()
+			}
+
+			switch {
+			case test.indexerErr != nil:
+				mockIndexer.On("Head", mock.Anything).Return(nil, test.indexerErr).Once()
+			case test.indexed != nil:
+				mockIndexer.On("Head", mock.Anything).Return(&types.Block{
+					BlockIdentifier: &types.BlockIdentifier{Index: *test.indexed},
+				}, nil).Once()
+			}
+
+			code, response := probe(t, s, ReadyPath)
+			assert.Equal(t, test.code, code)
+			assert.Equal(t, test.checks, response.Checks)
+			if test.code == http.StatusOK {
+				assert.Equal(t, statusOK, response.Status)
+			} else {
+				assert.Equal(t, statusUnavailable, response.Status)
+			}
+
+			mockClient.AssertExpectations(t)
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
@@ -16,10 +16,12 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
 )
 
 // MempoolAPIService implements the server.MempoolAPIServicer interface.
@@ -44,13 +46,13 @@ func (s *MempoolAPIService) Mempool(
 	ctx context.Context,
 	_ *types.NetworkRequest,
 ) (*types.MempoolResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
+	if !s.config.Mode.IsOnline() {
 		return nil, ErrUnavailableOffline
 	}
 
 	response, err := s.client.GetMempool(ctx)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, gethErr(err)
 	}
 
 	return response, nil
@@ -61,5 +63,19 @@ func (s *MempoolAPIService) MempoolTransaction(
 	ctx context.Context,
 	request *types.MempoolTransactionRequest,
 ) (*types.MempoolTransactionResponse, *types.Error) {
-	return nil, wrapErr(ErrUnimplemented, nil)
+	if !s.config.Mode.IsOnline() {
+		return nil, ErrUnavailableOffline
+	}
+
+	transaction, err := s.client.MempoolTransaction(ctx, request.TransactionIdentifier.Hash)
+	if errors.Is(err, geth.NotFound) {
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	}
+	if err != nil {
+		return nil, gethErr(err)
+	}
+
+	return &types.MempoolTransactionResponse{
+		Transaction: transaction,
+	}, nil
 }
@@ -22,6 +22,7 @@ import (
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 	"github.com/coinbase/rosetta-sdk-go/types"
 
+	geth "github.com/ethereum/go-ethereum"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,8 +41,8 @@ func TestMempoolService_Offline(t *testing.T) {
 
 	memTransaction, err := servicer.MempoolTransaction(ctx, nil)
 	assert.Nil(t, memTransaction)
-	assert.Equal(t, ErrUnimplemented.Code, err.Code)
-	assert.Equal(t, ErrUnimplemented.Message, err.Message)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+	assert.Equal(t, ErrUnavailableOffline.Message, err.Message)
 
 	mockClient.AssertExpectations(t)
 }
@@ -74,5 +75,38 @@ func TestMempoolService_Online(t *testing.T) {
 		assert.Equal(t, mempool, actualMempool)
 	})
 
+	t.Run("mempool transaction", func(t *testing.T) {
+		transaction := &types.Transaction{
+			TransactionIdentifier: mempool.TransactionIdentifiers[0],
+		}
+		mockClient.
+			On("MempoolTransaction", ctx, transaction.TransactionIdentifier.Hash).
+			Return(transaction, nil).
+			Once()
+
+		actualTransaction, err := servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+			TransactionIdentifier: transaction.TransactionIdentifier,
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &types.MempoolTransactionResponse{
+			Transaction: transaction,
+		}, actualTransaction)
+	})
+
+	t.Run("mempool transaction not found", func(t *testing.T) {
+		mockClient.
+			On("MempoolTransaction", ctx, "0x1234").
+			Return(nil, geth.NotFound).
+			Once()
+
+		actualTransaction, err := servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: "0x1234"},
+		})
+
+		assert.Nil(t, actualTransaction)
+		assert.Equal(t, ErrTransactionNotFound.Code, err.Code)
+	})
+
 	mockClient.AssertExpectations(t)
 }
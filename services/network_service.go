@@ -16,6 +16,7 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
@@ -23,20 +24,28 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
+// tokensKey is the key of the /network/options version
+// metadata listing the currencies of TOKEN_WHITELIST.
+const tokensKey = "tokens"
+
 // NetworkAPIService implements the server.NetworkAPIServicer interface.
 type NetworkAPIService struct {
-	config *configuration.Configuration
-	client Client
+	config  *configuration.Configuration
+	client  Client
+	indexer Indexer
 }
 
 // NewNetworkAPIService creates a new instance of a NetworkAPIService.
+// In INDEXER mode, the current block is the last block of indexer.
 func NewNetworkAPIService(
 	cfg *configuration.Configuration,
 	client Client,
+	indexer Indexer,
 ) *NetworkAPIService {
 	return &NetworkAPIService{
-		config: cfg,
-		client: client,
+		config:  cfg,
+		client:  client,
+		indexer: indexer,
 	}
 }
 
@@ -55,18 +64,30 @@ func (s *NetworkAPIService) NetworkOptions(
 	ctx context.Context,
 	request *types.NetworkRequest,
 ) (*types.NetworkOptionsResponse, *types.Error) {
+	version := &types.Version{
+		NodeVersion:       ethereum.NodeVersion,
+		RosettaVersion:    types.RosettaAPIVersion,
+		MiddlewareVersion: types.String(configuration.MiddlewareVersion),
+	}
+
+	// Allow has no currencies, so the tokens whose
+	// transfers are included in blocks are listed
+	// in the version metadata.
+	if len(s.config.Tokens) > 0 {
+		version.Metadata = map[string]interface{}{
+			tokensKey: s.config.Tokens,
+		}
+	}
+
 	return &types.NetworkOptionsResponse{
-		Version: &types.Version{
-			NodeVersion:       ethereum.NodeVersion,
-			RosettaVersion:    types.RosettaAPIVersion,
-			MiddlewareVersion: types.String(configuration.MiddlewareVersion),
-		},
+		Version: version,
 		Allow: &types.Allow{
 			Errors:                  Errors,
 			OperationTypes:          ethereum.OperationTypes,
 			OperationStatuses:       ethereum.OperationStatuses,
 			HistoricalBalanceLookup: ethereum.HistoricalBalanceSupported,
-			CallMethods:             ethereum.CallMethods,
+			CallMethods:             callMethods(s.config),
+			BalanceExemptions:       s.config.BalanceExemptions,
 		},
 	}, nil
 }
@@ -76,20 +97,75 @@ func (s *NetworkAPIService) NetworkStatus(
 	ctx context.Context,
 	request *types.NetworkRequest,
 ) (*types.NetworkStatusResponse, *types.Error) {
-	if s.config.Mode != configuration.Online {
-		return nil, ErrUnavailableOffline
+	if !s.config.Mode.IsOnline() {
+		return nil, wrapErr(
+			ErrUnavailableOffline,
+			errors.New("the sync status of geth is unknown in offline mode"),
+		)
 	}
 
 	currentBlock, currentTime, syncStatus, peers, err := s.client.Status(ctx)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, gethErr(err)
+	}
+
+	if s.config.Mode == configuration.Indexer {
+		head, err := s.indexer.Head(ctx)
+		if err != nil {
+			return nil, indexerErr(err)
+		}
+
+		// The index holds the full history, so
+		// there is no oldest block.
+		return &types.NetworkStatusResponse{
+			CurrentBlockIdentifier: head.BlockIdentifier,
+			CurrentBlockTimestamp:  head.Timestamp,
+			GenesisBlockIdentifier: s.config.GenesisBlockIdentifier,
+			SyncStatus:             indexSyncStatus(head.BlockIdentifier, currentBlock, syncStatus),
+			Peers:                  peers,
+		}, nil
+	}
+
+	var oldestBlock *types.BlockIdentifier
+	if s.config.PruneDepth > 0 {
+		oldestIndex := oldestBlockIndex(s.config, currentBlock.Index)
+		oldestBlock, err = s.client.BlockIdentifier(ctx, &oldestIndex)
+		if err != nil {
+			return nil, gethErr(err)
+		}
 	}
 
 	return &types.NetworkStatusResponse{
 		CurrentBlockIdentifier: currentBlock,
 		CurrentBlockTimestamp:  currentTime,
 		GenesisBlockIdentifier: s.config.GenesisBlockIdentifier,
+		OldestBlockIdentifier:  oldestBlock,
 		SyncStatus:             syncStatus,
 		Peers:                  peers,
 	}, nil
 }
+
+// indexSyncStatus returns the sync status of an index whose last
+// block is head, given the current block and sync status of geth.
+// The index is synced once geth is and head is its current block.
+func indexSyncStatus(
+	head *types.BlockIdentifier,
+	current *types.BlockIdentifier,
+	gethStatus *types.SyncStatus,
+) *types.SyncStatus {
+	synced := gethStatus != nil && gethStatus.Synced != nil && *gethStatus.Synced
+	stage := ethereum.SyncStageIndexing
+	switch {
+	case !synced && gethStatus != nil && gethStatus.Stage != nil:
+		stage = *gethStatus.Stage
+	case synced && head.Index >= current.Index:
+		stage = ethereum.SyncStageSynced
+	}
+
+	return &types.SyncStatus{
+		CurrentIndex: types.Int64(head.Index),
+		TargetIndex:  types.Int64(current.Index),
+		Stage:        &stage,
+		Synced:       types.Bool(stage == ethereum.SyncStageSynced),
+	}
+}
@@ -16,12 +16,15 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/coinbase/rosetta-ethereum/configuration"
 	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
 	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
 
+	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -55,7 +58,7 @@ func TestNetworkEndpoints_Offline(t *testing.T) {
 		Network: networkIdentifier,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewNetworkAPIService(cfg, mockClient)
+	servicer := NewNetworkAPIService(cfg, mockClient, nil)
 	ctx := context.Background()
 
 	networkList, err := servicer.NetworkList(ctx, nil)
@@ -68,6 +71,9 @@ func TestNetworkEndpoints_Offline(t *testing.T) {
 	assert.Nil(t, networkStatus)
 	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
 	assert.Equal(t, ErrUnavailableOffline.Message, err.Message)
+	assert.Equal(t, map[string]interface{}{
+		"context": "the sync status of geth is unknown in offline mode",
+	}, err.Details)
 
 	networkOptions, err := servicer.NetworkOptions(ctx, nil)
 	assert.Nil(t, err)
@@ -83,7 +89,7 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 		GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewNetworkAPIService(cfg, mockClient)
+	servicer := NewNetworkAPIService(cfg, mockClient, nil)
 	ctx := context.Background()
 
 	networkList, err := servicer.NetworkList(ctx, nil)
@@ -135,3 +141,234 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestNetworkStatus_Pruned(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:                   configuration.Online,
+		Network:                networkIdentifier,
+		GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+		PruneDepth:             4,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewNetworkAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	currentBlock := &types.BlockIdentifier{
+		Index: 10,
+		Hash:  "block 10",
+	}
+	oldestBlock := &types.BlockIdentifier{
+		Index: 7,
+		Hash:  "block 7",
+	}
+
+	mockClient.On(
+		"Status",
+		ctx,
+	).Return(
+		currentBlock,
+		int64(1000000000000),
+		(*types.SyncStatus)(nil),
+		[]*types.Peer{},
+		nil,
+	).Once()
+	mockClient.On(
+		"BlockIdentifier",
+		ctx,
+		types.Int64(7),
+	).Return(
+		oldestBlock,
+		nil,
+	).Once()
+
+	networkStatus, err := servicer.NetworkStatus(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.NetworkStatusResponse{
+		GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+		CurrentBlockIdentifier: currentBlock,
+		CurrentBlockTimestamp:  1000000000000,
+		OldestBlockIdentifier:  oldestBlock,
+		Peers:                  []*types.Peer{},
+	}, networkStatus)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestNetworkOptions_Tokens(t *testing.T) {
+	usdc := &types.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{
+			"contract_address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		},
+	}
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+		Tokens:  []*types.Currency{usdc},
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewNetworkAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	networkOptions, err := servicer.NetworkOptions(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"tokens": []*types.Currency{usdc},
+	}, networkOptions.Version.Metadata)
+	assert.Equal(t, defaultNetworkOptions.Allow, networkOptions.Allow)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestNetworkOptions_BalanceExemptions(t *testing.T) {
+	exemptions := []*types.BalanceExemption{
+		{
+			Currency: &types.Currency{
+				Symbol:   "stETH",
+				Decimals: 18,
+				Metadata: map[string]interface{}{
+					"contract_address": "0xae7ab96520DE3A18E5e111B5EaAb095312D7fE84",
+				},
+			},
+			ExemptionType: types.BalanceGreaterOrEqual,
+		},
+	}
+	cfg := &configuration.Configuration{
+		Mode:              configuration.Online,
+		Network:           networkIdentifier,
+		BalanceExemptions: exemptions,
+	}
+	mockClient := &mocks.Client{}
+	servicer := NewNetworkAPIService(cfg, mockClient, nil)
+	ctx := context.Background()
+
+	networkOptions, err := servicer.NetworkOptions(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, exemptions, networkOptions.Allow.BalanceExemptions)
+	assert.NoError(t, asserter.NetworkOptionsResponse(networkOptions))
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestNetworkStatus_Indexer(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:                   configuration.Indexer,
+		Network:                networkIdentifier,
+		GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+		PruneDepth:             4,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewNetworkAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	currentBlock := &types.BlockIdentifier{
+		Index: 10,
+		Hash:  "block 10",
+	}
+	peers := []*types.Peer{
+		{
+			PeerID: "77.93.223.9:8333",
+		},
+	}
+	stage := ethereum.SyncStageSynced
+	mockClient.On(
+		"Status",
+		ctx,
+	).Return(
+		currentBlock,
+		int64(1000000000000),
+		&types.SyncStatus{
+			CurrentIndex: types.Int64(10),
+			TargetIndex:  types.Int64(10),
+			Stage:        &stage,
+			Synced:       types.Bool(true),
+		},
+		peers,
+		nil,
+	)
+
+	// The current block is the last indexed
+	// block, and no block is pruned.
+	head := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: 8,
+			Hash:  "block 8",
+		},
+		Timestamp: 999999999000,
+	}
+	mockIndexer.On("Head", ctx).Return(head, nil).Once()
+
+	indexing := ethereum.SyncStageIndexing
+	networkStatus, err := servicer.NetworkStatus(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.NetworkStatusResponse{
+		GenesisBlockIdentifier: ethereum.MainnetGenesisBlockIdentifier,
+		CurrentBlockIdentifier: head.BlockIdentifier,
+		CurrentBlockTimestamp:  head.Timestamp,
+		Peers:                  peers,
+		SyncStatus: &types.SyncStatus{
+			CurrentIndex: types.Int64(8),
+			TargetIndex:  types.Int64(10),
+			Stage:        &indexing,
+			Synced:       types.Bool(false),
+		},
+	}, networkStatus)
+
+	mockIndexer.On("Head", ctx).Return(nil, fmt.Errorf("%w: the index is empty", indexer.ErrBlockNotFound)).Once()
+	networkStatus, err = servicer.NetworkStatus(ctx, nil)
+	assert.Nil(t, networkStatus)
+	assert.Equal(t, ErrBlockNotIndexed.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestIndexSyncStatus(t *testing.T) {
+	current := &types.BlockIdentifier{Index: 10, Hash: "block 10"}
+	blockSync := ethereum.SyncStageBlocks
+	synced := ethereum.SyncStageSynced
+
+	tests := map[string]struct {
+		head       int64
+		gethStatus *types.SyncStatus
+
+		expectedStage string
+	}{
+		"geth syncing": {
+			head: 10,
+			gethStatus: &types.SyncStatus{
+				Stage:  &blockSync,
+				Synced: types.Bool(false),
+			},
+			expectedStage: ethereum.SyncStageBlocks,
+		},
+		"indexing": {
+			head: 9,
+			gethStatus: &types.SyncStatus{
+				Stage:  &synced,
+				Synced: types.Bool(true),
+			},
+			expectedStage: ethereum.SyncStageIndexing,
+		},
+		"synced": {
+			head: 10,
+			gethStatus: &types.SyncStatus{
+				Stage:  &synced,
+				Synced: types.Bool(true),
+			},
+			expectedStage: ethereum.SyncStageSynced,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			status := indexSyncStatus(&types.BlockIdentifier{Index: test.head}, current, test.gethStatus)
+			assert.Equal(t, test.head, *status.CurrentIndex)
+			assert.Equal(t, current.Index, *status.TargetIndex)
+			assert.Equal(t, test.expectedStage, *status.Stage)
+			assert.Equal(t, test.expectedStage == ethereum.SyncStageSynced, *status.Synced)
+		})
+	}
+}
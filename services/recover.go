@@ -0,0 +1,85 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"go.uber.org/zap"
+)
+
+// headerRecorder records whether a
+// http.Handler wrote the response header.
+type headerRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (r *headerRecorder) WriteHeader(status int) {
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *headerRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Recover recovers the panics of next (i.e. on a transaction
+// of an unexpected shape introduced by a fork), so that they
+// fail the request that caused them instead of the process.
+// The panic and its stack are logged, and counted in metrics,
+// and the request fails with 500 and ErrInternal (unless the
+// response was already started).
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &headerRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			// ErrAbortHandler aborts the response on purpose.
+			if p == http.ErrAbortHandler { // nolint:errorlint
+				panic(p)
+			}
+
+			metrics.Panic(r.URL.Path)
+			logger.FromContext(r.Context()).Error(
+				"panic serving request",
+				zap.String("path", r.URL.Path),
+				zap.String("panic", fmt.Sprint(p)),
+				zap.ByteString("stack", debug.Stack()),
+			)
+
+			if recorder.wroteHeader {
+				// The response cannot be replaced, so the
+				// connection is aborted to signal the failure.
+				panic(http.ErrAbortHandler)
+			}
+
+			server.EncodeJSONResponse(ErrInternal, http.StatusInternalServerError, w) // nolint:errcheck
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
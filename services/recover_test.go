@@ -0,0 +1,61 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	serve := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		Recover(handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/block", nil))
+		return recorder
+	}
+
+	recorder := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve(func(w http.ResponseWriter, r *http.Request) {
+		panic("unexpected transaction type")
+	})
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	var rosettaErr types.Error
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rosettaErr))
+	assert.Equal(t, *ErrInternal, rosettaErr)
+
+	// Started responses are aborted.
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic("unexpected")
+		})
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+	})
+}
@@ -0,0 +1,271 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-ethereum/cache"
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/logger"
+	"github.com/coinbase/rosetta-ethereum/metrics"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultResponseCacheDepth is the number of blocks
+	// below the tip a block must be to be cached when
+	// RESPONSE_CACHE_DEPTH is not set.
+	defaultResponseCacheDepth = 64
+
+	// responsesCacheName is the cache label
+	// of cached responses in metrics.
+	responsesCacheName = "responses"
+)
+
+// responseCache caches the /block and /block/transaction
+// responses of blocks deep enough below the tip to be
+// considered immutable, by block hash. A nil responseCache
+// caches nothing.
+type responseCache struct {
+	store  cache.Store
+	prefix string
+	depth  int64
+}
+
+// newResponseCache returns a responseCache
+// in store, or nil if store is nil.
+func newResponseCache(cfg *configuration.Configuration, store cache.Store) *responseCache {
+	if store == nil {
+		return nil
+	}
+
+	// Keys are prefixed by the network, so
+	// that networks can share a Redis.
+	prefix := "rosetta:"
+	if cfg.Network != nil {
+		prefix += cfg.Network.Network + ":"
+	}
+
+	depth := int64(cfg.ResponseCacheDepth)
+	if depth == 0 {
+		depth = defaultResponseCacheDepth
+	}
+
+	return &responseCache{store: store, prefix: prefix, depth: depth}
+}
+
+func (c *responseCache) blockKey(hash string) string {
+	return c.prefix + "block:" + strings.ToLower(hash)
+}
+
+func (c *responseCache) indexKey(index int64) string {
+	return c.prefix + "index:" + strconv.FormatInt(index, 10)
+}
+
+func (c *responseCache) transactionKey(block string, transaction string) string {
+	return fmt.Sprintf(
+		"%stransaction:%s:%s",
+		c.prefix,
+		strings.ToLower(block),
+		strings.ToLower(transaction),
+	)
+}
+
+// get returns the value of key, if any. Errors of the
+// store are logged and considered misses, so that
+// requests are served by geth when the store fails.
+func (c *responseCache) get(ctx context.Context, key string) ([]byte, bool) {
+	value, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.warn(ctx, "unable to get cached response", key, err)
+		return nil, false
+	}
+
+	return value, ok
+}
+
+// getJSON decodes the value of key into v, returning
+// whether it was found.
+func (c *responseCache) getJSON(ctx context.Context, key string, v interface{}) bool {
+	value, ok := c.get(ctx, key)
+	if !ok {
+		return false
+	}
+
+	if err := json.Unmarshal(value, v); err != nil {
+		c.warn(ctx, "unable to decode cached response", key, err)
+		return false
+	}
+
+	return true
+}
+
+// set sets the value of key, logging errors of the store.
+func (c *responseCache) set(ctx context.Context, key string, value []byte) {
+	if err := c.store.Set(ctx, key, value); err != nil {
+		c.warn(ctx, "unable to cache response", key, err)
+	}
+}
+
+// setJSON sets the value of key to v encoded in JSON.
+func (c *responseCache) setJSON(ctx context.Context, key string, v interface{}) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		c.warn(ctx, "unable to encode response", key, err)
+		return
+	}
+
+	c.set(ctx, key, value)
+}
+
+func (c *responseCache) warn(ctx context.Context, msg string, key string, err error) {
+	// Redis is not used for a while after failing,
+	// which was already logged.
+	if errors.Is(err, cache.ErrRedisUnavailable) {
+		return
+	}
+
+	logger.FromContext(ctx).Warn(msg, zap.String("key", key), zap.Error(err))
+}
+
+// immutable returns whether the block at index
+// is at least depth blocks below the tip.
+func (c *responseCache) immutable(ctx context.Context, client Client, index int64) bool {
+	tip, err := client.BlockIdentifier(ctx, nil)
+	if err != nil {
+		return false
+	}
+
+	return index <= tip.Index-c.depth
+}
+
+// block returns the cached /block response of
+// block, if any. Requests of the current block
+// are never cached.
+func (c *responseCache) block(
+	ctx context.Context,
+	block *types.PartialBlockIdentifier,
+) *types.BlockResponse {
+	if c == nil || block == nil || (block.Hash == nil && block.Index == nil) {
+		return nil
+	}
+
+	// Blocks requested by index are found from
+	// the hash of the canonical block at index.
+	var hash string
+	if block.Hash != nil {
+		hash = *block.Hash
+	} else {
+		value, ok := c.get(ctx, c.indexKey(*block.Index))
+		if !ok {
+			metrics.CacheLookup(responsesCacheName, false)
+			return nil
+		}
+		hash = string(value)
+	}
+
+	var response types.BlockResponse
+	ok := c.getJSON(ctx, c.blockKey(hash), &response) && response.Block != nil
+	metrics.CacheLookup(responsesCacheName, ok)
+	if !ok {
+		return nil
+	}
+
+	return &response
+}
+
+// addBlock caches response, the /block response
+// of block, if it is immutable.
+func (c *responseCache) addBlock(
+	ctx context.Context,
+	client Client,
+	block *types.PartialBlockIdentifier,
+	response *types.BlockResponse,
+) {
+	if c == nil || block == nil || (block.Hash == nil && block.Index == nil) {
+		return
+	}
+
+	identifier := response.Block.BlockIdentifier
+	if !c.immutable(ctx, client, identifier.Index) {
+		return
+	}
+
+	c.setJSON(ctx, c.blockKey(identifier.Hash), response)
+
+	// Blocks requested by hash may not be canonical,
+	// so only blocks requested by index are indexed.
+	if block.Hash == nil {
+		c.set(ctx, c.indexKey(identifier.Index), []byte(identifier.Hash))
+	}
+}
+
+// transaction returns the cached /block/transaction
+// response of transaction in block, if any.
+func (c *responseCache) transaction(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	transaction *types.TransactionIdentifier,
+) *types.BlockTransactionResponse {
+	if c == nil || block == nil || len(block.Hash) == 0 {
+		return nil
+	}
+
+	var response types.BlockTransactionResponse
+	ok := c.getJSON(ctx, c.transactionKey(block.Hash, transaction.Hash), &response) &&
+		response.Transaction != nil
+	metrics.CacheLookup(responsesCacheName, ok)
+	if !ok {
+		return nil
+	}
+
+	return &response
+}
+
+// addTransaction caches response, the /block/transaction
+// response of transaction in block, if block is immutable.
+func (c *responseCache) addTransaction(
+	ctx context.Context,
+	client Client,
+	block *types.BlockIdentifier,
+	transaction *types.TransactionIdentifier,
+	response *types.BlockTransactionResponse,
+) {
+	if c == nil || block == nil || len(block.Hash) == 0 {
+		return
+	}
+
+	// The index of the request is not checked against
+	// its hash by geth, so the block is only cached if
+	// it is the canonical block at index.
+	canonical, err := client.BlockIdentifier(ctx, &block.Index)
+	if err != nil || !strings.EqualFold(canonical.Hash, block.Hash) {
+		return
+	}
+
+	if !c.immutable(ctx, client, block.Index) {
+		return
+	}
+
+	c.setJSON(ctx, c.transactionKey(block.Hash, transaction.Hash), response)
+}
@@ -17,6 +17,7 @@ package services
 import (
 	"net/http"
 
+	"github.com/coinbase/rosetta-ethereum/cache"
 	"github.com/coinbase/rosetta-ethereum/configuration"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
@@ -24,25 +25,29 @@ import (
 )
 
 // NewBlockchainRouter creates a Mux http.Handler from a collection
-// of server controllers.
+// of server controllers. Panics while serving requests are
+// recovered (see Recover). The responses of immutable
+// blocks are cached in responses, if not nil.
 func NewBlockchainRouter(
 	config *configuration.Configuration,
 	client Client,
+	indexer Indexer,
+	responses cache.Store,
 	asserter *asserter.Asserter,
 ) http.Handler {
-	networkAPIService := NewNetworkAPIService(config, client)
+	networkAPIService := NewNetworkAPIService(config, client, indexer)
 	networkAPIController := server.NewNetworkAPIController(
 		networkAPIService,
 		asserter,
 	)
 
-	accountAPIService := NewAccountAPIService(config, client)
+	accountAPIService := NewAccountAPIService(config, client, indexer)
 	accountAPIController := server.NewAccountAPIController(
 		accountAPIService,
 		asserter,
 	)
 
-	blockAPIService := NewBlockAPIService(config, client)
+	blockAPIService := NewBlockAPIService(config, client, indexer, responses)
 	blockAPIController := server.NewBlockAPIController(
 		blockAPIService,
 		asserter,
@@ -60,18 +65,45 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
-	callAPIService := NewCallAPIService(config, client)
+	callAPIService := NewCallAPIService(config, client, indexer)
 	callAPIController := server.NewCallAPIController(
 		callAPIService,
 		asserter,
 	)
 
-	return server.NewRouter(
+	eventsAPIService := NewEventsAPIService(config, indexer)
+	eventsAPIController := server.NewEventsAPIController(
+		eventsAPIService,
+		asserter,
+	)
+
+	searchAPIService := NewSearchAPIService(config, indexer)
+	searchAPIController := server.NewSearchAPIController(
+		searchAPIService,
+		asserter,
+	)
+
+	// The health endpoints are not part of the Rosetta
+	// API, so they are served next to its router.
+	healthService := NewHealthService(config, client, indexer)
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, healthService.Health)
+	mux.HandleFunc(ReadyPath, healthService.Ready)
+	router := server.NewRouter(
 		networkAPIController,
 		accountAPIController,
 		blockAPIController,
 		constructionAPIController,
 		mempoolAPIController,
 		callAPIController,
+		eventsAPIController,
+		searchAPIController,
 	)
+	mux.Handle("/", router)
+
+	// Blocks can be large, so their
+	// responses are streamed.
+	mux.Handle(blockPath, streamBlocks(blockAPIService, asserter, router))
+
+	return Recover(mux)
 }
@@ -0,0 +1,96 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// defaultSearchLimit is the number of transactions
+	// returned when no limit is requested.
+	defaultSearchLimit = 100
+
+	// maxSearchLimit is the largest number of
+	// transactions returned in one response.
+	maxSearchLimit = 1000
+)
+
+// SearchAPIService implements the server.SearchAPIServicer interface.
+type SearchAPIService struct {
+	config  *configuration.Configuration
+	indexer Indexer
+}
+
+// NewSearchAPIService creates a new instance of a SearchAPIService.
+// indexer is nil when neither BLOCK_EVENTS nor TRANSACTION_INDEX is
+// enabled.
+func NewSearchAPIService(
+	config *configuration.Configuration,
+	indexer Indexer,
+) server.SearchAPIServicer {
+	return &SearchAPIService{
+		config:  config,
+		indexer: indexer,
+	}
+}
+
+// SearchTransactions implements the /search/transactions endpoint.
+func (s *SearchAPIService) SearchTransactions(
+	ctx context.Context,
+	request *types.SearchTransactionsRequest,
+) (*types.SearchTransactionsResponse, *types.Error) {
+	if !s.config.Mode.IsOnline() {
+		return nil, ErrUnavailableOffline
+	}
+
+	if s.indexer == nil || !s.config.TransactionIndex {
+		return nil, wrapErr(ErrUnimplemented, errors.New("TRANSACTION_INDEX is not enabled"))
+	}
+
+	var offset int64
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+
+	limit := int64(defaultSearchLimit)
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	totalCount, transactions, err := s.indexer.SearchTransactions(ctx, request, offset, limit)
+	if err != nil {
+		return nil, wrapErr(ErrIndexer, err)
+	}
+
+	response := &types.SearchTransactionsResponse{
+		Transactions: transactions,
+		TotalCount:   totalCount,
+	}
+
+	if next := offset + int64(len(transactions)); next < totalCount {
+		response.NextOffset = &next
+	}
+
+	return response, nil
+}
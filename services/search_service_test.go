@@ -0,0 +1,163 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchService_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:             configuration.Offline,
+		TransactionIndex: true,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewSearchAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestSearchService_Disabled(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:        configuration.Online,
+		BlockEvents: true,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewSearchAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.SearchTransactions(ctx, &types.SearchTransactionsRequest{})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnimplemented.Code, err.Code)
+	assert.Equal(t, "TRANSACTION_INDEX is not enabled", err.Details["context"])
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestSearchService_Online(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:             configuration.Online,
+		TransactionIndex: true,
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewSearchAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	address := "0x5aCB42b3cfCD734a57AFF800C57F4F5d3A11a9f1"
+	transactions := []*types.BlockTransaction{
+		{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  "0x3c5a2a1dd3c1a6bd2caa0e3dd2e2d0e8fa6e34db4f4d3e8a2bf0e0a6e0c51d31",
+				Index: 10,
+			},
+			Transaction: &types.Transaction{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: "0xb89dbf00e5c1a6ec89a4d42879969e8ea843a6814a783fb5c2bbf712ea1ef071",
+				},
+				Operations: []*types.Operation{},
+			},
+		},
+	}
+
+	t.Run("next offset", func(t *testing.T) {
+		offset := int64(4)
+		limit := int64(1)
+		request := &types.SearchTransactionsRequest{
+			Address: &address,
+			Offset:  &offset,
+			Limit:   &limit,
+		}
+		mockIndexer.On("SearchTransactions", ctx, request, offset, limit).Return(int64(6), transactions, nil).Once()
+
+		resp, err := servicer.SearchTransactions(ctx, request)
+		assert.Nil(t, err)
+
+		nextOffset := int64(5)
+		assert.Equal(t, &types.SearchTransactionsResponse{
+			Transactions: transactions,
+			TotalCount:   6,
+			NextOffset:   &nextOffset,
+		}, resp)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		request := &types.SearchTransactionsRequest{
+			Address: &address,
+		}
+		mockIndexer.On(
+			"SearchTransactions",
+			ctx,
+			request,
+			int64(0),
+			int64(defaultSearchLimit),
+		).Return(int64(1), transactions, nil).Once()
+
+		resp, err := servicer.SearchTransactions(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, &types.SearchTransactionsResponse{
+			Transactions: transactions,
+			TotalCount:   1,
+		}, resp)
+	})
+
+	t.Run("max limit", func(t *testing.T) {
+		limit := int64(maxSearchLimit + 1)
+		request := &types.SearchTransactionsRequest{
+			Limit: &limit,
+		}
+		mockIndexer.On(
+			"SearchTransactions",
+			ctx,
+			request,
+			int64(0),
+			int64(maxSearchLimit),
+		).Return(int64(1), transactions, nil).Once()
+
+		resp, err := servicer.SearchTransactions(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, transactions, resp.Transactions)
+	})
+
+	t.Run("indexer error", func(t *testing.T) {
+		request := &types.SearchTransactionsRequest{}
+		mockIndexer.On(
+			"SearchTransactions",
+			ctx,
+			request,
+			int64(0),
+			int64(defaultSearchLimit),
+		).Return(int64(-1), nil, errors.New("closed")).Once()
+
+		resp, err := servicer.SearchTransactions(ctx, request)
+		assert.Nil(t, resp)
+		assert.Equal(t, ErrIndexer.Code, err.Code)
+		assert.Equal(t, "closed", err.Details["context"])
+	})
+
+	mockIndexer.AssertExpectations(t)
+}
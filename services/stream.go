@@ -0,0 +1,233 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// blockPath is the path of the /block
+	// endpoint, whose responses are streamed.
+	blockPath = "/block"
+
+	// streamBufferSize is the size of the buffer
+	// of streamed responses.
+	streamBufferSize = 32 * 1024 // nolint:gomnd
+
+	// maxPooledBufferSize is the capacity above which
+	// buffers are not pooled, so that a single large
+	// transaction does not pin memory.
+	maxPooledBufferSize = 1 << 20 // nolint:gomnd
+)
+
+var (
+	// writerPool pools the buffered
+	// writers of streamed responses.
+	writerPool = sync.Pool{
+		New: func() interface{} {
+			return bufio.NewWriterSize(nil, streamBufferSize)
+		},
+	}
+
+	// bufferPool pools the buffers that
+	// transactions are encoded into.
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+)
+
+// streamBlocks serves /block like the BlockAPIController of the
+// SDK does, except that responses are written with
+// writeBlockResponse instead of being marshaled whole, to cut the
+// memory used to serve blocks with many operations.
+func streamBlocks(
+	servicer server.BlockAPIServicer,
+	asserter *asserter.Asserter,
+	next http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Other methods are rejected by next.
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		request := &types.BlockRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			server.EncodeJSONResponse( // nolint:errcheck
+				&types.Error{Message: err.Error()},
+				http.StatusInternalServerError,
+				w,
+			)
+			return
+		}
+
+		if err := asserter.BlockRequest(request); err != nil {
+			server.EncodeJSONResponse( // nolint:errcheck
+				&types.Error{Message: err.Error()},
+				http.StatusInternalServerError,
+				w,
+			)
+			return
+		}
+
+		response, rosettaErr := servicer.Block(r.Context(), request)
+		if rosettaErr != nil {
+			server.EncodeJSONResponse(rosettaErr, http.StatusInternalServerError, w) // nolint:errcheck
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+
+		writer := writerPool.Get().(*bufio.Writer)
+		writer.Reset(w)
+		defer func() {
+			writer.Reset(nil)
+			writerPool.Put(writer)
+		}()
+
+		err := writeBlockResponse(writer, response)
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			logger.FromContext(r.Context()).Warn("unable to write block response", zap.Error(err))
+
+			// The status was already sent, so the connection
+			// is aborted for the response not to look complete.
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// encode writes the JSON encoding of v (as json.Marshal
+// does) to w, using a pooled buffer.
+func encode(w io.Writer, v interface{}) error {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buffer.Cap() <= maxPooledBufferSize {
+			buffer.Reset()
+			bufferPool.Put(buffer)
+		}
+	}()
+
+	if err := json.NewEncoder(buffer).Encode(v); err != nil {
+		return err
+	}
+
+	// Encode terminates values with a newline,
+	// which json.Marshal does not.
+	buffer.Truncate(buffer.Len() - 1)
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// transactionsPlaceholder is the encoding of the transactions
+// of a block without transactions. The block identifiers and the
+// timestamp precede the transactions, so its first occurrence
+// in the encoding of a response is that of the block.
+var transactionsPlaceholder = []byte(`"transactions":null`)
+
+// writeBlockResponse writes the JSON encoding of response, with
+// a trailing newline (as server.EncodeJSONResponse does), to w.
+// The response is encoded as is, except for the transactions of
+// its block, which are encoded one at a time in place of those of
+// the response encoded without them, so that the encoding of the
+// whole response is never held in memory.
+func writeBlockResponse(w io.Writer, response *types.BlockResponse) error {
+	if response.Block == nil || len(response.Block.Transactions) == 0 {
+		return json.NewEncoder(w).Encode(response)
+	}
+
+	block := *response.Block
+	block.Transactions = nil
+	withoutTransactions := *response
+	withoutTransactions.Block = &block
+
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buffer.Cap() <= maxPooledBufferSize {
+			buffer.Reset()
+			bufferPool.Put(buffer)
+		}
+	}()
+
+	if err := json.NewEncoder(buffer).Encode(&withoutTransactions); err != nil {
+		return err
+	}
+
+	encoded := buffer.Bytes()
+	i := bytes.Index(encoded, transactionsPlaceholder)
+	if i < 0 {
+		return errors.New("unable to find the transactions of the block")
+	}
+	split := i + len(`"transactions":`)
+
+	writer := &errWriter{w: w}
+	writer.write(encoded[:split])
+	writer.encodeTransactions(response.Block.Transactions)
+	writer.write(encoded[split+len("null"):])
+
+	return writer.err
+}
+
+// errWriter writes to w until a write fails,
+// recording the error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) write(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *errWriter) encode(v interface{}) {
+	if e.err == nil {
+		e.err = encode(e.w, v)
+	}
+}
+
+// encodeTransactions encodes transactions
+// one at a time, as a JSON array.
+func (e *errWriter) encodeTransactions(transactions []*types.Transaction) {
+	e.write([]byte(`[`))
+	for i, transaction := range transactions {
+		if i > 0 {
+			e.write([]byte(`,`))
+		}
+		e.encode(transaction)
+	}
+	e.write([]byte(`]`))
+}
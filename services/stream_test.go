@@ -0,0 +1,210 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	mocks "github.com/coinbase/rosetta-ethereum/mocks/services"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testBlock() *types.Block {
+	return &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 100, Hash: "0x64"},
+		ParentBlockIdentifier: &types.BlockIdentifier{Index: 99, Hash: "0x63"},
+		Timestamp:             1000,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "0xa"},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                ethereum.CallOpType,
+						Status:              types.String(ethereum.SuccessStatus),
+						Account:             &types.AccountIdentifier{Address: "0x1"},
+						Amount: &types.Amount{
+							Value:    "-1",
+							Currency: ethereum.Currency,
+						},
+					},
+				},
+				Metadata: map[string]interface{}{"trace": "<&>"},
+			},
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "0xb"},
+				Operations:            []*types.Operation{},
+			},
+		},
+	}
+}
+
+func TestWriteBlockResponse(t *testing.T) {
+	withMetadata := testBlock()
+	withMetadata.Metadata = map[string]interface{}{"trace_incomplete": true}
+	withTransactionsMetadata := testBlock()
+	withTransactionsMetadata.Metadata = map[string]interface{}{"transactions": nil}
+	withoutTransactions := testBlock()
+	withoutTransactions.Transactions = nil
+	withoutTransactions.Metadata = map[string]interface{}{"trace_incomplete": true}
+	withEmptyTransactions := testBlock()
+	withEmptyTransactions.Transactions = []*types.Transaction{}
+	otherTransactions := []*types.TransactionIdentifier{{Hash: "0xc"}}
+
+	tests := map[string]*types.BlockResponse{
+		"block":                     {Block: testBlock()},
+		"metadata":                  {Block: withMetadata},
+		"transactions metadata":     {Block: withTransactionsMetadata},
+		"no transactions":           {Block: withoutTransactions},
+		"empty transactions":        {Block: withEmptyTransactions},
+		"other transactions":        {Block: testBlock(), OtherTransactions: otherTransactions},
+		"no transactions and other": {Block: withoutTransactions, OtherTransactions: otherTransactions},
+		"only other transactions":   {OtherTransactions: otherTransactions},
+		"empty":                     {},
+	}
+
+	for name, response := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The response is encoded as by server.EncodeJSONResponse,
+			// which is json.Marshal followed by a newline.
+			expected, err := json.Marshal(response)
+			assert.NoError(t, err)
+
+			var written bytes.Buffer
+			assert.NoError(t, writeBlockResponse(&written, response))
+			assert.Equal(t, string(expected)+"\n", written.String())
+		})
+	}
+}
+
+// failingWriter is a http.ResponseWriter
+// whose writes fail.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestStreamBlocks(t *testing.T) {
+	// The network is local as other tests
+	// reassign networkIdentifier.
+	network := &types.NetworkIdentifier{
+		Blockchain: ethereum.Blockchain,
+		Network:    ethereum.MainnetNetwork,
+	}
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: network,
+	}
+	a, err := asserter.NewServer(
+		ethereum.OperationTypes,
+		ethereum.HistoricalBalanceSupported,
+		[]*types.NetworkIdentifier{network},
+		ethereum.CallMethods,
+		ethereum.IncludeMempoolCoins,
+		"",
+	)
+	require.NoError(t, err)
+
+	mockClient := &mocks.Client{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	handler := streamBlocks(NewBlockAPIService(cfg, mockClient, nil, nil), a, next)
+
+	serve := func(w http.ResponseWriter, method string, body string) {
+		handler.ServeHTTP(w, httptest.NewRequest(method, blockPath, strings.NewReader(body)))
+	}
+	blockRequest := func(network *types.NetworkIdentifier, index int64) string {
+		request, err := json.Marshal(&types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: types.Int64(index)},
+		})
+		require.NoError(t, err)
+		return string(request)
+	}
+
+	block := testBlock()
+	request := blockRequest(network, 100)
+	mockClient.On(
+		"Block",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(100)},
+	).Return(block, nil).Twice()
+
+	recorder := httptest.NewRecorder()
+	serve(recorder, http.MethodPost, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=UTF-8", recorder.Header().Get("Content-Type"))
+
+	var response types.BlockResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.NotNil(t, response.Block)
+	assert.Equal(t, block.BlockIdentifier, response.Block.BlockIdentifier)
+	assert.Len(t, response.Block.Transactions, 2)
+
+	// Requests are asserted.
+	recorder = httptest.NewRecorder()
+	serve(recorder, http.MethodPost, blockRequest(&types.NetworkIdentifier{
+		Blockchain: ethereum.Blockchain,
+		Network:    ethereum.GoerliNetwork,
+	}, 100))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	serve(recorder, http.MethodPost, `{`)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	// Errors of the service are returned.
+	mockClient.On(
+		"Block",
+		mock.Anything,
+		&types.PartialBlockIdentifier{Index: types.Int64(101)},
+	).Return(nil, ethereum.ErrBlockOrphaned).Once()
+	recorder = httptest.NewRecorder()
+	serve(recorder, http.MethodPost, blockRequest(network, 101))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	var rosettaErr types.Error
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rosettaErr))
+	assert.Equal(t, ErrBlockOrphaned.Code, rosettaErr.Code)
+
+	// Responses that cannot be written are aborted.
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(&failingWriter{httptest.NewRecorder()}, http.MethodPost, request)
+	})
+
+	// Other methods are served by next.
+	recorder = httptest.NewRecorder()
+	serve(recorder, http.MethodGet, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	mockClient.AssertExpectations(t)
+}
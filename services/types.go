@@ -19,7 +19,11 @@ import (
 	"encoding/json"
 	"math/big"
 
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	geth "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
@@ -41,6 +45,16 @@ type Client interface {
 		*types.PartialBlockIdentifier,
 	) (*types.Block, error)
 
+	BlockHeader(
+		context.Context,
+		*types.PartialBlockIdentifier,
+	) (*types.Block, error)
+
+	BlockIdentifier(
+		context.Context,
+		*int64,
+	) (*types.BlockIdentifier, error)
+
 	Transaction(
 		context.Context,
 		*types.BlockIdentifier,
@@ -51,40 +65,305 @@ type Client interface {
 		context.Context,
 		*types.AccountIdentifier,
 		*types.PartialBlockIdentifier,
+		[]*types.Currency,
 	) (*types.AccountBalanceResponse, error)
 
 	PendingNonceAt(context.Context, common.Address) (uint64, error)
 
 	SuggestGasPrice(ctx context.Context) (*big.Int, error)
 
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+
+	FeeHistory(
+		ctx context.Context,
+		blockCount uint64,
+		rewardPercentiles []float64,
+	) (*ethereum.FeeHistory, error)
+
 	SendTransaction(ctx context.Context, tx *ethTypes.Transaction) error
 
+	CallContract(
+		ctx context.Context,
+		msg geth.CallMsg,
+		blockNumber *big.Int,
+	) ([]byte, error)
+
+	EstimateGas(ctx context.Context, msg geth.CallMsg) (uint64, error)
+
+	TransactionByHash(
+		ctx context.Context,
+		hash common.Hash,
+	) (*ethTypes.Transaction, bool, error)
+
 	GetMempool(ctx context.Context) (*types.MempoolResponse, error)
 
+	MempoolTransaction(ctx context.Context, hash string) (*types.Transaction, error)
+
 	Call(
 		ctx context.Context,
 		request *types.CallRequest,
 	) (*types.CallResponse, error)
+
+	ValidateNetwork(
+		ctx context.Context,
+		genesis *types.BlockIdentifier,
+	) error
+
+	BreakerState() ethereum.BreakerState
+}
+
+// Indexer is used by the services to serve
+// data recorded in the local index.
+type Indexer interface {
+	Head(ctx context.Context) (*types.Block, error)
+
+	Block(
+		ctx context.Context,
+		identifier *types.PartialBlockIdentifier,
+	) (*types.Block, error)
+
+	Transaction(
+		ctx context.Context,
+		block *types.BlockIdentifier,
+		identifier *types.TransactionIdentifier,
+	) (*types.Transaction, error)
+
+	BlockEvents(
+		ctx context.Context,
+		offset int64,
+		limit int64,
+	) (int64, []*types.BlockEvent, error)
+
+	SearchTransactions(
+		ctx context.Context,
+		request *types.SearchTransactionsRequest,
+		offset int64,
+		limit int64,
+	) (int64, []*types.BlockTransaction, error)
+
+	Balance(
+		ctx context.Context,
+		account *types.AccountIdentifier,
+		block *types.PartialBlockIdentifier,
+		currencies []*types.Currency,
+	) (*types.AccountBalanceResponse, error)
+
+	BalanceHistory(
+		ctx context.Context,
+		account *types.AccountIdentifier,
+		currency *types.Currency,
+		offset int64,
+		limit int64,
+	) (int64, []*indexer.BalanceChange, error)
+
+	TokenTransfers(
+		ctx context.Context,
+		filter *indexer.TokenTransferFilter,
+		offset int64,
+		limit int64,
+	) (int64, []*indexer.TokenTransfer, error)
 }
 
+// options is the output of /construction/preprocess. Each
+// field other than From and ReplaceTxHash is an explicit
+// override of the value /construction/metadata would
+// otherwise fetch from geth. ReplaceTxHash is the hash of
+// the pending transaction to replace, if any. If Simulate is
+// set, /construction/metadata runs the transaction to To with
+// Value and CallData before returning its metadata.
 type options struct {
-	From string `json:"from"`
+	From                   string   `json:"from"`
+	SuggestedFeeMultiplier *float64 `json:"suggested_fee_multiplier,omitempty"`
+	GasPrice               *big.Int `json:"gas_price,omitempty"`
+	GasTipCap              *big.Int `json:"max_priority_fee_per_gas,omitempty"`
+	GasFeeCap              *big.Int `json:"max_fee_per_gas,omitempty"`
+	GasLimit               *uint64  `json:"gas_limit,omitempty"`
+	Nonce                  *uint64  `json:"nonce,omitempty"`
+	Data                   []byte   `json:"data,omitempty"`
+	ReplaceTxHash          string   `json:"replace_transaction_hash,omitempty"`
+	Simulate               bool     `json:"simulate,omitempty"`
+	To                     string   `json:"to,omitempty"`
+	Value                  *big.Int `json:"value,omitempty"`
+	CallData               []byte   `json:"call_data,omitempty"`
+}
+
+type optionsWire struct {
+	From                   string   `json:"from"`
+	SuggestedFeeMultiplier *float64 `json:"suggested_fee_multiplier,omitempty"`
+	GasPrice               string   `json:"gas_price,omitempty"`
+	GasTipCap              string   `json:"max_priority_fee_per_gas,omitempty"`
+	GasFeeCap              string   `json:"max_fee_per_gas,omitempty"`
+	GasLimit               string   `json:"gas_limit,omitempty"`
+	Nonce                  string   `json:"nonce,omitempty"`
+	Data                   string   `json:"data,omitempty"`
+	ReplaceTxHash          string   `json:"replace_transaction_hash,omitempty"`
+	Simulate               bool     `json:"simulate,omitempty"`
+	To                     string   `json:"to,omitempty"`
+	Value                  string   `json:"value,omitempty"`
+	CallData               string   `json:"call_data,omitempty"`
+}
+
+func (o *options) MarshalJSON() ([]byte, error) {
+	ow := &optionsWire{
+		From:                   o.From,
+		SuggestedFeeMultiplier: o.SuggestedFeeMultiplier,
+		ReplaceTxHash:          o.ReplaceTxHash,
+		Simulate:               o.Simulate,
+		To:                     o.To,
+	}
+	if o.GasPrice != nil {
+		ow.GasPrice = hexutil.EncodeBig(o.GasPrice)
+	}
+	if o.GasTipCap != nil {
+		ow.GasTipCap = hexutil.EncodeBig(o.GasTipCap)
+	}
+	if o.GasFeeCap != nil {
+		ow.GasFeeCap = hexutil.EncodeBig(o.GasFeeCap)
+	}
+	if o.GasLimit != nil {
+		ow.GasLimit = hexutil.EncodeUint64(*o.GasLimit)
+	}
+	if o.Nonce != nil {
+		ow.Nonce = hexutil.EncodeUint64(*o.Nonce)
+	}
+	if len(o.Data) > 0 {
+		ow.Data = hexutil.Encode(o.Data)
+	}
+	if o.Value != nil {
+		ow.Value = hexutil.EncodeBig(o.Value)
+	}
+	if len(o.CallData) > 0 {
+		ow.CallData = hexutil.Encode(o.CallData)
+	}
+
+	return json.Marshal(ow)
 }
 
+func (o *options) UnmarshalJSON(data []byte) error {
+	var ow optionsWire
+	if err := json.Unmarshal(data, &ow); err != nil {
+		return err
+	}
+
+	o.From = ow.From
+	o.SuggestedFeeMultiplier = ow.SuggestedFeeMultiplier
+	o.ReplaceTxHash = ow.ReplaceTxHash
+	o.Simulate = ow.Simulate
+	o.To = ow.To
+
+	if len(ow.GasPrice) > 0 {
+		gasPrice, err := hexutil.DecodeBig(ow.GasPrice)
+		if err != nil {
+			return err
+		}
+		o.GasPrice = gasPrice
+	}
+
+	if len(ow.GasTipCap) > 0 {
+		gasTipCap, err := hexutil.DecodeBig(ow.GasTipCap)
+		if err != nil {
+			return err
+		}
+		o.GasTipCap = gasTipCap
+	}
+
+	if len(ow.GasFeeCap) > 0 {
+		gasFeeCap, err := hexutil.DecodeBig(ow.GasFeeCap)
+		if err != nil {
+			return err
+		}
+		o.GasFeeCap = gasFeeCap
+	}
+
+	if len(ow.GasLimit) > 0 {
+		gasLimit, err := hexutil.DecodeUint64(ow.GasLimit)
+		if err != nil {
+			return err
+		}
+		o.GasLimit = &gasLimit
+	}
+
+	if len(ow.Nonce) > 0 {
+		nonce, err := hexutil.DecodeUint64(ow.Nonce)
+		if err != nil {
+			return err
+		}
+		o.Nonce = &nonce
+	}
+
+	if len(ow.Data) > 0 {
+		data, err := hexutil.Decode(ow.Data)
+		if err != nil {
+			return err
+		}
+		o.Data = data
+	}
+
+	if len(ow.Value) > 0 {
+		value, err := hexutil.DecodeBig(ow.Value)
+		if err != nil {
+			return err
+		}
+		o.Value = value
+	}
+
+	if len(ow.CallData) > 0 {
+		callData, err := hexutil.Decode(ow.CallData)
+		if err != nil {
+			return err
+		}
+		o.CallData = callData
+	}
+
+	return nil
+}
+
+// metadata is the output of /construction/metadata. Legacy
+// transactions have a GasPrice, dynamic fee (EIP-1559)
+// transactions have a GasTipCap and GasFeeCap instead. GasLimit
+// and ChainID are empty in metadata created by older versions.
+// Data is the calldata of contract calls.
 type metadata struct {
-	Nonce    uint64   `json:"nonce"`
-	GasPrice *big.Int `json:"gas_price"`
+	Nonce     uint64   `json:"nonce"`
+	GasPrice  *big.Int `json:"gas_price"`
+	GasTipCap *big.Int `json:"max_priority_fee_per_gas"`
+	GasFeeCap *big.Int `json:"max_fee_per_gas"`
+	GasLimit  uint64   `json:"gas_limit"`
+	ChainID   *big.Int `json:"chain_id"`
+	Data      []byte   `json:"data"`
 }
 
 type metadataWire struct {
-	Nonce    string `json:"nonce"`
-	GasPrice string `json:"gas_price"`
+	Nonce     string `json:"nonce"`
+	GasPrice  string `json:"gas_price,omitempty"`
+	GasTipCap string `json:"max_priority_fee_per_gas,omitempty"`
+	GasFeeCap string `json:"max_fee_per_gas,omitempty"`
+	GasLimit  string `json:"gas_limit,omitempty"`
+	ChainID   string `json:"chain_id,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 func (m *metadata) MarshalJSON() ([]byte, error) {
 	mw := &metadataWire{
-		Nonce:    hexutil.Uint64(m.Nonce).String(),
-		GasPrice: hexutil.EncodeBig(m.GasPrice),
+		Nonce: hexutil.Uint64(m.Nonce).String(),
+	}
+	if m.GasPrice != nil {
+		mw.GasPrice = hexutil.EncodeBig(m.GasPrice)
+	}
+	if m.GasTipCap != nil {
+		mw.GasTipCap = hexutil.EncodeBig(m.GasTipCap)
+	}
+	if m.GasFeeCap != nil {
+		mw.GasFeeCap = hexutil.EncodeBig(m.GasFeeCap)
+	}
+	if m.GasLimit > 0 {
+		mw.GasLimit = hexutil.EncodeUint64(m.GasLimit)
+	}
+	if m.ChainID != nil {
+		mw.ChainID = hexutil.EncodeBig(m.ChainID)
+	}
+	if len(m.Data) > 0 {
+		mw.Data = hexutil.Encode(m.Data)
 	}
 
 	return json.Marshal(mw)
@@ -101,58 +380,132 @@ func (m *metadata) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	gasPrice, err := hexutil.DecodeBig(mw.GasPrice)
-	if err != nil {
-		return err
+	if len(mw.GasPrice) > 0 {
+		gasPrice, err := hexutil.DecodeBig(mw.GasPrice)
+		if err != nil {
+			return err
+		}
+		m.GasPrice = gasPrice
+	}
+
+	if len(mw.GasTipCap) > 0 {
+		gasTipCap, err := hexutil.DecodeBig(mw.GasTipCap)
+		if err != nil {
+			return err
+		}
+		m.GasTipCap = gasTipCap
+	}
+
+	if len(mw.GasFeeCap) > 0 {
+		gasFeeCap, err := hexutil.DecodeBig(mw.GasFeeCap)
+		if err != nil {
+			return err
+		}
+		m.GasFeeCap = gasFeeCap
+	}
+
+	if len(mw.GasLimit) > 0 {
+		gasLimit, err := hexutil.DecodeUint64(mw.GasLimit)
+		if err != nil {
+			return err
+		}
+		m.GasLimit = gasLimit
+	}
+
+	if len(mw.ChainID) > 0 {
+		chainID, err := hexutil.DecodeBig(mw.ChainID)
+		if err != nil {
+			return err
+		}
+		m.ChainID = chainID
+	}
+
+	if len(mw.Data) > 0 {
+		data, err := hexutil.Decode(mw.Data)
+		if err != nil {
+			return err
+		}
+		m.Data = data
 	}
 
-	m.GasPrice = gasPrice
 	m.Nonce = nonce
 	return nil
 }
 
 type parseMetadata struct {
-	Nonce    uint64   `json:"nonce"`
-	GasPrice *big.Int `json:"gas_price"`
-	ChainID  *big.Int `json:"chain_id"`
+	Nonce        uint64   `json:"nonce"`
+	GasPrice     *big.Int `json:"gas_price"`
+	GasTipCap    *big.Int `json:"max_priority_fee_per_gas"`
+	GasFeeCap    *big.Int `json:"max_fee_per_gas"`
+	ChainID      *big.Int `json:"chain_id"`
+	Data         []byte   `json:"data"`
+	SuggestedFee *big.Int `json:"suggested_fee"`
 }
 
 type parseMetadataWire struct {
-	Nonce    string `json:"nonce"`
-	GasPrice string `json:"gas_price"`
-	ChainID  string `json:"chain_id"`
+	Nonce        string `json:"nonce"`
+	GasPrice     string `json:"gas_price,omitempty"`
+	GasTipCap    string `json:"max_priority_fee_per_gas,omitempty"`
+	GasFeeCap    string `json:"max_fee_per_gas,omitempty"`
+	ChainID      string `json:"chain_id"`
+	Data         string `json:"data,omitempty"`
+	SuggestedFee string `json:"suggested_fee,omitempty"`
 }
 
 func (p *parseMetadata) MarshalJSON() ([]byte, error) {
 	pmw := &parseMetadataWire{
-		Nonce:    hexutil.Uint64(p.Nonce).String(),
-		GasPrice: hexutil.EncodeBig(p.GasPrice),
-		ChainID:  hexutil.EncodeBig(p.ChainID),
+		Nonce:   hexutil.Uint64(p.Nonce).String(),
+		ChainID: hexutil.EncodeBig(p.ChainID),
+	}
+	if p.GasPrice != nil {
+		pmw.GasPrice = hexutil.EncodeBig(p.GasPrice)
+	}
+	if p.GasTipCap != nil {
+		pmw.GasTipCap = hexutil.EncodeBig(p.GasTipCap)
+	}
+	if p.GasFeeCap != nil {
+		pmw.GasFeeCap = hexutil.EncodeBig(p.GasFeeCap)
+	}
+	if len(p.Data) > 0 {
+		pmw.Data = hexutil.Encode(p.Data)
+	}
+	if p.SuggestedFee != nil {
+		pmw.SuggestedFee = hexutil.EncodeBig(p.SuggestedFee)
 	}
 
 	return json.Marshal(pmw)
 }
 
+// transaction is an unsigned transaction. It is a dynamic
+// fee (EIP-1559) transaction if GasFeeCap is set and a
+// legacy transaction otherwise. Currency is the ERC-20
+// token transferred by Data, if any.
 type transaction struct {
-	From     string   `json:"from"`
-	To       string   `json:"to"`
-	Value    *big.Int `json:"value"`
-	Data     []byte   `json:"data"`
-	Nonce    uint64   `json:"nonce"`
-	GasPrice *big.Int `json:"gas_price"`
-	GasLimit uint64   `json:"gas"`
-	ChainID  *big.Int `json:"chain_id"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Value     *big.Int        `json:"value"`
+	Data      []byte          `json:"data"`
+	Nonce     uint64          `json:"nonce"`
+	GasPrice  *big.Int        `json:"gas_price"`
+	GasTipCap *big.Int        `json:"max_priority_fee_per_gas"`
+	GasFeeCap *big.Int        `json:"max_fee_per_gas"`
+	GasLimit  uint64          `json:"gas"`
+	ChainID   *big.Int        `json:"chain_id"`
+	Currency  *types.Currency `json:"currency,omitempty"`
 }
 
 type transactionWire struct {
-	From     string `json:"from"`
-	To       string `json:"to"`
-	Value    string `json:"value"`
-	Data     string `json:"data"`
-	Nonce    string `json:"nonce"`
-	GasPrice string `json:"gas_price"`
-	GasLimit string `json:"gas"`
-	ChainID  string `json:"chain_id"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Value     string          `json:"value"`
+	Data      string          `json:"data"`
+	Nonce     string          `json:"nonce"`
+	GasPrice  string          `json:"gas_price,omitempty"`
+	GasTipCap string          `json:"max_priority_fee_per_gas,omitempty"`
+	GasFeeCap string          `json:"max_fee_per_gas,omitempty"`
+	GasLimit  string          `json:"gas"`
+	ChainID   string          `json:"chain_id"`
+	Currency  *types.Currency `json:"currency,omitempty"`
 }
 
 func (t *transaction) MarshalJSON() ([]byte, error) {
@@ -162,9 +515,18 @@ func (t *transaction) MarshalJSON() ([]byte, error) {
 		Value:    hexutil.EncodeBig(t.Value),
 		Data:     hexutil.Encode(t.Data),
 		Nonce:    hexutil.EncodeUint64(t.Nonce),
-		GasPrice: hexutil.EncodeBig(t.GasPrice),
 		GasLimit: hexutil.EncodeUint64(t.GasLimit),
 		ChainID:  hexutil.EncodeBig(t.ChainID),
+		Currency: t.Currency,
+	}
+	if t.GasPrice != nil {
+		tw.GasPrice = hexutil.EncodeBig(t.GasPrice)
+	}
+	if t.GasTipCap != nil {
+		tw.GasTipCap = hexutil.EncodeBig(t.GasTipCap)
+	}
+	if t.GasFeeCap != nil {
+		tw.GasFeeCap = hexutil.EncodeBig(t.GasFeeCap)
 	}
 
 	return json.Marshal(tw)
@@ -191,9 +553,28 @@ func (t *transaction) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	gasPrice, err := hexutil.DecodeBig(tw.GasPrice)
-	if err != nil {
-		return err
+	if len(tw.GasPrice) > 0 {
+		gasPrice, err := hexutil.DecodeBig(tw.GasPrice)
+		if err != nil {
+			return err
+		}
+		t.GasPrice = gasPrice
+	}
+
+	if len(tw.GasTipCap) > 0 {
+		gasTipCap, err := hexutil.DecodeBig(tw.GasTipCap)
+		if err != nil {
+			return err
+		}
+		t.GasTipCap = gasTipCap
+	}
+
+	if len(tw.GasFeeCap) > 0 {
+		gasFeeCap, err := hexutil.DecodeBig(tw.GasFeeCap)
+		if err != nil {
+			return err
+		}
+		t.GasFeeCap = gasFeeCap
 	}
 
 	gasLimit, err := hexutil.DecodeUint64(tw.GasLimit)
@@ -211,9 +592,26 @@ func (t *transaction) UnmarshalJSON(data []byte) error {
 	t.Value = value
 	t.Data = twData
 	t.Nonce = nonce
-	t.GasPrice = gasPrice
 	t.GasLimit = gasLimit
 	t.ChainID = chainID
-	t.GasPrice = gasPrice
+	t.Currency = tw.Currency
 	return nil
 }
+
+// unsignedTransactionVersion is the version of the
+// unsignedTransaction envelope created by
+// /construction/payloads. Unsigned transactions
+// without a version are bare transactions (created
+// by releases before the envelope was introduced).
+const unsignedTransactionVersion = 1
+
+// unsignedTransaction is the envelope of the unsigned
+// transactions created by /construction/payloads. Type is
+// the EIP-2718 type the transaction is signed as, and it and
+// ChainID must match Transaction.
+type unsignedTransaction struct {
+	Version     int            `json:"version"`
+	Type        hexutil.Uint64 `json:"type"`
+	ChainID     *hexutil.Big   `json:"chain_id"`
+	Transaction *transaction   `json:"transaction"`
+}
@@ -15,7 +15,16 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-ethereum/configuration"
+	"github.com/coinbase/rosetta-ethereum/ethereum"
+	"github.com/coinbase/rosetta-ethereum/indexer"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
 // *JSONMap functions are needed because `types.MarshalMap/types.UnmarshalMap`
@@ -45,3 +54,70 @@ func unmarshalJSONMap(m map[string]interface{}, i interface{}) error {
 
 	return json.Unmarshal(b, i)
 }
+
+// oldestBlockIndex returns the index of the oldest block
+// that still has state when currentIndex is the latest block
+// and the node keeps the state of the last PruneDepth blocks.
+func oldestBlockIndex(cfg *configuration.Configuration, currentIndex int64) int64 {
+	oldest := currentIndex - cfg.PruneDepth + 1
+	if oldest < ethereum.GenesisBlockIndex {
+		return ethereum.GenesisBlockIndex
+	}
+
+	return oldest
+}
+
+// checkPruned returns ErrBlockPruned if pruning is enabled and
+// block is older than the oldest block that still has state.
+// Blocks requested only by hash are resolved to their index
+// first, so they are checked like blocks requested by index.
+func checkPruned(
+	ctx context.Context,
+	cfg *configuration.Configuration,
+	client Client,
+	block *types.PartialBlockIdentifier,
+) *types.Error {
+	if cfg.PruneDepth == 0 || block == nil || (block.Index == nil && block.Hash == nil) {
+		return nil
+	}
+
+	index := block.Index
+	if index == nil {
+		header, err := client.BlockHeader(ctx, &types.PartialBlockIdentifier{Hash: block.Hash})
+		if err != nil {
+			return gethErr(err)
+		}
+
+		index = &header.BlockIdentifier.Index
+	}
+
+	current, err := client.BlockIdentifier(ctx, nil)
+	if err != nil {
+		return gethErr(err)
+	}
+
+	oldest := oldestBlockIndex(cfg, current.Index)
+	if *index < oldest {
+		return wrapErr(
+			ErrBlockPruned,
+			fmt.Errorf("block %d is older than the oldest block %d", *index, oldest),
+		)
+	}
+
+	return nil
+}
+
+// indexerErr returns the types.Error
+// of an error of the local index.
+func indexerErr(err error) *types.Error {
+	switch {
+	case errors.Is(err, indexer.ErrBlockNotFound):
+		return wrapErr(ErrBlockNotIndexed, err)
+	case errors.Is(err, indexer.ErrTransactionNotFound):
+		return wrapErr(ErrTransactionNotFound, err)
+	case errors.Is(err, indexer.ErrTransactionsPruned):
+		return wrapErr(ErrBlockPruned, err)
+	default:
+		return wrapErr(ErrIndexer, err)
+	}
+}
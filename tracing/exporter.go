@@ -0,0 +1,291 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coinbase/rosetta-ethereum/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// ServiceName is the service.name
+	// resource attribute of spans.
+	ServiceName = "rosetta-ethereum"
+
+	// tracesPath is the path of the OTLP/HTTP
+	// traces endpoint of a collector.
+	tracesPath = "/v1/traces"
+
+	// exportInterval is how often ended
+	// spans are sent to the collector.
+	exportInterval = 5 * time.Second
+
+	// exportBatchSize is the largest number of
+	// spans sent to the collector at once.
+	exportBatchSize = 512
+
+	// exportQueueSize is the largest number of ended
+	// spans waiting to be sent. Spans ending while
+	// the queue is full are dropped.
+	exportQueueSize = 4096
+
+	// exportTimeout is the longest a
+	// batch of spans may take to send.
+	exportTimeout = 10 * time.Second
+
+	// statusError is the OTLP status code of failed spans.
+	statusError = 2
+)
+
+// Exporter sends ended spans, in batches, to the OTLP/HTTP
+// traces endpoint of a collector (JSON-encoded).
+type Exporter struct {
+	// dropped is accessed atomically, so it is
+	// first to be 64-bit aligned.
+	dropped uint64
+
+	url    string
+	client *http.Client
+	spans  chan *Span
+}
+
+// NewExporter returns an Exporter sending spans to the
+// collector at endpoint (i.e. `http://localhost:4318`).
+func NewExporter(endpoint string) *Exporter {
+	return &Exporter{
+		url:    strings.TrimSuffix(endpoint, "/") + tracesPath,
+		client: &http.Client{Timeout: exportTimeout},
+		spans:  make(chan *Span, exportQueueSize),
+	}
+}
+
+// export queues s to be sent, or drops
+// it if the queue is full.
+func (e *Exporter) export(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// Run sends the queued spans every exportInterval (or once
+// exportBatchSize are queued) until ctx is done, when the
+// remaining spans are sent. Spans that cannot be sent are
+// logged and dropped.
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, exportBatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		// The remaining spans are still sent once ctx is done.
+		sendCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		if err := e.send(sendCtx, batch); err != nil {
+			logger.FromContext(ctx).Warn(
+				"unable to export spans",
+				zap.Int("spans", len(batch)),
+				zap.Error(err),
+			)
+		}
+		batch = batch[:0]
+
+		if dropped := atomic.SwapUint64(&e.dropped, 0); dropped > 0 {
+			logger.FromContext(ctx).Warn("dropped spans", zap.Uint64("spans", dropped))
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+					if len(batch) == exportBatchSize {
+						send()
+					}
+				default:
+					send()
+					return nil
+				}
+			}
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) == exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		}
+	}
+}
+
+// send posts spans to the collector.
+func (e *Exporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()            // nolint:errcheck
+	io.Copy(ioutil.Discard, resp.Body) // nolint:errcheck
+
+	if resp.StatusCode/100 != 2 { // nolint:gomnd
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding of spans.
+type (
+	otlpRequest struct {
+		ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   *otlpResource     `json:"resource"`
+		ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []*otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope *otlpScope  `json:"scope"`
+		Spans []*otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string           `json:"traceId"`
+		SpanID            string           `json:"spanId"`
+		ParentSpanID      string           `json:"parentSpanId,omitempty"`
+		Name              string           `json:"name"`
+		Kind              int              `json:"kind"`
+		StartTimeUnixNano string           `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string           `json:"endTimeUnixNano"`
+		Attributes        []*otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus      `json:"status,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// encodeAttribute returns the OTLP encoding of
+// the attribute key with value.
+func encodeAttribute(key string, value interface{}) *otlpAttribute {
+	var encoded map[string]interface{}
+	switch v := value.(type) {
+	case bool:
+		encoded = map[string]interface{}{"boolValue": v}
+	case int:
+		encoded = map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		encoded = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		encoded = map[string]interface{}{"doubleValue": v}
+	default:
+		encoded = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+
+	return &otlpAttribute{Key: key, Value: encoded}
+}
+
+// encodeSpans returns the OTLP/HTTP request exporting spans.
+func encodeSpans(spans []*Span) *otlpRequest {
+	encoded := make([]*otlpSpan, len(spans))
+	for i, s := range spans {
+		s.mu.Lock()
+		span := &otlpSpan{
+			TraceID:           fmt.Sprintf("%x", s.traceID),
+			SpanID:            fmt.Sprintf("%x", s.spanID),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != (spanID{}) {
+			span.ParentSpanID = fmt.Sprintf("%x", s.parentID)
+		}
+		for key, value := range s.attributes {
+			span.Attributes = append(span.Attributes, encodeAttribute(key, value))
+		}
+		if len(s.err) > 0 {
+			span.Status = &otlpStatus{Code: statusError, Message: s.err}
+		}
+		s.mu.Unlock()
+
+		encoded[i] = span
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []*otlpResourceSpans{
+			{
+				Resource: &otlpResource{
+					Attributes: []*otlpAttribute{encodeAttribute("service.name", ServiceName)},
+				},
+				ScopeSpans: []*otlpScopeSpans{
+					{
+						Scope: &otlpScope{Name: ServiceName},
+						Spans: encoded,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records OpenTelemetry spans, propagated with
+// W3C trace context, and exports them to an OTLP collector.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// traceparentHeader is the header carrying
+	// the W3C trace context of a request.
+	traceparentHeader = "traceparent"
+
+	// sampledFlag is the trace flag of sampled traces.
+	sampledFlag = 0x01
+)
+
+// Kinds of spans, as defined by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+type (
+	traceID [16]byte
+	spanID  [8]byte
+
+	contextKey struct{}
+)
+
+// exporter is the Exporter spans are sent to once they end
+// (nil while tracing is off, in which case no spans are
+// recorded).
+var exporter atomic.Value
+
+// SetExporter sends the spans that end from now on to e
+// (nil to stop recording spans).
+func SetExporter(e *Exporter) {
+	exporter.Store(&e)
+}
+
+func currentExporter() *Exporter {
+	e, ok := exporter.Load().(**Exporter)
+	if !ok {
+		return nil
+	}
+
+	return *e
+}
+
+// Span is an operation of a trace. All of its
+// methods are no-ops on a nil Span.
+type Span struct {
+	exporter *Exporter
+
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+// SetAttribute sets the attribute key of s to
+// value (a string, bool, int, int64 or float64).
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes[key] = value
+}
+
+// SetError marks s as failed with err, if it is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err.Error()
+}
+
+// End ends s and exports it.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.exporter.export(s)
+}
+
+// traceparent returns the W3C trace context of s.
+func (s *Span) traceparent() string {
+	return fmt.Sprintf("00-%x-%x-%02x", s.traceID, s.spanID, sampledFlag)
+}
+
+// FromContext returns the span carried by ctx, if any.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// Start starts a span of kind named name, child of the span
+// carried by ctx, and returns a copy of ctx carrying it. The
+// span must be ended with End. Only server spans start new
+// traces: if tracing is off, or if ctx carries no span and
+// kind is not KindServer (i.e. the indexer syncing in the
+// background), the span is nil and ctx is returned.
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+
+	parent := FromContext(ctx)
+	if parent == nil && kind != KindServer {
+		return ctx, nil
+	}
+
+	span := &Span{
+		exporter:   e,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:]) // nolint:errcheck
+	}
+	rand.Read(span.spanID[:]) // nolint:errcheck
+
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// parseTraceparent returns the trace and parent span
+// of a W3C traceparent header, if it is valid.
+func parseTraceparent(header string) (traceID, spanID, bool) {
+	var trace traceID
+	var parent spanID
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return trace, parent, false
+	}
+
+	if n, err := hex.Decode(trace[:], []byte(parts[1])); err != nil || n != len(trace) {
+		return trace, parent, false
+	}
+
+	if n, err := hex.Decode(parent[:], []byte(parts[2])); err != nil || n != len(parent) {
+		return trace, parent, false
+	}
+
+	if trace == (traceID{}) || parent == (spanID{}) {
+		return trace, parent, false
+	}
+
+	return trace, parent, true
+}
+
+// statusRecorder records the status code
+// written by a http.Handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware records a server span for each request served by
+// next, continuing the trace of its traceparent header if any.
+// The request context carries the span, so that spans started
+// while serving the request are its children, and the
+// traceparent of the span is returned in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if trace, parent, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+			ctx = context.WithValue(ctx, contextKey{}, &Span{traceID: trace, spanID: parent})
+		}
+
+		ctx, span := Start(ctx, r.URL.Path, KindServer)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		w.Header().Set(traceparentHeader, span.traceparent())
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("status %d", recorder.status))
+		}
+	})
+}
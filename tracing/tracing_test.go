@@ -0,0 +1,129 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	trace, parent, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fmt.Sprintf("%x", trace))
+	assert.Equal(t, "00f067aa0ba902b7", fmt.Sprintf("%x", parent))
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+	} {
+		_, _, ok := parseTraceparent(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	received := make(chan *otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, tracesPath, r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var request otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		received <- &request
+	}))
+	defer collector.Close()
+
+	// No spans are recorded while tracing is off.
+	_, span := Start(context.Background(), "off", KindInternal)
+	assert.Nil(t, span)
+	span.SetAttribute("ignored", true)
+	span.End()
+
+	exporter := NewExporter(collector.URL + "/")
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	// Only server spans start traces.
+	_, span = Start(context.Background(), "background", KindInternal)
+	assert.Nil(t, span)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "rpc eth_getBlockByNumber", KindClient)
+		span.SetAttribute("rpc.method", "eth_getBlockByNumber")
+		span.SetAttribute("cached", false)
+		span.SetAttribute("size", 42)
+		span.SetError(errors.New("timeout"))
+		span.End()
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/block", nil)
+	request.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	trace, server, ok := parseTraceparent(recorder.Header().Get(traceparentHeader))
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fmt.Sprintf("%x", trace))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, exporter.Run(ctx))
+
+	exported := <-received
+	assert.Len(t, exported.ResourceSpans, 1)
+	assert.Equal(t, "service.name", exported.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, ServiceName, exported.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 2)
+
+	// The client span ends first.
+	client, root := spans[0], spans[1]
+	assert.Equal(t, "rpc eth_getBlockByNumber", client.Name)
+	assert.Equal(t, KindClient, client.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", client.TraceID)
+	assert.Equal(t, fmt.Sprintf("%x", server), client.ParentSpanID)
+	assert.Equal(t, &otlpStatus{Code: statusError, Message: "timeout"}, client.Status)
+
+	attributes := map[string]map[string]interface{}{}
+	for _, attribute := range client.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	assert.Equal(t, map[string]map[string]interface{}{
+		"rpc.method": {"stringValue": "eth_getBlockByNumber"},
+		"cached":     {"boolValue": false},
+		"size":       {"intValue": "42"},
+	}, attributes)
+
+	assert.Equal(t, "/block", root.Name)
+	assert.Equal(t, KindServer, root.Kind)
+	assert.Equal(t, fmt.Sprintf("%x", server), root.SpanID)
+	assert.Equal(t, "00f067aa0ba902b7", root.ParentSpanID)
+	assert.Equal(t, statusError, root.Status.Code)
+}